@@ -0,0 +1,365 @@
+// Package archive implements a read only backend to access the
+// contents of archive files (zip, tar, tar.gz) stored on other
+// remotes.
+//
+// 7z archives aren't supported.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/hash"
+	libcache "github.com/rclone/rclone/lib/cache"
+	"github.com/rclone/rclone/lib/readers"
+)
+
+var (
+	errorReadOnly = errors.New("archive remotes are read only")
+
+	// indexCache keeps the indexes of the archives read recently
+	// so remotes for other directories in the same archive, or
+	// remotes made again after expiring from the Fs cache, don't
+	// need to read the archive again. The key includes the
+	// fingerprint of the archive so changed archives are read
+	// again.
+	indexCache = libcache.New()
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "archive",
+		Description: "Read archives",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "remote",
+			Help: `Remote containing the archive.
+
+Normally should contain a ':' and a path, e.g. "myremote:path/to/dir",
+"myremote:bucket" or maybe "myremote:" (not recommended).
+
+If this is left empty then the remote and the path to the archive
+should be given in the path, e.g. "archive:myremote:path/to/file.zip".`,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Remote string `config:"remote"`
+}
+
+// Fs represents the contents of an archive file
+type Fs struct {
+	name     string       // name of this remote
+	root     string       // the path inside the archive
+	opt      Options      // parsed options
+	features *fs.Features // optional features
+	archive  fs.Object    // the archive file
+	path     string       // the path to the archive file
+	format   *format      // the format of the archive
+	index    *index       // the contents of the archive
+}
+
+// Object describes a member of the archive
+type Object struct {
+	fs     *Fs
+	remote string
+	entry  *entry
+}
+
+// splitArchivePath splits fullPath into the path to the archive
+// and the path inside the archive by looking for the first path
+// segment with a known archive extension.
+func splitArchivePath(fullPath string) (archivePath string, format *format, inner string, err error) {
+	segments := strings.Split(fullPath, "/")
+	for i, segment := range segments {
+		format = findFormat(segment)
+		if format != nil {
+			archivePath = strings.Join(segments[:i+1], "/")
+			inner = strings.Join(segments[i+1:], "/")
+			return archivePath, format, strings.Trim(inner, "/"), nil
+		}
+	}
+	return "", nil, "", fmt.Errorf("no archive file found in %q - must end in one of %s", fullPath, formatExtensions())
+}
+
+// NewFs constructs an Fs from the path.
+//
+// The path should contain the path to an archive file, optionally
+// followed by the path of a directory or file inside the archive.
+func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, error) {
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(opt.Remote, name+":") {
+		return nil, errors.New("can't point archive remote at itself - check the value of the remote setting")
+	}
+	fullPath := root
+	if opt.Remote != "" {
+		fullPath = fspath.JoinRootPath(opt.Remote, root)
+	}
+	archivePath, format, inner, err := splitArchivePath(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the archive object
+	parent, err := cache.Get(ctx, archivePath)
+	if err != fs.ErrorIsFile {
+		if err == nil {
+			return nil, fmt.Errorf("archive %q is not a file", archivePath)
+		}
+		return nil, fmt.Errorf("failed to find archive %q: %w", archivePath, err)
+	}
+	archive, err := parent.NewObject(ctx, path.Base(archivePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archive %q: %w", archivePath, err)
+	}
+
+	f := &Fs{
+		name:    name,
+		root:    inner,
+		opt:     *opt,
+		archive: archive,
+		path:    archivePath,
+		format:  format,
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(ctx, f)
+
+	key := fs.ConfigString(parent) + "/" + archive.Remote() + "\x00" + fs.Fingerprint(ctx, archive, true)
+	value, err := indexCache.Get(key, func(key string) (interface{}, bool, error) {
+		fs.Debugf(f, "Reading %s index of %q", format.name, archivePath)
+		x, err := format.read(ctx, archive)
+		return x, err == nil, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s archive %q: %w", format.name, archivePath, err)
+	}
+	f.index = value.(*index)
+
+	// Check to see if the root points to a file in the archive
+	if e := f.index.find(f.root); e != nil && !e.isDir {
+		f.root = path.Dir(f.root)
+		if f.root == "." {
+			f.root = ""
+		}
+		return f, fs.ErrorIsFile
+	}
+	return f, nil
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("%s archive %s", f.format.name, path.Join(f.path, f.root))
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// Precision of the ModTimes in this Fs
+func (f *Fs) Precision() time.Duration {
+	return f.format.precision
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return f.format.hashes
+}
+
+// Convert a path inside the Fs to a path inside the archive
+func (f *Fs) archivePath(remote string) string {
+	return path.Join(f.root, remote)
+}
+
+// Convert a path inside the archive to a remote
+func (f *Fs) remote(archivePath string) string {
+	if f.root == "" {
+		return archivePath
+	}
+	return strings.TrimPrefix(archivePath, f.root+"/")
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	e := f.index.find(f.archivePath(remote))
+	if e == nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if e.isDir {
+		return nil, fs.ErrorIsDir
+	}
+	return &Object{
+		fs:     f,
+		remote: remote,
+		entry:  e,
+	}, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	d := f.index.find(f.archivePath(dir))
+	if d == nil || !d.isDir {
+		return nil, fs.ErrorDirNotFound
+	}
+	for _, e := range d.children {
+		remote := f.remote(e.name)
+		if e.isDir {
+			entries = append(entries, fs.NewDir(remote, e.modTime).SetItems(int64(len(e.children))))
+		} else {
+			entries = append(entries, &Object{
+				fs:     f,
+				remote: remote,
+				entry:  e,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return nil, errorReadOnly
+}
+
+// Mkdir makes the directory (container, bucket)
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	return errorReadOnly
+}
+
+// Rmdir removes the directory (container, bucket) if empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	return errorReadOnly
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Hash returns the hash of an object returning a lowercase hex string
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	if !o.fs.format.hashes.Contains(t) {
+		return "", hash.ErrUnsupported
+	}
+	return o.entry.hashes[t], nil
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	return o.entry.size
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.entry.modTime
+}
+
+// SetModTime sets the modification time of the object
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	return errorReadOnly
+}
+
+// Storable returns a boolean showing whether this object storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open an object for read
+//
+// Members which are stored uncompressed are read with range
+// requests on the archive. Compressed members are decompressed from
+// their start, discarding the data up to the offset requested.
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.entry.size)
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	if offset > o.entry.size {
+		offset = o.entry.size
+	}
+	if limit < 0 || offset+limit > o.entry.size {
+		limit = o.entry.size - offset
+	}
+	in, err = o.entry.open(ctx, o.fs.archive, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return readers.NewLimitedReadCloser(in, limit), nil
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return errorReadOnly
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	return errorReadOnly
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs     = (*Fs)(nil)
+	_ fs.Object = (*Object)(nil)
+)
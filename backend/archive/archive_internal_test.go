@@ -0,0 +1,409 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testModTime = time.Date(2022, 3, 4, 5, 6, 8, 0, time.UTC)
+
+// testFile is a file to put into the test archives
+type testFile struct {
+	name    string
+	content []byte
+}
+
+// the contents of the test archives
+//
+// big.bin is bigger than the blocks read by the readerAt
+var testFiles = []testFile{
+	{"hello.txt", []byte("hello world\n")},
+	{"empty.txt", []byte{}},
+	{"dir/big.bin", []byte(random.String(3 * blockSize / 2))},
+	{"dir/sub/deep.txt", []byte("deep\n")},
+	{"other/file.txt", []byte("other\n")},
+}
+
+func writeZip(t *testing.T, name string) {
+	out, err := os.Create(name)
+	require.NoError(t, err)
+	zw := zip.NewWriter(out)
+	_, err = zw.CreateHeader(&zip.FileHeader{Name: "dir/", Modified: testModTime})
+	require.NoError(t, err)
+	for i, file := range testFiles {
+		method := zip.Deflate
+		if i%2 == 0 {
+			method = zip.Store
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: method, Modified: testModTime})
+		require.NoError(t, err)
+		_, err = w.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, out.Close())
+}
+
+func writeTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755, ModTime: testModTime}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "hello.txt", ModTime: testModTime}))
+	for _, file := range testFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "./" + file.name, Mode: 0644, Size: int64(len(file.content)), ModTime: testModTime}))
+		_, err := tw.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
+
+func writeTarFile(t *testing.T, name string) {
+	var buf bytes.Buffer
+	writeTar(t, &buf)
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0666))
+}
+
+func writeTarGzFile(t *testing.T, name string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	writeTar(t, gz)
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0666))
+}
+
+// writeTarGzMembersFile writes a tar.gz made of many gzip members
+// like bgzip does
+func writeTarGzMembersFile(t *testing.T, name string) {
+	var tarBuf, buf bytes.Buffer
+	writeTar(t, &tarBuf)
+	data := tarBuf.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), 16*1024)
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write(data[:n])
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		data = data[n:]
+	}
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0666))
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	for _, test := range []struct {
+		in          string
+		archivePath string
+		format      string
+		inner       string
+		wantErr     bool
+	}{
+		{"remote:file.zip", "remote:file.zip", "zip", "", false},
+		{"remote:path/to/File.ZIP/dir/file.txt", "remote:path/to/File.ZIP", "zip", "dir/file.txt", false},
+		{"/tmp/backup.tar.gz/", "/tmp/backup.tar.gz", "tar.gz", "", false},
+		{"remote:a.tgz/b", "remote:a.tgz", "tar.gz", "b", false},
+		{"remote:a.tar/b.zip", "remote:a.tar", "tar", "b.zip", false},
+		{"remote:path/to/dir", "", "", "", true},
+	} {
+		archivePath, format, inner, err := splitArchivePath(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.archivePath, archivePath, test.in)
+		assert.Equal(t, test.format, format.name, test.in)
+		assert.Equal(t, test.inner, inner, test.in)
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"file.txt", "file.txt"},
+		{"./dir/file.txt", "dir/file.txt"},
+		{"/abs/file.txt", "abs/file.txt"},
+		{"dir/", "dir"},
+		{"../../etc/passwd", ""},
+		{"a/../../x", ""},
+		{"a/../x", "x"},
+		{"..", ""},
+		{"dir\\file.txt", "dir/file.txt"},
+		{"./", ""},
+	} {
+		assert.Equal(t, test.want, cleanName(test.in), test.in)
+	}
+}
+
+// list the remote recursively returning the paths and sizes
+func listAll(ctx context.Context, t *testing.T, f fs.Fs) []string {
+	var got []string
+	err := operations.ListFn(ctx, f, func(o fs.Object) {
+		got = append(got, fmt.Sprintf("%s %d", o.Remote(), o.Size()))
+	})
+	require.NoError(t, err)
+	sort.Strings(got)
+	return got
+}
+
+func testArchive(t *testing.T, name string, write func(t *testing.T, name string)) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	archivePath := filepath.ToSlash(filepath.Join(dir, name))
+	write(t, archivePath)
+
+	f, err := NewFs(ctx, "archive", archivePath, configmap.Simple{})
+	require.NoError(t, err)
+
+	// Check the listing
+	var want []string
+	for _, file := range testFiles {
+		want = append(want, fmt.Sprintf("%s %d", file.name, len(file.content)))
+	}
+	sort.Strings(want)
+	assert.Equal(t, want, listAll(ctx, t, f))
+
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Remote())
+	}
+	assert.Equal(t, []string{"dir", "empty.txt", "hello.txt", "other"}, names)
+
+	_, err = f.List(ctx, "notfound")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+	_, err = f.List(ctx, "hello.txt")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+
+	// Check reading the objects
+	for _, file := range testFiles {
+		o, err := f.NewObject(ctx, file.name)
+		require.NoError(t, err)
+		assert.True(t, o.ModTime(ctx).Equal(testModTime), file.name)
+
+		in, err := o.Open(ctx)
+		require.NoError(t, err)
+		data, err := io.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		assert.Equal(t, file.content, data, file.name)
+
+		if len(file.content) > 10 {
+			in, err = o.Open(ctx, &fs.RangeOption{Start: 5, End: 9})
+			require.NoError(t, err)
+			data, err = io.ReadAll(in)
+			require.NoError(t, err)
+			require.NoError(t, in.Close())
+			assert.Equal(t, file.content[5:10], data, file.name)
+
+			in, err = o.Open(ctx, &fs.SeekOption{Offset: 7})
+			require.NoError(t, err)
+			data, err = io.ReadAll(in)
+			require.NoError(t, err)
+			require.NoError(t, in.Close())
+			assert.Equal(t, file.content[7:], data, file.name)
+		}
+
+		if f.Hashes().Contains(hash.CRC32) {
+			sum, err := o.Hash(ctx, hash.CRC32)
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("%08x", crc32.ChecksumIEEE(file.content)), sum, file.name)
+		}
+	}
+	_, err = f.NewObject(ctx, "dir")
+	assert.Equal(t, fs.ErrorIsDir, err)
+	_, err = f.NewObject(ctx, "notfound")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+
+	// Check the remote is read only
+	assert.Equal(t, errorReadOnly, f.Mkdir(ctx, "new"))
+	o, err := f.NewObject(ctx, "hello.txt")
+	require.NoError(t, err)
+	assert.Equal(t, errorReadOnly, o.Remove(ctx))
+
+	// Check a root inside the archive
+	fSub, err := NewFs(ctx, "archive", archivePath+"/dir", configmap.Simple{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		fmt.Sprintf("big.bin %d", 3*blockSize/2),
+		"sub/deep.txt 5",
+	}, listAll(ctx, t, fSub))
+
+	// Check a root pointing to a file
+	fFile, err := NewFs(ctx, "archive", archivePath+"/dir/sub/deep.txt", configmap.Simple{})
+	assert.Equal(t, fs.ErrorIsFile, err)
+	assert.Equal(t, "dir/sub", fFile.Root())
+	_, err = fFile.NewObject(ctx, "deep.txt")
+	require.NoError(t, err)
+}
+
+func TestZip(t *testing.T) {
+	testArchive(t, "test.zip", writeZip)
+}
+
+func TestTar(t *testing.T) {
+	testArchive(t, "test.tar", writeTarFile)
+}
+
+func TestTarGz(t *testing.T) {
+	testArchive(t, "test.tar.gz", writeTarGzFile)
+}
+
+func TestTarGzMembers(t *testing.T) {
+	oldRestartInterval := restartInterval
+	restartInterval = 32 * 1024
+	defer func() {
+		restartInterval = oldRestartInterval
+	}()
+	testArchive(t, "test.tar.gz", writeTarGzMembersFile)
+
+	// Check the restart points are found
+	name := filepath.Join(t.TempDir(), "test.tar.gz")
+	writeTarGzMembersFile(t, name)
+	compressed, err := os.ReadFile(name)
+	require.NoError(t, err)
+	g, err := newGzipMembers(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	var want bytes.Buffer
+	writeTar(t, &want)
+	got, err := io.ReadAll(g)
+	require.NoError(t, err)
+	assert.Equal(t, want.Bytes(), got)
+	require.Greater(t, len(g.restarts), 2)
+	assert.Equal(t, gzipRestart{}, g.restarts[0])
+	for i, restart := range g.restarts[1:] {
+		assert.GreaterOrEqual(t, restart.uncompressed-g.restarts[i].uncompressed, restartInterval)
+		zr, err := gzip.NewReader(bytes.NewReader(compressed[restart.compressed:]))
+		require.NoError(t, err)
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, want.Bytes()[restart.uncompressed:], data)
+	}
+
+	// Check the right restart point is found for an offset
+	restarts := []gzipRestart{{}, {compressed: 10, uncompressed: 100}, {compressed: 20, uncompressed: 200}}
+	assert.Equal(t, restarts[0], findRestart(restarts, 0))
+	assert.Equal(t, restarts[0], findRestart(restarts, 99))
+	assert.Equal(t, restarts[1], findRestart(restarts, 100))
+	assert.Equal(t, restarts[2], findRestart(restarts, 1000))
+}
+
+func TestZipBadCRC(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	name := filepath.ToSlash(filepath.Join(dir, "bad.zip"))
+	content := []byte("hello world\n")
+
+	// Write the members raw with the wrong CRC
+	out, err := os.Create(name)
+	require.NoError(t, err)
+	zw := zip.NewWriter(out)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "stored.txt",
+		Method:             zip.Store,
+		Modified:           testModTime,
+		CRC32:              crc32.ChecksumIEEE(content) + 1,
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, fw.Close())
+	w, err = zw.CreateRaw(&zip.FileHeader{
+		Name:               "deflated.txt",
+		Method:             zip.Deflate,
+		Modified:           testModTime,
+		CRC32:              crc32.ChecksumIEEE(content) + 1,
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: uint64(len(content)),
+	})
+	require.NoError(t, err)
+	_, err = w.Write(compressed.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, out.Close())
+
+	f, err := NewFs(ctx, "archive", name, configmap.Simple{})
+	require.NoError(t, err)
+	for _, remote := range []string{"stored.txt", "deflated.txt"} {
+		o, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		in, err := o.Open(ctx)
+		require.NoError(t, err)
+		_, err = io.ReadAll(in)
+		assert.ErrorIs(t, err, zip.ErrChecksum, remote)
+		require.NoError(t, in.Close())
+
+		// Partial reads can't be checked
+		in, err = o.Open(ctx, &fs.RangeOption{Start: 0, End: 4})
+		require.NoError(t, err)
+		data, err := io.ReadAll(in)
+		require.NoError(t, err, remote)
+		assert.Equal(t, content[:5], data, remote)
+		require.NoError(t, in.Close())
+	}
+}
+
+func TestReaderAtContext(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "test.tar")
+	writeTarFile(t, name)
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+	o, err := f.NewObject(ctx, "test.tar")
+	require.NoError(t, err)
+
+	// Reads need a context
+	r := newReaderAt(o)
+	buf := make([]byte, 10)
+	_, err = r.ReadAt(buf, 0)
+	assert.ErrorContains(t, err, "without a context")
+
+	err = r.withContext(ctx, func() error {
+		_, err := r.ReadAt(buf, 0)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestNotArchive(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	_, err := NewFs(ctx, "archive", filepath.ToSlash(dir), configmap.Simple{})
+	assert.ErrorContains(t, err, "no archive file found")
+
+	_, err = NewFs(ctx, "archive", filepath.ToSlash(filepath.Join(dir, "missing.zip")), configmap.Simple{})
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.zip"), []byte("not a zip file"), 0666))
+	_, err = NewFs(ctx, "archive", filepath.ToSlash(filepath.Join(dir, "bad.zip")), configmap.Simple{})
+	assert.Error(t, err)
+}
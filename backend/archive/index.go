@@ -0,0 +1,226 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// format describes a type of archive
+type format struct {
+	name       string        // name of the format
+	extensions []string      // file extensions, lower case
+	precision  time.Duration // precision of the modification times
+	hashes     hash.Set      // hashes stored in the archive
+	read       func(ctx context.Context, o fs.Object) (*index, error)
+}
+
+// formats supported, in the order they are checked
+var formats = []*format{{
+	name:       "zip",
+	extensions: []string{".zip"},
+	precision:  2 * time.Second,
+	hashes:     hash.NewHashSet(hash.CRC32),
+	read:       readZip,
+}, {
+	name:       "tar.gz",
+	extensions: []string{".tar.gz", ".tgz"},
+	precision:  time.Second,
+	read:       readTarGz,
+}, {
+	name:       "tar",
+	extensions: []string{".tar"},
+	precision:  time.Second,
+	read:       readTar,
+}}
+
+// findFormat returns the format for the file name or nil if not found
+func findFormat(name string) *format {
+	lowerName := strings.ToLower(name)
+	for _, format := range formats {
+		for _, extension := range format.extensions {
+			if strings.HasSuffix(lowerName, extension) {
+				return format
+			}
+		}
+	}
+	return nil
+}
+
+// formatExtensions returns the known extensions as a string for
+// error messages
+func formatExtensions() string {
+	var extensions []string
+	for _, format := range formats {
+		extensions = append(extensions, format.extensions...)
+	}
+	return strings.Join(extensions, ", ")
+}
+
+// opener opens the data of an archive member from the archive
+//
+// offset and limit are always within the size of the member.
+type opener func(ctx context.Context, archive fs.Object, offset, limit int64) (io.ReadCloser, error)
+
+// entry is a file or directory in the archive
+type entry struct {
+	name     string // full path in the archive
+	size     int64
+	modTime  time.Time
+	isDir    bool
+	hashes   map[hash.Type]string
+	children []*entry // for directories only
+	open     opener   // for files only
+}
+
+// index is the contents of the archive
+type index struct {
+	modTime time.Time         // time for implicit directories
+	entries map[string]*entry // all entries by path
+	root    *entry
+}
+
+// newIndex makes a new empty index using modTime for directories
+// without their own entry
+func newIndex(modTime time.Time) *index {
+	root := &entry{
+		isDir:   true,
+		modTime: modTime,
+	}
+	return &index{
+		modTime: modTime,
+		entries: map[string]*entry{"": root},
+		root:    root,
+	}
+}
+
+// cleanName cleans up the name of an archive member returning "" if
+// it should be ignored.
+//
+// Members with absolute paths have the leading / removed and those
+// which would be outside the archive are ignored.
+func cleanName(name string) string {
+	name = strings.TrimLeft(strings.ReplaceAll(name, "\\", "/"), "/")
+	name = path.Clean(name)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+	return name
+}
+
+// dir finds or makes the directory entry for name
+func (x *index) dir(name string) (*entry, error) {
+	d, ok := x.entries[name]
+	if ok {
+		if !d.isDir {
+			return nil, fmt.Errorf("%q is both a file and a directory", name)
+		}
+		return d, nil
+	}
+	parentName := path.Dir(name)
+	if parentName == "." {
+		parentName = ""
+	}
+	parent, err := x.dir(parentName)
+	if err != nil {
+		return nil, err
+	}
+	d = &entry{
+		name:    name,
+		isDir:   true,
+		modTime: x.modTime,
+	}
+	x.entries[name] = d
+	parent.children = append(parent.children, d)
+	return d, nil
+}
+
+// add an entry to the index, creating any parent directories
+//
+// Later entries for files replace earlier ones as they do when the
+// archive is extracted.
+func (x *index) add(e *entry) error {
+	e.name = cleanName(e.name)
+	if e.name == "" {
+		return nil
+	}
+	if e.isDir {
+		d, err := x.dir(e.name)
+		if err != nil {
+			return err
+		}
+		if !e.modTime.IsZero() {
+			d.modTime = e.modTime
+		}
+		return nil
+	}
+	if old, ok := x.entries[e.name]; ok {
+		if old.isDir {
+			return fmt.Errorf("%q is both a file and a directory", e.name)
+		}
+		*old = *e
+		return nil
+	}
+	parentName := path.Dir(e.name)
+	if parentName == "." {
+		parentName = ""
+	}
+	parent, err := x.dir(parentName)
+	if err != nil {
+		return err
+	}
+	x.entries[e.name] = e
+	parent.children = append(parent.children, e)
+	return nil
+}
+
+// finish sorts the directory listings
+func (x *index) finish() {
+	for _, e := range x.entries {
+		if e.isDir {
+			sort.Slice(e.children, func(i, j int) bool {
+				return e.children[i].name < e.children[j].name
+			})
+		}
+	}
+}
+
+// find the entry for name or return nil if not found
+func (x *index) find(name string) *entry {
+	return x.entries[name]
+}
+
+// openRange returns an opener which reads the member stored
+// uncompressed at dataOffset in the archive
+func openRange(dataOffset int64) opener {
+	return func(ctx context.Context, archive fs.Object, offset, limit int64) (io.ReadCloser, error) {
+		if limit == 0 {
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		start := dataOffset + offset
+		return archive.Open(ctx, &fs.RangeOption{Start: start, End: start + limit - 1})
+	}
+}
+
+// discardReadCloser skips offset bytes of in and returns a
+// ReadCloser which closes closer
+func discardReadCloser(in io.Reader, closer io.Closer, offset int64) (io.ReadCloser, error) {
+	if offset > 0 {
+		_, err := io.CopyN(io.Discard, in, offset)
+		if err != nil {
+			_ = closer.Close()
+			return nil, fmt.Errorf("failed to skip to offset %d: %w", offset, err)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{in, closer}, nil
+}
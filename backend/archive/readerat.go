@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+const (
+	blockSize = 256 * 1024 // size of blocks read from the archive
+	maxBlocks = 16         // number of blocks to keep in memory
+)
+
+// readerAt implements io.ReaderAt on an fs.Object using range
+// requests.
+//
+// It reads whole blocks and keeps the most recently used in memory
+// as the archive readers make lots of small reads close together.
+//
+// The archive readers don't pass a context to ReadAt so reads may
+// only be made from inside withContext which sets the context to
+// use.
+type readerAt struct {
+	o      fs.Object
+	size   int64
+	ctxMu  sync.Mutex // held while a context is set
+	mu     sync.Mutex
+	ctx    context.Context  // context for reads, nil if not set
+	blocks map[int64][]byte // cached blocks by block number
+	used   []int64          // block numbers, least recently used first
+}
+
+// newReaderAt makes an io.ReaderAt reading o
+func newReaderAt(o fs.Object) *readerAt {
+	return &readerAt{
+		o:      o,
+		size:   o.Size(),
+		blocks: make(map[int64][]byte, maxBlocks),
+	}
+}
+
+// withContext calls fn with any reads it makes using ctx
+//
+// Only one fn runs at once.
+func (r *readerAt) withContext(ctx context.Context, fn func() error) error {
+	r.ctxMu.Lock()
+	defer r.ctxMu.Unlock()
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.ctx = nil
+		r.mu.Unlock()
+	}()
+	return fn()
+}
+
+// getBlock returns the block with block number n
+//
+// Call with mu held
+func (r *readerAt) getBlock(n int64) ([]byte, error) {
+	for i, used := range r.used {
+		if used == n {
+			r.used = append(append(r.used[:i:i], r.used[i+1:]...), n)
+			return r.blocks[n], nil
+		}
+	}
+	if r.ctx == nil {
+		return nil, errors.New("archive read without a context")
+	}
+	start := n * blockSize
+	end := start + blockSize
+	if end > r.size {
+		end = r.size
+	}
+	in, err := r.o.Open(r.ctx, &fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive at offset %d: %w", start, err)
+	}
+	block := make([]byte, end-start)
+	_, err = io.ReadFull(in, block)
+	_ = in.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive at offset %d: %w", start, err)
+	}
+	if len(r.used) >= maxBlocks {
+		delete(r.blocks, r.used[0])
+		r.used = r.used[1:]
+	}
+	r.blocks[n] = block
+	r.used = append(r.used, n)
+	return block, nil
+}
+
+// ReadAt reads len(p) bytes into p starting at offset off
+func (r *readerAt) ReadAt(p []byte, off int64) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(p) > 0 {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		block, err := r.getBlock(off / blockSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p, block[off%blockSize:])
+		n += copied
+		off += int64(copied)
+		p = p[copied:]
+	}
+	return n, nil
+}
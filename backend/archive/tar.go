@@ -0,0 +1,234 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/readers"
+)
+
+// addTarHeader adds the member with header hdr and data at
+// dataOffset to the index.
+//
+// If open is nil the member is read with a range request.
+func addTarHeader(o fs.Object, x *index, hdr *tar.Header, dataOffset int64, open opener) error {
+	e := &entry{
+		name:    hdr.Name,
+		size:    hdr.Size,
+		modTime: hdr.ModTime,
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		e.isDir = true
+	case tar.TypeReg:
+		if open == nil {
+			open = openRange(dataOffset)
+		}
+		e.open = open
+	default:
+		fs.Debugf(o, "Ignoring %q which isn't a regular file", hdr.Name)
+		return nil
+	}
+	return x.add(e)
+}
+
+// readTar reads the index of an uncompressed tar archive
+//
+// Only the headers are read using range requests as the tar reader
+// seeks over the data of each member.
+func readTar(ctx context.Context, o fs.Object) (*index, error) {
+	r := newReaderAt(o)
+	in := io.NewSectionReader(r, 0, o.Size())
+	tr := tar.NewReader(in)
+	x := newIndex(o.ModTime(ctx))
+	err := r.withContext(ctx, func() error {
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// The tar reader reads exactly the headers so the
+			// data starts at the current offset
+			dataOffset, err := in.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			err = addTarHeader(o, x, hdr, dataOffset, nil)
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	x.finish()
+	return x, nil
+}
+
+// restartInterval is the minimum amount of decompressed data between
+// the gzip restart points kept
+var restartInterval int64 = 4 * 1024 * 1024
+
+// gzipRestart is a point in a gzip compressed archive where
+// decompression can start
+type gzipRestart struct {
+	compressed   int64 // offset in the archive
+	uncompressed int64 // offset in the decompressed data
+}
+
+// gzipMembers decompresses a gzip stream made of one or more gzip
+// members, noting where each member starts so decompression can
+// restart there.
+//
+// Most gzip files are a single member so can only be decompressed
+// from the start, but some tools, eg bgzip, write many small
+// members.
+type gzipMembers struct {
+	in           *readers.CountingReader
+	br           *bufio.Reader
+	zr           *gzip.Reader
+	uncompressed int64
+	restarts     []gzipRestart
+}
+
+// newGzipMembers starts decompressing in
+func newGzipMembers(in io.Reader) (*gzipMembers, error) {
+	g := &gzipMembers{
+		in:       readers.NewCountingReader(in),
+		restarts: []gzipRestart{{}},
+	}
+	// The gzip reader doesn't read past the end of a member from
+	// an io.ByteReader so the position of the next member is known
+	g.br = bufio.NewReader(g.in)
+	zr, err := gzip.NewReader(g.br)
+	if err != nil {
+		return nil, err
+	}
+	zr.Multistream(false)
+	g.zr = zr
+	return g, nil
+}
+
+// Read decompressed data into p
+func (g *gzipMembers) Read(p []byte) (n int, err error) {
+	for n == 0 && err == nil {
+		n, err = g.zr.Read(p)
+		g.uncompressed += int64(n)
+		if err != io.EOF {
+			break
+		}
+		compressed := int64(g.in.BytesRead()) - int64(g.br.Buffered())
+		err = g.zr.Reset(g.br)
+		if err != nil {
+			break
+		}
+		g.zr.Multistream(false)
+		last := g.restarts[len(g.restarts)-1]
+		if g.uncompressed-last.uncompressed >= restartInterval {
+			g.restarts = append(g.restarts, gzipRestart{compressed: compressed, uncompressed: g.uncompressed})
+		}
+	}
+	return n, err
+}
+
+// findRestart returns the last restart point at or before offset in
+// the decompressed data
+func findRestart(restarts []gzipRestart, offset int64) gzipRestart {
+	i := sort.Search(len(restarts), func(i int) bool {
+		return restarts[i].uncompressed > offset
+	})
+	if i == 0 {
+		return gzipRestart{}
+	}
+	return restarts[i-1]
+}
+
+// readTarGz reads the index of a gzip compressed tar archive
+//
+// A gzip stream can't be read from the middle, so this reads the
+// whole archive to find the members. Reading a member decompresses
+// the archive from the last gzip member which starts before it,
+// which for an archive made of a single gzip member is the start.
+func readTarGz(ctx context.Context, o fs.Object) (*index, error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	g, err := newGzipMembers(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	tr := tar.NewReader(g)
+	x := newIndex(o.ModTime(ctx))
+	type member struct {
+		hdr        *tar.Header
+		dataOffset int64
+	}
+	var members []member
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The tar reader reads exactly the headers so the data
+		// starts at the current offset
+		members = append(members, member{hdr: hdr, dataOffset: g.uncompressed})
+	}
+	restarts := g.restarts
+	if len(restarts) > 1 {
+		fs.Debugf(o, "Found %d restart points in gzip stream", len(restarts))
+	}
+	for _, m := range members {
+		dataOffset := m.dataOffset
+		open := func(ctx context.Context, archive fs.Object, offset, limit int64) (io.ReadCloser, error) {
+			restart := findRestart(restarts, dataOffset+offset)
+			rc, err := openTarGz(ctx, archive, restart.compressed)
+			if err != nil {
+				return nil, err
+			}
+			return discardReadCloser(rc, rc, dataOffset+offset-restart.uncompressed)
+		}
+		err = addTarHeader(o, x, m.hdr, dataOffset, open)
+		if err != nil {
+			return nil, err
+		}
+	}
+	x.finish()
+	return x, nil
+}
+
+// openTarGz opens the archive at the start of the gzip member at
+// offset returning the decompressed stream
+func openTarGz(ctx context.Context, o fs.Object, offset int64) (io.ReadCloser, error) {
+	var options []fs.OpenOption
+	if offset > 0 {
+		options = append(options, &fs.RangeOption{Start: offset, End: -1})
+	}
+	in, err := o.Open(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := readers.NewGzipReader(in)
+	if err != nil {
+		_ = in.Close()
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return rc, nil
+}
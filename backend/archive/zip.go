@@ -0,0 +1,167 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"fmt"
+	gohash "hash"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// readZip reads the index of a zip archive from its central
+// directory using range requests
+func readZip(ctx context.Context, o fs.Object) (*index, error) {
+	r := newReaderAt(o)
+	var zr *zip.Reader
+	err := r.withContext(ctx, func() (err error) {
+		zr, err = zip.NewReader(r, o.Size())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	x := newIndex(o.ModTime(ctx))
+	for _, zf := range zr.File {
+		e := &entry{
+			name:    zf.Name,
+			size:    int64(zf.UncompressedSize64),
+			modTime: zipModTime(zf),
+			isDir:   zf.FileInfo().IsDir(),
+		}
+		if !e.isDir {
+			if !zf.Mode().IsRegular() {
+				fs.Debugf(o, "Ignoring %q which isn't a regular file", zf.Name)
+				continue
+			}
+			e.hashes = map[hash.Type]string{
+				hash.CRC32: fmt.Sprintf("%08x", zf.CRC32),
+			}
+			e.open = zipOpener(r, zf)
+		}
+		err = x.add(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	x.finish()
+	return x, nil
+}
+
+// zipModTime returns the modification time of the member
+//
+// If the archive has no time zone information the time is
+// interpreted as UTC.
+func zipModTime(zf *zip.File) time.Time {
+	if zf.Modified.IsZero() {
+		return zf.Modified
+	}
+	return zf.Modified.UTC()
+}
+
+// zipOpener returns an opener for the zip member
+//
+// Stored members are read directly with a range request. Deflated
+// members read the compressed data with a range request and
+// decompress it.
+//
+// The offset of the data is read from the local header with r the
+// first time the member is opened to save reading all the local
+// headers when listing.
+func zipOpener(r *readerAt, zf *zip.File) opener {
+	var (
+		mu         sync.Mutex
+		dataOffset int64 = -1
+	)
+	findData := func(ctx context.Context) (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if dataOffset < 0 {
+			err := r.withContext(ctx, func() (err error) {
+				dataOffset, err = zf.DataOffset()
+				return err
+			})
+			if err != nil {
+				dataOffset = -1
+				return 0, fmt.Errorf("failed to find data of %q: %w", zf.Name, err)
+			}
+		}
+		return dataOffset, nil
+	}
+	return func(ctx context.Context, archive fs.Object, offset, limit int64) (io.ReadCloser, error) {
+		if zf.Flags&0x1 != 0 {
+			return nil, fmt.Errorf("can't read %q: encrypted zip members are not supported", zf.Name)
+		}
+		switch zf.Method {
+		case zip.Store:
+			dataOffset, err := findData(ctx)
+			if err != nil {
+				return nil, err
+			}
+			in, err := openRange(dataOffset)(ctx, archive, offset, limit)
+			if err != nil || offset != 0 || limit != int64(zf.UncompressedSize64) {
+				return in, err
+			}
+			// The whole member is read so check its CRC
+			return struct {
+				io.Reader
+				io.Closer
+			}{newCRCReader(in, zf), in}, nil
+		case zip.Deflate:
+			dataOffset, err := findData(ctx)
+			if err != nil {
+				return nil, err
+			}
+			in, err := openRange(dataOffset)(ctx, archive, 0, int64(zf.CompressedSize64))
+			if err != nil {
+				return nil, err
+			}
+			return discardReadCloser(newCRCReader(flate.NewReader(in), zf), in, offset)
+		}
+		return nil, fmt.Errorf("can't read %q: unsupported zip compression method %d", zf.Name, zf.Method)
+	}
+}
+
+// crcReader checks the data read from a zip member against the CRC32
+// in the archive, as archive/zip does, returning an error from the
+// Read which completes the member if it doesn't match.
+type crcReader struct {
+	in   io.Reader
+	zf   *zip.File
+	crc  gohash.Hash32
+	read uint64
+}
+
+// newCRCReader returns a reader checking the CRC32 of zf read from in
+func newCRCReader(in io.Reader, zf *zip.File) *crcReader {
+	return &crcReader{
+		in:  in,
+		zf:  zf,
+		crc: crc32.NewIEEE(),
+	}
+}
+
+// Read data into p checking the CRC once the whole member is read
+func (r *crcReader) Read(p []byte) (n int, err error) {
+	n, err = r.in.Read(p)
+	r.crc.Write(p[:n])
+	r.read += uint64(n)
+	size := r.zf.UncompressedSize64
+	switch {
+	case r.read > size:
+		return n, fmt.Errorf("%q is bigger than the size in the archive", r.zf.Name)
+	case r.read == size && (n > 0 || err == io.EOF):
+		if r.crc.Sum32() != r.zf.CRC32 {
+			return n, fmt.Errorf("%q: %w", r.zf.Name, zip.ErrChecksum)
+		}
+	case err == io.EOF:
+		return n, fmt.Errorf("%q: %w", r.zf.Name, io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
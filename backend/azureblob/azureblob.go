@@ -475,6 +475,7 @@ type Fs struct {
 	pacer         *fs.Pacer                    // To pace and retry the API calls
 	uploadToken   *pacer.TokenDispenser        // control concurrency
 	publicAccess  container.PublicAccessType   // Container Public Access Level
+	cred          azcore.TokenCredential       // token credential if using one
 }
 
 // Object describes an azure object
@@ -691,12 +692,13 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	f.publicAccess = container.PublicAccessType(opt.PublicAccess)
 	f.setRoot(root)
 	f.features = (&fs.Features{
-		ReadMimeType:      true,
-		WriteMimeType:     true,
-		BucketBased:       true,
-		BucketBasedRootOK: true,
-		SetTier:           true,
-		GetTier:           true,
+		ReadMimeType:       true,
+		WriteMimeType:      true,
+		BucketBased:        true,
+		BucketBasedRootOK:  true,
+		SetTier:            true,
+		GetTier:            true,
+		ChunkWriterCanCopy: true,
 	}).Fill(ctx, f)
 	if opt.DirectoryMarkers {
 		f.features.CanHaveEmptyDirectories = true
@@ -918,6 +920,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	if f.svc == nil {
 		return nil, fmt.Errorf("internal error: auth failed to make credentials or client")
 	}
+	f.cred = cred
 
 	if f.rootContainer != "" && f.rootDirectory != "" {
 		// Check to see if the (container,directory) is actually an existing file
@@ -2076,6 +2079,24 @@ func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectIn
 	return info, chunkWriter, nil
 }
 
+// addBlock makes the block ID for chunkNumber and saves it for the
+// commit
+func (w *azChunkWriter) addBlock(chunkNumber int) (blockID string) {
+	// increment the blockID and save the blocks for finalize
+	var binaryBlockID [8]byte // block counter as LSB first 8 bytes
+	binary.LittleEndian.PutUint64(binaryBlockID[:], uint64(chunkNumber))
+	blockID = base64.StdEncoding.EncodeToString(binaryBlockID[:])
+
+	// Save the blockID for the commit
+	w.blocksMu.Lock()
+	w.blocks = append(w.blocks, azBlock{
+		chunkNumber: uint64(chunkNumber),
+		id:          blockID,
+	})
+	w.blocksMu.Unlock()
+	return blockID
+}
+
 // WriteChunk will write chunk number with reader bytes, where chunk number >= 0
 func (w *azChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
 	if chunkNumber < 0 {
@@ -2096,18 +2117,7 @@ func (w *azChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 	md5sum := m.Sum(nil)
 	transactionalMD5 := md5sum[:]
 
-	// increment the blockID and save the blocks for finalize
-	var binaryBlockID [8]byte // block counter as LSB first 8 bytes
-	binary.LittleEndian.PutUint64(binaryBlockID[:], uint64(chunkNumber))
-	blockID := base64.StdEncoding.EncodeToString(binaryBlockID[:])
-
-	// Save the blockID for the commit
-	w.blocksMu.Lock()
-	w.blocks = append(w.blocks, azBlock{
-		chunkNumber: uint64(chunkNumber),
-		id:          blockID,
-	})
-	w.blocksMu.Unlock()
+	blockID := w.addBlock(chunkNumber)
 
 	err = w.f.pacer.Call(func() (bool, error) {
 		// rewind the reader on retry and after reading md5
@@ -2137,6 +2147,68 @@ func (w *azChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 	return currentChunkSize, err
 }
 
+// copySource returns the URL and authorization for reading src
+// when copying from it with StageBlockFromURL.
+//
+// The source must be readable with the URL alone or with an OAuth
+// token so a SAS URL is made for it if using a shared key.
+func (f *Fs) copySource(ctx context.Context, src *Object) (srcURL string, auth *string, err error) {
+	srcBlobSVC := src.getBlobSVC()
+	srcURL, err = srcBlobSVC.GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(time.Hour), nil)
+	if err == nil {
+		return srcURL, nil, nil
+	}
+	srcURL = srcBlobSVC.URL()
+	if f.cred != nil {
+		token, err := f.cred.GetToken(ctx, policy.TokenRequestOptions{
+			Scopes: []string{"https://storage.azure.com/.default"},
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get token for copy source: %w", err)
+		}
+		auth = new(string)
+		*auth = "Bearer " + token.Token
+	}
+	return srcURL, auth, nil
+}
+
+// CopyChunk will write chunk number by copying size bytes from offset
+// in src server-side, where chunk number >= 0
+func (w *azChunkWriter) CopyChunk(ctx context.Context, chunkNumber int, src fs.Object, offset, size int64) (int64, error) {
+	if chunkNumber < 0 {
+		err := fmt.Errorf("invalid chunk number provided: %v", chunkNumber)
+		return -1, err
+	}
+	srcObj, ok := src.(*Object)
+	if !ok {
+		return -1, fmt.Errorf("can't copy chunk from %v: not same remote type", src)
+	}
+	srcURL, auth, err := w.f.copySource(ctx, srcObj)
+	if err != nil {
+		return -1, err
+	}
+
+	blockID := w.addBlock(chunkNumber)
+
+	err = w.f.pacer.Call(func() (bool, error) {
+		options := blockblob.StageBlockFromURLOptions{
+			CopySourceAuthorization: auth,
+			Range: blob.HTTPRange{
+				Offset: offset,
+				Count:  size,
+			},
+		}
+		_, err := w.ui.blb.StageBlockFromURL(ctx, blockID, srcURL, &options)
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to copy chunk %d with %v bytes: %w", chunkNumber+1, size, err)
+	}
+
+	fs.Debugf(w.o, "multipart upload copied chunk %d with %v bytes", chunkNumber+1, size)
+	return size, nil
+}
+
 // Abort the multipart upload.
 //
 // FIXME it would be nice to delete uncommitted blocks.
@@ -2460,6 +2532,7 @@ var (
 	_ fs.Purger          = &Fs{}
 	_ fs.ListRer         = &Fs{}
 	_ fs.OpenChunkWriter = &Fs{}
+	_ fs.ChunkCopier     = &azChunkWriter{}
 	_ fs.Object          = &Object{}
 	_ fs.MimeTyper       = &Object{}
 	_ fs.GetTierer       = &Object{}
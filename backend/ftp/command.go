@@ -0,0 +1,315 @@
+package ftp
+
+// This file implements sending commands the ftp library doesn't
+// support, namely the hash commands (HASH, XMD5, XSHA1, XSHA256,
+// XCRC), and reading metadata from the facts in MLSD listings.
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/backend/ftp/internal/ftp"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// connState is the state rclone keeps for each ftp library connection
+type connState struct {
+	hashAlgo string // algorithm currently selected for the HASH command
+}
+
+// getConnState returns the state for c or nil if not found
+func (f *Fs) getConnState(c *ftp.ServerConn) *connState {
+	f.poolMu.Lock()
+	defer f.poolMu.Unlock()
+	return f.conns[c]
+}
+
+// quitFtpConnection closes c and forgets its state
+func (f *Fs) quitFtpConnection(c *ftp.ServerConn) error {
+	f.poolMu.Lock()
+	delete(f.conns, c)
+	f.poolMu.Unlock()
+	return c.Quit()
+}
+
+// hashCommand describes how to read a hash from the server
+type hashCommand struct {
+	command string // command to use: HASH, XMD5, etc.
+	algo    string // algorithm name for the HASH command
+}
+
+// Names of the hash algorithms in the HASH command and the
+// equivalent non standard commands for each hash type.
+//
+// See https://datatracker.ietf.org/doc/html/draft-bryan-ftpext-hash-02
+var hashCommands = []struct {
+	ht      hash.Type
+	algo    string
+	command string
+}{
+	{hash.MD5, "MD5", "XMD5"},
+	{hash.SHA1, "SHA-1", "XSHA1"},
+	{hash.SHA256, "SHA-256", "XSHA256"},
+	{hash.CRC32, "CRC32", "XCRC"},
+}
+
+// findHashCommands works out which hashes the server can calculate
+// from the features it advertises
+func findHashCommands(features map[string]string) map[hash.Type]hashCommand {
+	commands := map[hash.Type]hashCommand{}
+	hashAlgos := map[string]bool{}
+	if algos, ok := features["HASH"]; ok {
+		for _, algo := range strings.Split(algos, ";") {
+			algo = strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(algo), "*"))
+			hashAlgos[algo] = true
+		}
+	}
+	for _, hc := range hashCommands {
+		if hashAlgos[hc.algo] {
+			commands[hc.ht] = hashCommand{command: "HASH", algo: hc.algo}
+		} else if _, ok := features[hc.command]; ok {
+			commands[hc.ht] = hashCommand{command: hc.command}
+		}
+	}
+	return commands
+}
+
+// parseHashResponse extracts the hash from the response to a hash
+// command
+//
+// The HASH command returns "SHA-1 0-49 hexhash filename" and the
+// other commands return the hash optionally followed by other text.
+func parseHashResponse(ht hash.Type, command string, message string) (string, error) {
+	fields := strings.Fields(message)
+	if command == "HASH" {
+		if len(fields) < 3 {
+			return "", fmt.Errorf("bad response to HASH: %q", message)
+		}
+		fields = fields[2:]
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty response to %s", command)
+	}
+	sum := strings.ToLower(fields[0])
+	sum = strings.TrimPrefix(sum, "0x")
+	width := hash.Width(ht, false)
+	if len(sum) < width && ht == hash.CRC32 {
+		// Some servers don't send leading zeros for CRC32
+		sum = strings.Repeat("0", width-len(sum)) + sum
+	}
+	if len(sum) != width {
+		return "", fmt.Errorf("bad hash in response to %s: %q", command, message)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", fmt.Errorf("bad hash in response to %s: %q", command, message)
+	}
+	return sum, nil
+}
+
+// readHash asks the server for the hash of the file at the encoded
+// absolute path
+func (f *Fs) readHash(ctx context.Context, ht hash.Type, filePath string) (sum string, err error) {
+	hc, ok := f.hashCommands[ht]
+	if !ok {
+		return "", hash.ErrUnsupported
+	}
+	c, err := f.getFtpConnection(ctx)
+	if err != nil {
+		return "", fmt.Errorf("hash: %w", err)
+	}
+	defer func() {
+		f.putFtpConnection(&c, err)
+	}()
+	state := f.getConnState(c)
+	if hc.command == "HASH" && (state == nil || state.hashAlgo != hc.algo) {
+		_, _, err = c.Cmd(ftp.StatusCommandOK, "OPTS HASH %s", hc.algo)
+		if err != nil {
+			return "", fmt.Errorf("hash: failed to select %s: %w", hc.algo, err)
+		}
+		if state != nil {
+			state.hashAlgo = hc.algo
+		}
+	}
+	code, message, err := c.Cmd(-1, "%s %s", hc.command, filePath)
+	if err != nil {
+		return "", fmt.Errorf("hash: %s failed: %w", hc.command, err)
+	}
+	if code < 200 || code >= 300 {
+		err = &textproto.Error{Code: code, Msg: message}
+		return "", fmt.Errorf("hash: %s failed: %w", hc.command, err)
+	}
+	return parseHashResponse(ht, hc.command, message)
+}
+
+// Metadata keys read from the MLSD facts
+var systemMetadataInfo = map[string]fs.MetadataHelp{
+	"mode": {
+		Help:     "File type and mode",
+		Type:     "octal, unix style",
+		Example:  "0100664",
+		ReadOnly: true,
+	},
+	"uid": {
+		Help:     "User ID of owner",
+		Type:     "decimal number",
+		Example:  "500",
+		ReadOnly: true,
+	},
+	"gid": {
+		Help:     "Group ID of owner",
+		Type:     "decimal number",
+		Example:  "500",
+		ReadOnly: true,
+	},
+	"owner": {
+		Help:     "User name of owner",
+		Type:     "string",
+		Example:  "alice",
+		ReadOnly: true,
+	},
+	"group": {
+		Help:     "Group name of owner",
+		Type:     "string",
+		Example:  "staff",
+		ReadOnly: true,
+	},
+	"mtime": {
+		Help:     "Time of last modification",
+		Type:     "RFC 3339",
+		Example:  "2006-01-02T15:04:05Z",
+		ReadOnly: true,
+	},
+	"btime": {
+		Help:     "Time of file birth (creation)",
+		Type:     "RFC 3339",
+		Example:  "2006-01-02T15:04:05Z",
+		ReadOnly: true,
+	},
+}
+
+// parseFacts parses the facts from an MLSD listing (RFC 3659) keyed
+// by lower case fact name into metadata
+//
+// It returns nil if there are no facts, eg for a LIST listing.
+func parseFacts(facts map[string]string) (m fs.Metadata) {
+	if facts == nil {
+		return nil
+	}
+	m = fs.Metadata{}
+	var fileType string
+	var mode uint64
+	haveMode := false
+	for key, value := range facts {
+		switch key {
+		case "type":
+			fileType = strings.ToLower(value)
+		case "modify", "create":
+			t, err := parseFactTime(value)
+			if err != nil {
+				fs.Debugf(nil, "Ignoring bad time %q in MLSD: %v", value, err)
+				continue
+			}
+			if key == "modify" {
+				m["mtime"] = t.Format(time.RFC3339Nano)
+			} else {
+				m["btime"] = t.Format(time.RFC3339Nano)
+			}
+		case "unix.mode":
+			var err error
+			mode, err = strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				fs.Debugf(nil, "Ignoring bad mode %q in MLSD: %v", value, err)
+				continue
+			}
+			haveMode = true
+		case "unix.uid":
+			m["uid"] = value
+		case "unix.gid":
+			m["gid"] = value
+		case "unix.owner":
+			// ProFTPD sends the uid here, other servers the name
+			if isNumber(value) {
+				m["uid"] = value
+			} else {
+				m["owner"] = value
+			}
+		case "unix.group":
+			if isNumber(value) {
+				m["gid"] = value
+			} else {
+				m["group"] = value
+			}
+		case "unix.ownername":
+			m["owner"] = value
+		case "unix.groupname":
+			m["group"] = value
+		}
+	}
+	if haveMode {
+		switch fileType {
+		case "file":
+			mode |= 0100000
+		case "dir", "cdir", "pdir":
+			mode |= 0040000
+		}
+		m["mode"] = fmt.Sprintf("%o", mode)
+	}
+	return m
+}
+
+// isNumber returns true if s is all decimal digits
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFactTime parses a time value from MLSD which is in UTC with
+// optional fractional seconds
+func parseFactTime(value string) (time.Time, error) {
+	layout := "20060102150405"
+	if i := strings.IndexRune(value, '.'); i >= 0 {
+		layout += "." + strings.Repeat("0", len(value)-i-1)
+	}
+	return time.ParseInLocation(layout, value, time.UTC)
+}
+
+// readMetadata reads the metadata for the object by listing its
+// parent directory
+func (o *Object) readMetadata(ctx context.Context) (m fs.Metadata, err error) {
+	remote := path.Join(o.fs.root, o.remote)
+	c, err := o.fs.getFtpConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	files, err := c.List(o.fs.dirFromStandardPath(path.Dir(remote)))
+	o.fs.putFtpConnection(&c, err)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", translateErrorFile(err))
+	}
+	name := o.fs.opt.Enc.FromStandardName(path.Base(remote))
+	for _, file := range files {
+		if file.Name == name && file.Type == ftp.EntryTypeFile {
+			return parseFacts(file.Facts), nil
+		}
+	}
+	return nil, fs.ErrorObjectNotFound
+}
+
+// objectPath returns the encoded absolute path for commands
+func (o *Object) objectPath() string {
+	return o.fs.opt.Enc.FromStandardPath(path.Join(o.fs.root, o.remote))
+}
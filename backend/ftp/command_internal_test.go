@@ -0,0 +1,294 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindHashCommands(t *testing.T) {
+	for _, test := range []struct {
+		features map[string]string
+		want     map[hash.Type]hashCommand
+	}{{
+		features: map[string]string{},
+		want:     map[hash.Type]hashCommand{},
+	}, {
+		features: map[string]string{"HASH": "SHA-1*;MD5;SHA-256;CRC32"},
+		want: map[hash.Type]hashCommand{
+			hash.MD5:    {command: "HASH", algo: "MD5"},
+			hash.SHA1:   {command: "HASH", algo: "SHA-1"},
+			hash.SHA256: {command: "HASH", algo: "SHA-256"},
+			hash.CRC32:  {command: "HASH", algo: "CRC32"},
+		},
+	}, {
+		features: map[string]string{"XMD5": "", "XCRC": "", "MLST": "size*;modify*;"},
+		want: map[hash.Type]hashCommand{
+			hash.MD5:   {command: "XMD5"},
+			hash.CRC32: {command: "XCRC"},
+		},
+	}, {
+		features: map[string]string{"HASH": "MD5", "XSHA1": ""},
+		want: map[hash.Type]hashCommand{
+			hash.MD5:  {command: "HASH", algo: "MD5"},
+			hash.SHA1: {command: "XSHA1"},
+		},
+	}} {
+		assert.Equal(t, test.want, findHashCommands(test.features), test.features)
+	}
+}
+
+func TestParseHashResponse(t *testing.T) {
+	for _, test := range []struct {
+		ht      hash.Type
+		command string
+		message string
+		want    string
+		wantErr bool
+	}{
+		{hash.MD5, "XMD5", "D41D8CD98F00B204E9800998ECF8427E", "d41d8cd98f00b204e9800998ecf8427e", false},
+		{hash.MD5, "XMD5", "d41d8cd98f00b204e9800998ecf8427e /file.txt", "d41d8cd98f00b204e9800998ecf8427e", false},
+		{hash.CRC32, "XCRC", "0x1234ABC", "01234abc", false},
+		{hash.SHA1, "HASH", "SHA-1 0-0 da39a3ee5e6b4b0d3255bfef95601890afd80709 /file.txt", "da39a3ee5e6b4b0d3255bfef95601890afd80709", false},
+		{hash.SHA1, "HASH", "SHA-1 0-0", "", true},
+		{hash.MD5, "XMD5", "", "", true},
+		{hash.MD5, "XMD5", "not a hash", "", true},
+		{hash.MD5, "XMD5", "z41d8cd98f00b204e9800998ecf8427e", "", true},
+	} {
+		got, err := parseHashResponse(test.ht, test.command, test.message)
+		if test.wantErr {
+			assert.Error(t, err, test.message)
+		} else {
+			require.NoError(t, err, test.message)
+			assert.Equal(t, test.want, got, test.message)
+		}
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	m := parseFacts(map[string]string{
+		"type":           "file",
+		"size":           "5",
+		"modify":         "20200102030405.123",
+		"create":         "20190102030405",
+		"unix.mode":      "0644",
+		"unix.owner":     "1000",
+		"unix.group":     "100",
+		"unix.ownername": "alice",
+		"unix.groupname": "users",
+	})
+	assert.Equal(t, fs.Metadata{
+		"mode":  "100644",
+		"uid":   "1000",
+		"gid":   "100",
+		"owner": "alice",
+		"group": "users",
+		"mtime": "2020-01-02T03:04:05.123Z",
+		"btime": "2019-01-02T03:04:05Z",
+	}, m)
+
+	m = parseFacts(map[string]string{
+		"type":       "dir",
+		"modify":     "20200102030405",
+		"unix.mode":  "0755",
+		"unix.owner": "bob",
+		"unix.group": "staff",
+	})
+	assert.Equal(t, fs.Metadata{
+		"mode":  "40755",
+		"owner": "bob",
+		"group": "staff",
+		"mtime": "2020-01-02T03:04:05Z",
+	}, m)
+
+	assert.Nil(t, parseFacts(nil))
+}
+
+// fakeControlServer runs a minimal FTP control connection server
+// which answers the commands with the responses passed in.
+//
+// It returns the address of the server and a channel which receives
+// the commands sent.
+func fakeControlServer(t *testing.T, responses map[string]string) (addr string, commands chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	commands = make(chan string, 100)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				text := textproto.NewConn(conn)
+				defer func() { _ = text.Close() }()
+				_ = text.PrintfLine("220 Fake FTP server ready")
+				for {
+					line, err := text.ReadLine()
+					if err != nil {
+						return
+					}
+					commands <- line
+					if line == "QUIT" {
+						_ = text.PrintfLine("221 Goodbye")
+						return
+					}
+					response, ok := responses[line]
+					if !ok {
+						verb, _, _ := strings.Cut(line, " ")
+						response, ok = responses[verb]
+					}
+					if !ok {
+						response = "502 Command not implemented"
+					}
+					_ = text.PrintfLine("%s", response)
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), commands
+}
+
+// fakeDataServer serves the MLSD listing passed in to each data
+// connection returning the port it is listening on
+func fakeDataServer(t *testing.T, listing string) (port int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(listing))
+			_ = conn.Close()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestConnectionCommands(t *testing.T) {
+	ctx := context.Background()
+	port := fakeDataServer(t, "type=file;size=5;modify=20200102030405;UNIX.mode=0640;UNIX.uid=1000;UNIX.gid=1000; file.txt\r\n")
+	addr, commands := fakeControlServer(t, map[string]string{
+		"USER":           "331 Password required",
+		"PASS":           "230 Logged in",
+		"FEAT":           "211-Features:\r\n HASH SHA-1*;MD5\r\n XCRC\r\n MLST type*;size*;modify*;UNIX.mode*;\r\n UTF8\r\n211 End",
+		"TYPE":           "200 Type set",
+		"NOOP":           "200 OK",
+		"OPTS UTF8 ON":   "200 OK",
+		"EPSV":           fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)", port),
+		"MLSD":           "150 Opening data connection\r\n226 Transfer complete",
+		"MLST file.txt":  "250-Listing file.txt\r\n type=file;size=5;modify=20200102030405;UNIX.mode=0640; file.txt\r\n250 End",
+		"OPTS HASH MD5":  "200 MD5",
+		"HASH file.txt":  "213 MD5 0-5 5D41402ABC4B2A76B9719D911017C592 file.txt",
+		"XCRC file.txt":  "250 3610A686",
+		"HASH other.txt": "550 File not found",
+	})
+	host, port2, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	fsInfo, err := fs.Find("ftp")
+	require.NoError(t, err)
+	m := fs.ConfigMap(fsInfo.Prefix, fsInfo.Options, "", configmap.Simple{
+		"host": host,
+		"port": port2,
+		"user": "user",
+		"pass": obscure.MustObscure("pass"),
+	})
+	fsys, err := NewFs(ctx, "TestFTP", "", m)
+	require.NoError(t, err)
+	f := fsys.(*Fs)
+	assert.Equal(t, hash.NewHashSet(hash.MD5, hash.SHA1, hash.CRC32), f.Hashes())
+	assert.True(t, f.Features().ReadMetadata)
+
+	// The metadata comes from the listing
+	wantMetadata := fs.Metadata{
+		"mode":  "100640",
+		"uid":   "1000",
+		"gid":   "1000",
+		"mtime": "2020-01-02T03:04:05Z",
+	}
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	o := entries[0].(*Object)
+	metadata, err := o.Metadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, wantMetadata, metadata)
+
+	sum, err := o.Hash(ctx, hash.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", sum)
+
+	// Second read should be cached
+	sum, err = o.Hash(ctx, hash.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", sum)
+
+	sum, err = o.Hash(ctx, hash.CRC32)
+	require.NoError(t, err)
+	assert.Equal(t, "3610a686", sum)
+
+	_, err = o.Hash(ctx, hash.SHA256)
+	assert.Equal(t, hash.ErrUnsupported, err)
+
+	_, err = f.readHash(ctx, hash.MD5, "other.txt")
+	assert.Error(t, err)
+
+	// An object not from a listing reads its metadata by listing
+	// its parent
+	o2, err := f.NewObject(ctx, "file.txt")
+	require.NoError(t, err)
+	metadata, err = o2.(*Object).Metadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, wantMetadata, metadata)
+
+	// Cleared metadata is read again
+	o.clearMetadata()
+	metadata, err = o.Metadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, wantMetadata, metadata)
+
+	// Check the commands sent used a single connection
+	require.NoError(t, f.Shutdown(ctx))
+	assert.Len(t, f.conns, 0)
+	var got []string
+	for command := range commands {
+		got = append(got, command)
+		if command == "QUIT" {
+			break
+		}
+	}
+	assert.Equal(t, []string{
+		"USER user",
+		"PASS pass",
+		"FEAT",
+		"TYPE I",
+		"OPTS UTF8 ON",
+		"EPSV",
+		"MLSD",
+		"OPTS HASH MD5",
+		"HASH file.txt",
+		"XCRC file.txt",
+		"HASH other.txt",
+		"NOOP",
+		"MLST file.txt",
+		"EPSV",
+		"MLSD .",
+		"EPSV",
+		"MLSD .",
+		"QUIT",
+	}, got)
+}
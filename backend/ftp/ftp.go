@@ -15,7 +15,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/jlaffaye/ftp"
+	"github.com/rclone/rclone/backend/ftp/internal/ftp"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/config"
@@ -48,6 +48,12 @@ func init() {
 		Name:        "ftp",
 		Description: "FTP",
 		NewFs:       NewFs,
+		MetadataInfo: &fs.MetadataInfo{
+			System: systemMetadataInfo,
+			Help: `FTP servers which support the MLSD command can return the
+owner, group and permissions of files in directory listings. These
+are read only.`,
+		},
 		Options: []fs.Option{{
 			Name:      "host",
 			Help:      "FTP host to connect to.\n\nE.g. \"ftp.example.com\".",
@@ -127,6 +133,16 @@ So for |concurrency 3| you'd use |--checkers 2 --transfers 2
 			Help:     "Use MDTM to set modification time (VsFtpd quirk)",
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name: "disable_hash_commands",
+			Help: `Disable using the server's hash commands.
+
+If the server advertises the HASH, XMD5, XSHA1, XSHA256 or XCRC
+commands in its FEAT response then rclone will use them to read the
+hashes of files. Set this to disable their use even if the server
+advertises support, for instance if they are too slow.`,
+			Default:  false,
+			Advanced: true,
 		}, {
 			Name:     "force_list_hidden",
 			Help:     "Use LIST -a to force listing of hidden files and folders. This will disable the use of MLSD.",
@@ -225,6 +241,7 @@ type Options struct {
 	DisableMLSD       bool                 `config:"disable_mlsd"`
 	DisableUTF8       bool                 `config:"disable_utf8"`
 	WritingMDTM       bool                 `config:"writing_mdtm"`
+	DisableHashCmds   bool                 `config:"disable_hash_commands"`
 	ForceListHidden   bool                 `config:"force_list_hidden"`
 	IdleTimeout       fs.Duration          `config:"idle_timeout"`
 	CloseTimeout      fs.Duration          `config:"close_timeout"`
@@ -247,12 +264,17 @@ type Fs struct {
 	dialAddr string
 	poolMu   sync.Mutex
 	pool     []*ftp.ServerConn
-	drain    *time.Timer // used to drain the pool when we stop using the connections
+	conns    map[*ftp.ServerConn]*connState // state of each open connection
+	drain    *time.Timer                    // used to drain the pool when we stop using the connections
 	tokens   *pacer.TokenDispenser
 	pacer    *fs.Pacer // pacer for FTP connections
 	fGetTime bool      // true if the ftp library accepts GetTime
 	fSetTime bool      // true if the ftp library accepts SetTime
 	fLstTime bool      // true if the List call returns precise time
+	fMLSD    bool      // true if listings are done with MLSD so have metadata
+	hashes   hash.Set  // hashes the server can calculate
+	// commands to read each hash type
+	hashCommands map[hash.Type]hashCommand
 }
 
 // Object describes an FTP file
@@ -260,15 +282,19 @@ type Object struct {
 	fs     *Fs
 	remote string
 	info   *FileInfo
+	hashMu sync.Mutex
+	hashes map[hash.Type]string // cached hashes read from the server
+	metaMu sync.Mutex           // protects info.metadata
 }
 
 // FileInfo is the metadata known about an FTP file
 type FileInfo struct {
-	Name    string
-	Size    uint64
-	ModTime time.Time
-	precise bool // true if the time is precise
-	IsDir   bool
+	Name     string
+	Size     uint64
+	ModTime  time.Time
+	precise  bool // true if the time is precise
+	IsDir    bool
+	metadata fs.Metadata // metadata from the MLSD facts if known
 }
 
 // ------------------------------------------------------------
@@ -477,9 +503,15 @@ func (f *Fs) ftpConnection(ctx context.Context) (c *ftp.ServerConn, err error) {
 		return false, nil
 	})
 	if err != nil {
-		err = fmt.Errorf("failed to make FTP connection to %q: %w", f.dialAddr, err)
+		return c, fmt.Errorf("failed to make FTP connection to %q: %w", f.dialAddr, err)
 	}
-	return c, err
+	f.poolMu.Lock()
+	if f.conns == nil {
+		f.conns = map[*ftp.ServerConn]*connState{}
+	}
+	f.conns[c] = &connState{}
+	f.poolMu.Unlock()
+	return c, nil
 }
 
 // Get an FTP connection from the pool, or open a new one
@@ -528,7 +560,7 @@ func (f *Fs) putFtpConnection(pc **ftp.ServerConn, err error) {
 			nopErr := c.NoOp()
 			if nopErr != nil {
 				fs.Debugf(f, "Connection failed, closing: %v", nopErr)
-				_ = c.Quit()
+				_ = f.quitFtpConnection(c)
 				return
 			}
 		}
@@ -552,6 +584,7 @@ func (f *Fs) drainPool(ctx context.Context) (err error) {
 		fs.Debugf(f, "closing %d unused connections", len(f.pool))
 	}
 	for i, c := range f.pool {
+		delete(f.conns, c)
 		if cErr := c.Quit(); cErr != nil {
 			err = cErr
 		}
@@ -613,6 +646,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (ff fs.Fs
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
 		PartialUploads:          true,
+		ReadMetadata:            true,
 	}).Fill(ctx, f)
 	// set the pool drainer timer going
 	if f.opt.IdleTimeout > 0 {
@@ -629,6 +663,21 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (ff fs.Fs
 	if !f.fLstTime && f.fGetTime {
 		f.features.SlowModTime = true
 	}
+	// The ftp library lists with MLSD if it is precise
+	f.fMLSD = f.fLstTime && !f.opt.ForceListHidden
+	if !f.fMLSD {
+		f.features.ReadMetadata = false
+	}
+	// Find the hash commands from the server features
+	if !f.opt.DisableHashCmds {
+		f.hashCommands = findHashCommands(c.Features())
+		for ht := range f.hashCommands {
+			f.hashes.Add(ht)
+		}
+	}
+	if f.hashes.Count() > 0 {
+		f.features.SlowHash = true
+	}
 	f.putFtpConnection(&c, nil)
 	if root != "" {
 		// Check to see if the root actually an existing file
@@ -866,10 +915,11 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 				remote: newremote,
 			}
 			info := &FileInfo{
-				Name:    newremote,
-				Size:    object.Size,
-				ModTime: object.Time,
-				precise: f.fLstTime,
+				Name:     newremote,
+				Size:     object.Size,
+				ModTime:  object.Time,
+				precise:  f.fLstTime,
+				metadata: parseFacts(object.Facts),
 			}
 			o.info = info
 			entries = append(entries, o)
@@ -878,9 +928,9 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 	return entries, nil
 }
 
-// Hashes are not supported
+// Hashes returns the hashes the server advertises it can calculate
 func (f *Fs) Hashes() hash.Set {
-	return 0
+	return f.hashes
 }
 
 // Precision shows whether modified time is supported or not depending on the
@@ -1110,7 +1160,51 @@ func (o *Object) Remote() string {
 
 // Hash returns the hash of an object returning a lowercase hex string
 func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
-	return "", hash.ErrUnsupported
+	if !o.fs.hashes.Contains(t) {
+		return "", hash.ErrUnsupported
+	}
+	o.hashMu.Lock()
+	defer o.hashMu.Unlock()
+	if sum, ok := o.hashes[t]; ok {
+		return sum, nil
+	}
+	sum, err := o.fs.readHash(ctx, t, o.objectPath())
+	if err != nil {
+		return "", err
+	}
+	if o.hashes == nil {
+		o.hashes = map[hash.Type]string{}
+	}
+	o.hashes[t] = sum
+	return sum, nil
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	if !o.fs.fMLSD {
+		return nil, nil
+	}
+	o.metaMu.Lock()
+	defer o.metaMu.Unlock()
+	if o.info.metadata == nil {
+		// Not listed so read it from a listing of the parent
+		o.info.metadata, err = o.readMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o.info.metadata, nil
+}
+
+// clearMetadata drops the cached metadata so it is read again
+func (o *Object) clearMetadata() {
+	o.metaMu.Lock()
+	if o.info != nil {
+		o.info.metadata = nil
+	}
+	o.metaMu.Unlock()
 }
 
 // Size returns the size of an object in bytes
@@ -1152,6 +1246,7 @@ func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
 	if err == nil && o.info != nil {
 		o.info.ModTime = modTime
 		o.info.precise = true
+		o.clearMetadata()
 	}
 	o.fs.putFtpConnection(&c, err)
 	return err
@@ -1203,7 +1298,7 @@ func (f *ftpReadCloser) Close() error {
 	}
 	// if errors while reading or closing, dump the connection
 	if err != nil || f.err != nil {
-		_ = f.c.Quit()
+		_ = f.f.quitFtpConnection(f.c)
 		f.f.putFtpConnection(nil, nil)
 	} else {
 		f.f.putFtpConnection(&f.c, nil)
@@ -1296,13 +1391,17 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		}
 	}
 	if err != nil {
-		_ = c.Quit() // toss this connection to avoid sync errors
+		_ = o.fs.quitFtpConnection(c) // toss this connection to avoid sync errors
 		// recycle connection in advance to let remove() find free token
 		o.fs.putFtpConnection(nil, err)
 		remove()
 		return fmt.Errorf("update stor: %w", err)
 	}
 	o.fs.putFtpConnection(&c, nil)
+	o.hashMu.Lock()
+	o.hashes = nil
+	o.hashMu.Unlock()
+	o.clearMetadata()
 	if err = o.SetModTime(ctx, src.ModTime(ctx)); err != nil {
 		return fmt.Errorf("SetModTime: %w", err)
 	}
@@ -1343,4 +1442,5 @@ var (
 	_ fs.PutStreamer = &Fs{}
 	_ fs.Shutdowner  = &Fs{}
 	_ fs.Object      = &Object{}
+	_ fs.Metadataer  = &Object{}
 )
@@ -0,0 +1,27 @@
+package ftp
+
+// This package is a copy of github.com/jlaffaye/ftp v0.2.0 with the
+// additions in this file, and the Facts in the Entry, which rclone
+// needs to read hashes and metadata from the server.
+
+import "strings"
+
+// Features returns the features the server advertised in response
+// to FEAT (RFC 2389) when logging in, keyed by the upper case
+// command name.
+func (c *ServerConn) Features() map[string]string {
+	features := make(map[string]string, len(c.features))
+	for command, desc := range c.features {
+		features[strings.ToUpper(command)] = desc
+	}
+	return features
+}
+
+// Cmd sends a command the library doesn't otherwise support on the
+// control connection and reads the response, checking it is the
+// expected code if expected is not -1.
+//
+// The command mustn't use a data connection.
+func (c *ServerConn) Cmd(expected int, format string, args ...interface{}) (code int, message string, err error) {
+	return c.cmd(expected, format, args...)
+}
@@ -10,6 +10,7 @@ import (
 	"os/user"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/colinmarc/hdfs/v2"
@@ -20,7 +21,9 @@ import (
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/lib/pacer"
+	"golang.org/x/sync/errgroup"
 )
 
 // Fs represents a HDFS server
@@ -126,6 +129,8 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
+		ReadMetadata:            true,
+		WriteMetadata:           true,
 	}).Fill(ctx, f)
 
 	info, err := f.client.Stat(f.realpath(""))
@@ -177,12 +182,28 @@ func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
 		return nil, err
 	}
 
+	return f.newObject(remote, info), nil
+}
+
+// newObject makes an Object from the remote and its info
+func (f *Fs) newObject(remote string, info os.FileInfo) *Object {
 	return &Object{
 		fs:      f,
 		remote:  remote,
 		size:    info.Size(),
 		modTime: info.ModTime(),
-	}, nil
+		info:    info,
+	}
+}
+
+// itemToDirEntry converts the info of an item in dir to a DirEntry
+func (f *Fs) itemToDirEntry(dir string, x os.FileInfo) fs.DirEntry {
+	stdName := f.opt.Enc.ToStandardName(x.Name())
+	remote := path.Join(dir, stdName)
+	if x.IsDir() {
+		return fs.NewDir(remote, x.ModTime())
+	}
+	return f.newObject(remote, x)
 }
 
 // List the objects and directories in dir into entries.
@@ -200,20 +221,83 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 		return nil, err
 	}
 	for _, x := range list {
-		stdName := f.opt.Enc.ToStandardName(x.Name())
-		remote := path.Join(dir, stdName)
-		if x.IsDir() {
-			entries = append(entries, fs.NewDir(remote, x.ModTime()))
-		} else {
-			entries = append(entries, &Object{
-				fs:      f,
-				remote:  remote,
-				size:    x.Size(),
-				modTime: x.ModTime(),
+		entries = append(entries, f.itemToDirEntry(dir, x))
+	}
+	return entries, nil
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// HDFS has no recursive listing call so this lists the directories
+// of each level of the tree in parallel using --checkers namenode
+// calls at once.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	realpath := f.realpath(dir)
+	fs.Debugf(f, "listR [%s]", realpath)
+
+	err = f.ensureDirectory(realpath)
+	if err != nil {
+		return err
+	}
+	return f.listR(ctx, dir, f.client.ReadDir, callback)
+}
+
+// listR lists dir recursively calling readDir to list each
+// directory level by level
+func (f *Fs) listR(ctx context.Context, dir string, readDir func(realpath string) ([]os.FileInfo, error), callback fs.ListRCallback) (err error) {
+	var (
+		mu   sync.Mutex // protect list and next
+		list = walk.NewListRHelper(callback)
+		dirs = []string{dir}
+	)
+	for len(dirs) > 0 {
+		var next []string
+		g, gCtx := errgroup.WithContext(ctx)
+		g.SetLimit(f.ci.Checkers)
+		for _, dir := range dirs {
+			dir := dir
+			g.Go(func() error {
+				if gCtx.Err() != nil {
+					return gCtx.Err()
+				}
+				items, err := readDir(f.realpath(dir))
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, x := range items {
+					entry := f.itemToDirEntry(dir, x)
+					if x.IsDir() {
+						next = append(next, entry.Remote())
+					}
+					err = list.Add(entry)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
 			})
 		}
+		err = g.Wait()
+		if err != nil {
+			return err
+		}
+		dirs = next
 	}
-	return entries, nil
+	return list.Flush()
 }
 
 // Put the object
@@ -314,12 +398,7 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	}
 
 	// And return it:
-	return &Object{
-		fs:      f,
-		remote:  remote,
-		size:    info.Size(),
-		modTime: info.ModTime(),
-	}, nil
+	return f.newObject(remote, info), nil
 }
 
 // DirMove moves src, srcRemote to this remote at dstRemote
@@ -365,16 +444,62 @@ func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string
 }
 
 // About gets quota information from the Fs
+//
+// If the root has a space quota set then this is reported as the
+// total with the space consumed under the root as used, otherwise
+// the capacity of the whole cluster is reported.
 func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
 	info, err := f.client.StatFs()
 	if err != nil {
 		return nil, err
 	}
-	return &fs.Usage{
+	summary, err := f.client.GetContentSummary(f.realpath(""))
+	if err != nil {
+		// The root may not exist yet
+		fs.Debugf(f, "Failed to read content summary: %v", err)
+		return f.usage(info, nil), nil
+	}
+	return f.usage(info, summary), nil
+}
+
+// contentSummary is the part of hdfs.ContentSummary used by About
+type contentSummary interface {
+	FileCount() int
+	DirectoryCount() int
+	NameQuota() int
+	SpaceQuota() int64
+	SizeAfterReplication() int64
+}
+
+// usage works out the usage from the cluster info and the content
+// summary of the root, which may be nil if it couldn't be read
+func (f *Fs) usage(info hdfs.FsInfo, summary contentSummary) *fs.Usage {
+	usage := &fs.Usage{
 		Total: fs.NewUsageValue(int64(info.Capacity)),
 		Used:  fs.NewUsageValue(int64(info.Used)),
 		Free:  fs.NewUsageValue(int64(info.Remaining)),
-	}, nil
+	}
+	if summary == nil {
+		return usage
+	}
+	usage.Objects = fs.NewUsageValue(int64(summary.FileCount()))
+	if quota := summary.SpaceQuota(); quota > 0 {
+		used := summary.SizeAfterReplication()
+		free := quota - used
+		if free < 0 {
+			free = 0
+		}
+		if free > int64(info.Remaining) {
+			free = int64(info.Remaining)
+		}
+		usage.Total = fs.NewUsageValue(quota)
+		usage.Used = fs.NewUsageValue(used)
+		usage.Free = fs.NewUsageValue(free)
+	}
+	if quota := summary.NameQuota(); quota > 0 {
+		fs.Debugf(f, "Name quota: %d files and directories of which %d used", quota, summary.FileCount()+summary.DirectoryCount())
+	}
+	return usage
 }
 
 func (f *Fs) ensureDirectory(realpath string) error {
@@ -421,4 +546,5 @@ var (
 	_ fs.Abouter     = (*Fs)(nil)
 	_ fs.Mover       = (*Fs)(nil)
 	_ fs.DirMover    = (*Fs)(nil)
+	_ fs.ListRer     = (*Fs)(nil)
 )
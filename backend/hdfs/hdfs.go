@@ -17,6 +17,12 @@ func init() {
 		Name:        "hdfs",
 		Description: "Hadoop distributed file system",
 		NewFs:       NewFs,
+		MetadataInfo: &fs.MetadataInfo{
+			System: systemMetadataInfo,
+			Help: `The owner, group, permissions and replication of files are
+stored as metadata. Setting the owner to a different user needs HDFS
+super user privileges.`,
+		},
 		Options: []fs.Option{{
 			Name:      "namenode",
 			Help:      "Hadoop name nodes and ports.\n\nE.g. \"namenode-1:8020,namenode-2:8020,...\" to connect to host namenodes at port 8020.",
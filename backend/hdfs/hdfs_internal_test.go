@@ -0,0 +1,154 @@
+//go:build !plan9
+
+package hdfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/colinmarc/hdfs/v2"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/encoder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFileInfo is an os.FileInfo for testing
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi fakeFileInfo) Name() string       { return fi.name }
+func (fi fakeFileInfo) Size() int64        { return fi.size }
+func (fi fakeFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi fakeFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fakeFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fakeFileInfo) Sys() interface{}   { return nil }
+
+var testModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestFs(root string) *Fs {
+	f := &Fs{
+		root: root,
+		ci:   fs.GetConfig(context.Background()),
+	}
+	f.opt.Enc = encoder.Display | encoder.EncodeColon
+	return f
+}
+
+func TestReadMetadata(t *testing.T) {
+	m := readMetadata(fakeFileInfo{name: "file", mode: 0640, modTime: testModTime})
+	assert.Equal(t, fs.Metadata{
+		"mode":  "100640",
+		"mtime": "2020-01-02T03:04:05Z",
+	}, m)
+
+	m = readMetadata(fakeFileInfo{name: "dir", mode: os.ModeDir | 0755, modTime: testModTime})
+	assert.Equal(t, fs.Metadata{
+		"mode":  "40755",
+		"mtime": "2020-01-02T03:04:05Z",
+	}, m)
+}
+
+func TestListR(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFs("root")
+	tree := map[string][]os.FileInfo{
+		"/root": {
+			fakeFileInfo{name: "a.txt", size: 1, mode: 0644, modTime: testModTime},
+			fakeFileInfo{name: "dir", mode: os.ModeDir | 0755, modTime: testModTime},
+		},
+		"/root/dir": {
+			fakeFileInfo{name: "b：c.txt", size: 2, mode: 0644, modTime: testModTime},
+			fakeFileInfo{name: "sub", mode: os.ModeDir | 0755, modTime: testModTime},
+		},
+		"/root/dir/sub": {},
+	}
+	readDir := func(realpath string) ([]os.FileInfo, error) {
+		items, ok := tree[realpath]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return items, nil
+	}
+
+	var got []string
+	err := f.listR(ctx, "", readDir, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			got = append(got, entry.Remote())
+			if o, ok := entry.(*Object); ok {
+				assert.Equal(t, testModTime, o.ModTime(ctx))
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(got)
+	assert.Equal(t, []string{"a.txt", "dir", "dir/b:c.txt", "dir/sub"}, got)
+
+	// Errors listing a directory are returned
+	delete(tree, "/root/dir/sub")
+	err = f.listR(ctx, "", readDir, func(entries fs.DirEntries) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, os.ErrNotExist), err)
+
+	// Errors from the callback are returned
+	tree["/root/dir/sub"] = nil
+	errStop := errors.New("stop")
+	err = f.listR(ctx, "dir", readDir, func(entries fs.DirEntries) error {
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+}
+
+// fakeContentSummary is a contentSummary for testing
+type fakeContentSummary struct {
+	files, dirs, nameQuota int
+	spaceQuota, spaceUsed  int64
+}
+
+func (cs fakeContentSummary) FileCount() int              { return cs.files }
+func (cs fakeContentSummary) DirectoryCount() int         { return cs.dirs }
+func (cs fakeContentSummary) NameQuota() int              { return cs.nameQuota }
+func (cs fakeContentSummary) SpaceQuota() int64           { return cs.spaceQuota }
+func (cs fakeContentSummary) SizeAfterReplication() int64 { return cs.spaceUsed }
+
+func TestUsage(t *testing.T) {
+	f := newTestFs("root")
+	info := hdfs.FsInfo{Capacity: 1000, Used: 300, Remaining: 700}
+
+	// Without a content summary the cluster is reported
+	usage := f.usage(info, nil)
+	assert.Equal(t, int64(1000), *usage.Total)
+	assert.Equal(t, int64(300), *usage.Used)
+	assert.Equal(t, int64(700), *usage.Free)
+	assert.Nil(t, usage.Objects)
+
+	// Without a quota the cluster is reported with the objects
+	usage = f.usage(info, fakeContentSummary{files: 5, dirs: 2, spaceUsed: 60})
+	assert.Equal(t, int64(1000), *usage.Total)
+	assert.Equal(t, int64(300), *usage.Used)
+	assert.Equal(t, int64(700), *usage.Free)
+	assert.Equal(t, int64(5), *usage.Objects)
+
+	// The space quota is reported instead if set
+	usage = f.usage(info, fakeContentSummary{files: 5, dirs: 2, nameQuota: 100, spaceQuota: 200, spaceUsed: 60})
+	assert.Equal(t, int64(200), *usage.Total)
+	assert.Equal(t, int64(60), *usage.Used)
+	assert.Equal(t, int64(140), *usage.Free)
+	assert.Equal(t, int64(5), *usage.Objects)
+
+	// The free space is limited by the cluster and can't go negative
+	usage = f.usage(info, fakeContentSummary{spaceQuota: 5000, spaceUsed: 60})
+	assert.Equal(t, int64(700), *usage.Free)
+	usage = f.usage(info, fakeContentSummary{spaceQuota: 50, spaceUsed: 60})
+	assert.Equal(t, int64(0), *usage.Free)
+}
@@ -0,0 +1,140 @@
+//go:build !plan9
+
+package hdfs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/colinmarc/hdfs/v2"
+	"github.com/rclone/rclone/fs"
+)
+
+const metadataTimeFormat = time.RFC3339Nano
+
+// system metadata keys which this backend uses
+var systemMetadataInfo = map[string]fs.MetadataHelp{
+	"mode": {
+		Help:    "File type and mode",
+		Type:    "octal, unix style",
+		Example: "0100644",
+	},
+	"owner": {
+		Help:    "User name of owner",
+		Type:    "string",
+		Example: "hdfs",
+	},
+	"group": {
+		Help:    "Group name of owner",
+		Type:    "string",
+		Example: "supergroup",
+	},
+	"replication": {
+		Help:    "Number of replicas of each block of the file",
+		Type:    "decimal number",
+		Example: "3",
+	},
+	"atime": {
+		Help:    "Time of last access",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999Z07:00",
+	},
+	"mtime": {
+		Help:    "Time of last modification",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999Z07:00",
+	},
+}
+
+// Read the metadata from the HDFS file info
+func readMetadata(info os.FileInfo) (m fs.Metadata) {
+	m = make(fs.Metadata, len(systemMetadataInfo))
+	mode := uint32(info.Mode().Perm())
+	if info.IsDir() {
+		mode |= 0040000
+	} else {
+		mode |= 0100000
+	}
+	m["mode"] = fmt.Sprintf("%0o", mode)
+	m["mtime"] = info.ModTime().Format(metadataTimeFormat)
+	fi, ok := info.(*hdfs.FileInfo)
+	if !ok {
+		return m
+	}
+	m["owner"] = fi.Owner()
+	m["group"] = fi.OwnerGroup()
+	m["atime"] = fi.AccessTime().Format(metadataTimeFormat)
+	if status, ok := fi.Sys().(*hdfs.FileStatus); ok && !fi.IsDir() {
+		m["replication"] = strconv.FormatUint(uint64(status.GetBlockReplication()), 10)
+	}
+	return m
+}
+
+// parse a time string from metadata with key
+func (o *Object) parseMetadataTime(m fs.Metadata, key string) (t time.Time, ok bool) {
+	value, ok := m[key]
+	if ok {
+		var err error
+		t, err = time.Parse(metadataTimeFormat, value)
+		if err != nil {
+			fs.Debugf(o, "failed to parse metadata %s: %q: %v", key, value, err)
+			ok = false
+		}
+	}
+	return t, ok
+}
+
+// parse an int from metadata with key and base
+func (o *Object) parseMetadataInt(m fs.Metadata, key string, base int) (result int, ok bool) {
+	value, ok := m[key]
+	if ok {
+		result64, err := strconv.ParseInt(value, base, 64)
+		if err != nil {
+			fs.Debugf(o, "failed to parse metadata %s: %q: %v", key, value, err)
+			ok = false
+		}
+		result = int(result64)
+	}
+	return result, ok
+}
+
+// Write the metadata to the file at realpath
+//
+// The replication is set when the file is created.
+func (o *Object) writeMetadata(realpath string, m fs.Metadata) (outErr error) {
+	atime, atimeOK := o.parseMetadataTime(m, "atime")
+	mtime, mtimeOK := o.parseMetadataTime(m, "mtime")
+	if atimeOK || mtimeOK {
+		if atimeOK && !mtimeOK {
+			mtime = o.modTime
+		}
+		if !atimeOK && mtimeOK {
+			atime = mtime
+		}
+		err := o.fs.client.Chtimes(realpath, atime, mtime)
+		if err != nil {
+			outErr = fmt.Errorf("failed to set times: %w", err)
+		} else {
+			o.modTime = mtime
+		}
+	}
+	owner, hasOwner := m["owner"]
+	group, hasGroup := m["group"]
+	if hasOwner || hasGroup {
+		// Changing the owner needs super user privileges
+		err := o.fs.client.Chown(realpath, owner, group)
+		if err != nil {
+			outErr = fmt.Errorf("failed to change ownership: %w", err)
+		}
+	}
+	mode, hasMode := o.parseMetadataInt(m, "mode", 8)
+	if hasMode {
+		err := o.fs.client.Chmod(realpath, os.FileMode(mode).Perm())
+		if err != nil {
+			outErr = fmt.Errorf("failed to change permissions: %w", err)
+		}
+	}
+	return outErr
+}
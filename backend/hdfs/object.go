@@ -5,7 +5,9 @@ package hdfs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"os"
 	"path"
 	"time"
 
@@ -21,6 +23,7 @@ type Object struct {
 	remote  string
 	size    int64
 	modTime time.Time
+	info    os.FileInfo // info from the namenode if known
 }
 
 // Fs returns the parent Fs
@@ -51,6 +54,7 @@ func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
 		return err
 	}
 	o.modTime = modTime
+	o.info = nil
 	return nil
 }
 
@@ -124,9 +128,27 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		}
 	}
 
-	out, err := o.fs.client.Create(realpath)
+	// Fetch metadata if --metadata is in use
+	meta, err := fs.GetMetadataOptions(ctx, o.fs, src, options)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to read metadata from source object: %w", err)
+	}
+
+	var out *hdfs.FileWriter
+	if replication, ok := o.parseMetadataInt(meta, "replication", 10); ok && replication > 0 {
+		defaults, err := o.fs.client.ServerDefaults()
+		if err != nil {
+			return err
+		}
+		out, err = o.fs.client.CreateFile(realpath, replication, defaults.BlockSize, 0644)
+		if err != nil {
+			return err
+		}
+	} else {
+		out, err = o.fs.client.Create(realpath)
+		if err != nil {
+			return err
+		}
 	}
 
 	cleanup := func() {
@@ -164,20 +186,41 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return err
 	}
 
-	info, err := o.fs.client.Stat(realpath)
+	err = o.SetModTime(ctx, src.ModTime(ctx))
 	if err != nil {
 		return err
 	}
 
-	err = o.SetModTime(ctx, src.ModTime(ctx))
+	if meta != nil {
+		err = o.writeMetadata(realpath, meta)
+		if err != nil {
+			return fmt.Errorf("failed to set metadata: %w", err)
+		}
+	}
+
+	info, err := o.fs.client.Stat(realpath)
 	if err != nil {
 		return err
 	}
 	o.size = info.Size()
+	o.info = info
 
 	return nil
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	if o.info == nil {
+		o.info, err = o.fs.client.Stat(o.realpath())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return readMetadata(o.info), nil
+}
+
 // Remove an object
 func (o *Object) Remove(ctx context.Context) error {
 	realpath := o.fs.realpath(o.remote)
@@ -191,5 +234,6 @@ func (o *Object) realpath() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Object = (*Object)(nil)
+	_ fs.Object     = (*Object)(nil)
+	_ fs.Metadataer = (*Object)(nil)
 )
@@ -2590,6 +2590,18 @@ knows about - please make a bug report if not.
 You can change this if you want to disable the use of multipart uploads.
 This shouldn't be necessary in normal operation.
 
+This should be automatically set correctly for all providers rclone
+knows about - please make a bug report if not.
+`,
+			Default:  fs.Tristate{},
+			Advanced: true,
+		}, {
+			Name: "use_multipart_copy",
+			Help: `Set if the provider supports copying parts of objects in multipart uploads.
+
+This is used by ` + "`--vfs-delta-upload-cutoff`" + ` to copy the parts of
+a file which haven't changed server-side with UploadPartCopy.
+
 This should be automatically set correctly for all providers rclone
 knows about - please make a bug report if not.
 `,
@@ -2725,6 +2737,7 @@ type Options struct {
 	NoSystemMetadata      bool                 `config:"no_system_metadata"`
 	UseAlreadyExists      fs.Tristate          `config:"use_already_exists"`
 	UseMultipartUploads   fs.Tristate          `config:"use_multipart_uploads"`
+	UseMultipartCopy      fs.Tristate          `config:"use_multipart_copy"`
 }
 
 // Fs represents a remote s3 server
@@ -3147,6 +3160,7 @@ func setQuirks(opt *Options) {
 		mightGzip             = true // assume all providers might use content encoding gzip until proven otherwise
 		useAlreadyExists      = true // Set if provider returns AlreadyOwnedByYou or no error if you try to remake your own bucket
 		useMultipartUploads   = true // Set if provider supports multipart uploads
+		useMultipartCopy      = true // Set if provider supports UploadPartCopy
 	)
 	switch opt.Provider {
 	case "AWS":
@@ -3223,6 +3237,7 @@ func setQuirks(opt *Options) {
 		urlEncodeListings = false
 		useMultipartEtag = false // untested
 		useAlreadyExists = false // untested
+		useMultipartCopy = false // untested
 	case "RackCorp":
 		// No quirks
 		useMultipartEtag = false // untested
@@ -3233,6 +3248,7 @@ func setQuirks(opt *Options) {
 		virtualHostStyle = false
 		useMultipartEtag = false
 		useAlreadyExists = false
+		useMultipartCopy = false
 		// useMultipartUploads = false - set this manually
 	case "Scaleway":
 		// Scaleway can only have 1000 parts in an upload
@@ -3247,6 +3263,7 @@ func setQuirks(opt *Options) {
 		urlEncodeListings = false
 		useMultipartEtag = false // untested
 		useAlreadyExists = false // untested
+		useMultipartCopy = false // untested
 	case "StackPath":
 		listObjectsV2 = false // untested
 		virtualHostStyle = false
@@ -3258,6 +3275,7 @@ func setQuirks(opt *Options) {
 			opt.ChunkSize = 64 * fs.Mebi
 		}
 		useAlreadyExists = false // returns BucketAlreadyExists
+		useMultipartCopy = false // UploadPartCopy not supported
 	case "Synology":
 		useMultipartEtag = false
 		useAlreadyExists = false // untested
@@ -3283,6 +3301,7 @@ func setQuirks(opt *Options) {
 		// See: https://issuetracker.google.com/issues/323465186
 		// So make cutoff very large which it does seem to support
 		opt.CopyCutoff = math.MaxInt64
+		useMultipartCopy = false
 	default:
 		fs.Logf("s3", "s3 provider %q not known - please set correctly", opt.Provider)
 		fallthrough
@@ -3292,6 +3311,7 @@ func setQuirks(opt *Options) {
 		urlEncodeListings = false
 		useMultipartEtag = false
 		useAlreadyExists = false
+		useMultipartCopy = false
 	}
 
 	// Path Style vs Virtual Host style
@@ -3347,6 +3367,12 @@ func setQuirks(opt *Options) {
 		opt.UploadCutoff = math.MaxInt64
 	}
 
+	// Set if the provider can copy parts if not manually set
+	if !opt.UseMultipartCopy.Valid {
+		opt.UseMultipartCopy.Valid = true
+		opt.UseMultipartCopy.Value = useMultipartCopy
+	}
+
 }
 
 // setRoot changes the root of the Fs
@@ -3441,16 +3467,17 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	}
 	f.setRoot(root)
 	f.features = (&fs.Features{
-		ReadMimeType:      true,
-		WriteMimeType:     true,
-		ReadMetadata:      true,
-		WriteMetadata:     true,
-		UserMetadata:      true,
-		BucketBased:       true,
-		BucketBasedRootOK: true,
-		SetTier:           true,
-		GetTier:           true,
-		SlowModTime:       true,
+		ReadMimeType:       true,
+		WriteMimeType:      true,
+		ReadMetadata:       true,
+		WriteMetadata:      true,
+		UserMetadata:       true,
+		BucketBased:        true,
+		BucketBasedRootOK:  true,
+		SetTier:            true,
+		GetTier:            true,
+		SlowModTime:        true,
+		ChunkWriterCanCopy: opt.UseMultipartCopy.Value,
 	}).Fill(ctx, f)
 	if opt.Provider == "Storj" {
 		f.features.SetTier = false
@@ -3466,6 +3493,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	if !opt.UseMultipartUploads.Value {
 		fs.Debugf(f, "Disabling multipart uploads")
 		f.features.OpenChunkWriter = nil
+		f.features.ChunkWriterCanCopy = false
 	}
 
 	if f.rootBucket != "" && f.rootDirectory != "" && !opt.NoHeadObject && !strings.HasSuffix(root, "/") {
@@ -5932,6 +5960,59 @@ func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader
 	return currentChunkSize, err
 }
 
+// CopyChunk will write chunk number by copying size bytes from offset
+// in src server-side, where chunk number >= 0
+func (w *s3ChunkWriter) CopyChunk(ctx context.Context, chunkNumber int, src fs.Object, offset, size int64) (int64, error) {
+	if chunkNumber < 0 {
+		err := fmt.Errorf("invalid chunk number provided: %v", chunkNumber)
+		return -1, err
+	}
+	srcObj, ok := src.(*Object)
+	if !ok {
+		return -1, fmt.Errorf("can't copy chunk from %v: not same remote type", src)
+	}
+	srcBucket, srcPath := srcObj.split()
+	source := pathEscape(bucket.Join(srcBucket, srcPath))
+	if srcObj.versionID != nil {
+		source += fmt.Sprintf("?versionId=%s", *srcObj.versionID)
+	}
+
+	// S3 requires 1 <= PartNumber <= 10000
+	s3PartNumber := aws.Int64(int64(chunkNumber + 1))
+	uploadPartReq := &s3.UploadPartCopyInput{
+		Bucket:                         w.bucket,
+		Key:                            w.key,
+		PartNumber:                     s3PartNumber,
+		UploadId:                       w.uploadID,
+		CopySource:                     &source,
+		CopySourceRange:                aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+size-1)),
+		CopySourceSSECustomerAlgorithm: w.multiPartUploadInput.SSECustomerAlgorithm,
+		CopySourceSSECustomerKey:       w.multiPartUploadInput.SSECustomerKey,
+		CopySourceSSECustomerKeyMD5:    w.multiPartUploadInput.SSECustomerKeyMD5,
+		RequestPayer:                   w.multiPartUploadInput.RequestPayer,
+		SSECustomerAlgorithm:           w.multiPartUploadInput.SSECustomerAlgorithm,
+		SSECustomerKey:                 w.multiPartUploadInput.SSECustomerKey,
+		SSECustomerKeyMD5:              w.multiPartUploadInput.SSECustomerKeyMD5,
+	}
+	var uout *s3.UploadPartCopyOutput
+	err := w.f.pacer.Call(func() (bool, error) {
+		var err error
+		uout, err = w.f.c.UploadPartCopyWithContext(ctx, uploadPartReq)
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to copy chunk %d with %v bytes: %w", chunkNumber+1, size, err)
+	}
+	if uout.CopyPartResult == nil || uout.CopyPartResult.ETag == nil {
+		return -1, fmt.Errorf("failed to copy chunk %d: no ETag returned", chunkNumber+1)
+	}
+
+	w.addCompletedPart(s3PartNumber, uout.CopyPartResult.ETag)
+
+	fs.Debugf(w.o, "multipart upload copied chunk %d with %v bytes and etag %v", chunkNumber+1, size, *uout.CopyPartResult.ETag)
+	return size, nil
+}
+
 // Abort the multipart upload
 func (w *s3ChunkWriter) Abort(ctx context.Context) error {
 	err := w.f.pacer.Call(func() (bool, error) {
@@ -6500,6 +6581,7 @@ var (
 	_ fs.Commander       = &Fs{}
 	_ fs.CleanUpper      = &Fs{}
 	_ fs.OpenChunkWriter = &Fs{}
+	_ fs.ChunkCopier     = &s3ChunkWriter{}
 	_ fs.Object          = &Object{}
 	_ fs.MimeTyper       = &Object{}
 	_ fs.GetTierer       = &Object{}
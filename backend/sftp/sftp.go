@@ -497,6 +497,20 @@ as the source and the destination will be the same file.
 
 This feature may be useful backups made with --copy-dest.`,
 			Advanced: true,
+		}, {
+			Name:    "disable_multi_thread_writes",
+			Default: false,
+			Help: `If set don't use multi-thread writes.
+
+Normally rclone will write large files to the sftp server in several
+chunks in parallel when doing multi-thread transfers (see
+--multi-thread-streams). This relies on the server supporting writes
+at arbitrary offsets within a file.
+
+Some sftp servers, for example gateways to object storage, only
+support writing files sequentially. Set this flag if uploads of large
+files fail to such a server.`,
+			Advanced: true,
 		}},
 	}
 	fs.Register(fsi)
@@ -504,42 +518,43 @@ This feature may be useful backups made with --copy-dest.`,
 
 // Options defines the configuration for this backend
 type Options struct {
-	Host                    string          `config:"host"`
-	User                    string          `config:"user"`
-	Port                    string          `config:"port"`
-	Pass                    string          `config:"pass"`
-	KeyPem                  string          `config:"key_pem"`
-	KeyFile                 string          `config:"key_file"`
-	KeyFilePass             string          `config:"key_file_pass"`
-	PubKeyFile              string          `config:"pubkey_file"`
-	KnownHostsFile          string          `config:"known_hosts_file"`
-	KeyUseAgent             bool            `config:"key_use_agent"`
-	UseInsecureCipher       bool            `config:"use_insecure_cipher"`
-	DisableHashCheck        bool            `config:"disable_hashcheck"`
-	AskPassword             bool            `config:"ask_password"`
-	PathOverride            string          `config:"path_override"`
-	SetModTime              bool            `config:"set_modtime"`
-	ShellType               string          `config:"shell_type"`
-	Md5sumCommand           string          `config:"md5sum_command"`
-	Sha1sumCommand          string          `config:"sha1sum_command"`
-	SkipLinks               bool            `config:"skip_links"`
-	Subsystem               string          `config:"subsystem"`
-	ServerCommand           string          `config:"server_command"`
-	UseFstat                bool            `config:"use_fstat"`
-	DisableConcurrentReads  bool            `config:"disable_concurrent_reads"`
-	DisableConcurrentWrites bool            `config:"disable_concurrent_writes"`
-	IdleTimeout             fs.Duration     `config:"idle_timeout"`
-	ChunkSize               fs.SizeSuffix   `config:"chunk_size"`
-	Concurrency             int             `config:"concurrency"`
-	Connections             int             `config:"connections"`
-	SetEnv                  fs.SpaceSepList `config:"set_env"`
-	Ciphers                 fs.SpaceSepList `config:"ciphers"`
-	KeyExchange             fs.SpaceSepList `config:"key_exchange"`
-	MACs                    fs.SpaceSepList `config:"macs"`
-	HostKeyAlgorithms       fs.SpaceSepList `config:"host_key_algorithms"`
-	SSH                     fs.SpaceSepList `config:"ssh"`
-	SocksProxy              string          `config:"socks_proxy"`
-	CopyIsHardlink          bool            `config:"copy_is_hardlink"`
+	Host                     string          `config:"host"`
+	User                     string          `config:"user"`
+	Port                     string          `config:"port"`
+	Pass                     string          `config:"pass"`
+	KeyPem                   string          `config:"key_pem"`
+	KeyFile                  string          `config:"key_file"`
+	KeyFilePass              string          `config:"key_file_pass"`
+	PubKeyFile               string          `config:"pubkey_file"`
+	KnownHostsFile           string          `config:"known_hosts_file"`
+	KeyUseAgent              bool            `config:"key_use_agent"`
+	UseInsecureCipher        bool            `config:"use_insecure_cipher"`
+	DisableHashCheck         bool            `config:"disable_hashcheck"`
+	AskPassword              bool            `config:"ask_password"`
+	PathOverride             string          `config:"path_override"`
+	SetModTime               bool            `config:"set_modtime"`
+	ShellType                string          `config:"shell_type"`
+	Md5sumCommand            string          `config:"md5sum_command"`
+	Sha1sumCommand           string          `config:"sha1sum_command"`
+	SkipLinks                bool            `config:"skip_links"`
+	Subsystem                string          `config:"subsystem"`
+	ServerCommand            string          `config:"server_command"`
+	UseFstat                 bool            `config:"use_fstat"`
+	DisableConcurrentReads   bool            `config:"disable_concurrent_reads"`
+	DisableConcurrentWrites  bool            `config:"disable_concurrent_writes"`
+	IdleTimeout              fs.Duration     `config:"idle_timeout"`
+	ChunkSize                fs.SizeSuffix   `config:"chunk_size"`
+	Concurrency              int             `config:"concurrency"`
+	Connections              int             `config:"connections"`
+	SetEnv                   fs.SpaceSepList `config:"set_env"`
+	Ciphers                  fs.SpaceSepList `config:"ciphers"`
+	KeyExchange              fs.SpaceSepList `config:"key_exchange"`
+	MACs                     fs.SpaceSepList `config:"macs"`
+	HostKeyAlgorithms        fs.SpaceSepList `config:"host_key_algorithms"`
+	SSH                      fs.SpaceSepList `config:"ssh"`
+	SocksProxy               string          `config:"socks_proxy"`
+	CopyIsHardlink           bool            `config:"copy_is_hardlink"`
+	DisableMultiThreadWrites bool            `config:"disable_multi_thread_writes"`
 }
 
 // Fs stores the interface to the remote SFTP files
@@ -1110,11 +1125,16 @@ func NewFsWithConnection(ctx context.Context, f *Fs, name string, root string, m
 		SlowHash:                 true,
 		PartialUploads:           true,
 		DirModTimeUpdatesOnWrite: true, // indicate writing files to a directory updates its modtime
+		ChunkWriterDoesntSeek:    true,
 	}).Fill(ctx, f)
 	if !opt.CopyIsHardlink {
 		// Disable server side copy unless --sftp-copy-is-hardlink is set
 		f.features.Copy = nil
 	}
+	if opt.DisableMultiThreadWrites {
+		f.features.OpenWriterAt = nil
+		f.features.OpenChunkWriter = nil
+	}
 	// Make a connection and pool it to return errors early
 	c, err := f.getSftpConnection(ctx)
 	if err != nil {
@@ -2216,6 +2236,171 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return nil
 }
 
+// writerAt represents a file open for random access writes on the
+// SFTP server
+type writerAt struct {
+	f        *Fs
+	c        *conn
+	sftpFile *sftp.File
+}
+
+// WriteAt writes len(p) bytes from p at offset off
+//
+// This is safe to call concurrently
+func (w *writerAt) WriteAt(p []byte, off int64) (n int, err error) {
+	return w.sftpFile.WriteAt(p, off)
+}
+
+// Close the file and return the connection to the pool
+func (w *writerAt) Close() (err error) {
+	err = w.sftpFile.Close()
+	w.f.putSftpConnection(&w.c, err)
+	w.f.removeSession()
+	return err
+}
+
+// OpenWriterAt opens with a handle for random access writes
+//
+// Pass in the remote desired and the size if known.
+//
+// It truncates any existing object
+func (f *Fs) OpenWriterAt(ctx context.Context, remote string, size int64) (fs.WriterAtCloser, error) {
+	err := f.mkParentDir(ctx, remote)
+	if err != nil {
+		return nil, fmt.Errorf("OpenWriterAt: failed to make parent directories: %w", err)
+	}
+	c, err := f.getSftpConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("OpenWriterAt: %w", err)
+	}
+	// Hang on to the connection until the writer is closed
+	sftpFile, err := c.sftpClient.OpenFile(f.remotePath(remote), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		f.putSftpConnection(&c, err)
+		return nil, fmt.Errorf("OpenWriterAt: failed to open: %w", err)
+	}
+	f.addSession() // Show session in use
+	return &writerAt{
+		f:        f,
+		c:        c,
+		sftpFile: sftpFile,
+	}, nil
+}
+
+// chunkWriter writes a file in chunks to the SFTP server, using a
+// separate connection for each chunk so chunks can be written in
+// parallel
+type chunkWriter struct {
+	o         *Object
+	src       fs.ObjectInfo
+	chunkSize int64
+}
+
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+// You can also use options to hint at the desired chunk size
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	chunkSize := int64(f.ci.MultiThreadChunkSize)
+	for _, option := range options {
+		if x, ok := option.(*fs.ChunkOption); ok && x.ChunkSize > 0 {
+			chunkSize = x.ChunkSize
+		}
+	}
+	err = f.mkParentDir(ctx, remote)
+	if err != nil {
+		return info, nil, fmt.Errorf("OpenChunkWriter: failed to make parent directories: %w", err)
+	}
+	// Create or truncate the file so the chunks can be written into it
+	c, err := f.getSftpConnection(ctx)
+	if err != nil {
+		return info, nil, fmt.Errorf("OpenChunkWriter: %w", err)
+	}
+	sftpFile, err := c.sftpClient.OpenFile(o.path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err == nil {
+		err = sftpFile.Close()
+	}
+	f.putSftpConnection(&c, err)
+	if err != nil {
+		return info, nil, fmt.Errorf("OpenChunkWriter: failed to create: %w", err)
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   chunkSize,
+		Concurrency: f.ci.MultiThreadStreams,
+	}
+	return info, &chunkWriter{
+		o:         o,
+		src:       src,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+// WriteChunk writes chunkNumber from reader
+func (w *chunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (n int64, err error) {
+	f := w.o.fs
+	f.addSession() // Show session in use
+	defer f.removeSession()
+	c, err := f.getSftpConnection(ctx)
+	if err != nil {
+		return -1, fmt.Errorf("WriteChunk: %w", err)
+	}
+	sftpFile, err := c.sftpClient.OpenFile(w.o.path(), os.O_WRONLY)
+	if err != nil {
+		f.putSftpConnection(&c, err)
+		return -1, fmt.Errorf("WriteChunk: failed to open: %w", err)
+	}
+	offset := int64(chunkNumber) * w.chunkSize
+	_, err = sftpFile.Seek(offset, io.SeekStart)
+	if err == nil {
+		n, err = sftpFile.ReadFrom(&sizeReader{Reader: reader, size: w.size(chunkNumber)})
+	}
+	closeErr := sftpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	f.putSftpConnection(&c, err)
+	if err != nil {
+		return -1, fmt.Errorf("WriteChunk: failed to write chunk %d: %w", chunkNumber, err)
+	}
+	return n, nil
+}
+
+// size returns the expected size of chunkNumber which is shorter
+// than the chunk size for the last chunk
+func (w *chunkWriter) size(chunkNumber int) int64 {
+	size := w.chunkSize
+	srcSize := w.src.Size()
+	if srcSize < 0 {
+		return size
+	}
+	remaining := srcSize - int64(chunkNumber)*w.chunkSize
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining < size {
+		size = remaining
+	}
+	return size
+}
+
+// Close completes the file, setting the modification time
+func (w *chunkWriter) Close(ctx context.Context) error {
+	err := w.o.SetModTime(ctx, w.src.ModTime(ctx))
+	if err != nil {
+		return fmt.Errorf("chunk writer SetModTime failed: %w", err)
+	}
+	return nil
+}
+
+// Abort removes the partially written file
+func (w *chunkWriter) Abort(ctx context.Context) error {
+	return w.o.Remove(ctx)
+}
+
 // Remove a remote sftp file object
 func (o *Object) Remove(ctx context.Context) error {
 	c, err := o.fs.getSftpConnection(ctx)
@@ -2229,13 +2414,15 @@ func (o *Object) Remove(ctx context.Context) error {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs             = &Fs{}
-	_ fs.PutStreamer    = &Fs{}
-	_ fs.Mover          = &Fs{}
-	_ fs.Copier         = &Fs{}
-	_ fs.DirMover       = &Fs{}
-	_ fs.DirSetModTimer = &Fs{}
-	_ fs.Abouter        = &Fs{}
-	_ fs.Shutdowner     = &Fs{}
-	_ fs.Object         = &Object{}
+	_ fs.Fs              = &Fs{}
+	_ fs.PutStreamer     = &Fs{}
+	_ fs.Mover           = &Fs{}
+	_ fs.Copier          = &Fs{}
+	_ fs.DirMover        = &Fs{}
+	_ fs.DirSetModTimer  = &Fs{}
+	_ fs.Abouter         = &Fs{}
+	_ fs.Shutdowner      = &Fs{}
+	_ fs.OpenWriterAter  = &Fs{}
+	_ fs.OpenChunkWriter = &Fs{}
+	_ fs.Object          = &Object{}
 )
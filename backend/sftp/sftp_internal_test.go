@@ -3,10 +3,21 @@
 package sftp
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/pkg/sftp"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShellEscapeUnix(t *testing.T) {
@@ -86,3 +97,107 @@ func TestParseUsage(t *testing.T) {
 		assert.Equal(t, test.usage, [3]int64{gotSpaceTotal, gotSpaceUsed, gotSpaceAvail}, fmt.Sprintf("Test %d sshOutput = %q", i, test.sshOutput))
 	}
 }
+
+// TestSFTPServerHelper serves SFTP on stdin and stdout so it can be
+// used as the ssh command by the tests below
+func TestSFTPServerHelper(t *testing.T) {
+	dir := os.Getenv("RCLONE_TEST_SFTP_SERVER_DIR")
+	if dir == "" {
+		t.Skip("only run as a helper for the sftp tests")
+	}
+	stdio := struct {
+		io.Reader
+		io.WriteCloser
+	}{os.Stdin, os.Stdout}
+	server, err := sftp.NewServer(stdio, sftp.WithServerWorkingDirectory(dir))
+	require.NoError(t, err)
+	err = server.Serve()
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+}
+
+// newTestFs makes an Fs on a temporary directory served by
+// TestSFTPServerHelper
+func newTestFs(t *testing.T) (f *Fs, dir string) {
+	dir = t.TempDir()
+	t.Setenv("RCLONE_TEST_SFTP_SERVER_DIR", dir)
+	fsInfo, err := fs.Find("sftp")
+	require.NoError(t, err)
+	m := fs.ConfigMap(fsInfo.Prefix, fsInfo.Options, "", configmap.Simple{
+		"ssh":             fmt.Sprintf("%q -test.run=^TestSFTPServerHelper$ --", os.Args[0]),
+		"shell_type":      "none",
+		"md5sum_command":  "none",
+		"sha1sum_command": "none",
+	})
+	fsys, err := NewFs(context.Background(), "TestSFTP", "", m)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, fsys.(*Fs).Shutdown(context.Background()))
+	})
+	return fsys.(*Fs), dir
+}
+
+func TestOpenWriterAt(t *testing.T) {
+	ctx := context.Background()
+	f, dir := newTestFs(t)
+
+	w, err := f.OpenWriterAt(ctx, "dir/file.txt", 11)
+	require.NoError(t, err)
+	_, err = w.WriteAt([]byte("world"), 6)
+	require.NoError(t, err)
+	_, err = w.WriteAt([]byte("hello "), 0)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "dir", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestOpenChunkWriter(t *testing.T) {
+	ctx := context.Background()
+	f, dir := newTestFs(t)
+
+	contents := []byte("0123456789abcdefghijKLMNO")
+	modTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	src := object.NewStaticObjectInfo("dir/file.txt", modTime, int64(len(contents)), true, nil, nil)
+	info, w, err := f.OpenChunkWriter(ctx, "dir/file.txt", src, &fs.ChunkOption{ChunkSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), info.ChunkSize)
+
+	// The last chunk is shorter than the others
+	cw := w.(*chunkWriter)
+	assert.Equal(t, int64(10), cw.size(0))
+	assert.Equal(t, int64(5), cw.size(2))
+	assert.Equal(t, int64(0), cw.size(3))
+
+	// Write the chunks out of order
+	for _, chunk := range []int{2, 0, 1} {
+		end := (chunk + 1) * 10
+		if end > len(contents) {
+			end = len(contents)
+		}
+		n, err := w.WriteChunk(ctx, chunk, bytes.NewReader(contents[chunk*10:end]))
+		require.NoError(t, err)
+		assert.Equal(t, int64(end-chunk*10), n)
+	}
+	require.NoError(t, w.Close(ctx))
+
+	osPath := filepath.Join(dir, "dir", "file.txt")
+	data, err := os.ReadFile(osPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(contents), string(data))
+	fi, err := os.Stat(osPath)
+	require.NoError(t, err)
+	assert.True(t, modTime.Equal(fi.ModTime()), fi.ModTime())
+
+	// Abort removes the file
+	_, w, err = f.OpenChunkWriter(ctx, "dir/aborted.txt", src, &fs.ChunkOption{ChunkSize: 10})
+	require.NoError(t, err)
+	_, err = w.WriteChunk(ctx, 0, bytes.NewReader(contents[:10]))
+	require.NoError(t, err)
+	require.NoError(t, w.Abort(ctx))
+	_, err = os.Stat(filepath.Join(dir, "dir", "aborted.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -141,10 +141,11 @@ type PropValue struct {
 //
 // </d:error>
 type Error struct {
-	Exception  string `xml:"exception,omitempty"`
-	Message    string `xml:"message,omitempty"`
-	Status     string
-	StatusCode int
+	Exception   string    `xml:"exception,omitempty"`
+	Message     string    `xml:"message,omitempty"`
+	FiniteDepth *struct{} `xml:"propfind-finite-depth"` // set if the DAV:propfind-finite-depth precondition failed
+	Status      string
+	StatusCode  int
 }
 
 // Error returns a string for the error and satisfies the error interface
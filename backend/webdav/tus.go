@@ -0,0 +1,260 @@
+package webdav
+
+/*
+	chunked update using the tus resumable upload protocol
+	as used by ownCloud Infinite Scale and other servers
+	see https://tus.io/protocols/resumable-upload
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+const tusVersion = "1.0.0" // version of the tus protocol we support
+
+func (o *Object) shouldUseTusUpload(src fs.ObjectInfo) bool {
+	return o.fs.canTus && o.fs.opt.ChunkSize > 0 && src.Size() > int64(o.fs.opt.ChunkSize)
+}
+
+func (o *Object) updateTus(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	// see https://tus.io/protocols/resumable-upload#creation
+	uploadURL, err := o.createTusUpload(ctx, src, options)
+	if err != nil {
+		return err
+	}
+
+	// see https://tus.io/protocols/resumable-upload#patch
+	err = o.uploadTusChunks(ctx, in, src.Size(), uploadURL)
+	if err != nil {
+		o.terminateTusUpload(ctx, uploadURL)
+		return err
+	}
+
+	// The mtime in the upload metadata is only a hint so set it
+	// explicitly if the server ignored it
+	if o.fs.propsetMtime {
+		o.hasMetaData = false
+		err = o.readMetaData(ctx)
+		if err != nil {
+			return fmt.Errorf("tus upload: failed to read metadata: %w", err)
+		}
+		modTime := src.ModTime(ctx)
+		if !o.modTime.Equal(modTime.Truncate(o.fs.precision)) {
+			err = o.SetModTime(ctx, modTime)
+			if err != nil {
+				return fmt.Errorf("tus upload: failed to set modtime: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// tusMetadata encodes the key value pairs passed in into the
+// Upload-Metadata header format
+func tusMetadata(kvs ...string) string {
+	var out []string
+	for i := 0; i+1 < len(kvs); i += 2 {
+		out = append(out, kvs[i]+" "+base64.StdEncoding.EncodeToString([]byte(kvs[i+1])))
+	}
+	return strings.Join(out, ",")
+}
+
+// createTusUpload creates the upload resource in the parent directory
+// of the object and returns its URL
+func (o *Object) createTusUpload(ctx context.Context, src fs.ObjectInfo, options []fs.OpenOption) (uploadURL string, err error) {
+	dir, leaf := path.Split(o.remote)
+	dirPath := o.fs.dirPath(dir)
+	leaf = o.fs.opt.Enc.FromStandardName(leaf)
+	size := int64(0)
+	opts := rest.Opts{
+		Method:        "POST",
+		Path:          dirPath,
+		NoResponse:    true,
+		ContentLength: &size,
+		Options:       options,
+		ExtraHeaders: map[string]string{
+			"Tus-Resumable":   tusVersion,
+			"Upload-Length":   strconv.FormatInt(src.Size(), 10),
+			"Upload-Metadata": tusMetadata("filename", leaf, "mtime", strconv.FormatInt(src.ModTime(ctx).Unix(), 10)),
+		},
+	}
+	if o.fs.useOCMtime {
+		opts.ExtraHeaders["X-OC-Mtime"] = fmt.Sprintf("%d", src.ModTime(ctx).Unix())
+	}
+	var resp *http.Response
+	err = o.fs.pacer.CallNoRetry(func() (bool, error) {
+		resp, err = o.fs.srv.Call(ctx, &opts)
+		return o.fs.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		return "", fmt.Errorf("tus upload: creating upload failed: %w", err)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("tus upload: no Location returned when creating upload")
+	}
+	// The Location may be relative to the URL we POSTed to
+	baseURL, err := rest.URLJoin(o.fs.endpoint, dirPath)
+	if err != nil {
+		return "", fmt.Errorf("tus upload: couldn't join URL: %w", err)
+	}
+	u, err := rest.URLJoin(baseURL, location)
+	if err != nil {
+		return "", fmt.Errorf("tus upload: couldn't join URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// readTusOffset reads how many bytes of the upload the server has
+func (o *Object) readTusOffset(ctx context.Context, uploadURL string) (offset int64, err error) {
+	opts := rest.Opts{
+		Method:     "HEAD",
+		RootURL:    uploadURL,
+		NoResponse: true,
+		ExtraHeaders: map[string]string{
+			"Tus-Resumable": tusVersion,
+		},
+	}
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		resp, err = o.fs.srv.Call(ctx, &opts)
+		return o.fs.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		return -1, fmt.Errorf("tus upload: failed to read upload offset: %w", err)
+	}
+	return parseTusOffset(resp)
+}
+
+// parseTusOffset reads the Upload-Offset header from resp
+func parseTusOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("tus upload: bad Upload-Offset header: %w", err)
+	}
+	return offset, nil
+}
+
+// uploadTusChunks sends the data in chunks with PATCH requests
+//
+// If sending a chunk fails, the server is asked how much of it
+// arrived and only the remainder is sent again.
+func (o *Object) uploadTusChunks(ctx context.Context, in io.Reader, size int64, uploadURL string) error {
+	chunkSize := int64(o.fs.opt.ChunkSize)
+	buf := make([]byte, chunkSize)
+	for offset := int64(0); offset < size; offset += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		contentLength := chunkSize
+
+		// Last chunk may be smaller
+		if size-offset < contentLength {
+			contentLength = size - offset
+		}
+
+		chunk := buf[:contentLength]
+		_, err := io.ReadFull(in, chunk)
+		if err != nil {
+			return fmt.Errorf("tus upload: failed to read chunk: %w", err)
+		}
+
+		err = o.uploadTusChunk(ctx, chunk, offset, uploadURL)
+		if err != nil {
+			return fmt.Errorf("tus upload: uploading chunk failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// uploadTusChunk sends chunk which starts at offset with PATCH
+// requests
+//
+// The PATCH is retried here rather than by the pacer so the server
+// can be asked how much of the chunk arrived between tries.
+func (o *Object) uploadTusChunk(ctx context.Context, chunk []byte, offset int64, uploadURL string) (err error) {
+	contentLength := int64(len(chunk))
+	sent := int64(0) // bytes of this chunk the server has
+	retries := fs.GetConfig(ctx).LowLevelRetries
+	for try := 1; ; try++ {
+		var retry bool
+		err = o.fs.pacer.CallNoRetry(func() (bool, error) {
+			remaining := contentLength - sent
+			opts := rest.Opts{
+				Method:        "PATCH",
+				RootURL:       uploadURL,
+				Body:          bytes.NewReader(chunk[sent:]),
+				ContentLength: &remaining,
+				ContentType:   "application/offset+octet-stream",
+				NoResponse:    true,
+				ExtraHeaders: map[string]string{
+					"Tus-Resumable": tusVersion,
+					"Upload-Offset": strconv.FormatInt(offset+sent, 10),
+				},
+			}
+			resp, err := o.fs.srv.Call(ctx, &opts)
+			if err == nil {
+				var newOffset int64
+				newOffset, err = parseTusOffset(resp)
+				if err == nil && newOffset != offset+contentLength {
+					err = fmt.Errorf("tus upload: server offset %d after chunk, expected %d", newOffset, offset+contentLength)
+				}
+			}
+			retry, err = o.fs.shouldRetry(ctx, resp, err)
+			return retry, err
+		})
+		if err == nil || !retry || try >= retries {
+			return err
+		}
+		fs.Debugf(o, "tus upload: retrying chunk (%d/%d): %v", try, retries, err)
+
+		// Find out how much of the chunk the server has
+		serverOffset, err := o.readTusOffset(ctx, uploadURL)
+		if err != nil {
+			return err
+		}
+		if serverOffset < offset || serverOffset > offset+contentLength {
+			return fmt.Errorf("tus upload: server offset %d outside chunk %d-%d", serverOffset, offset, offset+contentLength)
+		}
+		sent = serverOffset - offset
+		if sent == contentLength {
+			return nil
+		}
+		fs.Debugf(o, "tus upload: resuming chunk at offset %d", serverOffset)
+	}
+}
+
+// terminateTusUpload removes a failed upload from the server
+//
+// Not all servers support the termination extension so errors are
+// only logged.
+func (o *Object) terminateTusUpload(ctx context.Context, uploadURL string) {
+	opts := rest.Opts{
+		Method:     "DELETE",
+		RootURL:    uploadURL,
+		NoResponse: true,
+		ExtraHeaders: map[string]string{
+			"Tus-Resumable": tusVersion,
+		},
+	}
+	err := o.fs.pacer.Call(func() (bool, error) {
+		resp, err := o.fs.srv.Call(ctx, &opts)
+		return o.fs.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		fs.Debugf(o, "tus upload: failed to remove failed upload: %v", err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rclone/rclone/backend/webdav/api"
@@ -35,6 +36,7 @@ import (
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/pacer"
 	"github.com/rclone/rclone/lib/rest"
@@ -91,6 +93,9 @@ func init() {
 			}, {
 				Value: "sharepoint-ntlm",
 				Help:  "Sharepoint with NTLM authentication, usually self-hosted or on-premises",
+			}, {
+				Value: "infinitescale",
+				Help:  "ownCloud Infinite Scale",
 			}, {
 				Value: "rclone",
 				Help:  "rclone WebDAV server to serve a remote over HTTP via the WebDAV protocol",
@@ -142,6 +147,9 @@ You can set multiple headers, e.g. '"Cookie","name=value","Authorization","xxx"'
 			Name: "nextcloud_chunk_size",
 			Help: `Nextcloud upload chunk size.
 
+This is also used as the chunk size for servers supporting the tus
+resumable upload protocol, such as ownCloud Infinite Scale.
+
 We recommend configuring your NextCloud instance to increase the max chunk size to 1 GB for better upload performances.
 See https://docs.nextcloud.com/server/latest/admin_manual/configuration_files/big_file_upload_configuration.html#adjust-chunk-size-on-nextcloud-side
 
@@ -159,6 +167,27 @@ Set to 0 to disable chunked uploading.
 			Help:     "Exclude ownCloud mounted storages",
 			Advanced: true,
 			Default:  false,
+		}, {
+			Name:    "list_depth_infinity",
+			Default: false,
+			Help: `Use PROPFIND with Depth: infinity to implement recursive listings.
+
+If this flag is set the webdav backend will advertise ListR support
+for recursive listings and will read a whole directory tree with a
+single PROPFIND request with "Depth: infinity". This speeds up things
+like
+
+    rclone lsf -R webdav:
+    rclone size webdav:
+    rclone sync --fast-list webdav: /path/to/dir
+
+greatly on servers which allow it, for example ownCloud Infinite Scale.
+
+Many servers don't allow infinite depth listings as they can be
+expensive. If the server refuses the request then rclone will fall
+back to listing each directory in turn.
+`,
+			Advanced: true,
 		}},
 	})
 }
@@ -177,6 +206,7 @@ type Options struct {
 	ChunkSize          fs.SizeSuffix        `config:"nextcloud_chunk_size"`
 	ExcludeShares      bool                 `config:"owncloud_exclude_shares"`
 	ExcludeMounts      bool                 `config:"owncloud_exclude_mounts"`
+	ListDepthInfinity  bool                 `config:"list_depth_infinity"`
 }
 
 // Fs represents a remote webdav
@@ -201,6 +231,8 @@ type Fs struct {
 	ntlmAuthMu         sync.Mutex    // mutex to serialize NTLM auth roundtrips
 	chunksUploadURL    string        // upload URL for nextcloud chunked
 	canChunk           bool          // set if nextcloud and nextcloud_chunk_size is set
+	canTus             bool          // set if the server supports tus resumable uploads
+	noDepthInfinity    atomic.Bool   // set if the server refused a Depth: infinity PROPFIND
 }
 
 // Object describes a webdav object
@@ -479,6 +511,10 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
 	}).Fill(ctx, f)
+	// ListR only supported if list_depth_infinity set
+	if !opt.ListDepthInfinity {
+		f.features.ListR = nil
+	}
 	if opt.User != "" || opt.Pass != "" {
 		f.srv.SetUserPass(opt.User, opt.Pass)
 	} else if opt.BearerToken != "" {
@@ -659,11 +695,18 @@ func (f *Fs) setQuirks(ctx context.Context, vendor string) error {
 		// so we must perform an extra check to detect this
 		// condition and return a proper error code.
 		f.checkBeforePurge = true
+	case "infinitescale":
+		f.precision = time.Second
+		f.useOCMtime = true
+		f.propsetMtime = true
+		f.hasOCSHA1 = true
+		f.canTus = true
 	case "rclone":
 		f.canStream = true
 		f.precision = time.Second
 		f.useOCMtime = true
 	case "other":
+		f.detectCapabilities(ctx)
 	default:
 		fs.Debugf(f, "Unknown vendor %q", vendor)
 	}
@@ -675,6 +718,66 @@ func (f *Fs) setQuirks(ctx context.Context, vendor string) error {
 	return nil
 }
 
+// detectCapabilities reads the optional features the server supports
+// with an OPTIONS request and adjusts the Fs to match
+//
+// Errors are ignored as not all servers answer OPTIONS requests
+func (f *Fs) detectCapabilities(ctx context.Context) {
+	opts := rest.Opts{
+		Method:     "OPTIONS",
+		Path:       "",
+		NoResponse: true,
+	}
+	var resp *http.Response
+	err := f.pacer.CallNoRetry(func() (bool, error) {
+		var err error
+		resp, err = f.srv.Call(ctx, &opts)
+		return f.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		fs.Debugf(f, "Failed to read server capabilities: %v", err)
+		return
+	}
+	if dav := resp.Header.Get("DAV"); dav != "" {
+		fs.Debugf(f, "Server supports DAV compliance classes %q", dav)
+	}
+	// Only trust the Allow header if it looks like it came from a
+	// WebDAV server
+	allow := parseHeaderList(resp.Header.Values("Allow"))
+	if allow["PROPFIND"] {
+		if !allow["COPY"] {
+			fs.Debugf(f, "Server doesn't allow COPY - disabling server-side copy")
+			f.features.Copy = nil
+		}
+		if !allow["MOVE"] {
+			fs.Debugf(f, "Server doesn't allow MOVE - disabling server-side move")
+			f.features.Move = nil
+			f.features.DirMove = nil
+		}
+	}
+	tusVersions := parseHeaderList(resp.Header.Values("Tus-Version"))
+	tusExtensions := parseHeaderList(resp.Header.Values("Tus-Extension"))
+	if tusVersions[strings.ToUpper(tusVersion)] && tusExtensions["CREATION"] {
+		fs.Debugf(f, "Server supports tus resumable uploads")
+		f.canTus = true
+	}
+}
+
+// parseHeaderList parses comma separated HTTP header values into a
+// set of upper case tokens
+func parseHeaderList(values []string) map[string]bool {
+	tokens := map[string]bool{}
+	for _, value := range values {
+		for _, token := range strings.Split(value, ",") {
+			token = strings.ToUpper(strings.TrimSpace(token))
+			if token != "" {
+				tokens[token] = true
+			}
+		}
+	}
+	return tokens
+}
+
 // Return an Object from a path
 //
 // If it can't be found it returns the error fs.ErrorObjectNotFound.
@@ -843,19 +946,12 @@ func (f *Fs) listAll(ctx context.Context, dir string, directoriesOnly bool, file
 func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
 	var iErr error
 	_, err = f.listAll(ctx, dir, false, false, defaultDepth, func(remote string, isDir bool, info *api.Prop) bool {
-		if isDir {
-			d := fs.NewDir(remote, time.Time(info.Modified))
-			// .SetID(info.ID)
-			// FIXME more info from dir? can set size, items?
-			entries = append(entries, d)
-		} else {
-			o, err := f.newObjectWithInfo(ctx, remote, info)
-			if err != nil {
-				iErr = err
-				return true
-			}
-			entries = append(entries, o)
+		entry, err := f.itemToDirEntry(ctx, remote, isDir, info)
+		if err != nil {
+			iErr = err
+			return true
 		}
+		entries = append(entries, entry)
 		return false
 	})
 	if err != nil {
@@ -867,6 +963,99 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 	return entries, nil
 }
 
+// Convert a list item into a DirEntry
+func (f *Fs) itemToDirEntry(ctx context.Context, remote string, isDir bool, info *api.Prop) (fs.DirEntry, error) {
+	if isDir {
+		d := fs.NewDir(remote, time.Time(info.Modified))
+		// .SetID(info.ID)
+		// FIXME more info from dir? can set size, items?
+		return d, nil
+	}
+	return f.newObjectWithInfo(ctx, remote, info)
+}
+
+// isDepthInfinityRefused returns true if err shows the server
+// refused a PROPFIND with Depth: infinity
+//
+// RFC 4918 says servers should return 403 with a
+// propfind-finite-depth precondition, but some return 400 or 501.
+// Other 403 errors are permission errors so aren't counted.
+func isDepthInfinityRefused(err error) bool {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusNotImplemented:
+		return true
+	case http.StatusForbidden:
+		return apiErr.FiniteDepth != nil
+	}
+	return false
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	list := walk.NewListRHelper(callback)
+	var iErr error
+	addEntry := func(remote string, isDir bool, info *api.Prop) bool {
+		entry, err := f.itemToDirEntry(ctx, remote, isDir, info)
+		if err == nil {
+			err = list.Add(entry)
+		}
+		if err != nil {
+			iErr = err
+			return true
+		}
+		return false
+	}
+	if !f.noDepthInfinity.Load() {
+		_, err = f.listAll(ctx, dir, false, false, "infinity", addEntry)
+		if !isDepthInfinityRefused(err) {
+			if err != nil {
+				return err
+			}
+			if iErr != nil {
+				return iErr
+			}
+			return list.Flush()
+		}
+		fs.Logf(f, "Server refused PROPFIND with Depth: infinity so listing directories one at a time: %v", err)
+		f.noDepthInfinity.Store(true)
+	}
+	// Fall back to listing each directory in turn
+	dirs := []string{dir}
+	for len(dirs) > 0 {
+		dir := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		_, err = f.listAll(ctx, dir, false, false, defaultDepth, func(remote string, isDir bool, info *api.Prop) bool {
+			if isDir {
+				dirs = append(dirs, remote)
+			}
+			return addEntry(remote, isDir, info)
+		})
+		if err != nil {
+			return err
+		}
+		if iErr != nil {
+			return iErr
+		}
+	}
+	return list.Flush()
+}
+
 // Creates from the parameters passed in a half finished Object which
 // must have setMetaData called on it
 //
@@ -1200,6 +1389,7 @@ func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string
 		ExtraHeaders: map[string]string{
 			"Destination": addSlash(destinationURL.String()),
 			"Overwrite":   "T",
+			"Depth":       "infinity",
 		},
 	}
 	// Direct the MOVE/COPY to the source server
@@ -1470,7 +1660,13 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return fmt.Errorf("Update mkParentDir failed: %w", err)
 	}
 
-	if o.shouldUseChunkedUpload(src) {
+	if o.shouldUseTusUpload(src) {
+		fs.Debugf(src, "Update will use the tus chunked upload strategy")
+		err = o.updateTus(ctx, in, src, options...)
+		if err != nil {
+			return err
+		}
+	} else if o.shouldUseChunkedUpload(src) {
 		fs.Debugf(src, "Update will use the chunked upload strategy")
 		err = o.updateChunked(ctx, in, src, options...)
 		if err != nil {
@@ -1575,6 +1771,7 @@ var (
 	_ fs.Copier      = (*Fs)(nil)
 	_ fs.Mover       = (*Fs)(nil)
 	_ fs.DirMover    = (*Fs)(nil)
+	_ fs.ListRer     = (*Fs)(nil)
 	_ fs.Abouter     = (*Fs)(nil)
 	_ fs.Object      = (*Object)(nil)
 )
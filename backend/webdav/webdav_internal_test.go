@@ -1,19 +1,30 @@
 package webdav_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/backend/webdav"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configfile"
 	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/lib/random"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	xwebdav "golang.org/x/net/webdav"
 )
 
 var (
@@ -80,3 +91,286 @@ func TestHeaders(t *testing.T) {
 	_, err := f.Features().About(context.Background())
 	require.NoError(t, err)
 }
+
+// prepareDavServer starts an in memory WebDAV server, wrapping it in
+// wrap if set, and returns an Fs pointing at it
+func prepareDavServer(t *testing.T, wrap func(http.Handler) http.Handler, config configmap.Simple) fs.Fs {
+	var handler http.Handler = &xwebdav.Handler{
+		FileSystem: xwebdav.NewMemFS(),
+		LockSystem: xwebdav.NewMemLS(),
+	}
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	configfile.Install()
+	m := configmap.Simple{
+		"type":            "webdav",
+		"url":             ts.URL,
+		"pacer_min_sleep": "0",
+	}
+	for k, v := range config {
+		m[k] = v
+	}
+	f, err := webdav.NewFs(context.Background(), remoteName, "", m)
+	require.NoError(t, err)
+	return f
+}
+
+// putFiles uploads the files named and returns the sorted list of
+// all the paths created
+func putFiles(ctx context.Context, t *testing.T, f fs.Fs, names ...string) (paths []string) {
+	dirs := map[string]struct{}{}
+	for _, name := range names {
+		src := object.NewStaticObjectInfo(name, time.Now(), 5, true, nil, nil)
+		_, err := f.Put(ctx, strings.NewReader("hello"), src)
+		require.NoError(t, err)
+		paths = append(paths, name)
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+	for dir := range dirs {
+		paths = append(paths, dir+"/")
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// listRPaths lists f recursively with ListR
+func listRPaths(ctx context.Context, t *testing.T, f fs.Fs) (paths []string) {
+	require.NotNil(t, f.Features().ListR)
+	err := f.Features().ListR(ctx, "", func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			name := entry.Remote()
+			if _, ok := entry.(fs.Directory); ok {
+				name += "/"
+			}
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(paths)
+	return paths
+}
+
+var listRFiles = []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dir/sub/deeper/d.txt", "other/e.txt"}
+
+func TestListRDepthInfinity(t *testing.T) {
+	ctx := context.Background()
+	depths := map[string]int{}
+	var mu sync.Mutex
+	f := prepareDavServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PROPFIND" {
+				mu.Lock()
+				depths[r.Header.Get("Depth")]++
+				mu.Unlock()
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, configmap.Simple{"list_depth_infinity": "true"})
+	want := putFiles(ctx, t, f, listRFiles...)
+
+	depths = map[string]int{}
+	assert.Equal(t, want, listRPaths(ctx, t, f))
+	assert.Equal(t, map[string]int{"infinity": 1}, depths)
+}
+
+func TestListRDepthInfinityRefused(t *testing.T) {
+	ctx := context.Background()
+	refused := 0
+	f := prepareDavServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PROPFIND" && r.Header.Get("Depth") == "infinity" {
+				refused++
+				w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><D:error xmlns:D="DAV:"><D:propfind-finite-depth/></D:error>`)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, configmap.Simple{"list_depth_infinity": "true"})
+	want := putFiles(ctx, t, f, listRFiles...)
+
+	assert.Equal(t, want, listRPaths(ctx, t, f))
+	assert.Equal(t, want, listRPaths(ctx, t, f))
+	assert.Equal(t, 1, refused, "should only try Depth: infinity once")
+}
+
+// Check a permission error isn't mistaken for a refused Depth: infinity
+func TestListRDepthInfinityForbidden(t *testing.T) {
+	ctx := context.Background()
+	forbid := true
+	infinity := 0
+	f := prepareDavServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PROPFIND" && r.Header.Get("Depth") == "infinity" {
+				infinity++
+				if forbid {
+					http.Error(w, "permission denied", http.StatusForbidden)
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, configmap.Simple{"list_depth_infinity": "true"})
+	want := putFiles(ctx, t, f, listRFiles...)
+
+	err := f.Features().ListR(ctx, "", func(entries fs.DirEntries) error { return nil })
+	require.Error(t, err)
+	forbid = false
+	assert.Equal(t, want, listRPaths(ctx, t, f))
+	assert.Equal(t, 2, infinity, "should still use Depth: infinity")
+}
+
+func TestListRDisabled(t *testing.T) {
+	f := prepareDavServer(t, nil, nil)
+	assert.Nil(t, f.Features().ListR)
+}
+
+// tusServer wraps a WebDAV server adding a minimal tus implementation
+//
+// The first PATCH request fails after reading half of its body to
+// check that uploads are resumed.
+type tusServer struct {
+	t       *testing.T
+	dav     http.Handler
+	mu      sync.Mutex
+	uploads map[string]*tusUpload
+	failed  bool
+	patches int
+}
+
+type tusUpload struct {
+	path   string
+	length int64
+	data   []byte
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == "OPTIONS":
+		w.Header().Set("Tus-Resumable", "1.0.0")
+		w.Header().Set("Tus-Version", "1.0.0")
+		w.Header().Set("Tus-Extension", "creation,termination")
+		s.dav.ServeHTTP(w, r)
+	case r.Method == "POST":
+		assert.Equal(s.t, "1.0.0", r.Header.Get("Tus-Resumable"))
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		require.NoError(s.t, err)
+		var filename string
+		for _, kv := range strings.Split(r.Header.Get("Upload-Metadata"), ",") {
+			k, v, _ := strings.Cut(kv, " ")
+			if k == "filename" {
+				b, err := base64.StdEncoding.DecodeString(v)
+				require.NoError(s.t, err)
+				filename = string(b)
+			}
+		}
+		id := fmt.Sprintf("upload-%d", len(s.uploads))
+		s.uploads[id] = &tusUpload{
+			path:   path.Join(r.URL.Path, filename),
+			length: length,
+		}
+		w.Header().Set("Location", "/tus/"+id)
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(r.URL.Path, "/tus/"):
+		upload := s.uploads[strings.TrimPrefix(r.URL.Path, "/tus/")]
+		require.NotNil(s.t, upload)
+		switch r.Method {
+		case "HEAD":
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(upload.data)))
+		case "PATCH":
+			s.patches++
+			offset, err := strconv.Atoi(r.Header.Get("Upload-Offset"))
+			require.NoError(s.t, err)
+			if offset != len(upload.data) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if !s.failed {
+				s.failed = true
+				half := make([]byte, r.ContentLength/2)
+				_, err = io.ReadFull(r.Body, half)
+				require.NoError(s.t, err)
+				upload.data = append(upload.data, half...)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			data, err := io.ReadAll(r.Body)
+			require.NoError(s.t, err)
+			upload.data = append(upload.data, data...)
+			if int64(len(upload.data)) == upload.length {
+				// Upload complete so store it in the WebDAV server
+				req := httptest.NewRequest("PUT", upload.path, bytes.NewReader(upload.data))
+				s.dav.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(upload.data)))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		s.dav.ServeHTTP(w, r)
+	}
+}
+
+func TestTusUpload(t *testing.T) {
+	ctx := context.Background()
+	server := &tusServer{
+		t:       t,
+		uploads: map[string]*tusUpload{},
+	}
+	f := prepareDavServer(t, func(h http.Handler) http.Handler {
+		server.dav = h
+		return server
+	}, configmap.Simple{"nextcloud_chunk_size": "1k"})
+
+	contents := random.String(2500)
+	src := object.NewStaticObjectInfo("dir/file.txt", time.Now(), int64(len(contents)), true, nil, nil)
+	require.NoError(t, f.Mkdir(ctx, "dir"))
+	o, err := f.Put(ctx, strings.NewReader(contents), src)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), o.Size())
+	assert.Equal(t, 1, len(server.uploads))
+	assert.Equal(t, 4, server.patches, "3 chunks plus one resumed")
+
+	in, err := o.Open(ctx)
+	require.NoError(t, err)
+	got, err := io.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, contents, string(got))
+
+	// Small files should use a normal PUT
+	src = object.NewStaticObjectInfo("small.txt", time.Now(), 5, true, nil, nil)
+	_, err = f.Put(ctx, strings.NewReader("hello"), src)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(server.uploads))
+}
+
+func TestDetectCapabilitiesAllow(t *testing.T) {
+	f := prepareDavServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				w.Header().Set("Allow", "OPTIONS, GET, PUT, DELETE, PROPFIND, MKCOL")
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil)
+	assert.Nil(t, f.Features().Copy)
+	assert.Nil(t, f.Features().Move)
+	assert.Nil(t, f.Features().DirMove)
+
+	f = prepareDavServer(t, nil, nil)
+	assert.NotNil(t, f.Features().Copy)
+	assert.NotNil(t, f.Features().Move)
+	assert.NotNil(t, f.Features().DirMove)
+}
@@ -30,6 +30,7 @@ docs = [
     # Keep these alphabetical by full name
     "fichier.md",
     "alias.md",
+    "archive.md",
     "s3.md",
     "b2.md",
     "box.md",
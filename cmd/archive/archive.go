@@ -0,0 +1,85 @@
+// Package archive provides the archive command.
+package archive
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(Command)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "archive <action> [opts] <source> <destination>",
+	Short: `Create and extract archives.`,
+	Long: `Create and extract archive files on remotes.
+
+Select the action with the subcommand, e.g.
+
+    rclone archive create remote:path/to/dir remote2:backup/dir.tar.gz
+    rclone archive extract remote2:backup/dir.tar.gz remote:path/to/restore
+
+Each subcommand has its own options which you can see in their help.
+
+To read the files in a zip or tar archive without extracting them use
+the [archive](/archive/) backend.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+	},
+}
+
+// Format is a type of archive
+type Format string
+
+// Archive formats
+const (
+	Zip    Format = "zip"
+	Tar    Format = "tar"
+	TarGz  Format = "tar.gz"
+	TarZst Format = "tar.zst"
+)
+
+// extensions for each format, lower case
+var extensions = []struct {
+	extension string
+	format    Format
+}{
+	{".zip", Zip},
+	{".tar", Tar},
+	{".tar.gz", TarGz},
+	{".tgz", TarGz},
+	{".tar.zst", TarZst},
+	{".tzst", TarZst},
+}
+
+// FormatHelp is help for the --format flag
+const FormatHelp = "Archive format: zip, tar, tar.gz or tar.zst (default: from the file name)"
+
+// FindFormat returns the format to use for the archive name.
+//
+// If format is set it is checked and used, otherwise the format is
+// found from the extension of name.
+func FindFormat(name string, format string) (Format, error) {
+	if format != "" {
+		format = strings.TrimPrefix(strings.ToLower(format), ".")
+		for _, e := range extensions {
+			if "."+format == e.extension {
+				return e.format, nil
+			}
+		}
+		return "", fmt.Errorf("unknown archive format %q", format)
+	}
+	lowerName := strings.ToLower(name)
+	for _, e := range extensions {
+		if strings.HasSuffix(lowerName, e.extension) {
+			return e.format, nil
+		}
+	}
+	return "", fmt.Errorf("can't work out archive format from %q - use --format", name)
+}
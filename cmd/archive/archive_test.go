@@ -0,0 +1,36 @@
+package archive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindFormat(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		format  string
+		want    Format
+		wantErr bool
+	}{
+		{"file.zip", "", Zip, false},
+		{"FILE.TAR", "", Tar, false},
+		{"file.tar.gz", "", TarGz, false},
+		{"file.tgz", "", TarGz, false},
+		{"file.tar.zst", "", TarZst, false},
+		{"file.tzst", "", TarZst, false},
+		{"file.txt", "", "", true},
+		{"file.txt", "zip", Zip, false},
+		{"file.zip", ".tar.gz", TarGz, false},
+		{"file.zip", "rar", "", true},
+	} {
+		got, err := FindFormat(test.name, test.format)
+		if test.wantErr {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.want, got, test.name)
+	}
+}
@@ -0,0 +1,333 @@
+// Package create provides the archive create command.
+package create
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/archive"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	format = ""
+	prefix = ""
+)
+
+func init() {
+	archive.Command.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringVarP(cmdFlags, &format, "format", "", format, archive.FormatHelp, "")
+	flags.StringVarP(cmdFlags, &prefix, "prefix", "", prefix, "Directory to put the files in inside the archive", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "create [opts] source:path dest:path/to/archive",
+	Short: `Create an archive from a source and upload it to a destination.`,
+	Long: `
+Create an archive of the files in source:path and upload it to
+dest:path/to/archive.
+
+    rclone archive create drive:photos s3:backup/photos.zip
+
+The archive is streamed straight to the destination, in the same way
+as ` + "`rclone rcat`" + `, so nothing is stored on the local disk unless the
+destination doesn't support streaming uploads.
+
+The format of the archive is worked out from the extension of the
+destination file name:
+
+- ` + "`.zip`" + ` - zip with deflate compression
+- ` + "`.tar`" + ` - uncompressed tar
+- ` + "`.tar.gz`" + ` or ` + "`.tgz`" + ` - tar compressed with gzip
+- ` + "`.tar.zst`" + ` or ` + "`.tzst`" + ` - tar compressed with zstd
+
+Use ` + "`--format`" + ` to choose the format if the file name doesn't have one of
+these extensions.
+
+The usual [filters](/filtering/) can be used to choose which files
+are put into the archive. Use ` + "`--prefix`" + ` to put the files in a directory
+inside the archive rather than at its root.
+
+If ` + "`--metadata`" + ` is set then the permissions of the files are read from
+their metadata if available.
+
+Files with unknown sizes, such as Google Docs, can't be put into an
+archive and are skipped with an error.
+
+Note that the upload can't be retried because the archive isn't
+stored.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc := cmd.NewFsSrc(args[:1])
+		fdst, dstFileName := cmd.NewFsDstFile(args[1:])
+		archiveFormat, err := archive.FindFormat(dstFileName, format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmd.Run(false, true, command, func() error {
+			_, err := Create(context.Background(), fsrc, fdst, dstFileName, archiveFormat, prefix)
+			return err
+		})
+	},
+}
+
+// Create makes an archive of fsrc in format and uploads it to
+// dstFileName in fdst.
+//
+// The files are put in the directory prefix in the archive if it is
+// set.
+func Create(ctx context.Context, fsrc fs.Fs, fdst fs.Fs, dstFileName string, format archive.Format, prefix string) (dst fs.Object, err error) {
+	ci := fs.GetConfig(ctx)
+	if operations.SkipDestructive(ctx, dstFileName, "create archive") {
+		return nil, nil
+	}
+
+	// List the source using the filters
+	var entries fs.DirEntries
+	err = walk.ListR(ctx, fsrc, "", false, ci.MaxDepth, walk.ListAll, func(tranche fs.DirEntries) error {
+		entries = append(entries, tranche...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Remote() < entries[j].Remote()
+	})
+
+	// Write the archive into a pipe and upload it
+	pr, pw := io.Pipe()
+	go func() {
+		err := write(ctx, pw, format, prefix, entries)
+		_ = pw.CloseWithError(err)
+	}()
+	dst, err = operations.Rcat(ctx, fdst, dstFileName, pr, time.Now(), nil)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	return dst, nil
+}
+
+// header is the common information about an archive member
+type header struct {
+	name    string
+	size    int64
+	modTime time.Time
+	mode    int64
+	isDir   bool
+}
+
+// archiveWriter writes an archive member by member
+type archiveWriter interface {
+	// add a member - call write to copy the data if it is a file
+	add(h header, write func(out io.Writer) error) error
+	// finish the archive
+	close() error
+}
+
+// write the archive of entries in format to out
+func write(ctx context.Context, out io.Writer, format archive.Format, prefix string, entries fs.DirEntries) (err error) {
+	var (
+		aw         archiveWriter
+		compressor io.WriteCloser
+	)
+	switch format {
+	case archive.Zip:
+		aw = &zipWriter{zw: zip.NewWriter(out)}
+	case archive.Tar:
+		aw = &tarWriter{tw: tar.NewWriter(out)}
+	case archive.TarGz:
+		compressor = gzip.NewWriter(out)
+		aw = &tarWriter{tw: tar.NewWriter(compressor)}
+	case archive.TarZst:
+		compressor, err = zstd.NewWriter(out)
+		if err != nil {
+			return err
+		}
+		aw = &tarWriter{tw: tar.NewWriter(compressor)}
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+	var errCount int
+	for _, entry := range entries {
+		err = addEntry(ctx, aw, prefix, entry)
+		if errors.Is(err, errSkipped) {
+			errCount++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err = aw.close()
+	if err != nil {
+		return err
+	}
+	if compressor != nil {
+		err = compressor.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if errCount > 0 {
+		fs.Errorf(nil, "%d files could not be added to the archive", errCount)
+	}
+	return nil
+}
+
+// errSkipped is returned if an entry was skipped after logging an error
+var errSkipped = errors.New("skipped")
+
+// readMode reads the permissions from the metadata if --metadata is set
+func readMode(ctx context.Context, entry fs.DirEntry, defaultMode int64) int64 {
+	if !fs.GetConfig(ctx).Metadata {
+		return defaultMode
+	}
+	metadata, err := fs.GetMetadata(ctx, entry)
+	if err != nil {
+		fs.Debugf(entry, "Failed to read metadata: %v", err)
+		return defaultMode
+	}
+	mode, ok := metadata["mode"]
+	if !ok {
+		return defaultMode
+	}
+	m, err := strconv.ParseInt(mode, 8, 64)
+	if err != nil {
+		fs.Debugf(entry, "Failed to parse mode %q: %v", mode, err)
+		return defaultMode
+	}
+	return m & 07777
+}
+
+// add a single entry to the archive
+func addEntry(ctx context.Context, aw archiveWriter, prefix string, entry fs.DirEntry) (err error) {
+	h := header{
+		name:    path.Join(prefix, entry.Remote()),
+		modTime: entry.ModTime(ctx),
+	}
+	switch x := entry.(type) {
+	case fs.Directory:
+		h.isDir = true
+		h.mode = readMode(ctx, entry, 0755)
+		return aw.add(h, nil)
+	case fs.Object:
+		h.size = x.Size()
+		if h.size < 0 {
+			err = fs.CountError(errors.New("can't archive file of unknown size"))
+			fs.Errorf(x, "%v", err)
+			return errSkipped
+		}
+		h.mode = readMode(ctx, entry, 0644)
+		tr := accounting.Stats(ctx).NewTransfer(x, nil)
+		defer func() {
+			tr.Done(ctx, err)
+		}()
+		return aw.add(h, func(out io.Writer) (err error) {
+			in0, err := operations.NewReOpen(ctx, x, fs.GetConfig(ctx).LowLevelRetries)
+			if err != nil {
+				return fmt.Errorf("failed to open %q: %w", x.Remote(), err)
+			}
+			in := tr.Account(ctx, in0).WithBuffer() // account and buffer the transfer
+			defer fs.CheckClose(in, &err)
+			n, err := io.Copy(out, in)
+			if err != nil {
+				return fmt.Errorf("failed to read %q: %w", x.Remote(), err)
+			}
+			if n != h.size {
+				return fmt.Errorf("failed to read %q: size changed from %d to %d", x.Remote(), h.size, n)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// tarWriter writes tar archives
+type tarWriter struct {
+	tw *tar.Writer
+}
+
+func (t *tarWriter) add(h header, write func(out io.Writer) error) error {
+	hdr := &tar.Header{
+		Name:    h.name,
+		Size:    h.size,
+		Mode:    h.mode,
+		ModTime: h.modTime,
+	}
+	if h.isDir {
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	} else {
+		hdr.Typeflag = tar.TypeReg
+	}
+	err := t.tw.WriteHeader(hdr)
+	if err != nil {
+		return fmt.Errorf("failed to write tar header for %q: %w", h.name, err)
+	}
+	if write != nil {
+		return write(t.tw)
+	}
+	return nil
+}
+
+func (t *tarWriter) close() error {
+	return t.tw.Close()
+}
+
+// zipWriter writes zip archives
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) add(h header, write func(out io.Writer) error) error {
+	fh := &zip.FileHeader{
+		Name:               h.name,
+		Method:             zip.Deflate,
+		Modified:           h.modTime,
+		UncompressedSize64: uint64(h.size),
+	}
+	if h.isDir {
+		fh.Name += "/"
+		fh.Method = zip.Store
+		fh.SetMode(os.ModeDir | os.FileMode(h.mode))
+	} else {
+		fh.SetMode(os.FileMode(h.mode))
+	}
+	out, err := z.zw.CreateHeader(fh)
+	if err != nil {
+		return fmt.Errorf("failed to write zip header for %q: %w", h.name, err)
+	}
+	if write != nil {
+		return write(out)
+	}
+	return nil
+}
+
+func (z *zipWriter) close() error {
+	return z.zw.Close()
+}
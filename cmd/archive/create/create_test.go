@@ -0,0 +1,202 @@
+package create
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/archive"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2017-02-03T04:05:06Z")
+	t2 = fstest.Time("2019-07-08T09:10:12Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+// member is what is read back from an archive
+type member struct {
+	isDir   bool
+	content string
+	mode    int64
+	modTime time.Time
+}
+
+// readTar reads the members of a tar archive
+func readTar(t *testing.T, in io.Reader) map[string]member {
+	members := map[string]member{}
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		members[hdr.Name] = member{
+			isDir:   hdr.Typeflag == tar.TypeDir,
+			content: string(data),
+			mode:    hdr.Mode,
+			modTime: hdr.ModTime,
+		}
+	}
+	return members
+}
+
+// readZip reads the members of a zip archive
+func readZip(t *testing.T, data []byte) map[string]member {
+	members := map[string]member{}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	for _, zf := range zr.File {
+		in, err := zf.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		members[zf.Name] = member{
+			isDir:   zf.FileInfo().IsDir(),
+			content: string(content),
+			mode:    int64(zf.Mode().Perm()),
+			modTime: zf.Modified,
+		}
+	}
+	return members
+}
+
+// readArchive reads the members of the archive in data
+func readArchive(t *testing.T, data []byte, format archive.Format) map[string]member {
+	switch format {
+	case archive.Zip:
+		return readZip(t, data)
+	case archive.Tar:
+		return readTar(t, bytes.NewReader(data))
+	case archive.TarGz:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		return readTar(t, zr)
+	case archive.TarZst:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		defer zr.Close()
+		return readTar(t, zr)
+	}
+	t.Fatalf("unknown format %q", format)
+	return nil
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("hello.txt", "hello world", t1)
+	r.WriteFile("dir/sub/file2.txt", "the contents of file 2", t2)
+	r.WriteFile("empty.txt", "", t1)
+
+	for _, test := range []struct {
+		name   string
+		format archive.Format
+	}{
+		{"test.zip", archive.Zip},
+		{"test.tar", archive.Tar},
+		{"test.tar.gz", archive.TarGz},
+		{"test.tar.zst", archive.TarZst},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := accounting.WithStatsGroup(ctx, "create-"+test.name)
+			dst, err := Create(ctx, r.Flocal, r.Fremote, test.name, test.format, "backup")
+			require.NoError(t, err)
+			require.NotNil(t, dst)
+			assert.Equal(t, test.name, dst.Remote())
+
+			// The files read are shown as transferred along with
+			// the archive
+			stats := accounting.StatsGroup(ctx, "create-"+test.name)
+			assert.Equal(t, int64(4), stats.GetTransfers())
+			assert.Equal(t, int64(0), stats.GetChecks())
+
+			in, err := dst.Open(ctx)
+			require.NoError(t, err)
+			data, err := io.ReadAll(in)
+			require.NoError(t, err)
+			require.NoError(t, in.Close())
+			assert.Equal(t, int64(len(data)), dst.Size())
+
+			members := readArchive(t, data, test.format)
+			var names []string
+			for name := range members {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, []string{
+				"backup/dir/",
+				"backup/dir/sub/",
+				"backup/dir/sub/file2.txt",
+				"backup/empty.txt",
+				"backup/hello.txt",
+			}, names)
+
+			dir := members["backup/dir/"]
+			assert.True(t, dir.isDir)
+			assert.Equal(t, int64(0755), dir.mode)
+
+			for name, want := range map[string]struct {
+				content string
+				modTime time.Time
+			}{
+				"backup/hello.txt":         {"hello world", t1},
+				"backup/empty.txt":         {"", t1},
+				"backup/dir/sub/file2.txt": {"the contents of file 2", t2},
+			} {
+				got := members[name]
+				assert.False(t, got.isDir, name)
+				assert.Equal(t, want.content, got.content, name)
+				assert.Equal(t, int64(0644), got.mode, name)
+				fstest.AssertTimeEqualWithPrecision(t, name, want.modTime, got.modTime, 2*time.Second)
+			}
+		})
+	}
+}
+
+func TestCreateDryRun(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.DryRun = true
+	r := fstest.NewRun(t)
+	r.WriteFile("hello.txt", "hello world", t1)
+
+	dst, err := Create(ctx, r.Flocal, r.Fremote, "test.zip", archive.Zip, "")
+	require.NoError(t, err)
+	assert.Nil(t, dst)
+	r.CheckRemoteItems(t)
+}
+
+func TestWriteUnknownSize(t *testing.T) {
+	ctx := context.Background()
+	unknown := mockobject.New("unknown.txt").WithContent([]byte("unknown"), mockobject.SeekModeNone)
+	unknown.SetUnknownSize(true)
+	known := mockobject.New("known.txt").WithContent([]byte("known"), mockobject.SeekModeNone)
+
+	// Files of unknown size are skipped without failing the archive
+	var buf bytes.Buffer
+	err := write(ctx, &buf, archive.Tar, "", fs.DirEntries{known, unknown})
+	require.NoError(t, err)
+	members := readTar(t, &buf)
+	assert.Equal(t, 1, len(members))
+	assert.Equal(t, "known", members["known.txt"].content)
+}
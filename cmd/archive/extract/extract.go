@@ -0,0 +1,242 @@
+// Package extract provides the archive extract command.
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/archive"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	fssync "github.com/rclone/rclone/fs/sync"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	format = ""
+)
+
+func init() {
+	archive.Command.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringVarP(cmdFlags, &format, "format", "", format, archive.FormatHelp, "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "extract [opts] source:path/to/archive dest:path",
+	Short: `Extract an archive from a source to a destination.`,
+	Long: `
+Extract the files in the archive source:path/to/archive into
+dest:path.
+
+    rclone archive extract s3:backup/photos.zip drive:photos
+
+The format of the archive is worked out from the extension of the
+file name, or can be given with ` + "`--format`" + `. The formats supported
+are the same as ` + "`rclone archive create`" + `.
+
+Zip and tar archives are read with the [archive](/archive/) backend
+and copied to the destination as with ` + "`rclone copy`" + `, so files which
+are unchanged in the destination are skipped and ` + "`--transfers`" + ` files
+are uploaded in parallel.
+
+Compressed tar archives (` + "`.tar.gz`" + ` and ` + "`.tar.zst`" + `) can only be read
+from their start, so they are downloaded once and the files are
+uploaded as they are read. Files smaller than
+` + "`--streaming-upload-cutoff`" + ` are buffered in memory so up to
+` + "`--transfers`" + ` of them can be uploaded in parallel. All the files are
+uploaded, even if they exist in the destination already.
+
+The usual [filters](/filtering/) can be used to choose which files
+are extracted. Directories in the archive are created in the
+destination, even if they are empty. Symlinks and other special files
+in the archive are ignored.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, srcFileName := cmd.NewFsFile(args[0])
+		if srcFileName == "" {
+			log.Fatalf("%q is not a file", args[0])
+		}
+		fdst := cmd.NewFsDir(args[1:])
+		archiveFormat, err := archive.FindFormat(srcFileName, format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmd.Run(true, true, command, func() error {
+			return Extract(context.Background(), fdst, fsrc, srcFileName, archiveFormat)
+		})
+	},
+}
+
+// Extract the archive srcFileName in fsrc in format into fdst.
+func Extract(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, srcFileName string, format archive.Format) error {
+	// Zip and tar archives can be read with the archive backend if
+	// the file name has the right extension
+	if format == archive.Zip || format == archive.Tar {
+		if extFormat, _ := archive.FindFormat(srcFileName, ""); extFormat == format {
+			return extractWithBackend(ctx, fdst, fsrc, srcFileName)
+		}
+		if format == archive.Zip {
+			return fmt.Errorf("zip archive %q must have a .zip extension to be extracted", srcFileName)
+		}
+	}
+	src, err := fsrc.NewObject(ctx, srcFileName)
+	if err != nil {
+		return fmt.Errorf("failed to find archive: %w", err)
+	}
+	return extractStream(ctx, fdst, src, format)
+}
+
+// extractWithBackend copies the contents of the archive to fdst using
+// the archive backend.
+func extractWithBackend(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, srcFileName string) error {
+	archivePath := fs.ConfigString(fsrc)
+	if !strings.HasSuffix(archivePath, ":") {
+		archivePath += "/"
+	}
+	archivePath += srcFileName
+	farchive, err := cache.Get(ctx, ":archive:"+archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	return fssync.CopyDir(ctx, fdst, farchive, true)
+}
+
+// cleanName makes a name from an archive into a relative path
+// which can't escape the destination.
+func cleanName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+// extractStream reads the tar archive in src sequentially,
+// decompressing it if required, and uploads the files to fdst.
+func extractStream(ctx context.Context, fdst fs.Fs, src fs.Object, format archive.Format) (err error) {
+	ci := fs.GetConfig(ctx)
+	fi := filter.GetConfig(ctx)
+	in, err := operations.NewReOpen(ctx, src, ci.LowLevelRetries)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+
+	var r io.Reader = in
+	switch format {
+	case archive.Tar:
+	case archive.TarGz:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		defer fs.CheckClose(gz, &err)
+		r = gz
+	case archive.TarZst:
+		var zr *zstd.Decoder
+		zr, err = zstd.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to read zstd header: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	var (
+		g       errgroup.Group
+		mu      sync.Mutex
+		lastErr error
+		errs    int
+	)
+	g.SetLimit(ci.Transfers)
+	// record an error extracting name
+	recordErr := func(name string, err error) {
+		fs.Errorf(name, "Failed to extract: %v", err)
+		mu.Lock()
+		lastErr = err
+		errs++
+		mu.Unlock()
+	}
+	// upload a file, recording any errors
+	upload := func(name string, in io.ReadCloser, hdr *tar.Header) {
+		_, err := operations.RcatSize(ctx, fdst, name, in, hdr.Size, hdr.ModTime, nil)
+		if err != nil {
+			recordErr(name, err)
+		}
+	}
+	includeDir := fi.IncludeDirectory(ctx, fdst)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = g.Wait()
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		name := cleanName(hdr.Name)
+		if name != "" && hdr.Typeflag == tar.TypeDir {
+			// Make directories so empty ones are extracted too
+			include, err := includeDir(name)
+			if err != nil {
+				recordErr(name, err)
+			} else if !include {
+				fs.Debugf(name, "Excluded from extract")
+			} else if err = operations.Mkdir(ctx, fdst, name); err != nil {
+				recordErr(name, err)
+			}
+			continue
+		}
+		if name == "" || hdr.Typeflag != tar.TypeReg {
+			fs.Debugf(src, "Ignoring %q", hdr.Name)
+			continue
+		}
+		if !fi.Include(name, hdr.Size, hdr.ModTime, nil) {
+			fs.Debugf(name, "Excluded from extract")
+			continue
+		}
+		if hdr.Size > int64(ci.StreamingUploadCutoff) {
+			// Upload large files directly from the archive
+			upload(name, io.NopCloser(tr), hdr)
+			continue
+		}
+		// Buffer small files so they can be uploaded in parallel
+		buf := make([]byte, hdr.Size)
+		_, err = io.ReadFull(tr, buf)
+		if err != nil {
+			_ = g.Wait()
+			return fmt.Errorf("failed to read %q from archive: %w", name, err)
+		}
+		g.Go(func() error {
+			upload(name, io.NopCloser(bytes.NewReader(buf)), hdr)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if errs > 0 {
+		return fmt.Errorf("failed to extract %d files: last error: %w", errs, lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package extract
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/archive"
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/archive"
+	"github.com/rclone/rclone/cmd/archive/create"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2017-02-03T04:05:06Z")
+	t2 = fstest.Time("2019-07-08T09:10:12Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestCleanName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"file.txt", "file.txt"},
+		{"./dir/file.txt", "dir/file.txt"},
+		{"/abs/file.txt", "abs/file.txt"},
+		{"../../etc/passwd", "etc/passwd"},
+		{"dir\\file.txt", "dir/file.txt"},
+	} {
+		assert.Equal(t, test.want, cleanName(test.in), test.in)
+	}
+}
+
+// Create an archive in each format then extract it and check the
+// files are the same.
+func TestCreateExtract(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("hello.txt", "hello world", t1)
+	file2 := r.WriteFile("dir/sub/file2.txt", "the contents of file 2", t2)
+	file3 := r.WriteFile("empty.txt", "", t1)
+	items := []fstest.Item{file1, file2, file3}
+	require.NoError(t, r.Flocal.Mkdir(ctx, "emptydir"))
+	dirs := []string{"dir", "dir/sub", "emptydir"}
+
+	for _, test := range []struct {
+		name   string
+		format archive.Format
+	}{
+		{"test.zip", archive.Zip},
+		{"test.tar", archive.Tar},
+		{"test.tar.gz", archive.TarGz},
+		{"test.tar.zst", archive.TarZst},
+		{"test.tar-no-extension", archive.Tar},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dst, err := create.Create(ctx, r.Flocal, r.Fremote, test.name, test.format, "")
+			require.NoError(t, err)
+			assert.Equal(t, test.name, dst.Remote())
+			assert.True(t, dst.Size() > 0)
+
+			fdst, err := fs.NewFs(ctx, filepath.Join(t.TempDir(), "out"))
+			require.NoError(t, err)
+			err = Extract(ctx, fdst, r.Fremote, test.name, test.format)
+			require.NoError(t, err)
+			fstest.CheckListingWithPrecision(t, fdst, items, dirs, 2*time.Second)
+		})
+	}
+
+	// Zip archives must have an extension
+	fdst, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+	err = Extract(ctx, fdst, r.Fremote, "test.tar-no-extension", archive.Zip)
+	assert.ErrorContains(t, err, "must have a .zip extension")
+}
+
+// Check the files are put in the prefix directory
+func TestCreatePrefix(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("hello.txt", "hello world", t1)
+
+	_, err := create.Create(ctx, r.Flocal, r.Fremote, "test.tar.gz", archive.TarGz, "backup/2024")
+	require.NoError(t, err)
+
+	fdst, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+	err = Extract(ctx, fdst, r.Fremote, "test.tar.gz", archive.TarGz)
+	require.NoError(t, err)
+	fstest.CheckListingWithPrecision(t, fdst, []fstest.Item{
+		fstest.NewItem("backup/2024/hello.txt", "hello world", t1),
+	}, nil, 2*time.Second)
+}
@@ -3,6 +3,7 @@ package check
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -28,6 +29,12 @@ var (
 	differ            = ""
 	errFile           = ""
 	checkFileHashType = ""
+	fixPolicy         = ""
+	fixSuffix         = ".conflict"
+	fixReport         = ""
+	fixBothWays       = false
+	metadataInclude   []string
+	metadataExclude   []string
 )
 
 func init() {
@@ -35,6 +42,12 @@ func init() {
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &download, "download", "", download, "Check by downloading rather than with hash", "")
 	flags.StringVarP(cmdFlags, &checkFileHashType, "checkfile", "C", checkFileHashType, "Treat source:path as a SUM file with hashes of given type", "")
+	flags.StringVarP(cmdFlags, &fixPolicy, "fix", "", fixPolicy, "Fix the differences found with this policy: source, newer, larger, keep-both or ask", "")
+	flags.StringVarP(cmdFlags, &fixSuffix, "fix-suffix", "", fixSuffix, "Suffix to add to destination files renamed by --fix keep-both", "")
+	flags.StringVarP(cmdFlags, &fixReport, "fix-report", "", fixReport, "Report what --fix did to each file to this file", "")
+	flags.BoolVarP(cmdFlags, &fixBothWays, "fix-both-ways", "", fixBothWays, "Allow --fix to copy files from the destination to the source", "")
+	flags.StringArrayVarP(cmdFlags, &metadataInclude, "metadata-include-key", "", metadataInclude, "Only compare this metadata key with --metadata (may be repeated)", "Metadata")
+	flags.StringArrayVarP(cmdFlags, &metadataExclude, "metadata-exclude-key", "", metadataExclude, "Don't compare this metadata key with --metadata (may be repeated)", "Metadata")
 	AddFlags(cmdFlags)
 }
 
@@ -72,6 +85,7 @@ you what happened to it. These are reminiscent of diff files.
 - |+ path| means path was missing on the destination, so only in the source
 - |* path| means path was present in source and destination but different.
 - |! path| means there was an error reading or hashing the source or dest.
+- |~ path: detail| follows a |* path| line to give more detail, for example which metadata keys differ.
 
 The default number of parallel checks is 8. See the [--checkers=N](/docs/#checkers-n)
 option for more information.
@@ -157,7 +171,27 @@ to check all the data.
 
 If you supply the |--checkfile HASH| flag with a valid hash name,
 the |source:path| must point to a text file in the SUM format.
-`, "|", "`") + FlagsHelp,
+
+### Metadata
+
+If you supply the |--metadata|/|-M| flag, then as well as the
+contents, the [metadata](/docs/#metadata) of each file which matches
+is compared. The source metadata is transformed in the same way as it
+would be when copied, so |--metadata-set| and |--metadata-mapper|
+should be given as they were for the copy. Only the keys in the source
+metadata are compared, so keys the destination adds don't count as
+differences. Times such as |mtime| are compared allowing for the
+modify window.
+
+Use |--metadata-include-key| to compare only the keys given, and
+|--metadata-exclude-key| to skip keys which can't be preserved between
+the backends, for example
+
+    rclone check -M --metadata-exclude-key btime --combined - s3:bucket /data
+
+Files whose metadata differs are reported as different with a |~| line
+for each key in the |--combined| output.
+`, "|", "`") + FlagsHelp + FixHelp,
 	Annotations: map[string]string{
 		"groups": "Filter,Listing,Check",
 	},
@@ -179,7 +213,7 @@ the |source:path| must point to a text file in the SUM format.
 			fsrc, fdst = cmd.NewFsSrcDst(args)
 		}
 
-		cmd.Run(false, true, command, func() error {
+		cmd.Run(false, true, command, func() (err error) {
 			opt, close, err := GetCheckOpt(fsrc, fdst)
 			if err != nil {
 				return err
@@ -187,19 +221,48 @@ the |source:path| must point to a text file in the SUM format.
 			defer close()
 
 			if checkFileHashType != "" {
+				if fixPolicy != "" {
+					return errors.New("can't use --fix with --checkfile")
+				}
 				return operations.CheckSum(context.Background(), fsrc, fsum, sumFile, hashType, opt, download)
 			}
 
+			opt.CheckMetadata = fs.GetConfig(context.Background()).Metadata
+			opt.MetadataInclude = metadataInclude
+			opt.MetadataExclude = metadataExclude
+			checkFn := operations.Check
 			if download {
-				return operations.CheckDownload(context.Background(), opt)
-			}
-			hashType := fsrc.Hashes().Overlap(fdst.Hashes()).GetOne()
-			if hashType == hash.None {
-				fs.Errorf(nil, "No common hash found - not using a hash for checks")
+				checkFn = operations.CheckDownload
 			} else {
-				fs.Infof(nil, "Using %v for hash comparisons", hashType)
+				hashType := fsrc.Hashes().Overlap(fdst.Hashes()).GetOne()
+				if hashType == hash.None {
+					fs.Errorf(nil, "No common hash found - not using a hash for checks")
+				} else {
+					fs.Infof(nil, "Using %v for hash comparisons", hashType)
+				}
+			}
+			if fixPolicy == "" {
+				return checkFn(context.Background(), opt)
+			}
+			fixOpt := FixOpt{
+				Policy:   fixPolicy,
+				Suffix:   fixSuffix,
+				BothWays: fixBothWays,
+			}
+			switch fixReport {
+			case "":
+			case "-":
+				fixOpt.Report = os.Stdout
+			default:
+				var out *os.File
+				out, err = os.Create(fixReport)
+				if err != nil {
+					return err
+				}
+				defer fs.CheckClose(out, &err)
+				fixOpt.Report = out
 			}
-			return operations.Check(context.Background(), opt)
+			return Fix(context.Background(), opt, checkFn, fixOpt)
 		})
 		return nil
 	},
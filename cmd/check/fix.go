@@ -0,0 +1,399 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// Policies for --fix
+const (
+	FixSource   = "source"    // the source version wins
+	FixNewer    = "newer"     // the newer version wins
+	FixLarger   = "larger"    // the larger version wins
+	FixKeepBoth = "keep-both" // rename the destination version and copy the source version
+	FixAsk      = "ask"       // ask the user what to do with each file
+)
+
+// FixHelp describes the --fix flag
+var FixHelp = strings.ReplaceAll(`
+If you supply the |--fix POLICY| flag then, after checking, rclone
+will fix the differences it found. Files which are only in the source
+are copied to the destination. Files which are different are dealt
+with according to POLICY:
+
+- |source| - copy the source file over the destination file
+- |newer| - copy the newer file over the older one
+- |larger| - copy the larger file over the smaller one
+- |keep-both| - rename the destination file by adding |--fix-suffix| before its extension, then copy the source file to the destination
+- |ask| - ask what to do for each file, including the missing ones
+
+The source is only written to if |--fix-both-ways| is set or the
+policy is |ask|. Then files which are only in the destination are
+copied to the source, and |newer| and |larger| copy the destination
+file to the source if it wins. Otherwise these are skipped. With
+|--one-way| the source is never written to, even with |ask|.
+
+Files which can't be decided by |newer| or |larger| because their
+modification times or sizes are the same are skipped.
+
+If the renamed name for |keep-both| is in use then a number is added
+after the suffix, e.g. |file.conflict-1.txt|.
+
+The |--fix-report| flag writes what was done to each file to a file
+(or stdout if it is |-|), one per line, as the action followed by a
+space and the path.
+
+Use |--dry-run| to see what would be done, or |--interactive| to
+confirm each copy. With |--dry-run| the actions in the report are
+prefixed with |dry-run-|, e.g. |dry-run-copied-to-dst|.
+
+The differences found count as errors in the exit code of rclone
+even if they were all fixed. Run |rclone check| again to confirm the
+remotes now match.
+`, "|", "`")
+
+// fixReport describes what was done to fix a file
+const (
+	fixCopiedToDst = "copied-to-dst"
+	fixCopiedToSrc = "copied-to-src"
+	fixKeptBoth    = "kept-both"
+	fixDeletedSrc  = "deleted-from-src"
+	fixDeletedDst  = "deleted-from-dst"
+	fixSkipped     = "skipped"
+	fixDryRun      = "dry-run-" // prefix for actions not done because of --dry-run
+)
+
+// FixOpt are the options for Fix
+type FixOpt struct {
+	Policy   string    // one of the Fix constants
+	Suffix   string    // suffix for FixKeepBoth
+	Report   io.Writer // if set, report what was done here
+	BothWays bool      // allow the source to be written to
+}
+
+// fixer fixes the differences found by check
+type fixer struct {
+	fsrc, fdst fs.Fs
+	oneWay     bool
+	dryRun     bool
+	opt        FixOpt
+	errCount   int // number of files which failed to be fixed
+	unresolved int // number of differences left as they are
+}
+
+// CheckFn is the signature of the check functions in operations
+type CheckFn func(ctx context.Context, opt *operations.CheckOpt) error
+
+// Fix runs check with the options in checkOpt then fixes the
+// differences found according to opt.
+//
+// It returns nil if all the differences were fixed.
+func Fix(ctx context.Context, checkOpt *operations.CheckOpt, check CheckFn, opt FixOpt) error {
+	switch opt.Policy {
+	case FixSource, FixNewer, FixLarger, FixKeepBoth, FixAsk:
+	default:
+		return fmt.Errorf("unknown --fix policy %q - must be one of %s, %s, %s, %s or %s", opt.Policy, FixSource, FixNewer, FixLarger, FixKeepBoth, FixAsk)
+	}
+	if opt.BothWays && checkOpt.OneWay {
+		return errors.New("can't use --fix-both-ways with --one-way")
+	}
+
+	// Collect the files to fix from the check
+	var (
+		mu                                 sync.Mutex
+		differ, missingOnSrc, missingOnDst []string
+		errored                            bool
+	)
+	checkOptCopy := *checkOpt
+	checkOptCopy.Callback = func(remote string, sigil rune) {
+		if checkOpt.Callback != nil {
+			checkOpt.Callback(remote, sigil)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch sigil {
+		case '*':
+			differ = append(differ, remote)
+		case '-':
+			missingOnSrc = append(missingOnSrc, remote)
+		case '+':
+			missingOnDst = append(missingOnDst, remote)
+		case '!':
+			errored = true
+		}
+	}
+	checkErr := check(ctx, &checkOptCopy)
+	sort.Strings(differ)
+	sort.Strings(missingOnSrc)
+	sort.Strings(missingOnDst)
+
+	f := &fixer{
+		fsrc:   checkOpt.Fsrc,
+		fdst:   checkOpt.Fdst,
+		oneWay: checkOpt.OneWay,
+		dryRun: fs.GetConfig(ctx).DryRun,
+		opt:    opt,
+	}
+	for _, remote := range missingOnDst {
+		f.fixMissing(ctx, remote, f.fsrc, f.fdst, fixCopiedToDst, fixDeletedSrc, f.canWriteSrc())
+	}
+	for _, remote := range missingOnSrc {
+		if !f.canWriteSrc() {
+			f.skip(remote)
+			continue
+		}
+		f.fixMissing(ctx, remote, f.fdst, f.fsrc, fixCopiedToSrc, fixDeletedDst, true)
+	}
+	for _, remote := range differ {
+		f.fixDiffer(ctx, remote)
+	}
+
+	if f.errCount > 0 {
+		return fmt.Errorf("failed to fix %d files", f.errCount)
+	}
+	if checkErr != nil && (errored || f.unresolved > 0) {
+		return checkErr
+	}
+	if checkErr != nil {
+		fs.Logf(nil, "All differences fixed")
+	}
+	return nil
+}
+
+// canWriteSrc returns true if the fixes may write to the source
+func (f *fixer) canWriteSrc() bool {
+	return !f.oneWay && (f.opt.BothWays || f.opt.Policy == FixAsk)
+}
+
+// report what was done to remote
+//
+// With --dry-run actions other than skipping are prefixed with
+// fixDryRun as they weren't done.
+func (f *fixer) report(action, remote string) {
+	if f.dryRun && action != fixSkipped {
+		action = fixDryRun + action
+	}
+	fs.Infof(remote, "Fix: %s", action)
+	if f.opt.Report != nil {
+		operations.SyncFprintf(f.opt.Report, "%s %s\n", action, remote)
+	}
+}
+
+// skip records that remote was left as it is
+func (f *fixer) skip(remote string) {
+	f.report(fixSkipped, remote)
+	f.unresolved++
+}
+
+// countError logs and counts an error fixing remote
+func (f *fixer) countError(remote string, err error) {
+	fs.Errorf(remote, "Failed to fix: %v", err)
+	f.errCount++
+}
+
+// copy remote from fsrc to fdst overwriting the existing file if any
+func (f *fixer) copy(ctx context.Context, fdst, fsrc fs.Fs, remote string) error {
+	src, err := fsrc.NewObject(ctx, remote)
+	if err != nil {
+		return err
+	}
+	dst, err := fdst.NewObject(ctx, remote)
+	if errors.Is(err, fs.ErrorObjectNotFound) {
+		dst = nil
+	} else if err != nil {
+		return err
+	}
+	_, err = operations.Copy(ctx, fdst, dst, remote, src)
+	return err
+}
+
+// remove remote from fremote
+func (f *fixer) remove(ctx context.Context, fremote fs.Fs, remote string) error {
+	o, err := fremote.NewObject(ctx, remote)
+	if err != nil {
+		return err
+	}
+	return operations.DeleteFile(ctx, o)
+}
+
+// ask the user to choose one of choices about remote
+func ask(question, remote string, choices []string) byte {
+	// Lock the StdoutMutex - must not call fs.Log anything
+	// otherwise it will deadlock with --progress
+	operations.StdoutMutex.Lock()
+	fmt.Printf("\nrclone: %s %q\n", question, remote)
+	choice := config.CommandDefault(append(choices, "qExit rclone now."), len(choices)-1)
+	operations.StdoutMutex.Unlock()
+	if choice == 'q' {
+		fs.Logf(nil, "Quitting rclone now")
+		atexit.Run()
+		os.Exit(0)
+	}
+	return choice
+}
+
+// fixMissing fixes remote which is in fsrc but not fdst
+//
+// If canDelete is set then the user may choose to delete remote from
+// fsrc instead.
+func (f *fixer) fixMissing(ctx context.Context, remote string, fsrc, fdst fs.Fs, copied, deleted string, canDelete bool) {
+	doCopy := true
+	if f.opt.Policy == FixAsk {
+		choices := []string{fmt.Sprintf("cCopy to %v", fdst)}
+		if canDelete {
+			choices = append(choices, fmt.Sprintf("dDelete from %v", fsrc))
+		}
+		choices = append(choices, "kKeep as it is")
+		switch ask(fmt.Sprintf("only in %v:", fsrc), remote, choices) {
+		case 'd':
+			err := f.remove(ctx, fsrc, remote)
+			if err != nil {
+				f.countError(remote, err)
+				return
+			}
+			f.report(deleted, remote)
+			return
+		case 'k':
+			doCopy = false
+		}
+	}
+	if !doCopy {
+		f.skip(remote)
+		return
+	}
+	err := f.copy(ctx, fdst, fsrc, remote)
+	if err != nil {
+		f.countError(remote, err)
+		return
+	}
+	f.report(copied, remote)
+}
+
+// keepBothName returns the name the destination file is renamed to
+// for FixKeepBoth, adding a number if the name is in use.
+func (f *fixer) keepBothName(ctx context.Context, remote string) (string, error) {
+	ext := path.Ext(remote)
+	if ext == path.Base(remote) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(remote, ext) + f.opt.Suffix
+	for i := 0; ; i++ {
+		newName := base + ext
+		if i > 0 {
+			newName = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		_, err := f.fdst.NewObject(ctx, newName)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			return newName, nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to check %q is free: %w", newName, err)
+		}
+	}
+}
+
+// fixDiffer fixes remote which is different in fsrc and fdst
+func (f *fixer) fixDiffer(ctx context.Context, remote string) {
+	src, err := f.fsrc.NewObject(ctx, remote)
+	if err != nil {
+		f.countError(remote, err)
+		return
+	}
+	dst, err := f.fdst.NewObject(ctx, remote)
+	if err != nil {
+		f.countError(remote, err)
+		return
+	}
+
+	// Decide what to do: 's' source wins, 'd' destination wins,
+	// 'b' keep both or 'k' keep as is
+	var action byte
+	switch f.opt.Policy {
+	case FixSource:
+		action = 's'
+	case FixKeepBoth:
+		action = 'b'
+	case FixNewer:
+		srcTime, dstTime := src.ModTime(ctx), dst.ModTime(ctx)
+		dt := srcTime.Sub(dstTime)
+		window := fs.GetModifyWindow(ctx, f.fsrc, f.fdst)
+		switch {
+		case dt >= window:
+			action = 's'
+		case dt <= -window && f.canWriteSrc():
+			action = 'd'
+		case dt <= -window:
+			fs.Logf(remote, "Fix: destination file is newer - use --fix-both-ways to copy it to the source")
+			action = 'k'
+		default:
+			fs.Logf(remote, "Fix: can't choose newer file as modification times are the same")
+			action = 'k'
+		}
+	case FixLarger:
+		switch {
+		case src.Size() > dst.Size():
+			action = 's'
+		case src.Size() < dst.Size() && f.canWriteSrc():
+			action = 'd'
+		case src.Size() < dst.Size():
+			fs.Logf(remote, "Fix: destination file is larger - use --fix-both-ways to copy it to the source")
+			action = 'k'
+		default:
+			fs.Logf(remote, "Fix: can't choose larger file as sizes are the same")
+			action = 'k'
+		}
+	case FixAsk:
+		choices := []string{
+			fmt.Sprintf("sUse source version (size %v, modified %v)", fs.SizeSuffix(src.Size()), src.ModTime(ctx).Format("2006-01-02 15:04:05")),
+		}
+		if f.canWriteSrc() {
+			choices = append(choices, fmt.Sprintf("dUse destination version (size %v, modified %v)", fs.SizeSuffix(dst.Size()), dst.ModTime(ctx).Format("2006-01-02 15:04:05")))
+		}
+		choices = append(choices,
+			fmt.Sprintf("bKeep both, renaming the destination version with suffix %q", f.opt.Suffix),
+			"kKeep both as they are",
+		)
+		action = ask("differs:", remote, choices)
+	}
+
+	switch action {
+	case 's':
+		_, err = operations.Copy(ctx, f.fdst, dst, remote, src)
+		if err == nil {
+			f.report(fixCopiedToDst, remote)
+		}
+	case 'd':
+		_, err = operations.Copy(ctx, f.fsrc, src, remote, dst)
+		if err == nil {
+			f.report(fixCopiedToSrc, remote)
+		}
+	case 'b':
+		var newName string
+		newName, err = f.keepBothName(ctx, remote)
+		if err == nil {
+			_, err = operations.Move(ctx, f.fdst, nil, newName, dst)
+		}
+		if err == nil {
+			_, err = operations.Copy(ctx, f.fdst, nil, remote, src)
+		}
+		if err == nil {
+			f.report(fixKeptBoth, remote)
+		}
+	default:
+		f.skip(remote)
+	}
+	if err != nil {
+		f.countError(remote, err)
+	}
+}
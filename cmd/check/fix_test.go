@@ -0,0 +1,262 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestFix(t *testing.T) {
+	ctx := context.Background()
+	var (
+		srcUnchanged = []string{"both.txt=same", "newer-dst.txt=old src", "newer-src.txt=new source", "src-only.txt=src only"}
+		dstUnchanged = []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=new destination", "newer-src.txt=old"}
+		bothSynced   = []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=new destination", "newer-src.txt=new source", "src-only.txt=src only"}
+	)
+	for _, test := range []struct {
+		name       string
+		policy     string
+		oneWay     bool
+		bothWays   bool
+		wantErr    bool
+		wantSrc    []string
+		wantDst    []string
+		wantReport []string
+	}{
+		{
+			name:       "source",
+			policy:     FixSource,
+			wantErr:    true,
+			wantSrc:    srcUnchanged,
+			wantDst:    []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=old src", "newer-src.txt=new source", "src-only.txt=src only"},
+			wantReport: []string{"copied-to-dst newer-dst.txt", "copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "skipped dst-only.txt"},
+		},
+		{
+			name:       "source-both-ways",
+			policy:     FixSource,
+			bothWays:   true,
+			wantSrc:    []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=old src", "newer-src.txt=new source", "src-only.txt=src only"},
+			wantDst:    []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=old src", "newer-src.txt=new source", "src-only.txt=src only"},
+			wantReport: []string{"copied-to-dst newer-dst.txt", "copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "copied-to-src dst-only.txt"},
+		},
+		{
+			name:       "source-one-way",
+			policy:     FixSource,
+			oneWay:     true,
+			wantSrc:    srcUnchanged,
+			wantDst:    []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=old src", "newer-src.txt=new source", "src-only.txt=src only"},
+			wantReport: []string{"copied-to-dst newer-dst.txt", "copied-to-dst newer-src.txt", "copied-to-dst src-only.txt"},
+		},
+		{
+			name:       "newer",
+			policy:     FixNewer,
+			wantErr:    true,
+			wantSrc:    srcUnchanged,
+			wantDst:    bothSynced,
+			wantReport: []string{"copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "skipped dst-only.txt", "skipped newer-dst.txt"},
+		},
+		{
+			name:       "newer-one-way",
+			policy:     FixNewer,
+			oneWay:     true,
+			wantErr:    true,
+			wantSrc:    srcUnchanged,
+			wantDst:    bothSynced,
+			wantReport: []string{"copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "skipped newer-dst.txt"},
+		},
+		{
+			name:       "newer-both-ways",
+			policy:     FixNewer,
+			bothWays:   true,
+			wantSrc:    bothSynced,
+			wantDst:    bothSynced,
+			wantReport: []string{"copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "copied-to-src dst-only.txt", "copied-to-src newer-dst.txt"},
+		},
+		{
+			name:       "larger-one-way",
+			policy:     FixLarger,
+			oneWay:     true,
+			wantErr:    true,
+			wantSrc:    srcUnchanged,
+			wantDst:    bothSynced,
+			wantReport: []string{"copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "skipped newer-dst.txt"},
+		},
+		{
+			name:       "larger-both-ways",
+			policy:     FixLarger,
+			bothWays:   true,
+			wantSrc:    bothSynced,
+			wantDst:    bothSynced,
+			wantReport: []string{"copied-to-dst newer-src.txt", "copied-to-dst src-only.txt", "copied-to-src dst-only.txt", "copied-to-src newer-dst.txt"},
+		},
+		{
+			name:     "keep-both",
+			policy:   FixKeepBoth,
+			bothWays: true,
+			wantSrc:  []string{"both.txt=same", "dst-only.txt=dst only", "newer-dst.txt=old src", "newer-src.txt=new source", "src-only.txt=src only"},
+			wantDst: []string{
+				"both.txt=same", "dst-only.txt=dst only",
+				"newer-dst.conflict.txt=new destination", "newer-dst.txt=old src",
+				"newer-src.conflict.txt=old", "newer-src.txt=new source", "src-only.txt=src only",
+			},
+			wantReport: []string{"copied-to-dst src-only.txt", "copied-to-src dst-only.txt", "kept-both newer-dst.txt", "kept-both newer-src.txt"},
+		},
+		{
+			name:     "one-way-and-both-ways",
+			policy:   FixSource,
+			oneWay:   true,
+			bothWays: true,
+			wantErr:  true,
+			wantSrc:  srcUnchanged,
+			wantDst:  dstUnchanged,
+		},
+		{
+			name:    "potato",
+			policy:  "potato",
+			wantErr: true,
+			wantSrc: srcUnchanged,
+			wantDst: dstUnchanged,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := fstest.NewRun(t)
+			r.WriteFile("both.txt", "same", t1)
+			r.WriteObject(ctx, "both.txt", "same", t1)
+			r.WriteFile("src-only.txt", "src only", t1)
+			r.WriteObject(ctx, "dst-only.txt", "dst only", t1)
+			r.WriteFile("newer-src.txt", "new source", t2)
+			r.WriteObject(ctx, "newer-src.txt", "old", t1)
+			r.WriteFile("newer-dst.txt", "old src", t1)
+			r.WriteObject(ctx, "newer-dst.txt", "new destination", t2)
+
+			var report bytes.Buffer
+			accounting.GlobalStats().ResetCounters()
+			err := Fix(ctx, &operations.CheckOpt{
+				Fsrc:   r.Flocal,
+				Fdst:   r.Fremote,
+				OneWay: test.oneWay,
+			}, operations.Check, FixOpt{
+				Policy:   test.policy,
+				Suffix:   ".conflict",
+				Report:   &report,
+				BothWays: test.bothWays,
+			})
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				// The differences found by check are still counted
+				assert.NotEqual(t, int64(0), accounting.GlobalStats().GetErrors())
+			}
+			assert.Equal(t, test.wantSrc, contents(ctx, t, r.Flocal))
+			assert.Equal(t, test.wantDst, contents(ctx, t, r.Fremote))
+			var got []string
+			if report.Len() > 0 {
+				got = strings.Split(strings.TrimSpace(report.String()), "\n")
+				sort.Strings(got)
+			}
+			assert.Equal(t, test.wantReport, got)
+		})
+	}
+}
+
+// Check that files which can't be decided are left as they are
+func TestFixUnresolved(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file.txt", "aaaa", t1)
+	r.WriteObject(ctx, "file.txt", "bbbb", t1)
+
+	var report bytes.Buffer
+	err := Fix(ctx, &operations.CheckOpt{
+		Fsrc: r.Flocal,
+		Fdst: r.Fremote,
+	}, operations.Check, FixOpt{
+		Policy: FixLarger,
+		Report: &report,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "skipped file.txt\n", report.String())
+}
+
+// Check that nothing is changed with --dry-run and the report shows it
+func TestFixDryRun(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.DryRun = true
+	r := fstest.NewRun(t)
+	r.WriteFile("src-only.txt", "src only", t1)
+
+	var report bytes.Buffer
+	err := Fix(ctx, &operations.CheckOpt{
+		Fsrc:   r.Flocal,
+		Fdst:   r.Fremote,
+		OneWay: true,
+	}, operations.Check, FixOpt{
+		Policy: FixSource,
+		Report: &report,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string(nil), contents(ctx, t, r.Fremote))
+	assert.Equal(t, "dry-run-copied-to-dst src-only.txt\n", report.String())
+}
+
+// Check that keep-both doesn't overwrite an existing renamed file
+func TestFixKeepBothNameInUse(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file.txt", "source", t1)
+	r.WriteObject(ctx, "file.txt", "destination", t1)
+	r.WriteObject(ctx, "file.conflict.txt", "earlier conflict", t1)
+
+	err := Fix(ctx, &operations.CheckOpt{
+		Fsrc:   r.Flocal,
+		Fdst:   r.Fremote,
+		OneWay: true,
+	}, operations.Check, FixOpt{
+		Policy: FixKeepBoth,
+		Suffix: ".conflict",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file.txt=source"}, contents(ctx, t, r.Flocal))
+	assert.Equal(t, []string{
+		"file.conflict-1.txt=destination",
+		"file.conflict.txt=earlier conflict",
+		"file.txt=source",
+	}, contents(ctx, t, r.Fremote))
+}
+
+// contents returns the names and contents of the files in f
+func contents(ctx context.Context, t *testing.T, f fs.Fs) (got []string) {
+	err := operations.ListFn(ctx, f, func(o fs.Object) {
+		in, err := o.Open(ctx)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		got = append(got, o.Remote()+"="+buf.String())
+	})
+	require.NoError(t, err)
+	sort.Strings(got)
+	return got
+}
@@ -3,6 +3,7 @@
 package cmount
 
 import (
+	"context"
 	"io"
 	"os"
 	"path"
@@ -157,6 +158,8 @@ func (fsys *FS) stat(node vfs.Node, stat *fuse.Stat_t) (errc int) {
 	Mode := node.Mode().Perm()
 	if node.IsDir() {
 		Mode |= fuse.S_IFDIR
+	} else if node.Mode()&os.ModeSymlink != 0 {
+		Mode |= fuse.S_IFLNK
 	} else {
 		Mode |= fuse.S_IFREG
 	}
@@ -510,13 +513,23 @@ func (fsys *FS) Link(oldpath string, newpath string) (errc int) {
 // Symlink creates a symbolic link.
 func (fsys *FS) Symlink(target string, newpath string) (errc int) {
 	defer log.Trace(target, "newpath=%q", newpath)("errc=%d", &errc)
-	return -fuse.ENOSYS
+	leaf, parentDir, errc := fsys.lookupParentDir(newpath)
+	if errc != 0 {
+		return errc
+	}
+	_, err := parentDir.Symlink(target, leaf)
+	return translateError(err)
 }
 
 // Readlink reads the target of a symbolic link.
 func (fsys *FS) Readlink(path string) (errc int, linkPath string) {
 	defer log.Trace(path, "")("linkPath=%q, errc=%d", &linkPath, &errc)
-	return -fuse.ENOSYS, ""
+	file, errc := fsys.lookupFile(path)
+	if errc != 0 {
+		return errc, ""
+	}
+	linkPath, err := file.Readlink()
+	return translateError(err), linkPath
 }
 
 // Chmod changes the permission bits of a file.
@@ -550,13 +563,36 @@ func (fsys *FS) Fsyncdir(path string, datasync bool, fh uint64) (errc int) {
 // Setxattr sets extended attributes.
 func (fsys *FS) Setxattr(path string, name string, value []byte, flags int) (errc int) {
 	defer log.Trace(path, "name=%q, value=%q, flags=%d", name, value, flags)("errc=%d", &errc)
-	return -fuse.ENOSYS
+	if !fsys.opt.Xattr {
+		return -fuse.ENOSYS
+	}
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return -fuse.EPERM
+	}
+	return translateError(file.SetXattr(context.Background(), name, value))
 }
 
 // Getxattr gets extended attributes.
 func (fsys *FS) Getxattr(path string, name string) (errc int, value []byte) {
 	defer log.Trace(path, "name=%q", name)("errc=%d, value=%q", &errc, &value)
-	return -fuse.ENOSYS, nil
+	if !fsys.opt.Xattr {
+		return -fuse.ENOSYS, nil
+	}
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc, nil
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return -fuse.ENOATTR, nil
+	}
+	value, err := file.GetXattr(context.Background(), name)
+	return translateError(err), value
 }
 
 // Removexattr removes extended attributes.
@@ -568,7 +604,27 @@ func (fsys *FS) Removexattr(path string, name string) (errc int) {
 // Listxattr lists extended attributes.
 func (fsys *FS) Listxattr(path string, fill func(name string) bool) (errc int) {
 	defer log.Trace(path, "fill=%p", fill)("errc=%d", &errc)
-	return -fuse.ENOSYS
+	if !fsys.opt.Xattr {
+		return -fuse.ENOSYS
+	}
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return 0
+	}
+	names, err := file.ListXattr(context.Background())
+	if err != nil {
+		return translateError(err)
+	}
+	for _, name := range names {
+		if !fill(name) {
+			return -fuse.ERANGE
+		}
+	}
+	return 0
 }
 
 // Getpath allows a case-insensitive file system to report the correct case of
@@ -615,6 +671,10 @@ func translateError(err error) (errc int) {
 		return -fuse.ENOSYS
 	case vfs.EINVAL:
 		return -fuse.EINVAL
+	case vfs.ENOATTR:
+		return -fuse.ENOATTR
+	case vfs.EAGAIN:
+		return -fuse.EAGAIN
 	}
 	fs.Errorf(nil, "IO error: %v", err)
 	return -fuse.EIO
@@ -0,0 +1,278 @@
+// Package convmv provides the convmv command.
+package convmv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/transform"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// Collision policies
+const (
+	CollisionSkip      = "skip"
+	CollisionOverwrite = "overwrite"
+	CollisionNumber    = "number"
+)
+
+var (
+	nameTransforms []string
+	onCollision    = CollisionSkip
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringArrayVarP(cmdFlags, &nameTransforms, "name-transform", "t", nil, "Transform to apply to the names (may be repeated)", "")
+	flags.StringVarP(cmdFlags, &onCollision, "on-collision", "", onCollision, "What to do if the new name exists: skip, overwrite or number", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "convmv remote:path",
+	Short: `Rename the files and directories in a remote by transforming their names.`,
+	Long: strings.ReplaceAll(`
+Rename the files and directories in remote:path in place by applying
+one or more transforms to their names. Use |--name-transform| (or
+|-t|) to give each transform, which are applied in the order given.
+
+For example to convert file names made on macOS, which are in unicode
+NFD form, to NFC form and replace characters which aren't allowed on
+Windows
+
+    rclone convmv remote:path -t nfc -t encoder=Colon,Question,Asterisk,Pipe,LtGt,DoubleQuote
+
+or to lower case the names and add the modification date
+
+    rclone convmv remote:path -t lowercase -t date=-{YYYY}{MM}{DD}
+
+`+transform.Help+`
+The transforms are applied to each part of the path separately, so
+they can't move files into a different directory.
+
+Files are renamed with server-side moves and directories with
+server-side directory moves if the remote supports them, otherwise
+they are copied and deleted.
+
+If a new name is already in use then |--on-collision| decides what
+happens:
+
+- |skip| - don't rename the file and report an error (the default)
+- |overwrite| - replace the existing file (directories are never overwritten)
+- |number| - add |-1|, |-2|, etc to the new name before its extension until it is unused
+
+Use |--dry-run| or |--interactive| to see what would be renamed
+first. The usual [filters](/filtering/) can be used to choose which
+files and directories are renamed.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+		"groups":            "Filter,Listing,Important",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(true, true, command, func() error {
+			ts, err := transform.ParseAll(nameTransforms)
+			if err != nil {
+				return err
+			}
+			if len(ts) == 0 {
+				return errors.New("need at least one --name-transform")
+			}
+			return Convmv(context.Background(), f, ts, onCollision)
+		})
+	},
+}
+
+// renamer renames the entries in a remote
+type renamer struct {
+	f           fs.Fs
+	ts          transform.Transforms
+	onCollision string
+	mu          sync.Mutex
+	names       map[string]struct{} // names in use - see key
+	errCount    int
+	lastErr     error
+}
+
+// key returns the key for the names map for remote
+func (r *renamer) key(remote string) string {
+	if r.f.Features().CaseInsensitive {
+		return strings.ToLower(remote)
+	}
+	return remote
+}
+
+// Convmv renames all the files and directories in f by applying
+// the transforms ts to their names.
+//
+// onCollision should be one of the Collision constants.
+func Convmv(ctx context.Context, f fs.Fs, ts transform.Transforms, onCollision string) error {
+	ci := fs.GetConfig(ctx)
+	switch onCollision {
+	case CollisionSkip, CollisionOverwrite, CollisionNumber:
+	default:
+		return fmt.Errorf("unknown --on-collision %q - must be %s, %s or %s", onCollision, CollisionSkip, CollisionOverwrite, CollisionNumber)
+	}
+	r := &renamer{
+		f:           f,
+		ts:          ts,
+		onCollision: onCollision,
+		names:       map[string]struct{}{},
+	}
+
+	// Read all the entries, grouping them by depth
+	byDepth := map[int]fs.DirEntries{}
+	err := walk.ListR(ctx, f, "", false, ci.MaxDepth, walk.ListAll, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			depth := strings.Count(entry.Remote(), "/")
+			byDepth[depth] = append(byDepth[depth], entry)
+			r.names[r.key(entry.Remote())] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %v: %w", f, err)
+	}
+	var depths []int
+	for depth := range byDepth {
+		depths = append(depths, depth)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(depths)))
+
+	// Rename the deepest entries first so the parent directories
+	// still have their old names
+	for _, depth := range depths {
+		entries := byDepth[depth]
+		sort.Sort(entries)
+		g, gCtx := errgroup.WithContext(ctx)
+		g.SetLimit(ci.Transfers)
+		for _, entry := range entries {
+			entry := entry
+			g.Go(func() error {
+				err := r.rename(gCtx, entry)
+				if err != nil {
+					fs.Errorf(entry, "Failed to rename: %v", err)
+					r.mu.Lock()
+					r.errCount++
+					r.lastErr = err
+					r.mu.Unlock()
+				}
+				return nil
+			})
+		}
+		_ = g.Wait()
+	}
+	if r.errCount > 0 {
+		return fmt.Errorf("failed to rename %d entries: last error: %w", r.errCount, r.lastErr)
+	}
+	return nil
+}
+
+// newName works out the new path of remote from its transformed
+// name newLeaf, returning "" if it doesn't need renaming.
+//
+// It reserves the new name in r.names.
+func (r *renamer) newName(ctx context.Context, remote string, newLeaf string, isDir bool) (string, error) {
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	if newLeaf == "" || newLeaf == "." || newLeaf == ".." || strings.Contains(newLeaf, "/") {
+		return "", fmt.Errorf("transformed name %q is invalid", newLeaf)
+	}
+	newRemote := path.Join(dir, newLeaf)
+	if newRemote == remote {
+		return "", nil
+	}
+
+	// taken returns true if newRemote is reserved by another entry.
+	//
+	// Call with r.mu held.
+	taken := func(newRemote string) bool {
+		if r.key(newRemote) == r.key(remote) {
+			// changing case on a case insensitive remote
+			return false
+		}
+		_, found := r.names[r.key(newRemote)]
+		return found
+	}
+	overwrite := r.onCollision == CollisionOverwrite && !isDir
+	ext := path.Ext(newLeaf)
+	if ext == newLeaf {
+		ext = ""
+	}
+	base := strings.TrimSuffix(newLeaf, ext)
+	for i := 0; ; i++ {
+		try := newRemote
+		if i > 0 {
+			try = path.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+		}
+		r.mu.Lock()
+		exists := taken(try)
+		r.mu.Unlock()
+		// check for files excluded from the listing without the
+		// lock held as this is a call to the remote
+		if !exists && !isDir && r.key(try) != r.key(remote) {
+			if _, err := r.f.NewObject(ctx, try); err == nil {
+				exists = true
+			}
+		}
+		r.mu.Lock()
+		// another entry may have reserved the name while unlocked
+		exists = exists || taken(try)
+		if exists && !overwrite {
+			r.mu.Unlock()
+			if r.onCollision == CollisionNumber {
+				continue
+			}
+			return "", fmt.Errorf("can't rename to %q as it already exists", try)
+		}
+		if exists {
+			fs.Infof(remote, "Overwriting existing %q", try)
+		}
+		delete(r.names, r.key(remote))
+		r.names[r.key(try)] = struct{}{}
+		r.mu.Unlock()
+		return try, nil
+	}
+}
+
+// rename entry applying the transforms
+func (r *renamer) rename(ctx context.Context, entry fs.DirEntry) error {
+	remote := entry.Remote()
+	newLeaf := r.ts.Apply(path.Base(remote), entry.ModTime(ctx))
+	_, isDir := entry.(fs.Directory)
+	newRemote, err := r.newName(ctx, remote, newLeaf, isDir)
+	if err != nil || newRemote == "" {
+		return err
+	}
+	if !isDir {
+		return operations.MoveFile(ctx, r.f, r.f, newRemote, remote)
+	}
+	if operations.SkipDestructive(ctx, entry, "rename directory") {
+		return nil
+	}
+	if r.f.Features().CaseInsensitive && strings.EqualFold(newRemote, remote) {
+		err = operations.DirMoveCaseInsensitive(ctx, r.f, remote, newRemote)
+	} else {
+		err = operations.DirMove(ctx, r.f, remote, newRemote)
+	}
+	if err != nil {
+		return err
+	}
+	fs.Infof(entry, "Renamed directory to %q", newRemote)
+	return nil
+}
@@ -0,0 +1,110 @@
+package convmv
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t1 = fstest.Time("2017-02-03T04:05:06.499999999Z")
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func mustParse(t *testing.T, specs ...string) transform.Transforms {
+	ts, err := transform.ParseAll(specs)
+	require.NoError(t, err)
+	return ts
+}
+
+func TestConvmv(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.Mkdir(ctx, r.Fremote)
+	file1 := r.WriteObject(ctx, "Dir/Sub Dir/File One.TXT", "one", t1)
+	file2 := r.WriteObject(ctx, "Dir/Two.txt", "two", t1)
+	file3 := r.WriteObject(ctx, "caf\u00e9.txt", "three", t1)
+
+	err := Convmv(ctx, r.Fremote, mustParse(t, "nfd", "lowercase", "regex= /_"), CollisionSkip)
+	require.NoError(t, err)
+
+	file1.Path = "dir/sub_dir/file_one.txt"
+	file2.Path = "dir/two.txt"
+	file3.Path = "cafe\u0301.txt"
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1, file2, file3}, []string{"dir", "dir/sub_dir"}, fs.GetModifyWindow(ctx, r.Fremote))
+}
+
+func TestConvmvCollision(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		onCollision string
+		wantErr     bool
+		want        []string
+	}{
+		{CollisionSkip, true, []string{"A.txt", "a.txt"}},
+		{CollisionOverwrite, false, []string{"a.txt"}},
+		{CollisionNumber, false, []string{"a-1.txt", "a.txt"}},
+	} {
+		t.Run(test.onCollision, func(t *testing.T) {
+			r := fstest.NewRun(t)
+			r.WriteObject(ctx, "A.txt", "upper", t1)
+			r.WriteObject(ctx, "a.txt", "lower", t1)
+
+			err := Convmv(ctx, r.Fremote, mustParse(t, "lowercase"), test.onCollision)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			var got []string
+			entries, err := r.Fremote.List(ctx, "")
+			require.NoError(t, err)
+			for _, entry := range entries {
+				got = append(got, entry.Remote())
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestConvmvCollisionNumber(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	for _, name := range []string{"A.txt", "a.TXT", "A.Txt", "a.tXt", "a.txt", "a-2.txt"} {
+		r.WriteObject(ctx, name, name, t1)
+	}
+
+	// a-2.txt is excluded from the listing so must be found by
+	// looking it up
+	fi, err := filter.NewFilter(nil)
+	require.NoError(t, err)
+	require.NoError(t, fi.AddRule("- a-2.txt"))
+	ctx = filter.ReplaceConfig(ctx, fi)
+
+	err = Convmv(ctx, r.Fremote, mustParse(t, "lowercase"), CollisionNumber)
+	require.NoError(t, err)
+	var got []string
+	entries, err := r.Fremote.List(context.Background(), "")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		got = append(got, entry.Remote())
+	}
+	sort.Strings(got)
+	assert.Equal(t, []string{"a-1.txt", "a-2.txt", "a-3.txt", "a-4.txt", "a-5.txt", "a.txt"}, got)
+}
+
+func TestConvmvBadCollision(t *testing.T) {
+	r := fstest.NewRun(t)
+	err := Convmv(context.Background(), r.Fremote, mustParse(t, "lowercase"), "potato")
+	assert.ErrorContains(t, err, "unknown --on-collision")
+}
@@ -4,10 +4,12 @@ package dedupe
 import (
 	"context"
 	"log"
+	"os"
 
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/fspath"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/spf13/cobra"
 )
@@ -24,8 +26,23 @@ func init() {
 	flags.BoolVarP(cmdFlag, &byHash, "by-hash", "", false, "Find identical hashes rather than names", "")
 }
 
+// isRemote returns true if arg looks like a remote rather than a
+// misspelled mode, so it has a remote name or is an existing local
+// path.
+func isRemote(arg string) bool {
+	parsed, err := fspath.Parse(arg)
+	if err != nil {
+		return false
+	}
+	if parsed.Name != "" {
+		return true
+	}
+	_, err = os.Stat(arg)
+	return err == nil
+}
+
 var commandDefinition = &cobra.Command{
-	Use:   "dedupe [mode] remote:path",
+	Use:   "dedupe [mode] remote:path [secondary:path...]",
 	Short: `Interactively find duplicate filenames and delete/rename them.`,
 	Long: `
 
@@ -43,6 +60,12 @@ duplicate hashes instead which will work on any backend which supports
 at least one hash. This can be used to find files with duplicate
 content. This is known as deduping by hash.
 
+If the backend has no hashes then ` + "`--hash-sample`" + ` can be used with
+` + "`--by-hash`" + ` to find files of the same size whose sampled blocks
+match. As these might still differ between the blocks, they are
+compared in full before any are deleted, and only identical files are
+deleted.
+
 If deduping by name, first rclone will merge directories with the same
 name.  It will do this iteratively until all the identically named
 directories have been merged.
@@ -134,19 +157,77 @@ For example, to rename all the identically named photos in your Google Photos di
 Or
 
     rclone dedupe rename "drive:Google Photos"
+
+### Deduping across remotes
+
+If ` + "`--by-hash`" + ` is passed with more than one remote then dedupe finds
+files with the same content in any of them. The first remote is the
+primary and the rest are secondaries. Dedupe only ever deletes or
+renames the copies in the secondaries so the primary is never changed.
+This is useful to find out which files in old accounts already exist
+somewhere else.
+
+Files are compared using a hash which all the remotes support. If
+there isn't one then they are compared by size and the MD5 of their
+first 1 MiB, which is read from the remotes. Only files which have the
+same size as another file are read. With ` + "`--size-only`" + ` files are
+compared by size alone.
+
+Unless the files were compared with a hash, or are small enough to be
+read in full, the match is only a likely one. Before deleting a copy
+matched like this dedupe reads it and the copy it keeps in full and
+only deletes it if they are identical. The ` + "`list`" + ` and ` + "`rename`" + `
+modes don't need to do this.
+
+When deduping across remotes the first argument is treated as the
+mode if it is one, and otherwise must be a remote or an existing
+local directory.
+
+The dedupe modes work like this for each group of identical files:
+
+  * ` + "`interactive`" + ` - asks which copy to keep, then deletes the other secondary copies.
+  * ` + "`skip`" + ` - does nothing.
+  * ` + "`first`" + ` - keeps the first copy, which is in the primary if there is one there, and deletes the other secondary copies.
+  * ` + "`newest`" + `, ` + "`oldest`" + `, ` + "`largest`" + `, ` + "`smallest`" + ` - keeps the chosen copy and deletes the other secondary copies.
+  * ` + "`rename`" + ` - renames all the secondary copies apart from the first by changing file.jpg to file-1.jpg.
+  * ` + "`list`" + ` - lists the copies and changes nothing.
+
+For example to see which files in two old accounts are already in a
+new one
+
+    rclone dedupe --by-hash list new: old1: old2:
+
+and then to delete them from the old accounts
+
+    rclone dedupe --by-hash first new: old1: old2:
 `,
 	Annotations: map[string]string{
 		"versionIntroduced": "v1.27",
 		"groups":            "Important",
 	},
 	Run: func(command *cobra.Command, args []string) {
-		cmd.CheckArgs(1, 2, command, args)
+		cmd.CheckArgs(1, 256, command, args)
 		if len(args) > 1 {
 			err := dedupeMode.Set(args[0])
-			if err != nil {
+			if err == nil {
+				args = args[1:]
+			} else if !byHash || !isRemote(args[0]) {
 				log.Fatal(err)
 			}
-			args = args[1:]
+		}
+		if len(args) > 1 {
+			if !byHash {
+				log.Fatal("need --by-hash to dedupe across more than one remote")
+			}
+			primary := cmd.NewFsSrc(args)
+			var secondaries []fs.Fs
+			for i := 1; i < len(args); i++ {
+				secondaries = append(secondaries, cmd.NewFsSrc(args[i:]))
+			}
+			cmd.Run(false, false, command, func() error {
+				return operations.DeduplicateRemotes(context.Background(), primary, secondaries, dedupeMode)
+			})
+			return
 		}
 		fdst := cmd.NewFsSrc(args)
 		if !byHash && !fdst.Features().DuplicateFiles {
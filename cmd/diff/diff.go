@@ -0,0 +1,143 @@
+// Package diff provides the diff command.
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/check"
+	"github.com/rclone/rclone/cmd/manifest"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	check.AddFlags(cmdFlags)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "diff old new",
+	Short: `Show the differences between two manifests or remotes.`,
+	Long: strings.ReplaceAll(`
+Compare old with new and show which files have been added, removed
+or changed. Each of old and new can be a manifest made by
+[rclone manifest](/commands/rclone_manifest/) or a live remote, so
+two snapshots of a remote can be compared without listing it again.
+
+    rclone diff s3:manifests/2024-05.json.gz s3:manifests/2024-06.json.gz
+    rclone diff s3:manifests/2024-06.json.gz s3:backups/current
+
+If an argument points to a file then it is read as a manifest,
+otherwise it is listed as a remote.
+
+Files are compared by size, by modification time and by hash if old
+and new have a hash in common. Modification times are compared to
+within the modify window (the least precise of old and new, or
+|--modify-window|) and aren't compared if either doesn't support them.
+With |--size-only| only the sizes are compared, as with
+[rclone check](/commands/rclone_check/).
+If no report flags are given then a combined report is written to
+standard output, where
+
+- |= path| means path is the same in old and new
+- |- path| means path was removed, so is only in old
+- |+ path| means path was added, so is only in new
+- |* path| means path is in old and new but has changed
+- |! path| means there was an error reading path
+
+Note that this means new is the source and old is the destination in
+the descriptions of the flags below.
+`, "|", "`") + check.FlagsHelp,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+		"groups":            "Filter,Listing,Check",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			fold, err := newFs(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			fnew, err := newFs(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			opt, closeOpt, err := check.GetCheckOpt(fnew, fold)
+			if err != nil {
+				return err
+			}
+			defer closeOpt()
+			if opt.Combined == nil && opt.MissingOnSrc == nil && opt.MissingOnDst == nil &&
+				opt.Match == nil && opt.Differ == nil && opt.Error == nil {
+				opt.Combined = os.Stdout
+			}
+			return Diff(ctx, opt)
+		})
+	},
+}
+
+// newFs returns a manifest Fs if remote points to a file, otherwise
+// the remote itself.
+func newFs(ctx context.Context, remote string) (fs.Fs, error) {
+	f, fileName := cmd.NewFsFile(remote)
+	if fileName == "" {
+		return f, nil
+	}
+	o, err := f.NewObject(ctx, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find manifest %q: %w", remote, err)
+	}
+	return manifest.NewFs(ctx, o)
+}
+
+// Diff compares opt.Fdst (old) with opt.Fsrc (new) writing the
+// results to the outputs in opt.
+//
+// Unlike check, files whose modification times differ by more than
+// the modify window are reported as changed unless --size-only is
+// set.
+func Diff(ctx context.Context, opt *operations.CheckOpt) error {
+	hashType := opt.Fsrc.Hashes().Overlap(opt.Fdst.Hashes()).GetOne()
+	if hashType == hash.None {
+		fs.Logf(nil, "No common hash found - only comparing sizes and modification times")
+	} else {
+		fs.Infof(nil, "Using %v for hash comparisons", hashType)
+	}
+	modifyWindow := fs.GetModifyWindow(ctx, opt.Fsrc, opt.Fdst)
+	optCopy := *opt
+	optCopy.Check = func(ctx context.Context, dst, src fs.Object) (differ bool, noHash bool, err error) {
+		if modifyWindow != fs.ModTimeNotSupported {
+			dt := dst.ModTime(ctx).Sub(src.ModTime(ctx))
+			if dt < -modifyWindow || dt > modifyWindow {
+				fs.Errorf(src, "modification times differ by %s", dt)
+				return true, false, nil
+			}
+		}
+		if hashType == hash.None {
+			return false, true, nil
+		}
+		same, ht, err := operations.CheckHashes(ctx, src, dst)
+		if err != nil {
+			return true, false, err
+		}
+		if ht == hash.None {
+			return false, true, nil
+		}
+		if !same {
+			err = fmt.Errorf("%v differ", ht)
+			fs.Errorf(src, "%v", err)
+			return true, false, nil
+		}
+		return false, false, nil
+	}
+	return operations.CheckFn(ctx, &optCopy)
+}
@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/manifest"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2017-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2018-02-03T04:05:06.499999999Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+// snapshot makes a manifest of f
+func snapshot(ctx context.Context, t *testing.T, f fs.Fs) fs.Fs {
+	var buf bytes.Buffer
+	require.NoError(t, manifest.Write(ctx, f, &buf, hash.NewHashSet(hash.MD5)))
+	fm, err := manifest.Read(ctx, "snapshot", &buf)
+	require.NoError(t, err)
+	return fm
+}
+
+// diff fold and fnew returning the sorted combined output
+func diff(ctx context.Context, t *testing.T, fold, fnew fs.Fs) []string {
+	var buf bytes.Buffer
+	accounting.GlobalStats().ResetCounters()
+	_ = Diff(ctx, &operations.CheckOpt{
+		Fsrc:     fnew,
+		Fdst:     fold,
+		Combined: &buf,
+	})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("same.txt", "same", t1)
+	r.WriteFile("removed.txt", "removed", t1)
+	r.WriteFile("changed.txt", "before", t1)
+	r.WriteFile("dir/resized.txt", "small", t1)
+	r.WriteFile("touched.txt", "touched", t1)
+	old := snapshot(ctx, t, r.Flocal)
+
+	r.WriteFile("added.txt", "added", t1)
+	r.WriteFile("changed.txt", "after!", t1)
+	r.WriteFile("dir/resized.txt", "bigger now", t1)
+	r.WriteFile("touched.txt", "touched", t2)
+	o, err := r.Flocal.NewObject(ctx, "removed.txt")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+
+	want := []string{
+		"* changed.txt",
+		"* dir/resized.txt",
+		"* touched.txt",
+		"+ added.txt",
+		"- removed.txt",
+		"= same.txt",
+	}
+
+	// manifest against manifest
+	assert.Equal(t, want, diff(ctx, t, old, snapshot(ctx, t, r.Flocal)))
+
+	// manifest against live remote
+	assert.Equal(t, want, diff(ctx, t, old, r.Flocal))
+}
+
+func TestDiffSizeOnly(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.SizeOnly = true
+	r := fstest.NewRun(t)
+	r.WriteFile("touched.txt", "touched", t1)
+	r.WriteFile("changed.txt", "before", t1)
+	old := snapshot(ctx, t, r.Flocal)
+
+	r.WriteFile("touched.txt", "touched", t2)
+	r.WriteFile("changed.txt", "after!", t1)
+
+	// Only the sizes are compared with --size-only
+	assert.Equal(t, []string{
+		"= changed.txt",
+		"= touched.txt",
+	}, diff(ctx, t, old, r.Flocal))
+}
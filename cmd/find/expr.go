@@ -0,0 +1,571 @@
+// Expression parser and evaluator for find
+
+package find
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// object is an fs.Object being matched with the properties which are
+// expensive to read cached
+type object struct {
+	ctx      context.Context
+	o        fs.Object
+	now      time.Time
+	metadata fs.Metadata
+	metaRead bool
+	hashes   map[hash.Type]string
+}
+
+// newObject makes a new object for evaluating o at time now
+func newObject(ctx context.Context, o fs.Object, now time.Time) *object {
+	return &object{
+		ctx: ctx,
+		o:   o,
+		now: now,
+	}
+}
+
+// getMetadata reads the metadata of the object once
+func (obj *object) getMetadata() (fs.Metadata, error) {
+	if !obj.metaRead {
+		metadata, err := fs.GetMetadata(obj.ctx, obj.o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata: %w", err)
+		}
+		obj.metadata = metadata
+		obj.metaRead = true
+	}
+	return obj.metadata, nil
+}
+
+// getHash reads the hash of type ht of the object once
+func (obj *object) getHash(ht hash.Type) (string, error) {
+	if sum, found := obj.hashes[ht]; found {
+		return sum, nil
+	}
+	sum, err := obj.o.Hash(obj.ctx, ht)
+	if errors.Is(err, hash.ErrUnsupported) {
+		sum, err = "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %v hash: %w", ht, err)
+	}
+	if obj.hashes == nil {
+		obj.hashes = map[hash.Type]string{}
+	}
+	obj.hashes[ht] = sum
+	return sum, nil
+}
+
+// Expr is a compiled find expression made by Parse
+type Expr interface {
+	// String returns the expression in canonical form
+	String() string
+	// match returns true if the object matches the expression
+	match(obj *object) (bool, error)
+}
+
+// Match returns true if o matches the expression e at time now, which
+// is used for the age of o.
+func Match(ctx context.Context, e Expr, o fs.Object, now time.Time) (bool, error) {
+	return e.match(newObject(ctx, o, now))
+}
+
+// Operators for combining expressions
+type (
+	andExpr  struct{ left, right Expr }
+	orExpr   struct{ left, right Expr }
+	notExpr  struct{ expr Expr }
+	boolExpr bool
+)
+
+func (e *andExpr) match(obj *object) (bool, error) {
+	ok, err := e.left.match(obj)
+	if err != nil || !ok {
+		return false, err
+	}
+	return e.right.match(obj)
+}
+
+func (e *andExpr) String() string {
+	return "(" + e.left.String() + " and " + e.right.String() + ")"
+}
+
+func (e *orExpr) match(obj *object) (bool, error) {
+	ok, err := e.left.match(obj)
+	if err != nil || ok {
+		return ok, err
+	}
+	return e.right.match(obj)
+}
+
+func (e *orExpr) String() string {
+	return "(" + e.left.String() + " or " + e.right.String() + ")"
+}
+
+func (e *notExpr) match(obj *object) (bool, error) {
+	ok, err := e.expr.match(obj)
+	return !ok && err == nil, err
+}
+
+func (e *notExpr) String() string {
+	return "not " + e.expr.String()
+}
+
+func (e boolExpr) match(obj *object) (bool, error) {
+	return bool(e), nil
+}
+
+func (e boolExpr) String() string {
+	return strconv.FormatBool(bool(e))
+}
+
+// kind is the type of value a field has
+type kind int
+
+// Kinds of field
+const (
+	kindString kind = iota
+	kindSize
+	kindTime
+	kindDuration
+)
+
+// field describes something about an object which can be tested
+type field struct {
+	name string
+	kind kind
+	// get returns the value of the field and whether it exists
+	get func(obj *object) (value interface{}, exists bool, err error)
+}
+
+// fields are the simple fields which can be used in expressions
+//
+// hash.<type> and meta.<key> are made by lookupField.
+var fields = map[string]*field{
+	"name": {kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+		return path.Base(obj.o.Remote()), true, nil
+	}},
+	"path": {kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+		return obj.o.Remote(), true, nil
+	}},
+	"ext": {kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+		ext := path.Ext(obj.o.Remote())
+		return strings.TrimPrefix(ext, "."), ext != "", nil
+	}},
+	"size": {kind: kindSize, get: func(obj *object) (interface{}, bool, error) {
+		size := obj.o.Size()
+		return size, size >= 0, nil
+	}},
+	"mtime": {kind: kindTime, get: func(obj *object) (interface{}, bool, error) {
+		return obj.o.ModTime(obj.ctx), true, nil
+	}},
+	"age": {kind: kindDuration, get: func(obj *object) (interface{}, bool, error) {
+		return obj.now.Sub(obj.o.ModTime(obj.ctx)), true, nil
+	}},
+	"mimetype": {kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+		mimeType := fs.MimeType(obj.ctx, obj.o)
+		return mimeType, mimeType != "", nil
+	}},
+	"tier": {kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+		do, ok := obj.o.(fs.GetTierer)
+		if !ok {
+			return "", false, nil
+		}
+		tier := do.GetTier()
+		return tier, tier != "", nil
+	}},
+}
+
+func init() {
+	for name, f := range fields {
+		f.name = name
+	}
+}
+
+// lookupField returns the field called name
+func lookupField(name string) (*field, error) {
+	lowerName := strings.ToLower(name)
+	if f := fields[lowerName]; f != nil {
+		return f, nil
+	}
+	if typeName, found := strings.CutPrefix(lowerName, "hash."); found {
+		var ht hash.Type
+		if err := ht.Set(typeName); err != nil {
+			return nil, err
+		}
+		return &field{name: "hash." + ht.String(), kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+			sum, err := obj.getHash(ht)
+			return sum, sum != "", err
+		}}, nil
+	}
+	if key, found := strings.CutPrefix(name, "meta."); found && key != "" {
+		// Metadata keys are stored in lower case
+		key = strings.ToLower(key)
+		return &field{name: "meta." + key, kind: kindString, get: func(obj *object) (interface{}, bool, error) {
+			metadata, err := obj.getMetadata()
+			if err != nil {
+				return "", false, err
+			}
+			value, found := metadata[key]
+			return value, found, nil
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+// existsExpr tests whether a field exists
+type existsExpr struct {
+	field *field
+}
+
+func (e *existsExpr) match(obj *object) (bool, error) {
+	_, exists, err := e.field.get(obj)
+	return exists, err
+}
+
+func (e *existsExpr) String() string {
+	return e.field.name + " exists"
+}
+
+// compareExpr compares a field with a value
+type compareExpr struct {
+	field *field
+	op    string
+	raw   string         // value as written
+	value interface{}    // parsed value
+	re    *regexp.Regexp // for ~, !~, =~ and !=~
+}
+
+func (e *compareExpr) String() string {
+	return e.field.name + " " + e.op + " " + strconv.Quote(e.raw)
+}
+
+// newCompareExpr makes a comparison checking the value is valid for the field
+func newCompareExpr(f *field, op, raw string) (*compareExpr, error) {
+	e := &compareExpr{field: f, op: op, raw: raw}
+	var err error
+	switch op {
+	case "~", "!~":
+		if f.kind != kindString {
+			return nil, fmt.Errorf("can't use %q with %s", op, f.name)
+		}
+		e.re, err = filter.GlobToRegexp(raw, false)
+		if err != nil {
+			return nil, fmt.Errorf("bad glob %q: %w", raw, err)
+		}
+		return e, nil
+	case "=~", "!=~":
+		if f.kind != kindString {
+			return nil, fmt.Errorf("can't use %q with %s", op, f.name)
+		}
+		e.re, err = regexp.Compile(raw)
+		if err != nil {
+			return nil, fmt.Errorf("bad regexp %q: %w", raw, err)
+		}
+		return e, nil
+	}
+	switch f.kind {
+	case kindString:
+		e.value = raw
+	case kindSize:
+		var size fs.SizeSuffix
+		err = size.Set(raw)
+		e.value = int64(size)
+	case kindTime:
+		e.value, err = fs.ParseTime(raw)
+	case kindDuration:
+		e.value, err = fs.ParseDuration(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad value for %s: %w", f.name, err)
+	}
+	return e, nil
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal to or greater
+// than b
+//
+// Strings which both look like numbers are compared as numbers so
+// metadata such as sizes and times compare as expected.
+func compare(a, b interface{}) int {
+	switch x := a.(type) {
+	case int64:
+		y := b.(int64)
+		return cmp.Compare(x, y)
+	case time.Duration:
+		y := b.(time.Duration)
+		return cmp.Compare(x, y)
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	case string:
+		y := b.(string)
+		if fx, err := strconv.ParseFloat(x, 64); err == nil {
+			if fy, err := strconv.ParseFloat(y, 64); err == nil {
+				return cmp.Compare(fx, fy)
+			}
+		}
+		return strings.Compare(x, y)
+	}
+	panic(fmt.Sprintf("can't compare %T", a))
+}
+
+func (e *compareExpr) match(obj *object) (bool, error) {
+	value, exists, err := e.field.get(obj)
+	if err != nil || !exists {
+		return false, err
+	}
+	switch e.op {
+	case "~", "=~":
+		return e.re.MatchString(value.(string)), nil
+	case "!~", "!=~":
+		return !e.re.MatchString(value.(string)), nil
+	}
+	c := compare(value, e.value)
+	switch e.op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", e.op)
+}
+
+// token is a lexical token of an expression
+type token struct {
+	text   string
+	quoted bool // set if text was a quoted string
+}
+
+// operators in the order they should be tried so the longest matches
+var operators = []string{"!=~", "&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "(", ")", "<", ">", "=", "~", "!"}
+
+// tokenize splits an expression into tokens
+func tokenize(s string) (tokens []token, err error) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			var text strings.Builder
+			for ; end < len(s) && s[end] != c; end++ {
+				if s[end] == '\\' && end+1 < len(s) {
+					end++
+				}
+				text.WriteByte(s[end])
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string starting at %d", i)
+			}
+			tokens = append(tokens, token{text: text.String(), quoted: true})
+			i = end + 1
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op != "" {
+				tokens = append(tokens, token{text: op})
+				i += len(op)
+				continue
+			}
+			end := i
+			for end < len(s) && !unicode.IsSpace(rune(s[end])) && !strings.ContainsRune(`()"'<>=!~&|`, rune(s[end])) {
+				end++
+			}
+			// Allow globs such as video/* and values such as 1G
+			// which don't start with an operator character
+			tokens = append(tokens, token{text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// parser parses a list of tokens into an Expr
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next unquoted token in lower case or ""
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return strings.ToLower(p.tokens[p.pos].text)
+}
+
+// next returns the next token
+func (p *parser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, errors.New("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+// Parse parses an expression such as
+//
+//	size > 1G and mimetype ~ "video/*" and not meta.tier == STANDARD
+//
+// An empty expression matches everything.
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return boolExpr(true), nil
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return e, nil
+}
+
+// parseOr parses and expressions separated by or
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "or" || op == "||"; op = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses not expressions separated by and
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "and" || op == "&&"; op = p.peek() {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseNot parses an optionally negated primary expression
+func (p *parser) parseNot() (Expr, error) {
+	if op := p.peek(); op == "not" || op == "!" {
+		p.pos++
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: e}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a bracketed expression, true, false or a test
+// on a field
+func (p *parser) parsePrimary() (Expr, error) {
+	switch p.peek() {
+	case "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return e, nil
+	case "true":
+		p.pos++
+		return boolExpr(true), nil
+	case "false":
+		p.pos++
+		return boolExpr(false), nil
+	}
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if name.quoted {
+		return nil, fmt.Errorf("expecting field name but got %q", name.text)
+	}
+	f, err := lookupField(name.text)
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch opText := strings.ToLower(op.text); {
+	case op.quoted:
+		return nil, fmt.Errorf("expecting operator after %s but got %q", f.name, op.text)
+	case opText == "exists":
+		return &existsExpr{field: f}, nil
+	case opText == "=":
+		op.text = "=="
+	case !isOperator(opText):
+		return nil, fmt.Errorf("expecting operator after %s but got %q", f.name, op.text)
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !value.quoted && isOperator(value.text) {
+		return nil, fmt.Errorf("expecting value after %s %s but got %q", f.name, op.text, value.text)
+	}
+	return newCompareExpr(f, op.text, value.text)
+}
+
+// isOperator returns true if s is a comparison operator
+func isOperator(s string) bool {
+	switch s {
+	case "==", "!=", "<", "<=", ">", ">=", "~", "!~", "=~", "!=~":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package find
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []token
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "size>1G", want: []token{{text: "size"}, {text: ">"}, {text: "1G"}}},
+		{in: `name ~ "*.mkv" && !(x!=~'a b')`, want: []token{
+			{text: "name"}, {text: "~"}, {text: "*.mkv", quoted: true}, {text: "&&"}, {text: "!"},
+			{text: "("}, {text: "x"}, {text: "!=~"}, {text: "a b", quoted: true}, {text: ")"},
+		}},
+		{in: `mimetype ~ video/*`, want: []token{{text: "mimetype"}, {text: "~"}, {text: "video/*"}}},
+		{in: `name == "a\"b"`, want: []token{{text: "name"}, {text: "=="}, {text: `a"b`, quoted: true}}},
+		{in: `name == "ab`, err: true},
+	} {
+		got, err := tokenize(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: "true"},
+		{in: "size > 1G", want: `size > "1G"`},
+		{in: "a.b", want: `error: unknown field "a.b"`},
+		{in: "NAME = x", want: `name == "x"`},
+		{in: "name ~ '*.jpg' or name ~ '*.png' and size < 1k", want: `(name ~ "*.jpg" or (name ~ "*.png" and size < "1k"))`},
+		{in: "(name ~ '*.jpg' or name ~ '*.png') and size < 1k", want: `((name ~ "*.jpg" or name ~ "*.png") and size < "1k")`},
+		{in: "not not tier exists", want: "not not tier exists"},
+		{in: "hash.MD5 exists and meta.Tier == STANDARD", want: `(hash.md5 exists and meta.tier == "STANDARD")`},
+		{in: "hash.potato exists", want: `error: unknown hash type "potato"`},
+		{in: "size ~ 1G", want: `error: can't use "~" with size`},
+		{in: "size > potato", want: "error: bad value for size: ..."},
+		{in: "name =~ '('", want: "error: bad regexp ..."},
+		{in: "(size > 1G", want: "error: missing )"},
+		{in: "size > 1G size", want: `error: unexpected "size"`},
+		{in: "size >", want: "error: unexpected end of expression"},
+		{in: "size > >", want: `error: expecting value after size > but got ">"`},
+		{in: "size 1G", want: `error: expecting operator after size but got "1G"`},
+		{in: "'size' > 1G", want: `error: expecting field name but got "size"`},
+	} {
+		e, err := Parse(test.in)
+		got := ""
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = e.String()
+		}
+		if want, found := strings.CutSuffix(test.want, "..."); found {
+			assert.Contains(t, got, want, test.in)
+		} else {
+			assert.Equal(t, test.want, got, test.in)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	o := mockobject.New("dir/video.MP4").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	require.NoError(t, o.SetModTime(ctx, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)))
+
+	for _, test := range []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"true", true},
+		{"false", false},
+		{"name == video.MP4", true},
+		{"path == dir/video.MP4", true},
+		{"path ~ 'dir/**'", true},
+		{"name ~ '*.mp4'", false},
+		{"name =~ '(?i)\\.mp4$'", true},
+		{"name !~ '*.mp4'", true},
+		{"name !=~ 'video'", false},
+		{"ext == MP4", true},
+		{"size == 5B", true},
+		{"size > 1k", false},
+		{"size >= 5B and size <= 5B", true},
+		{"mtime >= 2023-01-01 and mtime < 2024-01-01", true},
+		{"mtime < 2023-01-01", false},
+		{"age > 300d and age < 400d", true},
+		{"mimetype ~ 'video/*'", true},
+		{"mimetype == video/mp4", true},
+		{"tier exists", false},
+		{"not tier exists", true},
+		{"tier != STANDARD", false},
+		{"hash.md5 == 5d41402abc4b2a76b9719d911017c592", true},
+		{"hash.md5 exists and size < 1B", false},
+		{"size < 1B or name ~ video.*", true},
+	} {
+		e, err := Parse(test.expr)
+		require.NoError(t, err, test.expr)
+		got, err := Match(ctx, e, o, now)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.want, got, test.expr)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	assert.Equal(t, -1, compare("9", "10"))
+	assert.Equal(t, 1, compare("b", "a"))
+	assert.Equal(t, 0, compare("1.0", "1"))
+	assert.Equal(t, 1, compare(int64(2), int64(1)))
+	assert.Equal(t, -1, compare(time.Second, time.Minute))
+}
@@ -0,0 +1,267 @@
+// Package find provides the find command.
+package find
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/spf13/cobra"
+)
+
+// Actions are the things done to each object which matches
+type Actions struct {
+	Print   bool   // print the path of the object
+	Delete  bool   // delete the object
+	CopyTo  fs.Fs  // copy the object here
+	MoveTo  fs.Fs  // move the object here
+	SetTier string // set the tier of the object to this
+}
+
+// Globals
+var (
+	printPaths = false
+	del        = false
+	copyTo     = ""
+	moveTo     = ""
+	setTier    = ""
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &printPaths, "print", "", printPaths, "Print the path of matching files even if an action is given", "")
+	flags.BoolVarP(cmdFlags, &del, "delete", "", del, "Delete matching files", "")
+	flags.StringVarP(cmdFlags, &copyTo, "copy-to", "", copyTo, "Copy matching files to this remote:path keeping their paths", "")
+	flags.StringVarP(cmdFlags, &moveTo, "move-to", "", moveTo, "Move matching files to this remote:path keeping their paths", "")
+	flags.StringVarP(cmdFlags, &setTier, "set-tier", "", setTier, "Set the storage tier of matching files", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "find remote:path [expression]",
+	Short: `Find files in remote:path which match an expression.`,
+	Long: strings.ReplaceAll(`
+Lists the files in remote:path which match the expression given, and
+optionally does something to each of them.
+
+The expression is a single argument so will usually need quoting. For
+example to find the video files larger than 1 GiB modified in 2023
+which are in the STANDARD tier:
+
+    rclone find remote:path 'size > 1G and mimetype ~ "video/*" and mtime >= 2023-01-01 and mtime < 2024-01-01 and tier == STANDARD'
+
+If no expression is given then all the files match.
+
+The usual [filters](/filtering/) are applied before the expression
+so can be used to cut down the files which need to be tested.
+
+### Expressions
+
+An expression is made of tests combined with |and|, |or|, |not| and
+brackets. |and| binds tighter than |or|, so |a or b and c| means
+|a or (b and c)|.
+
+A test compares a field of the file with a value, eg |size > 1G|, or
+checks a field exists, eg |meta.mtime exists|. Values may be quoted
+with |"| or |'| and must be if they contain spaces, brackets or
+operator characters.
+
+These fields are available:
+
+- |name| - the leaf name of the file
+- |path| - the path of the file relative to remote:path
+- |ext| - the extension of the file without the |.|, eg |jpg|
+- |size| - the size of the file, eg |100B| or |1G|, in KiB if no suffix is given as with |--min-size|
+- |mtime| - the modification time, as a date or a duration ago, eg |2023-01-01| or |7d|
+- |age| - how long ago the file was modified, eg |7d|
+- |mimetype| - the MIME type of the file, eg |video/mp4|
+- |tier| - the storage tier of the file, if the backend has them
+- |hash.TYPE| - the hash of the file, eg |hash.md5|
+- |meta.KEY| - the metadata value KEY of the file, eg |meta.content-type|
+
+These comparisons can be used:
+
+- |==| or |=| - equal
+- |!=| - not equal
+- |<|, |<=|, |>|, |>=| - less than or greater than
+- |~| - matches the glob, which works as in the [filters](/filtering/)
+- |!~| - doesn't match the glob
+- |=~| - matches the regular expression
+- |!=~| - doesn't match the regular expression
+- |exists| - the field has a value (no value is given)
+
+String comparisons are case sensitive. When comparing metadata, values
+which are both numbers are compared numerically.
+
+A test on a field which doesn't exist, for example the hash of a file
+on a backend which doesn't support it, is always false. Note that
+|mimetype| and |tier| cost nothing on most backends, but |hash| and
+|meta| may require an extra transaction per file and |hash| may
+require reading the whole file on the local backend.
+
+### Actions
+
+If no action is given, the paths of matching files are printed one per
+line. Otherwise, these actions are done on each matching file in this
+order:
+
+- |--set-tier TIER| - set the storage tier, as in |rclone settier|
+- |--copy-to remote:path| - copy the file keeping its path relative to remote:path
+- |--move-to remote:path| - move the file keeping its path relative to remote:path
+- |--delete| - delete the file
+
+|--move-to| and |--delete| can't be used together. Use |--print| to
+print the matching paths as well. It is recommended to try the
+expression with |--dry-run| or |--interactive|/|-i| before using
+actions which change things.
+
+The expression is evaluated on |--checkers| files at once and the
+actions are done on |--transfers| files at once, so the paths printed
+aren't in any particular order.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+		"groups":            "Filter,Listing,Important",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 2, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		expr := ""
+		if len(args) > 1 {
+			expr = args[1]
+		}
+		e, err := Parse(expr)
+		if err != nil {
+			log.Fatalf("Failed to parse expression: %v", err)
+		}
+		actions := Actions{
+			Print:   printPaths,
+			Delete:  del,
+			SetTier: setTier,
+		}
+		if copyTo != "" {
+			actions.CopyTo = cmd.NewFsDir([]string{copyTo})
+		}
+		if moveTo != "" {
+			actions.MoveTo = cmd.NewFsDir([]string{moveTo})
+		}
+		if !actions.Delete && actions.CopyTo == nil && actions.MoveTo == nil && actions.SetTier == "" {
+			actions.Print = true
+		}
+		showStats := actions.CopyTo != nil || actions.MoveTo != nil
+		cmd.Run(false, showStats, command, func() error {
+			return Find(context.Background(), fsrc, e, actions, os.Stdout)
+		})
+	},
+}
+
+// do runs the actions on o which has matched
+func (a *Actions) do(ctx context.Context, f fs.Fs, o fs.Object, out io.Writer) (err error) {
+	if a.Print {
+		operations.SyncFprintf(out, "%s\n", o.Remote())
+	}
+	if a.SetTier != "" && !operations.SkipDestructive(ctx, o, "set tier") {
+		err = operations.SetTierFile(ctx, o, a.SetTier)
+		if err != nil {
+			return err
+		}
+	}
+	if a.CopyTo != nil {
+		err = operations.CopyFile(ctx, a.CopyTo, f, o.Remote(), o.Remote())
+		if err != nil {
+			return err
+		}
+	}
+	if a.MoveTo != nil {
+		return operations.MoveFile(ctx, a.MoveTo, f, o.Remote(), o.Remote())
+	}
+	if a.Delete {
+		return operations.DeleteFile(ctx, o)
+	}
+	return nil
+}
+
+// Find walks f calling actions on each object which matches e
+//
+// The expression is evaluated on --checkers objects at once and the
+// actions are run on --transfers objects at once.
+//
+// Errors evaluating the expression or running the actions on an
+// object are logged and counted and the walk continues. The last one
+// is returned.
+func Find(ctx context.Context, f fs.Fs, e Expr, actions Actions, out io.Writer) error {
+	if actions.Delete && actions.MoveTo != nil {
+		return errors.New("can't use --delete and --move-to together")
+	}
+	fs.Debugf(f, "Finding files matching: %v", e)
+	ci := fs.GetConfig(ctx)
+	now := time.Now()
+	var (
+		mu      sync.Mutex
+		lastErr error
+	)
+	recordErr := func(o fs.Object, err error) {
+		err = fs.CountError(err)
+		fs.Errorf(o, "find: %v", err)
+		mu.Lock()
+		lastErr = err
+		mu.Unlock()
+	}
+
+	// Match the objects
+	toMatch := make(chan fs.Object, ci.Checkers)
+	toAct := make(chan fs.Object, ci.Transfers)
+	var matchWg, actWg sync.WaitGroup
+	matchWg.Add(ci.Checkers)
+	for i := 0; i < ci.Checkers; i++ {
+		go func() {
+			defer matchWg.Done()
+			for o := range toMatch {
+				match, err := Match(ctx, e, o, now)
+				if err != nil {
+					recordErr(o, err)
+				} else if match {
+					toAct <- o
+				}
+			}
+		}()
+	}
+
+	// Run the actions on the matches
+	actWg.Add(ci.Transfers)
+	for i := 0; i < ci.Transfers; i++ {
+		go func() {
+			defer actWg.Done()
+			for o := range toAct {
+				if err := actions.do(ctx, f, o, out); err != nil {
+					recordErr(o, err)
+				}
+			}
+		}()
+	}
+
+	err := walk.ListR(ctx, f, "", false, operations.ConfigMaxDepth(ctx, true), walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			toMatch <- o
+		})
+		return nil
+	})
+	close(toMatch)
+	matchWg.Wait()
+	close(toAct)
+	actWg.Wait()
+	if err != nil {
+		return err
+	}
+	return lastErr
+}
@@ -0,0 +1,77 @@
+package find
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t1 = fstest.Time("2017-02-03T04:05:06.499999999Z")
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+// find runs Find on r.Fremote returning the sorted paths printed
+func find(t *testing.T, r *fstest.Run, expr string, actions Actions) []string {
+	e, err := Parse(expr)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, Find(context.Background(), r.Fremote, e, actions, &out))
+	lines := strings.Fields(out.String())
+	sort.Strings(lines)
+	return lines
+}
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteObject(ctx, "small.txt", "a", t1)
+	file2 := r.WriteObject(ctx, "dir/big.txt", strings.Repeat("b", 2048), t1)
+	file3 := r.WriteObject(ctx, "dir/sub/big.jpg", strings.Repeat("c", 4096), t1)
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	assert.Equal(t, []string{"dir/big.txt", "dir/sub/big.jpg", "small.txt"}, find(t, r, "", Actions{Print: true}))
+	assert.Equal(t, []string{"dir/big.txt", "dir/sub/big.jpg"}, find(t, r, "size > 1k", Actions{Print: true}))
+	assert.Equal(t, []string{"dir/sub/big.jpg"}, find(t, r, "size > 1k and not ext == txt", Actions{Print: true}))
+	assert.Equal(t, []string{"small.txt"}, find(t, r, "meta.mtime exists and size < 1k", Actions{Print: true}))
+	assert.Empty(t, find(t, r, "meta.potato exists", Actions{Print: true}))
+
+	// Copy then delete the big text files
+	fdst := r.Flocal
+	assert.Empty(t, find(t, r, "name ~ 'big.txt'", Actions{CopyTo: fdst, Delete: true}))
+	r.CheckLocalItems(t, file2)
+	r.CheckRemoteItems(t, file1, file3)
+
+	// Move the jpg printing it
+	assert.Equal(t, []string{"dir/sub/big.jpg"}, find(t, r, "mimetype == image/jpeg", Actions{Print: true, MoveTo: fdst}))
+	r.CheckLocalItems(t, file2, file3)
+	r.CheckRemoteItems(t, file1)
+
+	e, err := Parse("")
+	require.NoError(t, err)
+	err = Find(ctx, r.Fremote, e, Actions{Delete: true, MoveTo: fdst}, nil)
+	assert.Error(t, err)
+}
+
+func TestFindDryRun(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.DryRun = true
+	r := fstest.NewRun(t)
+	file1 := r.WriteObject(ctx, "file1.txt", "one", t1)
+
+	e, err := Parse("")
+	require.NoError(t, err)
+	require.NoError(t, Find(ctx, r.Fremote, e, Actions{Delete: true}, nil))
+	r.CheckRemoteItems(t, file1)
+}
@@ -0,0 +1,302 @@
+package manifest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// Version is the version of the manifest format written
+const Version = 1
+
+// Header is the first line of a manifest
+type Header struct {
+	Manifest  int           // version of the manifest format
+	Remote    string        // the remote the manifest was made from
+	Created   time.Time     // when the manifest was made
+	Precision time.Duration // precision of the modification times
+	Hashes    []string      // the hashes stored in the manifest
+	Metadata  bool          // set if metadata is stored in the manifest
+}
+
+// Entry is a line of the manifest describing a file
+type Entry struct {
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	Hashes   map[string]string `json:",omitempty"`
+	Metadata fs.Metadata       `json:",omitempty"`
+}
+
+var errorReadOnly = errors.New("manifests are read only")
+
+// Fs is a read only Fs made from the contents of a manifest
+type Fs struct {
+	name     string
+	header   Header
+	hashes   hash.Set
+	features *fs.Features
+	dirs     map[string]fs.DirEntries // directory listings
+	objects  map[string]*Object       // objects by path
+}
+
+// Object is a file described in a manifest
+type Object struct {
+	fs    *Fs
+	entry Entry
+}
+
+// NewFs reads the manifest in the object o and returns an Fs
+// describing its contents.
+//
+// If o has a .gz suffix then it is decompressed.
+func NewFs(ctx context.Context, o fs.Object) (f *Fs, err error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+	var r io.Reader = in
+	if strings.HasSuffix(o.Remote(), ".gz") {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress manifest: %w", err)
+		}
+		defer fs.CheckClose(gz, &err)
+		r = gz
+	}
+	return Read(ctx, fs.ConfigString(o.Fs())+"/"+o.Remote(), r)
+}
+
+// Read the manifest from in and return an Fs describing its
+// contents. The name is used to describe the Fs in logs.
+func Read(ctx context.Context, name string, in io.Reader) (*Fs, error) {
+	f := &Fs{
+		name:    name,
+		dirs:    map[string]fs.DirEntries{},
+		objects: map[string]*Object{},
+	}
+	f.features = (&fs.Features{}).Fill(ctx, f)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		return nil, errors.New("manifest is empty")
+	}
+	err := json.Unmarshal(scanner.Bytes(), &f.header)
+	if err != nil || f.header.Manifest == 0 {
+		return nil, errors.New("not a manifest file - bad header")
+	}
+	if f.header.Manifest > Version {
+		return nil, fmt.Errorf("manifest version %d is too new - upgrade rclone", f.header.Manifest)
+	}
+	for _, name := range f.header.Hashes {
+		var ht hash.Type
+		if err := ht.Set(name); err != nil {
+			fs.Debugf(f, "Ignoring unknown hash %q", name)
+			continue
+		}
+		f.hashes.Add(ht)
+	}
+
+	dirs := map[string]struct{}{}
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		o := &Object{fs: f}
+		err := json.Unmarshal(scanner.Bytes(), &o.entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest line %d: %w", lineNumber, err)
+		}
+		if o.entry.Path == "" {
+			return nil, fmt.Errorf("failed to parse manifest line %d: empty path", lineNumber)
+		}
+		if _, found := f.objects[o.entry.Path]; found {
+			return nil, fmt.Errorf("failed to parse manifest line %d: duplicate path %q", lineNumber, o.entry.Path)
+		}
+		f.objects[o.entry.Path] = o
+		dir := parentDir(o.entry.Path)
+		f.dirs[dir] = append(f.dirs[dir], o)
+		// Make the parent directories
+		for dir != "" {
+			if _, found := dirs[dir]; found {
+				break
+			}
+			dirs[dir] = struct{}{}
+			parent := parentDir(dir)
+			f.dirs[parent] = append(f.dirs[parent], fs.NewDir(dir, f.header.Created))
+			dir = parent
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return f, nil
+}
+
+// parentDir returns the parent directory of remote, "" for the root
+func parentDir(remote string) string {
+	dir := path.Dir(remote)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// Header returns the header of the manifest
+func (f *Fs) Header() Header {
+	return f.header
+}
+
+// Name of the remote
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote
+func (f *Fs) Root() string {
+	return ""
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("manifest %s", f.name)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// Precision of the ModTimes in this Fs
+func (f *Fs) Precision() time.Duration {
+	if f.header.Precision == 0 {
+		return time.Second
+	}
+	return f.header.Precision
+}
+
+// Hashes returns the hashes stored in the manifest
+func (f *Fs) Hashes() hash.Set {
+	return f.hashes
+}
+
+// List the objects and directories in dir into entries.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	entries, found := f.dirs[dir]
+	if !found && dir != "" {
+		return nil, fs.ErrorDirNotFound
+	}
+	return entries, nil
+}
+
+// NewObject finds the Object at remote.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, found := f.objects[remote]
+	if !found {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return o, nil
+}
+
+// Put is not supported
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return nil, errorReadOnly
+}
+
+// Mkdir is not supported
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	return errorReadOnly
+}
+
+// Rmdir is not supported
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	return errorReadOnly
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.entry.Path
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.entry.Path
+}
+
+// Hash returns the hash stored in the manifest
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	if !o.fs.hashes.Contains(t) {
+		return "", hash.ErrUnsupported
+	}
+	return o.entry.Hashes[t.String()], nil
+}
+
+// Size returns the size of the object in bytes
+func (o *Object) Size() int64 {
+	return o.entry.Size
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.entry.ModTime
+}
+
+// Metadata returns the metadata stored in the manifest
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return o.entry.Metadata, nil
+}
+
+// SetModTime is not supported
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	return errorReadOnly
+}
+
+// Storable returns a boolean showing whether this object storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open is not supported as manifests don't contain file data
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	return nil, errors.New("can't read file data from a manifest")
+}
+
+// Update is not supported
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return errorReadOnly
+}
+
+// Remove is not supported
+func (o *Object) Remove(ctx context.Context) error {
+	return errorReadOnly
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs         = (*Fs)(nil)
+	_ fs.Object     = (*Object)(nil)
+	_ fs.Metadataer = (*Object)(nil)
+)
@@ -0,0 +1,250 @@
+// Package manifest provides the manifest command.
+package manifest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	hashTypes []string
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringArrayVarP(cmdFlags, &hashTypes, "hash-type", "", nil, "Store this hash type in the manifest (may be repeated)", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "manifest remote:path [dest:path/to/manifest]",
+	Short: `Make a manifest of the files in a remote.`,
+	Long: strings.ReplaceAll(`
+Write a manifest of the files in remote:path, recording the path,
+size, modification time and hashes of each one, and their metadata if
+|--metadata| is set.
+
+The manifest is written to standard output, or uploaded to
+dest:path/to/manifest if it is given. If the name of the manifest
+ends in |.gz| then it is compressed with gzip.
+
+    rclone manifest s3:backups/2024-06 s3:manifests/2024-06.json.gz
+
+Manifests can be compared with each other, or with a live remote,
+with [rclone diff](/commands/rclone_diff/) without listing the
+remotes again.
+
+By default the manifest stores one of the hashes the remote supports.
+Note that for some remotes, such as local disks, this means reading
+all the files to calculate their hashes. Use
+|--hash-type| (which may be repeated) to choose which hashes are
+stored, or |--hash-type none| to store no hashes.
+
+The manifest is a text file with one JSON object per line. The first
+line is a header describing the manifest, for example
+
+    {"Manifest":1,"Remote":"s3:backups/2024-06","Created":"2024-07-01T02:00:00Z","Precision":1,"Hashes":["md5"],"Metadata":false}
+
+and each following line describes a file
+
+    {"Path":"dir/file.txt","Size":6,"ModTime":"2024-06-03T04:05:06Z","Hashes":{"md5":"b1946ac92492d2347c6235b4d2611184"}}
+
+The usual [filters](/filtering/) can be used to choose which files
+are put in the manifest.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+		"groups":            "Filter,Listing",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 2, command, args)
+		fsrc := cmd.NewFsSrc(args[:1])
+		var (
+			fdst        fs.Fs
+			dstFileName string
+		)
+		if len(args) > 1 {
+			fdst, dstFileName = cmd.NewFsDstFile(args[1:])
+		}
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			hashes, err := chooseHashes(fsrc, hashTypes)
+			if err != nil {
+				return err
+			}
+			if fdst == nil {
+				out := bufio.NewWriter(os.Stdout)
+				err = Write(ctx, fsrc, out, hashes)
+				if err != nil {
+					return err
+				}
+				return out.Flush()
+			}
+			return Upload(ctx, fsrc, fdst, dstFileName, hashes)
+		})
+	},
+}
+
+// chooseHashes works out which hashes to store from the flags
+func chooseHashes(f fs.Fs, hashTypes []string) (hashes hash.Set, err error) {
+	if len(hashTypes) == 0 {
+		return hash.NewHashSet(f.Hashes().GetOne()), nil
+	}
+	for _, name := range hashTypes {
+		var ht hash.Type
+		if err := ht.Set(name); err != nil {
+			return hashes, err
+		}
+		if ht == hash.None {
+			continue
+		}
+		if !f.Hashes().Contains(ht) {
+			return hashes, fmt.Errorf("hash type %v not supported by %v", ht, f)
+		}
+		hashes.Add(ht)
+	}
+	return hashes, nil
+}
+
+// Upload writes the manifest of fsrc with the hashes given to
+// dstFileName in fdst. It is compressed if dstFileName ends in .gz.
+func Upload(ctx context.Context, fsrc fs.Fs, fdst fs.Fs, dstFileName string, hashes hash.Set) error {
+	pr, pw := io.Pipe()
+	go func() {
+		var out io.Writer = pw
+		var gz *gzip.Writer
+		if strings.HasSuffix(dstFileName, ".gz") {
+			gz = gzip.NewWriter(pw)
+			out = gz
+		}
+		err := Write(ctx, fsrc, out, hashes)
+		if err == nil && gz != nil {
+			err = gz.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	_, err := operations.Rcat(ctx, fdst, dstFileName, pr, time.Now(), nil)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	return nil
+}
+
+// Write the manifest of fsrc with the hashes given to out.
+//
+// Metadata is stored if --metadata is set.
+func Write(ctx context.Context, fsrc fs.Fs, out io.Writer, hashes hash.Set) error {
+	ci := fs.GetConfig(ctx)
+	header := Header{
+		Manifest:  Version,
+		Remote:    fs.ConfigString(fsrc),
+		Created:   time.Now().UTC(),
+		Precision: fsrc.Precision(),
+		Hashes:    []string{},
+		Metadata:  ci.Metadata,
+	}
+	for _, ht := range hashes.Array() {
+		header.Hashes = append(header.Hashes, ht.String())
+	}
+
+	// List the files
+	var objs []fs.Object
+	err := walk.ListR(ctx, fsrc, "", false, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			objs = append(objs, o)
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %v: %w", fsrc, err)
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Remote() < objs[j].Remote()
+	})
+
+	// Read the hashes and metadata in parallel
+	entries := make([]Entry, len(objs))
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(ci.Checkers)
+	for i, o := range objs {
+		i, o := i, o
+		g.Go(func() error {
+			entry, err := newEntry(gCtx, o, hashes, ci.Metadata)
+			if err != nil {
+				return err
+			}
+			entries[i] = entry
+			return nil
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	err = enc.Encode(header)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	for i := range entries {
+		err = enc.Encode(&entries[i])
+		if err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// newEntry makes a manifest entry for o
+func newEntry(ctx context.Context, o fs.Object, hashes hash.Set, metadata bool) (entry Entry, err error) {
+	tr := accounting.Stats(ctx).NewCheckingTransfer(o, "listing")
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	entry = Entry{
+		Path:    o.Remote(),
+		Size:    o.Size(),
+		ModTime: o.ModTime(ctx).UTC(),
+	}
+	for _, ht := range hashes.Array() {
+		var sum string
+		sum, err = o.Hash(ctx, ht)
+		if err != nil {
+			return entry, fmt.Errorf("failed to read %v hash of %q: %w", ht, o.Remote(), err)
+		}
+		if sum == "" {
+			continue
+		}
+		if entry.Hashes == nil {
+			entry.Hashes = map[string]string{}
+		}
+		entry.Hashes[ht.String()] = sum
+	}
+	if metadata {
+		entry.Metadata, err = fs.GetMetadata(ctx, o)
+		if err != nil {
+			return entry, fmt.Errorf("failed to read metadata of %q: %w", o.Remote(), err)
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,96 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t1 = fstest.Time("2017-02-03T04:05:06.499999999Z")
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestWriteRead(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("hello.txt", "hello world", t1)
+	file2 := r.WriteFile("dir/sub/file2.txt", "the contents of file 2", t1)
+
+	var buf bytes.Buffer
+	err := Write(ctx, r.Flocal, &buf, hash.NewHashSet(hash.MD5))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[0], `"Manifest":1`)
+	assert.Contains(t, lines[1], `"Path":"dir/sub/file2.txt"`)
+	assert.Contains(t, lines[2], `"Path":"hello.txt"`)
+
+	f, err := Read(ctx, "test", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, hash.NewHashSet(hash.MD5), f.Hashes())
+	assert.Equal(t, r.Flocal.Precision(), f.Precision())
+	fstest.CheckListingWithPrecision(t, f, []fstest.Item{file1, file2}, []string{"dir", "dir/sub"}, fs.GetModifyWindow(ctx, f))
+
+	// Check the hashes match the files
+	o, err := f.NewObject(ctx, "hello.txt")
+	require.NoError(t, err)
+	src, err := r.Flocal.NewObject(ctx, "hello.txt")
+	require.NoError(t, err)
+	equal, ht, err := operations.CheckHashes(ctx, src, o)
+	require.NoError(t, err)
+	assert.Equal(t, hash.MD5, ht)
+	assert.True(t, equal)
+
+	_, err = f.List(ctx, "notfound")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+	_, err = f.NewObject(ctx, "notfound")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+}
+
+func TestReadBad(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"", "manifest is empty"},
+		{"hello\n", "bad header"},
+		{`{"Manifest":99}` + "\n", "too new"},
+		{`{"Manifest":1}` + "\n" + "{\n", "line 2"},
+		{`{"Manifest":1}` + "\n" + `{"Size":1}` + "\n", "empty path"},
+		{`{"Manifest":1}` + "\n" + `{"Path":"a"}` + "\n" + `{"Path":"a"}` + "\n", "line 3: duplicate path \"a\""},
+	} {
+		_, err := Read(ctx, "test", strings.NewReader(test.in))
+		assert.ErrorContains(t, err, test.want, test.in)
+	}
+}
+
+func TestChooseHashes(t *testing.T) {
+	r := fstest.NewRun(t)
+	hashes, err := chooseHashes(r.Flocal, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, hashes.Count())
+
+	hashes, err = chooseHashes(r.Flocal, []string{"none"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, hashes.Count())
+
+	hashes, err = chooseHashes(r.Flocal, []string{"md5", "sha1"})
+	require.NoError(t, err)
+	assert.Equal(t, hash.NewHashSet(hash.MD5, hash.SHA1), hashes)
+
+	_, err = chooseHashes(r.Flocal, []string{"potato"})
+	assert.Error(t, err)
+}
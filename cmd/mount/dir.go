@@ -167,6 +167,20 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (node fusefs.No
 	return node, nil
 }
 
+var _ fusefs.NodeSymlinker = (*Dir)(nil)
+
+// Symlink creates a new symbolic link in the receiver, which must be a directory.
+func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (node fusefs.Node, err error) {
+	defer log.Trace(d, "name=%q, target=%q", req.NewName, req.Target)("node=%+v, err=%v", &node, &err)
+	file, err := d.Dir.Symlink(req.Target, req.NewName)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	node = &File{file, d.fsys}
+	file.SetSys(node) // cache the FUSE node for later
+	return node, nil
+}
+
 var _ fusefs.NodeRemover = (*Dir)(nil)
 
 // Remove removes the entry with the given name from
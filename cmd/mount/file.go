@@ -33,6 +33,9 @@ func (f *File) Attr(ctx context.Context, a *fuse.Attr) (err error) {
 	a.Gid = f.VFS().Opt.GID
 	a.Uid = f.VFS().Opt.UID
 	a.Mode = os.FileMode(f.VFS().Opt.FilePerms)
+	if f.File.Mode()&os.ModeSymlink != 0 {
+		a.Mode = f.File.Mode()
+	}
 	a.Size = Size
 	a.Atime = modTime
 	a.Mtime = modTime
@@ -96,27 +99,58 @@ func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 	return nil
 }
 
+var _ fusefs.NodeReadlinker = (*File)(nil)
+
+// Readlink reads the target of a symbolic link.
+func (f *File) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (target string, err error) {
+	defer log.Trace(f, "")("target=%q, err=%v", &target, &err)
+	target, err = f.File.Readlink()
+	return target, translateError(err)
+}
+
 // Getxattr gets an extended attribute by the given name from the
 // node.
 //
 // If there is no xattr by that name, returns fuse.ErrNoXattr.
-func (f *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return syscall.ENOSYS // we never implement this
+func (f *File) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) (err error) {
+	if !f.fsys.opt.Xattr {
+		return syscall.ENOSYS // only implemented with --xattr
+	}
+	defer log.Trace(f, "name=%q", req.Name)("value=%q, err=%v", &resp.Xattr, &err)
+	value, err := f.File.GetXattr(ctx, req.Name)
+	if err != nil {
+		return translateError(err)
+	}
+	resp.Xattr = value
+	return nil
 }
 
 var _ fusefs.NodeGetxattrer = (*File)(nil)
 
 // Listxattr lists the extended attributes recorded for the node.
-func (f *File) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return syscall.ENOSYS // we never implement this
+func (f *File) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) (err error) {
+	if !f.fsys.opt.Xattr {
+		return syscall.ENOSYS // only implemented with --xattr
+	}
+	defer log.Trace(f, "")("err=%v", &err)
+	names, err := f.File.ListXattr(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	resp.Append(names...)
+	return nil
 }
 
 var _ fusefs.NodeListxattrer = (*File)(nil)
 
 // Setxattr sets an extended attribute with the given name and
 // value for the node.
-func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	return syscall.ENOSYS // we never implement this
+func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) (err error) {
+	if !f.fsys.opt.Xattr {
+		return syscall.ENOSYS // only implemented with --xattr
+	}
+	defer log.Trace(f, "name=%q, value=%q", req.Name, req.Xattr)("err=%v", &err)
+	return translateError(f.File.SetXattr(ctx, req.Name, req.Xattr))
 }
 
 var _ fusefs.NodeSetxattrer = (*File)(nil)
@@ -100,6 +100,10 @@ func translateError(err error) error {
 		return syscall.ENOSYS
 	case vfs.EINVAL:
 		return fuse.Errno(syscall.EINVAL)
+	case vfs.ENOATTR:
+		return fuse.ErrNoXattr
+	case vfs.EAGAIN:
+		return fuse.Errno(syscall.EAGAIN)
 	}
 	fs.Errorf(nil, "IO error: %v", err)
 	return err
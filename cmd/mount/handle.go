@@ -5,11 +5,14 @@ package mount
 import (
 	"context"
 	"io"
+	"syscall"
 
 	"bazil.org/fuse"
 	fusefs "bazil.org/fuse/fs"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 )
 
 // FileHandle is an open for read file handle on a File
@@ -67,6 +70,8 @@ var _ fusefs.HandleFlusher = (*FileHandle)(nil)
 // some writes, or that if will be called at all.
 func (fh *FileHandle) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 	defer log.Trace(fh, "")("err=%v", &err)
+	// POSIX locks are released on any close of the file
+	fh.releaseLocks(ctx, req.LockOwner)
 	return translateError(fh.Handle.Flush())
 }
 
@@ -78,5 +83,114 @@ var _ fusefs.HandleReleaser = (*FileHandle)(nil)
 // the kernel
 func (fh *FileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error) {
 	defer log.Trace(fh, "")("err=%v", &err)
+	// flock locks are released on the final close of the file
+	if req.ReleaseFlags&fuse.ReleaseFlockUnlock != 0 {
+		fh.releaseLocks(ctx, req.LockOwner)
+	}
 	return translateError(fh.Handle.Release())
 }
+
+// Check interface satisfied
+var _ fusefs.HandleLocker = (*FileHandle)(nil)
+
+// fileLock converts a FUSE lock held by owner into a VFS lock
+func fileLock(lk fuse.FileLock, owner fuse.LockOwner) vfs.FileLock {
+	out := vfs.FileLock{
+		Start: int64(lk.Start),
+		End:   vfs.LockEOF,
+		Type:  vfs.LockUnlock,
+		Owner: uint64(owner),
+		PID:   lk.PID,
+	}
+	if lk.End < vfs.LockEOF {
+		out.End = int64(lk.End)
+	}
+	switch lk.Type {
+	case fuse.LockRead:
+		out.Type = vfs.LockRead
+	case fuse.LockWrite:
+		out.Type = vfs.LockWrite
+	}
+	return out
+}
+
+// file returns the file the handle is open on or nil if it isn't a file
+func (fh *FileHandle) file() *vfs.File {
+	file, _ := fh.Handle.Node().(*vfs.File)
+	return file
+}
+
+// setLock takes or releases the lock in req on the file, waiting
+// for it if wait is set
+func (fh *FileHandle) setLock(ctx context.Context, req *fuse.LockRequest, wait bool) error {
+	file := fh.file()
+	if file == nil {
+		return fuse.Errno(syscall.EBADF)
+	}
+	err := file.SetLock(ctx, fileLock(req.Lock, req.LockOwner), wait)
+	if err != nil && ctx.Err() != nil {
+		return fuse.Errno(syscall.EINTR)
+	}
+	return translateError(err)
+}
+
+// Lock tries to take a lock on a byte range of the file, returning
+// EAGAIN if a conflicting lock is held
+func (fh *FileHandle) Lock(ctx context.Context, req *fuse.LockRequest) (err error) {
+	defer log.Trace(fh, "lock=%+v, owner=%v, flags=%v", req.Lock, req.LockOwner, req.LockFlags)("err=%v", &err)
+	return fh.setLock(ctx, req, false)
+}
+
+// LockWait takes a lock on a byte range of the file, waiting until
+// any conflicting locks are released
+func (fh *FileHandle) LockWait(ctx context.Context, req *fuse.LockWaitRequest) (err error) {
+	defer log.Trace(fh, "lock=%+v, owner=%v, flags=%v", req.Lock, req.LockOwner, req.LockFlags)("err=%v", &err)
+	lockReq := fuse.LockRequest(*req)
+	return fh.setLock(ctx, &lockReq, true)
+}
+
+// Unlock releases a lock on a byte range of the file
+func (fh *FileHandle) Unlock(ctx context.Context, req *fuse.UnlockRequest) (err error) {
+	defer log.Trace(fh, "lock=%+v, owner=%v, flags=%v", req.Lock, req.LockOwner, req.LockFlags)("err=%v", &err)
+	lockReq := fuse.LockRequest(*req)
+	return fh.setLock(ctx, &lockReq, false)
+}
+
+// QueryLock returns a lock which conflicts with the one in req if
+// there is one
+func (fh *FileHandle) QueryLock(ctx context.Context, req *fuse.QueryLockRequest, resp *fuse.QueryLockResponse) (err error) {
+	defer log.Trace(fh, "lock=%+v, owner=%v, flags=%v", req.Lock, req.LockOwner, req.LockFlags)("lock=%+v, err=%v", &resp.Lock, &err)
+	file := fh.file()
+	if file == nil {
+		return fuse.Errno(syscall.EBADF)
+	}
+	held, err := file.GetLock(ctx, fileLock(req.Lock, req.LockOwner))
+	if err != nil {
+		return translateError(err)
+	}
+	if held.Type == vfs.LockUnlock {
+		return nil
+	}
+	resp.Lock = fuse.FileLock{
+		Start: uint64(held.Start),
+		End:   uint64(held.End),
+		Type:  fuse.LockRead,
+		PID:   held.PID,
+	}
+	if held.Type == vfs.LockWrite {
+		resp.Lock.Type = fuse.LockWrite
+	}
+	return nil
+}
+
+// releaseLocks releases the locks held by owner on the file if locks
+// are enabled
+func (fh *FileHandle) releaseLocks(ctx context.Context, owner fuse.LockOwner) {
+	file := fh.file()
+	if file == nil || file.VFS().Opt.Locks == vfscommon.LocksOff {
+		return
+	}
+	if err := file.ReleaseLocks(ctx, uint64(owner)); err != nil {
+		fs.Errorf(file, "Failed to release locks: %v", err)
+	}
+}
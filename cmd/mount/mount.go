@@ -13,6 +13,7 @@ import (
 	"github.com/rclone/rclone/cmd/mountlib"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 )
 
 func init() {
@@ -47,6 +48,9 @@ func mountOptions(VFS *vfs.VFS, device string, opt *mountlib.Options) (options [
 	if VFS.Opt.ReadOnly {
 		options = append(options, fuse.ReadOnly())
 	}
+	if VFS.Opt.Locks != vfscommon.LocksOff {
+		options = append(options, fuse.LockingFlock(), fuse.LockingPOSIX())
+	}
 	if opt.WritebackCache {
 		options = append(options, fuse.WritebackCache())
 	}
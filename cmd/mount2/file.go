@@ -6,10 +6,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
 	"syscall"
 
 	fusefs "github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/vfs"
 )
@@ -35,6 +37,9 @@ import (
 type FileHandle struct {
 	h    vfs.Handle
 	fsys *FS
+
+	mu     sync.Mutex          // protects owners
+	owners map[uint64]struct{} // owners which have taken locks through this handle
 }
 
 // Create a new FileHandle
@@ -95,6 +100,7 @@ var _ fusefs.FileFlusher = (*FileHandle)(nil)
 // so any cleanup that requires specific synchronization or
 // could fail with I/O errors should happen in Flush instead.
 func (f *FileHandle) Release(ctx context.Context) syscall.Errno {
+	f.releaseLocks(ctx)
 	return translateError(f.h.Release())
 }
 
@@ -149,3 +155,116 @@ func (f *FileHandle) Setattr(ctx context.Context, in *fuse.SetAttrIn, out *fuse.
 }
 
 var _ fusefs.FileSetattrer = (*FileHandle)(nil)
+
+// fileLock converts a FUSE lock held by owner into a VFS lock
+func fileLock(lk *fuse.FileLock, owner uint64) vfs.FileLock {
+	out := vfs.FileLock{
+		Start: int64(lk.Start),
+		End:   vfs.LockEOF,
+		Type:  vfs.LockUnlock,
+		Owner: owner,
+		PID:   int32(lk.Pid),
+	}
+	if lk.End < vfs.LockEOF {
+		out.End = int64(lk.End)
+	}
+	switch lk.Typ {
+	case syscall.F_RDLCK:
+		out.Type = vfs.LockRead
+	case syscall.F_WRLCK:
+		out.Type = vfs.LockWrite
+	}
+	return out
+}
+
+// file returns the file the handle is open on or nil if it isn't a file
+func (f *FileHandle) file() *vfs.File {
+	file, _ := f.h.Node().(*vfs.File)
+	return file
+}
+
+// setLock takes or releases the lock lk held by owner on the file,
+// waiting for it if wait is set
+func (f *FileHandle) setLock(ctx context.Context, owner uint64, lk *fuse.FileLock, wait bool) syscall.Errno {
+	file := f.file()
+	if file == nil {
+		return syscall.EBADF
+	}
+	f.mu.Lock()
+	if f.owners == nil {
+		f.owners = make(map[uint64]struct{})
+	}
+	f.owners[owner] = struct{}{}
+	f.mu.Unlock()
+	err := file.SetLock(ctx, fileLock(lk, owner), wait)
+	if err != nil && ctx.Err() != nil {
+		return syscall.EINTR
+	}
+	return translateError(err)
+}
+
+// Getlk returns a lock which conflicts with lk in out if there is one
+func (f *FileHandle) Getlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32, out *fuse.FileLock) (errno syscall.Errno) {
+	defer log.Trace(f, "owner=%d, lk=%+v, flags=%d", owner, *lk, flags)("out=%+v, errno=%v", out, &errno)
+	file := f.file()
+	if file == nil {
+		return syscall.EBADF
+	}
+	held, err := file.GetLock(ctx, fileLock(lk, owner))
+	if err != nil {
+		return translateError(err)
+	}
+	*out = fuse.FileLock{
+		Start: uint64(held.Start),
+		End:   uint64(held.End),
+		Typ:   syscall.F_UNLCK,
+		Pid:   uint32(held.PID),
+	}
+	switch held.Type {
+	case vfs.LockRead:
+		out.Typ = syscall.F_RDLCK
+	case vfs.LockWrite:
+		out.Typ = syscall.F_WRLCK
+	}
+	return 0
+}
+
+var _ fusefs.FileGetlker = (*FileHandle)(nil)
+
+// Setlk tries to take or release lk, returning EAGAIN if a
+// conflicting lock is held
+func (f *FileHandle) Setlk(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) (errno syscall.Errno) {
+	defer log.Trace(f, "owner=%d, lk=%+v, flags=%d", owner, *lk, flags)("errno=%v", &errno)
+	return f.setLock(ctx, owner, lk, false)
+}
+
+var _ fusefs.FileSetlker = (*FileHandle)(nil)
+
+// Setlkw takes or releases lk, waiting until any conflicting locks
+// are released
+func (f *FileHandle) Setlkw(ctx context.Context, owner uint64, lk *fuse.FileLock, flags uint32) (errno syscall.Errno) {
+	defer log.Trace(f, "owner=%d, lk=%+v, flags=%d", owner, *lk, flags)("errno=%v", &errno)
+	return f.setLock(ctx, owner, lk, true)
+}
+
+var _ fusefs.FileSetlkwer = (*FileHandle)(nil)
+
+// releaseLocks releases any locks taken through the handle.
+//
+// The kernel normally releases these itself before the handle is
+// released, so this is a backstop.
+func (f *FileHandle) releaseLocks(ctx context.Context) {
+	f.mu.Lock()
+	owners := f.owners
+	f.owners = nil
+	f.mu.Unlock()
+	file := f.file()
+	if file == nil {
+		return
+	}
+	for owner := range owners {
+		if err := file.ReleaseLocks(ctx, owner); err != nil {
+			fs.Errorf(file, "Failed to release locks: %v", err)
+		}
+	}
+}
@@ -357,6 +357,42 @@ func (n *Node) Create(ctx context.Context, name string, flags uint32, mode uint3
 
 var _ = (fusefs.NodeCreater)((*Node)(nil))
 
+// Symlink is similar to Lookup, but must create a symbolic link
+// called name pointing to target.
+func (n *Node) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (inode *fusefs.Inode, errno syscall.Errno) {
+	defer log.Trace(n, "name=%q, target=%q", name, target)("inode=%v, errno=%v", &inode, &errno)
+	dir, ok := n.node.(*vfs.Dir)
+	if !ok {
+		return nil, syscall.ENOTDIR
+	}
+	file, err := dir.Symlink(target, name)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	newNode := newNode(n.fsys, file)
+	n.fsys.setEntryOut(newNode.node, out)
+	newInode := n.NewInode(ctx, newNode, fusefs.StableAttr{Mode: out.Attr.Mode})
+	return newInode, 0
+}
+
+var _ = (fusefs.NodeSymlinker)((*Node)(nil))
+
+// Readlink reads the content of a symlink.
+func (n *Node) Readlink(ctx context.Context) (target []byte, errno syscall.Errno) {
+	defer log.Trace(n, "")("target=%q, errno=%v", &target, &errno)
+	file, ok := n.node.(*vfs.File)
+	if !ok {
+		return nil, syscall.EINVAL
+	}
+	link, err := file.Readlink()
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return []byte(link), 0
+}
+
+var _ = (fusefs.NodeReadlinker)((*Node)(nil))
+
 // Unlink should remove a child from this directory.  If the
 // return status is OK, the Inode is removed as child in the
 // FS tree automatically. Default is to return EROFS.
@@ -411,8 +447,23 @@ var _ = (fusefs.NodeRenamer)((*Node)(nil))
 // `dest` and return the number of bytes. If `dest` is too
 // small, it should return ERANGE and the size of the attribute.
 // If not defined, Getxattr will return ENOATTR.
-func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
-	return 0, syscall.ENOSYS // we never implement this
+func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (size uint32, errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return 0, syscall.ENOSYS // only implemented with --xattr
+	}
+	defer log.Trace(n, "attr=%q", attr)("size=%d, errno=%v", &size, &errno)
+	file, ok := n.node.(*vfs.File)
+	if !ok {
+		return 0, syscall.Errno(fuse.ENOATTR)
+	}
+	value, err := file.GetXattr(ctx, attr)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	if len(value) > len(dest) {
+		return uint32(len(value)), syscall.ERANGE
+	}
+	return uint32(copy(dest, value)), 0
 }
 
 var _ fusefs.NodeGetxattrer = (*Node)(nil)
@@ -420,8 +471,16 @@ var _ fusefs.NodeGetxattrer = (*Node)(nil)
 // Setxattr should store data for the given attribute.  See
 // setxattr(2) for information about flags.
 // If not defined, Setxattr will return ENOATTR.
-func (n *Node) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) syscall.Errno {
-	return syscall.ENOSYS // we never implement this
+func (n *Node) Setxattr(ctx context.Context, attr string, data []byte, flags uint32) (errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return syscall.ENOSYS // only implemented with --xattr
+	}
+	defer log.Trace(n, "attr=%q, data=%q, flags=%d", attr, data, flags)("errno=%v", &errno)
+	file, ok := n.node.(*vfs.File)
+	if !ok {
+		return syscall.EPERM
+	}
+	return translateError(file.SetXattr(ctx, attr, data))
 }
 
 var _ fusefs.NodeSetxattrer = (*Node)(nil)
@@ -438,8 +497,28 @@ var _ fusefs.NodeRemovexattrer = (*Node)(nil)
 // `dest`. If the `dest` buffer is too small, it should return ERANGE
 // and the correct size.  If not defined, return an empty list and
 // success.
-func (n *Node) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
-	return 0, syscall.ENOSYS // we never implement this
+func (n *Node) Listxattr(ctx context.Context, dest []byte) (size uint32, errno syscall.Errno) {
+	if !n.fsys.opt.Xattr {
+		return 0, syscall.ENOSYS // only implemented with --xattr
+	}
+	defer log.Trace(n, "")("size=%d, errno=%v", &size, &errno)
+	file, ok := n.node.(*vfs.File)
+	if !ok {
+		return 0, 0
+	}
+	names, err := file.ListXattr(ctx)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	var buf []byte
+	for _, name := range names {
+		buf = append(buf, name...)
+		buf = append(buf, 0)
+	}
+	if len(buf) > len(dest) {
+		return uint32(len(buf)), syscall.ERANGE
+	}
+	return uint32(copy(dest, buf)), 0
 }
 
 var _ fusefs.NodeListxattrer = (*Node)(nil)
@@ -139,6 +139,11 @@ var OptionsInfo = fs.Options{{
 	Default: false,
 	Help:    "Ignore all \"com.apple.*\" extended attributes (supported on OSX only)",
 	Groups:  "Mount",
+}, {
+	Name:    "xattr",
+	Default: false,
+	Help:    "Expose the hashes, tier and metadata of files as extended attributes",
+	Groups:  "Mount",
 }, {
 	Name:    "network_mode",
 	Default: false,
@@ -185,6 +190,7 @@ type Options struct {
 	VolumeName         string        `config:"volname"`
 	NoAppleDouble      bool          `config:"noappledouble"`
 	NoAppleXattr       bool          `config:"noapplexattr"`
+	Xattr              bool          `config:"xattr"`
 	DaemonTimeout      fs.Duration   `config:"daemon_timeout"` // OSXFUSE only
 	AsyncRead          bool          `config:"async_read"`
 	NetworkMode        bool          `config:"network_mode"` // Windows only
@@ -0,0 +1,418 @@
+//go:build !plan9 && !js
+
+package ncdu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rclone/rclone/cmd/ncdu/scan"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/sync"
+)
+
+// statsGroup is the stats group used for copies and moves so their
+// progress can be shown separately
+const statsGroup = "ncdu"
+
+// previewSize is the maximum number of bytes read to preview a file
+const previewSize = 64 * 1024
+
+// errBinary is returned when trying to preview a binary file
+var errBinary = errors.New("file doesn't look like text")
+
+// transfer is a copy or move between the panes
+type transfer struct {
+	move    bool          // move rather than copy
+	src     *pane         // pane the entries came from
+	srcDir  *scan.Dir     // directory the entries came from
+	dst     *pane         // pane the entries are going to
+	dstDir  string        // directory in dst.f they are going to
+	entries fs.DirEntries // entries being transferred
+	stats   *accounting.StatsInfo
+	cancel  func()
+}
+
+// verb returns a description of the transfer
+func (t *transfer) verb() string {
+	if t.move {
+		return "Moving"
+	}
+	return "Copying"
+}
+
+// progress returns a one line summary of the progress of the transfer
+func (t *transfer) progress(humanReadable bool) string {
+	out, err := t.stats.RemoteStats()
+	if err != nil {
+		return fmt.Sprintf("%s: %v", t.verb(), err)
+	}
+	bytes, _ := out.GetInt64("bytes")
+	totalBytes, _ := out.GetInt64("totalBytes")
+	transfers, _ := out.GetInt64("transfers")
+	totalTransfers, _ := out.GetInt64("totalTransfers")
+	speed, _ := out.GetFloat64("speed")
+	return fmt.Sprintf("%s %d items to %s: %s / %s, %d / %d files, %s/s",
+		t.verb(), len(t.entries), fspath.JoinRootPath(t.dst.fsName, t.dstDir),
+		operations.SizeString(bytes, humanReadable), operations.SizeString(totalBytes, humanReadable),
+		transfers, totalTransfers, operations.SizeString(int64(speed), humanReadable))
+}
+
+// cursorEntry returns the entry under the cursor or nil if there isn't one
+func (u *UI) cursorEntry() fs.DirEntry {
+	if u.d == nil || len(u.entries) == 0 {
+		return nil
+	}
+	cursorPos := u.dirPosMap[u.path]
+	return u.entries[u.sortPerm[cursorPos.entry]]
+}
+
+// chosenEntries returns the selected entries, or the entry under the
+// cursor if there are none selected
+func (u *UI) chosenEntries() (entries fs.DirEntries) {
+	if len(u.selectedEntries) == 0 {
+		if entry := u.cursorEntry(); entry != nil {
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	for _, cursorPos := range u.selectedEntries {
+		entries = append(entries, u.entries[u.sortPerm[cursorPos.entry]])
+	}
+	sort.Sort(entries)
+	return entries
+}
+
+// startTransfer asks whether to copy or move the chosen entries to
+// the current directory of the other pane and starts doing it in the
+// background
+func (u *UI) startTransfer(move bool) {
+	other := u.otherPane()
+	switch {
+	case other == nil:
+		u.popupBox([]string{"error:", "press o to open a remote in the other pane first"})
+		return
+	case other.d == nil:
+		u.popupBox([]string{"error:", "the other pane hasn't been read yet"})
+		return
+	case u.transfer != nil:
+		u.popupBox([]string{"error:", "wait for the current transfer to finish"})
+		return
+	}
+	entries := u.chosenEntries()
+	if len(entries) == 0 {
+		return
+	}
+	t := &transfer{
+		move:    move,
+		src:     u.pane,
+		srcDir:  u.d,
+		dst:     other,
+		dstDir:  other.d.Path(),
+		entries: entries,
+	}
+	what := "Copy"
+	if move {
+		what = "Move"
+	}
+	u.boxMenu = []string{"cancel", "confirm"}
+	u.boxMenuHandler = func(f fs.Fs, p string, o int) (string, error) {
+		if o != 1 {
+			return "Aborted!", nil
+		}
+		ctx := accounting.WithStatsGroup(context.Background(), statsGroup)
+		ctx, t.cancel = context.WithCancel(ctx)
+		t.stats = accounting.StatsGroup(ctx, statsGroup)
+		t.stats.ResetCounters()
+		u.transfer = t
+		go func() {
+			u.transferDone <- transferEntries(ctx, t.src.f, t.entries, t.dst.f, t.dstDir, t.move)
+		}()
+		return fmt.Sprintf("%s started - progress is shown at the bottom", what), nil
+	}
+	u.popupBox([]string{
+		fmt.Sprintf("%s %d items?", what, len(entries)),
+		fmt.Sprintf("from %s", u.path),
+		fmt.Sprintf("to %s", other.path)})
+}
+
+// finishTransfer updates the panes once the transfer has finished
+// with err
+func (u *UI) finishTransfer(err error) {
+	t := u.transfer
+	u.transfer = nil
+	t.cancel()
+
+	// The destination has new entries so needs reading again.
+	// The source only changes on a move and can be patched up
+	// unless something went wrong.
+	if u.hasPane(t.dst) {
+		t.dst.scan()
+	}
+	if t.move && u.hasPane(t.src) {
+		if err != nil {
+			t.src.scan()
+		} else {
+			u.withPane(t.src, func() {
+				u.removeEntries(t.srcDir, t.entries)
+			})
+		}
+	}
+
+	if err != nil {
+		u.popupBox([]string{"error:", err.Error()})
+		return
+	}
+	u.popupBox([]string{"Finished:", fmt.Sprintf("%s %d items done", t.verb(), len(t.entries))})
+}
+
+// removeEntries removes entries from d in the current pane
+func (u *UI) removeEntries(d *scan.Dir, entries fs.DirEntries) {
+	for _, entry := range entries {
+		for i, dirEntry := range d.Entries() {
+			if dirEntry.Remote() == entry.Remote() {
+				d.Remove(i)
+				break
+			}
+		}
+	}
+	if d == u.d {
+		u.setCurrentDir(d)
+		if cursorPos := u.dirPosMap[u.path]; cursorPos.entry >= len(u.entries) {
+			u.move(-1) // move back onto a valid entry
+		}
+	}
+}
+
+// transferEntries copies or moves entries from fsrc into the
+// directory dstDir of fdst
+func transferEntries(ctx context.Context, fsrc fs.Fs, entries fs.DirEntries, fdst fs.Fs, dstDir string, move bool) error {
+	var errCount int
+	var lastErr error
+	for _, entry := range entries {
+		dstRemote := path.Join(dstDir, path.Base(entry.Remote()))
+		var err error
+		switch entry.(type) {
+		case fs.Object:
+			if move {
+				err = operations.MoveFile(ctx, fdst, fsrc, dstRemote, entry.Remote())
+			} else {
+				err = operations.CopyFile(ctx, fdst, fsrc, dstRemote, entry.Remote())
+			}
+		case fs.Directory:
+			err = transferDir(ctx, fsrc, entry.Remote(), fdst, dstRemote, move)
+		}
+		if err != nil {
+			fs.Errorf(entry, "Failed to transfer: %v", err)
+			errCount++
+			lastErr = err
+		}
+	}
+	if errCount > 0 {
+		return fmt.Errorf("failed to transfer %d items: last error: %w", errCount, lastErr)
+	}
+	return nil
+}
+
+// transferDir copies or moves the directory srcRemote in fsrc to
+// dstRemote in fdst
+func transferDir(ctx context.Context, fsrc fs.Fs, srcRemote string, fdst fs.Fs, dstRemote string, move bool) error {
+	srcFs, err := cache.Get(ctx, fspath.JoinRootPath(fs.ConfigString(fsrc), srcRemote))
+	if err != nil {
+		return err
+	}
+	dstFs, err := cache.Get(ctx, fspath.JoinRootPath(fs.ConfigString(fdst), dstRemote))
+	if err != nil {
+		return err
+	}
+	if !move {
+		return sync.CopyDir(ctx, dstFs, srcFs, true)
+	}
+	err = sync.MoveDir(ctx, dstFs, srcFs, true, true)
+	if err != nil {
+		return err
+	}
+	// MoveDir leaves the source directory behind unless it did
+	// a server-side move
+	err = operations.TryRmdir(ctx, fsrc, srcRemote)
+	if err != nil && !errors.Is(err, fs.ErrorDirNotFound) {
+		fs.Debugf(fsrc, "Failed to remove %q after move: %v", srcRemote, err)
+	}
+	return nil
+}
+
+// readStart reads up to n bytes from the start of o
+func readStart(ctx context.Context, o fs.Object, n int64) (data []byte, err error) {
+	tr := accounting.Stats(ctx).NewTransfer(o, nil)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	var options []fs.OpenOption
+	if o.Size() < 0 || o.Size() > n {
+		options = append(options, &fs.RangeOption{Start: 0, End: n - 1})
+	}
+	var in io.ReadCloser
+	in, err = operations.Open(ctx, o, options...)
+	if err != nil {
+		return nil, err
+	}
+	in = tr.Account(ctx, in)
+	defer fs.CheckClose(in, &err)
+	return io.ReadAll(io.LimitReader(in, n))
+}
+
+// previewLines returns up to maxLines lines of text from data, which
+// may have been cut off part way through a character
+func previewLines(data []byte, maxLines int) ([]string, error) {
+	// Remove any partial character at the end
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		r, size := utf8.DecodeLastRune(data)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return nil, errBinary
+	}
+	text := strings.ReplaceAll(string(data), "\r", "")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+	return lines, nil
+}
+
+// preview shows the start of the file under the cursor
+func (u *UI) preview() {
+	o, ok := u.cursorEntry().(fs.Object)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	_, h := u.s.Size()
+	title := fmt.Sprintf("Preview of %s", fspath.JoinRootPath(u.fsName, o.Remote()))
+	data, err := readStart(ctx, o, previewSize)
+	var lines []string
+	if err == nil {
+		lines, err = previewLines(data, h-6)
+	}
+	if err != nil {
+		u.popupBox([]string{title, err.Error()})
+		return
+	}
+	u.popupBox(append([]string{title}, lines...))
+}
+
+// entryInfo returns lines describing entry in f, including its
+// hashes and metadata
+func entryInfo(ctx context.Context, f fs.Fs, fsName string, entry fs.DirEntry) (lines []string) {
+	lines = append(lines,
+		fmt.Sprintf("Info for %s", fspath.JoinRootPath(fsName, entry.Remote())),
+		fmt.Sprintf("Size: %d", entry.Size()),
+		fmt.Sprintf("Modified: %s", entry.ModTime(ctx).Local().Format("2006-01-02 15:04:05.000000000")),
+	)
+	if o, ok := entry.(fs.Object); ok {
+		lines = append(lines, fmt.Sprintf("MIME type: %s", fs.MimeType(ctx, o)))
+		for _, ht := range f.Hashes().Array() {
+			sum, err := o.Hash(ctx, ht)
+			if err != nil {
+				sum = fmt.Sprintf("error: %v", err)
+			} else if sum == "" {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%v: %s", ht, sum))
+		}
+	}
+	metadata, err := fs.GetMetadata(ctx, entry)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("Metadata error: %v", err))
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, metadata[k]))
+	}
+	return lines
+}
+
+// info shows the hashes and metadata of the entry under the cursor
+func (u *UI) info() {
+	entry := u.cursorEntry()
+	if entry == nil {
+		return
+	}
+	u.togglePopupBox(entryInfo(context.Background(), u.f, u.fsName, entry))
+}
+
+// promptOpenOther asks for a remote to open in the other pane
+func (u *UI) promptOpenOther() {
+	start := u.fsName
+	if other := u.otherPane(); other != nil {
+		start = other.fsName
+	}
+	u.prompt("Open remote:path in the other pane", start, func(input string) {
+		f, err := cache.Get(context.Background(), input)
+		if err != nil {
+			u.popupBox([]string{"error:", err.Error()})
+			return
+		}
+		u.OpenOther(f)
+	})
+}
+
+// prompt shows an input box with initial text which calls handler
+// with the input when enter is pressed
+func (u *UI) prompt(text string, initial string, handler func(input string)) {
+	u.inputPrompt = text
+	u.input = []rune(initial)
+	u.inputHandler = handler
+	u.showInput()
+}
+
+// showInput shows the input box
+func (u *UI) showInput() {
+	u.popupBox([]string{u.inputPrompt, string(u.input) + "_"})
+}
+
+// handleInput deals with key presses while the input box is shown
+func (u *UI) handleInput(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyRune:
+		u.input = append(u.input, ev.Rune())
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(u.input) > 0 {
+			u.input = u.input[:len(u.input)-1]
+		}
+	case tcell.KeyEsc, tcell.KeyCtrlC:
+		u.inputHandler = nil
+		u.showBox = false
+		return
+	case tcell.KeyEnter:
+		handler := u.inputHandler
+		u.inputHandler = nil
+		u.showBox = false
+		handler(string(u.input))
+		return
+	}
+	u.showInput()
+}
@@ -0,0 +1,108 @@
+//go:build !plan9 && !js
+
+package ncdu
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t1 = fstest.Time("2017-02-03T04:05:06.499999999Z")
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestTransferEntries(t *testing.T) {
+	ctx := context.Background()
+	for _, move := range []bool{false, true} {
+		t.Run(map[bool]string{false: "copy", true: "move"}[move], func(t *testing.T) {
+			r := fstest.NewRun(t)
+			file1 := r.WriteFile("file1.txt", "one", t1)
+			file2 := r.WriteFile("dir/sub/file2.txt", "two", t1)
+			file3 := r.WriteFile("dir/file3.txt", "three", t1)
+			r.Mkdir(ctx, r.Fremote)
+
+			entries, err := r.Flocal.List(ctx, "")
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+
+			err = transferEntries(ctx, r.Flocal, entries, r.Fremote, "into", move)
+			require.NoError(t, err)
+
+			want := []fstest.Item{
+				fstest.NewItem("into/file1.txt", "one", t1),
+				fstest.NewItem("into/dir/sub/file2.txt", "two", t1),
+				fstest.NewItem("into/dir/file3.txt", "three", t1),
+			}
+			fstest.CheckListingWithPrecision(t, r.Fremote, want, []string{"into", "into/dir", "into/dir/sub"}, fs.GetModifyWindow(ctx, r.Fremote))
+			if move {
+				fstest.CheckListingWithPrecision(t, r.Flocal, nil, nil, fs.GetModifyWindow(ctx, r.Flocal))
+			} else {
+				r.CheckLocalItems(t, file1, file2, file3)
+			}
+		})
+	}
+}
+
+func TestPreviewLines(t *testing.T) {
+	lines, err := previewLines([]byte("one\r\n\ttwo\nthree\n"), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "    two", "three"}, lines)
+
+	lines, err = previewLines([]byte("one\ntwo\nthree"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, lines)
+
+	// cut off in the middle of a character
+	lines, err = previewLines([]byte("caf\xc3"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"caf"}, lines)
+
+	_, err = previewLines([]byte("bin\x00ary"), 2)
+	assert.Equal(t, errBinary, err)
+	_, err = previewLines([]byte("\xff\xfe\xfdtext"), 2)
+	assert.Equal(t, errBinary, err)
+}
+
+func TestReadStart(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file.txt", "hello world", t1)
+	o, err := r.Flocal.NewObject(ctx, "file.txt")
+	require.NoError(t, err)
+
+	data, err := readStart(ctx, o, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	data, err = readStart(ctx, o, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestEntryInfo(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	r.WriteFile("file.txt", "hello world", t1)
+	o, err := r.Flocal.NewObject(ctx, "file.txt")
+	require.NoError(t, err)
+
+	lines := entryInfo(ctx, r.Flocal, "local:", o)
+	text := strings.Join(lines, "\n")
+	assert.Contains(t, text, "Info for local:file.txt")
+	assert.Contains(t, text, "Size: 11")
+	assert.Contains(t, text, "MIME type: text/plain")
+	if r.Flocal.Hashes().Contains(hash.MD5) {
+		assert.Contains(t, text, "md5: 5eb63bbbe01eeed093cb22bb8f5acdc3")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/gdamore/tcell/v2"
@@ -29,7 +30,7 @@ func init() {
 }
 
 var commandDefinition = &cobra.Command{
-	Use:   "ncdu remote:path",
+	Use:   "ncdu remote:path [remote2:path]",
 	Short: `Explore a remote with a text based user interface.`,
 	Long: `
 This displays a text based user interface allowing the navigation of a
@@ -70,6 +71,24 @@ but is useful as it stands. Unlike ncdu it does not show excluded files.
 Note that it might take some time to delete big files/directories. The
 UI won't respond in the meantime since the deletion is done synchronously.
 
+### File manager
+
+rclone ncdu can also be used to move data between remotes. Give a
+second remote:path on the command line, or press 'o' and type one in,
+to open it in a second pane beside the first. TAB switches between
+the panes.
+
+Pressing F5 or 'p' copies the selected files/directories, or the one
+under the cursor if none are selected, into the current directory of
+the other pane. F6 or 'P' moves them instead. The transfer runs in the
+background and its progress is shown at the bottom of the screen. When
+it has finished the other pane is scanned again to show the new files.
+Only one transfer can run at once.
+
+F3 or 'f' shows the start of a text file and 'i' shows the size,
+modification time, hashes and metadata of the file/directory under
+the cursor.
+
 For a non-interactive listing of the remote, see the
 [tree](/commands/rclone_tree/) command. To just get the total size of
 the remote you can also use the [size](/commands/rclone_size/) command.
@@ -79,10 +98,18 @@ the remote you can also use the [size](/commands/rclone_size/) command.
 		"groups":            "Filter,Listing",
 	},
 	Run: func(command *cobra.Command, args []string) {
-		cmd.CheckArgs(1, 1, command, args)
+		cmd.CheckArgs(1, 2, command, args)
 		fsrc := cmd.NewFsSrc(args)
+		var fother fs.Fs
+		if len(args) > 1 {
+			fother = cmd.NewFsDir(args[1:])
+		}
 		cmd.Run(false, false, command, func() error {
-			return NewUI(fsrc).Run()
+			u := NewUI(fsrc)
+			if fother != nil {
+				u.OpenOther(fother)
+			}
+			return u.Run()
 		})
 	},
 }
@@ -104,6 +131,12 @@ func helpText() (tr []string) {
 		" v select file/directory",
 		" V enter visual select mode",
 		" D delete selected files/directories",
+		" o open a remote in the other pane",
+		" TAB switch between panes",
+		" F5,p copy file/directory or selection to the other pane",
+		" F6,P move file/directory or selection to the other pane",
+		" F3,f preview the start of a text file",
+		" i show hashes and metadata",
 	}
 	if !clipboard.Unsupported {
 		tr = append(tr, " y copy current path to clipboard")
@@ -121,38 +154,57 @@ func helpText() (tr []string) {
 
 // UI contains the state of the user interface
 type UI struct {
+	*pane              // pane being controlled by the keys
 	s                  tcell.Screen
-	f                  fs.Fs     // fs being displayed
-	cancel             func()    // cancel the current scanning process
-	fsName             string    // human name of Fs
-	root               *scan.Dir // root directory
-	d                  *scan.Dir // current directory being displayed
-	path               string    // path of current directory
-	showBox            bool      // whether to show a box
-	boxText            []string  // text to show in box
-	boxMenu            []string  // box menu options
+	panes              []*pane  // panes being displayed, at most 2
+	active             int      // index of the active pane
+	showBox            bool     // whether to show a box
+	boxText            []string // text to show in box
+	boxMenu            []string // box menu options
 	boxMenuButton      int
 	boxMenuHandler     func(fs fs.Fs, path string, option int) (string, error)
-	entries            fs.DirEntries // entries of current directory
-	sortPerm           []int         // order to display entries in after sorting
-	invSortPerm        []int         // inverse order
-	dirListHeight      int           // height of listing
-	listing            bool          // whether listing is in progress
-	showGraph          bool          // toggle showing graph
-	showCounts         bool          // toggle showing counts
-	showDirAverageSize bool          // toggle average size
-	showModTime        bool          // toggle showing timestamps
-	humanReadable      bool          // toggle human-readable format
-	visualSelectMode   bool          // toggle visual selection mode
-	sortByName         int8          // +1 for normal (lexical), 0 for off, -1 for reverse
-	sortBySize         int8          // +1 for normal (largest first), 0 for off, -1 for reverse (smallest first)
+	inputPrompt        string             // prompt for the input box
+	input              []rune             // text being input
+	inputHandler       func(input string) // called with the input when enter is pressed
+	transfer           *transfer          // copy or move in progress
+	transferDone       chan error         // receives the result of the transfer
+	dirListHeight      int                // height of listing
+	showGraph          bool               // toggle showing graph
+	showCounts         bool               // toggle showing counts
+	showDirAverageSize bool               // toggle average size
+	showModTime        bool               // toggle showing timestamps
+	humanReadable      bool               // toggle human-readable format
+	sortByName         int8               // +1 for normal (lexical), 0 for off, -1 for reverse
+	sortBySize         int8               // +1 for normal (largest first), 0 for off, -1 for reverse (smallest first)
 	sortByCount        int8
 	sortByAverageSize  int8
-	sortByModTime      int8              // +1 for normal (newest first), 0 for off, -1 for reverse (oldest first)
-	dirPosMap          map[string]dirPos // store for directory positions
-	selectedEntries    map[string]dirPos // selected entries of current directory
+	sortByModTime      int8 // +1 for normal (newest first), 0 for off, -1 for reverse (oldest first)
 }
 
+// pane contains the state of one remote being displayed
+type pane struct {
+	f                fs.Fs             // fs being displayed
+	cancel           func()            // cancel the current scanning process
+	fsName           string            // human name of Fs
+	root             *scan.Dir         // root directory
+	d                *scan.Dir         // current directory being displayed
+	path             string            // path of current directory
+	entries          fs.DirEntries     // entries of current directory
+	sortPerm         []int             // order to display entries in after sorting
+	invSortPerm      []int             // inverse order
+	listing          bool              // whether listing is in progress
+	visualSelectMode bool              // toggle visual selection mode
+	dirPosMap        map[string]dirPos // store for directory positions
+	selectedEntries  map[string]dirPos // selected entries of current directory
+	rootChan         chan *scan.Dir    // receives the root directory from the scan
+	errChan          chan error        // receives the result of the scan
+	updated          chan struct{}     // notified when the scan has updated
+}
+
+// noPane stands in for a missing pane in the main loop - its nil
+// channels are never ready
+var noPane = &pane{}
+
 // Where we have got to in the directory listing
 type dirPos struct {
 	entry  int
@@ -358,7 +410,6 @@ func (u *UI) hasEmptyDir() bool {
 
 // Draw the current screen
 func (u *UI) Draw() {
-	ctx := context.Background()
 	w, h := u.s.Size()
 	u.dirListHeight = h - 3
 
@@ -368,8 +419,53 @@ func (u *UI) Draw() {
 	// Header line
 	u.Linef(0, 0, w, tcell.StyleDefault.Reverse(true), ' ', "rclone ncdu %s - use the arrow keys to navigate, press ? for help", fs.Version)
 
+	// Panes side by side
+	active := u.pane
+	paneWidth := w / len(u.panes)
+	for i, p := range u.panes {
+		x := i * paneWidth
+		xmax := x + paneWidth
+		if i == len(u.panes)-1 {
+			xmax = w
+		}
+		u.pane = p
+		u.drawPane(x, xmax, h, p == active)
+	}
+	u.pane = active
+
+	// Footer
+	if u.transfer != nil {
+		u.Line(0, h-1, w, tcell.StyleDefault.Reverse(true), ' ', u.transfer.progress(u.humanReadable))
+	} else if u.d == nil {
+		u.Line(0, h-1, w, tcell.StyleDefault.Reverse(true), ' ', "Waiting for root directory...")
+	} else {
+		message := ""
+		if u.listing {
+			message = " [listing in progress]"
+		}
+		size, count := u.d.Attr()
+		u.Linef(0, h-1, w, tcell.StyleDefault.Reverse(true), ' ', "Total usage: %s, Objects: %s%s",
+			operations.SizeString(size, u.humanReadable), operations.CountString(count, u.humanReadable), message)
+	}
+
+	// Show the box on top if required
+	if u.showBox {
+		u.Box()
+	}
+}
+
+// drawPane draws the current pane between x and xmax
+//
+// The cursor is only shown if the pane is active.
+func (u *UI) drawPane(x, xmax, h int, active bool) {
+	ctx := context.Background()
+
 	// Directory line
-	u.Linef(0, 1, w, tcell.StyleDefault, '-', "-- %s ", u.path)
+	dirStyle := tcell.StyleDefault
+	if active && len(u.panes) > 1 {
+		dirStyle = dirStyle.Reverse(true)
+	}
+	u.Linef(x, 1, xmax, dirStyle, '-', "-- %s ", u.path)
 
 	// graphs
 	const (
@@ -416,7 +512,7 @@ func (u *UI) Draw() {
 			if isSelected {
 				style = style.Foreground(tcell.ColorLightYellow)
 			}
-			if n == dirPos.entry {
+			if active && n == dirPos.entry {
 				style = style.Reverse(true)
 			}
 			mark := ' '
@@ -476,29 +572,11 @@ func (u *UI) Draw() {
 				}
 				extras += "[" + graph[graphBars-bars:2*graphBars-bars] + "] "
 			}
-			u.Linef(0, y, w, style, ' ', "%c %s %s%c%s%s",
+			u.Linef(x, y, xmax, style, ' ', "%c %s %s%c%s%s",
 				fileFlag, operations.SizeStringField(attrs.Size, u.humanReadable, 12), extras, mark, path.Base(entry.Remote()), message)
 			y++
 		}
 	}
-
-	// Footer
-	if u.d == nil {
-		u.Line(0, h-1, w, tcell.StyleDefault.Reverse(true), ' ', "Waiting for root directory...")
-	} else {
-		message := ""
-		if u.listing {
-			message = " [listing in progress]"
-		}
-		size, count := u.d.Attr()
-		u.Linef(0, h-1, w, tcell.StyleDefault.Reverse(true), ' ', "Total usage: %s, Objects: %s%s",
-			operations.SizeString(size, u.humanReadable), operations.CountString(count, u.humanReadable), message)
-	}
-
-	// Show the box on top if required
-	if u.showBox {
-		u.Box()
-	}
 }
 
 // Move the cursor this many spaces adjusting the viewport as necessary
@@ -871,7 +949,9 @@ func (u *UI) toggleSort(sortType *int8) {
 	} else {
 		*sortType = -old
 	}
-	u.sortCurrentDir()
+	for _, p := range u.panes {
+		u.withPane(p, u.sortCurrentDir)
+	}
 }
 
 func (u *UI) toggleSelectForCursor() {
@@ -890,11 +970,12 @@ func (u *UI) toggleSelectForCursor() {
 
 // NewUI creates a new user interface for ncdu on f
 func NewUI(f fs.Fs) *UI {
+	p := newPane(f)
 	return &UI{
-		f:                  f,
-		path:               "Waiting for root...",
+		pane:               p,
+		panes:              []*pane{p},
 		dirListHeight:      20, // updated in Draw
-		fsName:             fs.ConfigString(f),
+		transferDone:       make(chan error),
 		showGraph:          true,
 		showCounts:         false,
 		showDirAverageSize: false,
@@ -903,19 +984,101 @@ func NewUI(f fs.Fs) *UI {
 		sortBySize:         1, // Sort by largest first
 		sortByModTime:      0,
 		sortByCount:        0,
-		dirPosMap:          make(map[string]dirPos),
-		selectedEntries:    make(map[string]dirPos),
 	}
 }
 
-func (u *UI) scan() (chan *scan.Dir, chan error, chan struct{}) {
-	if cancel := u.cancel; cancel != nil {
+// newPane creates a new pane showing f
+func newPane(f fs.Fs) *pane {
+	return &pane{
+		f:               f,
+		path:            "Waiting for root...",
+		fsName:          fs.ConfigString(f),
+		dirPosMap:       make(map[string]dirPos),
+		selectedEntries: make(map[string]dirPos),
+	}
+}
+
+// OpenOther shows f in the other pane, replacing what was there
+//
+// It is scanned when Run is called, or straight away if Run is
+// already running.
+func (u *UI) OpenOther(f fs.Fs) {
+	p := newPane(f)
+	if len(u.panes) < 2 {
+		u.panes = append(u.panes, p)
+	} else {
+		other := u.panes[1-u.active]
+		if other.cancel != nil {
+			other.cancel()
+		}
+		u.panes[1-u.active] = p
+	}
+	if u.s != nil {
+		p.scan()
+	}
+}
+
+// otherPane returns the pane which isn't active or nil if there isn't one
+func (u *UI) otherPane() *pane {
+	if len(u.panes) < 2 {
+		return nil
+	}
+	return u.panes[1-u.active]
+}
+
+// switchPane makes the other pane active
+func (u *UI) switchPane() {
+	if len(u.panes) < 2 {
+		return
+	}
+	u.pane.visualSelectMode = false
+	u.active = 1 - u.active
+	u.pane = u.panes[u.active]
+}
+
+// withPane runs fn with p as the current pane
+func (u *UI) withPane(p *pane, fn func()) {
+	active := u.pane
+	u.pane = p
+	fn()
+	u.pane = active
+}
+
+// hasPane returns true if p is being displayed
+func (u *UI) hasPane(p *pane) bool {
+	for _, q := range u.panes {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// scan starts scanning the pane in the background
+func (p *pane) scan() {
+	if cancel := p.cancel; cancel != nil {
 		cancel()
 	}
-	u.listing = true
+	p.listing = true
 	ctx := context.Background()
-	ctx, u.cancel = context.WithCancel(ctx)
-	return scan.Scan(ctx, u.f)
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.rootChan, p.errChan, p.updated = scan.Scan(ctx, p.f)
+}
+
+// getPane returns the i-th pane, or noPane if it doesn't exist
+func (u *UI) getPane(i int) *pane {
+	if i >= len(u.panes) {
+		return noPane
+	}
+	return u.panes[i]
+}
+
+// setRoot sets the root directory of p once it has been read
+func (u *UI) setRoot(p *pane, root *scan.Dir) {
+	u.withPane(p, func() {
+		u.root = root
+		u.setCurrentDir(root)
+	})
 }
 
 // Run shows the user interface
@@ -952,9 +1115,20 @@ func (u *UI) Run() error {
 	}
 
 	defer u.s.Fini()
+	defer func() {
+		if u.transfer != nil {
+			u.transfer.cancel()
+		}
+	}()
+
+	// scan the disks in the background
+	for _, p := range u.panes {
+		p.scan()
+	}
 
-	// scan the disk in the background
-	rootChan, errChan, updated := u.scan()
+	// Redraw regularly to show the progress of transfers
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
 	// Poll the events into a channel
 	events := make(chan tcell.Event)
@@ -963,18 +1137,33 @@ func (u *UI) Run() error {
 	// Main loop, waiting for events and channels
 outer:
 	for {
+		p0, p1 := u.getPane(0), u.getPane(1)
 		select {
-		case root := <-rootChan:
-			u.root = root
-			u.setCurrentDir(root)
-		case err := <-errChan:
+		case root := <-p0.rootChan:
+			u.setRoot(p0, root)
+		case root := <-p1.rootChan:
+			u.setRoot(p1, root)
+		case err := <-p0.errChan:
 			if err != nil {
 				return fmt.Errorf("ncdu directory listing: %w", err)
 			}
-			u.listing = false
-		case <-updated:
+			p0.listing = false
+		case err := <-p1.errChan:
+			if err != nil {
+				return fmt.Errorf("ncdu directory listing: %w", err)
+			}
+			p1.listing = false
+		case <-p0.updated:
 			// TODO: might want to limit updates per second
-			u.sortCurrentDir()
+			u.withPane(p0, u.sortCurrentDir)
+		case <-p1.updated:
+			u.withPane(p1, u.sortCurrentDir)
+		case err := <-u.transferDone:
+			u.finishTransfer(err)
+		case <-ticker.C:
+			if u.transfer == nil {
+				continue // nothing to update
+			}
 		case ev := <-events:
 			switch ev := ev.(type) {
 			case *tcell.EventResize:
@@ -982,6 +1171,10 @@ outer:
 				u.s.Sync()
 				continue // don't draw again
 			case *tcell.EventKey:
+				if u.inputHandler != nil {
+					u.handleInput(ev)
+					break
+				}
 				var c rune
 				if k := ev.Key(); k == tcell.KeyRune {
 					c = ev.Rune()
@@ -1057,7 +1250,19 @@ outer:
 					u.togglePopupBox(helpText())
 				case 'r':
 					// restart scan
-					rootChan, errChan, updated = u.scan()
+					u.pane.scan()
+				case key(tcell.KeyTab):
+					u.switchPane()
+				case 'o':
+					u.promptOpenOther()
+				case key(tcell.KeyF5), 'p':
+					u.startTransfer(false)
+				case key(tcell.KeyF6), 'P':
+					u.startTransfer(true)
+				case key(tcell.KeyF3), 'f':
+					u.preview()
+				case 'i':
+					u.info()
 
 				// Refresh the screen. Not obvious what key to map
 				// this onto, but ^L is a common choice.
@@ -109,6 +109,13 @@ func newDir(parent *Dir, dirPath string, entries fs.DirEntries, err error) *Dir
 	return d
 }
 
+// ReadError returns the error reading the directory, if any
+func (d *Dir) ReadError() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.readError
+}
+
 // Entries returns a copy of the entries in the directory
 func (d *Dir) Entries() fs.DirEntries {
 	return append(fs.DirEntries(nil), d.entries...)
@@ -0,0 +1,246 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Report types
+const (
+	ReportHistory   = "history"
+	ReportGrowth    = "growth"
+	ReportExtension = "extension"
+	ReportAge       = "age"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Table is a report ready to be output
+//
+// The values in the rows are strings, int64 or time.Time. Columns
+// with names ending in "size" or "size_change" are sizes in bytes.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// add a row to the table
+func (t *Table) add(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// isSize returns true if the column is a size
+func isSize(column string) bool {
+	return strings.HasSuffix(column, "size") || strings.HasSuffix(column, "size_change")
+}
+
+// isChange returns true if the column is a change
+func isChange(column string) bool {
+	return strings.HasSuffix(column, "_change")
+}
+
+// History returns a table showing the total usage in each snapshot
+// and how it changed from the previous one
+//
+// Snapshots which are incomplete are shown with a status of
+// "incomplete".
+func History(snapshots []*Snapshot) *Table {
+	t := &Table{Columns: []string{"time", "size", "count", "size_change", "count_change", "status"}}
+	var prev Usage
+	for i, s := range snapshots {
+		total := s.Total()
+		if i == 0 {
+			prev = total
+		}
+		status := "complete"
+		if !s.Complete() {
+			status = "incomplete"
+		}
+		t.add(s.Time, total.Size, total.Count, total.Size-prev.Size, total.Count-prev.Count, status)
+		prev = total
+	}
+	return t
+}
+
+// completeSnapshots returns the snapshots which are complete
+func completeSnapshots(snapshots []*Snapshot) (complete []*Snapshot) {
+	for _, s := range snapshots {
+		if s.Complete() {
+			complete = append(complete, s)
+		}
+	}
+	return complete
+}
+
+// Growth returns a table showing the top directories which grew the
+// most between the first snapshot at or after since and the last one
+//
+// Incomplete snapshots are ignored. If top is 0 all the directories
+// are shown.
+func Growth(snapshots []*Snapshot, since time.Time, top int) (*Table, error) {
+	snapshots = completeSnapshots(snapshots)
+	i := sort.Search(len(snapshots), func(i int) bool {
+		return !snapshots[i].Time.Before(since)
+	})
+	if len(snapshots)-i < 2 {
+		return nil, errors.New("need at least 2 complete snapshots in the time range to show growth")
+	}
+	first, last := snapshots[i], snapshots[len(snapshots)-1]
+	type growth struct {
+		dir           string
+		before, after Usage
+	}
+	var growths []growth
+	seen := map[string]struct{}{}
+	for _, s := range []*Snapshot{last, first} {
+		for dir := range s.Dirs {
+			if _, found := seen[dir]; found {
+				continue
+			}
+			seen[dir] = struct{}{}
+			growths = append(growths, growth{dir: dir, before: first.Dirs[dir], after: last.Dirs[dir]})
+		}
+	}
+	sort.Slice(growths, func(i, j int) bool {
+		di := growths[i].after.Size - growths[i].before.Size
+		dj := growths[j].after.Size - growths[j].before.Size
+		if di != dj {
+			return di > dj
+		}
+		return growths[i].dir < growths[j].dir
+	})
+	if top > 0 && len(growths) > top {
+		growths = growths[:top]
+	}
+	t := &Table{Columns: []string{"dir", "before_size", "after_size", "size_change", "count_change"}}
+	for _, g := range growths {
+		dir := g.dir
+		if dir == "" {
+			dir = "."
+		}
+		t.add(dir, g.before.Size, g.after.Size, g.after.Size-g.before.Size, g.after.Count-g.before.Count)
+	}
+	return t, nil
+}
+
+// Extensions returns a table showing the usage by file extension in
+// s, largest first
+//
+// If top is 0 all the extensions are shown.
+func Extensions(s *Snapshot, top int) *Table {
+	var exts []string
+	for ext := range s.Extensions {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		si, sj := s.Extensions[exts[i]].Size, s.Extensions[exts[j]].Size
+		if si != sj {
+			return si > sj
+		}
+		return exts[i] < exts[j]
+	})
+	if top > 0 && len(exts) > top {
+		exts = exts[:top]
+	}
+	t := &Table{Columns: []string{"extension", "size", "count"}}
+	for _, ext := range exts {
+		t.add(ext, s.Extensions[ext].Size, s.Extensions[ext].Count)
+	}
+	return t
+}
+
+// AgeReport returns a table showing the usage by age of file in s
+func AgeReport(s *Snapshot) *Table {
+	t := &Table{Columns: []string{"age", "size", "count"}}
+	for _, age := range Ages {
+		name := age.Name
+		if age.MaxAge > 0 {
+			name = "under " + name
+		}
+		t.add(name, s.Ages[age.Name].Size, s.Ages[age.Name].Count)
+	}
+	return t
+}
+
+// formatValue formats v from column for text output
+func formatValue(column string, v interface{}, humanReadable bool) string {
+	switch x := v.(type) {
+	case time.Time:
+		return x.Local().Format("2006-01-02 15:04:05")
+	case int64:
+		sign := ""
+		if isChange(column) && x > 0 {
+			sign = "+"
+		} else if x < 0 {
+			sign = "-"
+			x = -x
+		}
+		if isSize(column) {
+			return sign + operations.SizeString(x, humanReadable)
+		}
+		return sign + operations.CountString(x, humanReadable)
+	}
+	return fmt.Sprint(v)
+}
+
+// Write writes the table to out in format
+func (t *Table) Write(out io.Writer, format string, humanReadable bool) error {
+	switch format {
+	case FormatText:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+		_, _ = fmt.Fprintln(w, strings.Join(t.Columns, "\t")+"\t")
+		for _, row := range t.Rows {
+			for i, v := range row {
+				_, _ = fmt.Fprint(w, formatValue(t.Columns[i], v, humanReadable)+"\t")
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		return w.Flush()
+	case FormatCSV:
+		w := csv.NewWriter(out)
+		_ = w.Write(t.Columns)
+		for _, row := range t.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				switch x := v.(type) {
+				case int64:
+					record[i] = strconv.FormatInt(x, 10)
+				case time.Time:
+					record[i] = x.Format(time.RFC3339Nano)
+				default:
+					record[i] = fmt.Sprint(v)
+				}
+			}
+			_ = w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	case FormatJSON:
+		rows := make([]map[string]interface{}, len(t.Rows))
+		for i, row := range t.Rows {
+			rows[i] = make(map[string]interface{}, len(row))
+			for j, v := range row {
+				rows[i][t.Columns[j]] = v
+			}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		return enc.Encode(rows)
+	}
+	return fmt.Errorf("unknown format %q - must be %s, %s or %s", format, FormatText, FormatJSON, FormatCSV)
+}
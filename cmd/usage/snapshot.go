@@ -0,0 +1,226 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd/ncdu/scan"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// facility is the name of the kv database the snapshots are kept in
+const facility = "usage"
+
+// timeFormat is used in the database keys so they sort in time order
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// Usage is the size and number of files in something
+type Usage struct {
+	Size  int64 `json:"size"`
+	Count int64 `json:"count"`
+}
+
+// add adds a file of size bytes
+func (u *Usage) add(size int64) {
+	if size > 0 {
+		u.Size += size
+	}
+	u.Count++
+}
+
+// Ages are the age groups files are counted in, youngest first
+var Ages = []struct {
+	Name   string
+	MaxAge time.Duration
+}{
+	{"1 day", 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+	{"1 month", 30 * 24 * time.Hour},
+	{"1 year", 365 * 24 * time.Hour},
+	{"older", 0},
+}
+
+// ageName returns the name of the age group for age
+func ageName(age time.Duration) string {
+	for _, a := range Ages {
+		if age < a.MaxAge {
+			return a.Name
+		}
+	}
+	return Ages[len(Ages)-1].Name
+}
+
+// extension returns the lower case extension of name for grouping
+func extension(name string) string {
+	ext := path.Ext(name)
+	if ext == name || ext == "" {
+		return "(none)"
+	}
+	return strings.ToLower(ext)
+}
+
+// Snapshot is the usage of a remote at a point in time
+type Snapshot struct {
+	Remote     string           `json:"remote"`
+	Time       time.Time        `json:"time"`
+	Dirs       map[string]Usage `json:"dirs"`             // by directory, including subdirectories
+	Extensions map[string]Usage `json:"extensions"`       // by file extension
+	Ages       map[string]Usage `json:"ages"`             // by age of file when recorded
+	Errors     int              `json:"errors,omitempty"` // number of directories which couldn't be listed
+}
+
+// Complete returns true if all the directories were listed when the
+// snapshot was recorded
+func (s *Snapshot) Complete() bool {
+	return s.Errors == 0
+}
+
+// Total returns the total usage of the snapshot
+func (s *Snapshot) Total() Usage {
+	return s.Dirs[""]
+}
+
+// key returns the key to store the snapshot under
+func (s *Snapshot) key() string {
+	return s.Remote + "\x00" + s.Time.UTC().Format(timeFormat)
+}
+
+// scanRemote scans f using the ncdu scanner returning its root
+// directory when the scan has finished
+func scanRemote(ctx context.Context, f fs.Fs) (*scan.Dir, error) {
+	rootChan, errChan, _ := scan.Scan(ctx, f)
+	err := <-errChan
+	if err != nil {
+		return nil, err
+	}
+	return <-rootChan, nil
+}
+
+// NewSnapshot scans f and returns its usage at time now
+//
+// The usage of directories up to dirDepth deep is stored, or all of
+// them if dirDepth is negative. Directories which couldn't be listed
+// are counted in s.Errors.
+func NewSnapshot(ctx context.Context, f fs.Fs, now time.Time, dirDepth int) (*Snapshot, error) {
+	root, err := scanRemote(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{
+		Remote:     fs.ConfigString(f),
+		Time:       now,
+		Dirs:       map[string]Usage{},
+		Extensions: map[string]Usage{},
+		Ages:       map[string]Usage{},
+	}
+	s.addDir(ctx, root, 0, dirDepth)
+	return s, nil
+}
+
+// addDir adds d which is depth deep and its subdirectories to the
+// snapshot, storing the usage of the directories up to dirDepth deep.
+func (s *Snapshot) addDir(ctx context.Context, d *scan.Dir, depth, dirDepth int) {
+	if err := d.ReadError(); err != nil {
+		fs.Errorf(d.Path(), "Failed to list directory: %v", err)
+		s.Errors++
+	}
+	if dirDepth < 0 || depth <= dirDepth {
+		size, count := d.Attr()
+		s.Dirs[d.Path()] = Usage{Size: size, Count: count}
+	}
+	for i, entry := range d.Entries() {
+		if o, ok := entry.(fs.Object); ok {
+			extName := extension(path.Base(o.Remote()))
+			ext := s.Extensions[extName]
+			ext.add(o.Size())
+			s.Extensions[extName] = ext
+			ageGroup := ageName(s.Time.Sub(o.ModTime(ctx)))
+			age := s.Ages[ageGroup]
+			age.add(o.Size())
+			s.Ages[ageGroup] = age
+			continue
+		}
+		if subDir, _ := d.GetDir(i); subDir != nil {
+			s.addDir(ctx, subDir, depth+1, dirDepth)
+		}
+	}
+}
+
+// kvPut stores a snapshot
+type kvPut struct {
+	s *Snapshot
+}
+
+func (op *kvPut) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := json.Marshal(op.s)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(op.s.key()), data)
+}
+
+// kvList reads the snapshots of a remote in time order
+type kvList struct {
+	remote    string
+	snapshots []*Snapshot
+}
+
+func (op *kvList) Do(ctx context.Context, b kv.Bucket) error {
+	prefix := op.remote + "\x00"
+	cur := b.Cursor()
+	for bkey, data := cur.Seek([]byte(prefix)); bkey != nil && strings.HasPrefix(string(bkey), prefix); bkey, data = cur.Next() {
+		s := new(Snapshot)
+		if err := json.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("corrupted snapshot %q: %w", bkey, err)
+		}
+		op.snapshots = append(op.snapshots, s)
+	}
+	return nil
+}
+
+// openDB opens the snapshot database for f
+func openDB(ctx context.Context, f fs.Fs) (*kv.DB, error) {
+	db, err := kv.Start(ctx, facility, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage database: %w", err)
+	}
+	return db, nil
+}
+
+// Record scans f and stores a snapshot of its usage in db, with the
+// directories up to dirDepth deep.
+//
+// If some directories couldn't be listed the snapshot is stored
+// marked as incomplete and an error is returned with it.
+func Record(ctx context.Context, db *kv.DB, f fs.Fs, dirDepth int) (*Snapshot, error) {
+	s, err := NewSnapshot(ctx, f, time.Now(), dirDepth)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Do(true, &kvPut{s: s})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	if !s.Complete() {
+		return s, fmt.Errorf("stored incomplete snapshot as %d directories couldn't be listed", s.Errors)
+	}
+	return s, nil
+}
+
+// Snapshots returns the snapshots of f in db in time order
+func Snapshots(ctx context.Context, db *kv.DB, f fs.Fs) ([]*Snapshot, error) {
+	op := &kvList{remote: fs.ConfigString(f)}
+	err := db.Do(false, op)
+	if err == kv.ErrEmpty {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	return op.snapshots, nil
+}
@@ -0,0 +1,196 @@
+// Package usage provides the usage command.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	report = ReportHistory
+	format = FormatText
+	top    = 10
+	since  = fs.DurationOff
+	depth  = 3
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(recordDefinition, reportDefinition)
+	flags.IntVarP(recordDefinition.Flags(), &depth, "dir-depth", "", depth, "Record the usage of directories this deep (-1 for all)", "")
+	cmdFlags := reportDefinition.Flags()
+	flags.StringVarP(cmdFlags, &report, "report", "", report, "Report to show: history, growth, extension or age", "")
+	flags.StringVarP(cmdFlags, &format, "format", "", format, "Output format: text, json or csv", "")
+	flags.IntVarP(cmdFlags, &top, "top", "", top, "Number of rows to show in the growth and extension reports (0 for all)", "")
+	flags.FVarP(cmdFlags, &since, "since", "", "Only use snapshots newer than this for the history and growth reports", "")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "usage <action> remote:path",
+	Short: `Record and report the disk usage of a remote over time.`,
+	Long: strings.ReplaceAll(`
+|rclone size| and |rclone about| show how much is in a remote now.
+|rclone usage| records snapshots of the size and number of files in
+each directory of a remote so you can see how it changes over time.
+
+Record a snapshot, for example daily from cron, with
+
+    rclone usage record remote:path
+
+and show how the total usage has changed with
+
+    rclone usage report remote:path
+
+The snapshots are kept in a small database in the rclone cache
+directory (see |--cache-dir|), one per remote.
+
+Recording scans the whole remote in the same way as |rclone ncdu|. It
+also counts the files by extension and by age, which needs the
+modification time of each file. On remotes where reading the
+modification time is expensive, for example S3, use
+|--use-server-modtime| to avoid this.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+		"groups":            "Filter,Listing",
+	},
+}
+
+var recordDefinition = &cobra.Command{
+	Use:   "record remote:path",
+	Short: `Record a snapshot of the disk usage of a remote.`,
+	Long: strings.ReplaceAll(`
+Scan remote:path and record the size and number of files in each
+directory, by file extension and by age, in the usage database.
+
+Only the directories up to |--dir-depth| deep (3 by default) are
+recorded, to stop the database growing too large on remotes with many
+directories. The usage of each directory includes everything below
+it. Use |--dir-depth -1| to record every directory.
+
+If some directories can't be listed the snapshot is still recorded
+but is marked as incomplete and rclone exits with an error. The
+|history| report shows incomplete snapshots, and the other reports
+ignore them.
+
+The usual [filters](/filtering/) can be used to choose which files are
+counted.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+		"groups":            "Filter,Listing",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			db, err := openDB(ctx, f)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Stop(false)
+			}()
+			s, err := Record(ctx, db, f, depth)
+			if s == nil {
+				return err
+			}
+			total := s.Total()
+			fs.Logf(f, "Recorded usage: %v in %d files", fs.SizeSuffix(total.Size), total.Count)
+			return err
+		})
+	},
+}
+
+var reportDefinition = &cobra.Command{
+	Use:   "report remote:path",
+	Short: `Report on the disk usage of a remote over time.`,
+	Long: strings.ReplaceAll(`
+Show a report made from the snapshots recorded by |rclone usage record|.
+Use the same remote:path as was used to record them. Choose the
+report with |--report|:
+
+- |history| - the total size and count in each snapshot and how they changed (the default)
+- |growth| - the directories which grew the most between the first and last snapshots
+- |extension| - the size and count of each file extension in the last snapshot
+- |age| - the size and count of files by age in the last snapshot
+
+|--since| limits the |history| and |growth| reports to snapshots
+recorded in the given time, for example |--since 30d|. |--top| sets
+how many rows the |growth| and |extension| reports show.
+
+The report is shown as a table unless |--format json| or |--format csv|
+is given. Sizes and counts are in bytes and files in the JSON and CSV
+output, and times are in RFC 3339 format. In the table they are
+human-readable if |--human-readable| is given.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.68",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			db, err := openDB(ctx, f)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = db.Stop(false)
+			}()
+			snapshots, err := Snapshots(ctx, db, f)
+			if err != nil {
+				return err
+			}
+			t, err := Report(snapshots, report, since, top)
+			if err != nil {
+				return err
+			}
+			return t.Write(os.Stdout, format, fs.GetConfig(ctx).HumanReadable)
+		})
+	},
+}
+
+// Report makes the report called name from the snapshots which are
+// in time order
+func Report(snapshots []*Snapshot, name string, since fs.Duration, top int) (*Table, error) {
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots found - record some with \"rclone usage record\"")
+	}
+	start := time.Time{}
+	if since.IsSet() {
+		start = time.Now().Add(-time.Duration(since))
+	}
+	// Use the last complete snapshot if there is one
+	last := snapshots[len(snapshots)-1]
+	if complete := completeSnapshots(snapshots); len(complete) > 0 {
+		last = complete[len(complete)-1]
+	}
+	switch name {
+	case ReportHistory:
+		var recent []*Snapshot
+		for _, s := range snapshots {
+			if !s.Time.Before(start) {
+				recent = append(recent, s)
+			}
+		}
+		return History(recent), nil
+	case ReportGrowth:
+		return Growth(snapshots, start, top)
+	case ReportExtension:
+		return Extensions(last, top), nil
+	case ReportAge:
+		return AgeReport(last), nil
+	}
+	return nil, fmt.Errorf("unknown report %q - must be %s, %s, %s or %s", name, ReportHistory, ReportGrowth, ReportExtension, ReportAge)
+}
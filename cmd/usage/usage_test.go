@@ -0,0 +1,252 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestAgeName(t *testing.T) {
+	assert.Equal(t, "1 day", ageName(time.Hour))
+	assert.Equal(t, "1 week", ageName(2*24*time.Hour))
+	assert.Equal(t, "1 month", ageName(10*24*time.Hour))
+	assert.Equal(t, "1 year", ageName(100*24*time.Hour))
+	assert.Equal(t, "older", ageName(1000*24*time.Hour))
+}
+
+func TestExtension(t *testing.T) {
+	assert.Equal(t, ".txt", extension("file.TXT"))
+	assert.Equal(t, "(none)", extension("file"))
+	assert.Equal(t, "(none)", extension(".bashrc"))
+	assert.Equal(t, ".gz", extension("file.tar.gz"))
+}
+
+func TestRecordAndSnapshots(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	now := time.Now()
+	r.WriteObject(ctx, "file1.txt", "one", now.Add(-time.Hour))
+	r.WriteObject(ctx, "dir/file2.jpg", "twotwo", now.Add(-48*time.Hour))
+	r.WriteObject(ctx, "dir/sub/file3.txt", "three", now.Add(-1000*24*time.Hour))
+
+	s, err := NewSnapshot(ctx, r.Fremote, now, -1)
+	require.NoError(t, err)
+	assert.Equal(t, fs.ConfigString(r.Fremote), s.Remote)
+	assert.Equal(t, Usage{Size: 14, Count: 3}, s.Total())
+	assert.Equal(t, Usage{Size: 11, Count: 2}, s.Dirs["dir"])
+	assert.Equal(t, Usage{Size: 5, Count: 1}, s.Dirs["dir/sub"])
+	assert.Equal(t, map[string]Usage{
+		".txt": {Size: 8, Count: 2},
+		".jpg": {Size: 6, Count: 1},
+	}, s.Extensions)
+	assert.Equal(t, map[string]Usage{
+		"1 day":  {Size: 3, Count: 1},
+		"1 week": {Size: 6, Count: 1},
+		"older":  {Size: 5, Count: 1},
+	}, s.Ages)
+
+	// The database is removed when opened under test so only open it once
+	db, err := openDB(ctx, r.Fremote)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Stop(false)
+	}()
+
+	snapshots, err := Snapshots(ctx, db, r.Fremote)
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 0)
+
+	_, err = Record(ctx, db, r.Fremote, -1)
+	require.NoError(t, err)
+	r.WriteObject(ctx, "dir/file4.txt", "four", now)
+	_, err = Record(ctx, db, r.Fremote, -1)
+	require.NoError(t, err)
+
+	snapshots, err = Snapshots(ctx, db, r.Fremote)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.True(t, snapshots[0].Time.Before(snapshots[1].Time))
+	assert.Equal(t, Usage{Size: 14, Count: 3}, snapshots[0].Total())
+	assert.Equal(t, Usage{Size: 18, Count: 4}, snapshots[1].Total())
+}
+
+func TestSnapshotDirDepth(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	now := time.Now()
+	r.WriteObject(ctx, "dir/sub/file.txt", "file", now)
+	r.WriteObject(ctx, "dir/sub/subsub/file.txt", "file", now)
+
+	s, err := NewSnapshot(ctx, r.Fremote, now, 1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Usage{
+		"":    {Size: 8, Count: 2},
+		"dir": {Size: 8, Count: 2},
+	}, s.Dirs)
+	assert.Equal(t, map[string]Usage{".txt": {Size: 8, Count: 2}}, s.Extensions)
+}
+
+// failFs is an fs.Fs which fails to list the directory bad
+type failFs struct {
+	fs.Fs
+}
+
+func (f failFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	if dir == "bad" {
+		return nil, errors.New("listing failed")
+	}
+	return f.Fs.List(ctx, dir)
+}
+
+func TestRecordIncomplete(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	now := time.Now()
+	r.WriteObject(ctx, "good/file.txt", "good", now)
+	r.WriteObject(ctx, "bad/file.txt", "bad", now)
+	f := failFs{Fs: r.Fremote}
+
+	db, err := openDB(ctx, f)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Stop(false)
+	}()
+
+	// The snapshot is stored, marked as incomplete
+	s, err := Record(ctx, db, f, -1)
+	assert.ErrorContains(t, err, "incomplete")
+	require.NotNil(t, s)
+	assert.Equal(t, 1, s.Errors)
+	assert.False(t, s.Complete())
+
+	snapshots, err := Snapshots(ctx, db, f)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.False(t, snapshots[0].Complete())
+}
+
+// makeSnapshot makes a snapshot at t with the usage of dir given
+func makeSnapshot(t time.Time, dirSize int64) *Snapshot {
+	return &Snapshot{
+		Time: t,
+		Dirs: map[string]Usage{
+			"":    {Size: 100 + dirSize, Count: 10},
+			"dir": {Size: dirSize, Count: 1},
+			"big": {Size: 100, Count: 9},
+		},
+		Extensions: map[string]Usage{
+			".txt": {Size: 10, Count: 5},
+			".iso": {Size: 90 + dirSize, Count: 5},
+		},
+		Ages: map[string]Usage{
+			"1 day": {Size: 100 + dirSize, Count: 10},
+		},
+	}
+}
+
+func TestReports(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := []*Snapshot{
+		makeSnapshot(t0, 10),
+		makeSnapshot(t0.Add(24*time.Hour), 30),
+		makeSnapshot(t0.Add(48*time.Hour), 25),
+	}
+
+	history := History(snapshots)
+	assert.Equal(t, [][]interface{}{
+		{t0, int64(110), int64(10), int64(0), int64(0), "complete"},
+		{t0.Add(24 * time.Hour), int64(130), int64(10), int64(20), int64(0), "complete"},
+		{t0.Add(48 * time.Hour), int64(125), int64(10), int64(-5), int64(0), "complete"},
+	}, history.Rows)
+
+	growth, err := Growth(snapshots, time.Time{}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{".", int64(110), int64(125), int64(15), int64(0)},
+		{"dir", int64(10), int64(25), int64(15), int64(0)},
+	}, growth.Rows)
+
+	_, err = Growth(snapshots, t0.Add(47*time.Hour), 0)
+	assert.Error(t, err)
+
+	exts := Extensions(snapshots[2], 0)
+	assert.Equal(t, [][]interface{}{
+		{".iso", int64(115), int64(5)},
+		{".txt", int64(10), int64(5)},
+	}, exts.Rows)
+
+	ages := AgeReport(snapshots[2])
+	require.Len(t, ages.Rows, len(Ages))
+	assert.Equal(t, []interface{}{"under 1 day", int64(125), int64(10)}, ages.Rows[0])
+	assert.Equal(t, []interface{}{"older", int64(0), int64(0)}, ages.Rows[len(Ages)-1])
+
+	_, err = Report(nil, ReportHistory, fs.DurationOff, 0)
+	assert.Error(t, err)
+	_, err = Report(snapshots, "potato", fs.DurationOff, 0)
+	assert.Error(t, err)
+	table, err := Report(snapshots, ReportExtension, fs.DurationOff, 1)
+	require.NoError(t, err)
+	assert.Len(t, table.Rows, 1)
+
+	// Incomplete snapshots are marked in the history and ignored
+	// by the other reports
+	incomplete := makeSnapshot(t0.Add(72*time.Hour), 1000)
+	incomplete.Errors = 1
+	snapshots = append(snapshots, incomplete)
+	history = History(snapshots)
+	assert.Equal(t, "incomplete", history.Rows[3][5])
+	growth, err = Growth(snapshots, time.Time{}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{".", int64(110), int64(125), int64(15), int64(0)},
+	}, growth.Rows)
+	table, err = Report(snapshots, ReportExtension, fs.DurationOff, 1)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{".iso", int64(115), int64(5)}}, table.Rows)
+}
+
+func TestTableWrite(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	table := &Table{
+		Columns: []string{"time", "size", "size_change"},
+		Rows: [][]interface{}{
+			{t0, int64(2048), int64(-1024)},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, table.Write(&buf, FormatCSV, false))
+	assert.Equal(t, "time,size,size_change\n2024-01-01T00:00:00Z,2048,-1024\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, table.Write(&buf, FormatJSON, false))
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rows))
+	assert.Equal(t, []map[string]interface{}{
+		{"time": "2024-01-01T00:00:00Z", "size": float64(2048), "size_change": float64(-1024)},
+	}, rows)
+
+	buf.Reset()
+	require.NoError(t, table.Write(&buf, FormatText, true))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "2Ki")
+	assert.Contains(t, lines[1], "-1Ki")
+
+	assert.Error(t, table.Write(&buf, "potato", false))
+}
@@ -77,6 +77,16 @@ var ConfigOptionsInfo = Options{{
 	Default: false,
 	Help:    "Skip based on size only, not modtime or checksum",
 	Groups:  "Copy",
+}, {
+	Name:    "hash_sample",
+	Default: 0,
+	Help:    "Compare this many sampled blocks of the content if there is no common hash (0 to disable)",
+	Groups:  "Copy,Check",
+}, {
+	Name:    "hash_sample_size",
+	Default: SizeSuffix(64 * 1024),
+	Help:    "Size of each block read by --hash-sample",
+	Groups:  "Copy,Check",
 }, {
 	Name:     "ignore_times",
 	ShortOpt: "I",
@@ -539,6 +549,8 @@ type ConfigInfo struct {
 	Interactive                bool              `config:"interactive"`
 	CheckSum                   bool              `config:"checksum"`
 	SizeOnly                   bool              `config:"size_only"`
+	HashSample                 int               `config:"hash_sample"`
+	HashSampleSize             SizeSuffix        `config:"hash_sample_size"`
 	IgnoreTimes                bool              `config:"ignore_times"`
 	IgnoreExisting             bool              `config:"ignore_existing"`
 	IgnoreErrors               bool              `config:"ignore_errors"`
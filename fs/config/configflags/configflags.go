@@ -132,6 +132,14 @@ func SetFlags(ci *fs.ConfigInfo) {
 		ci.DeleteMode = fs.DeleteModeDefault
 	}
 
+	// Process --hash-sample and --hash-sample-size
+	if ci.HashSample < 0 || ci.HashSample == 1 {
+		log.Fatalf("--hash-sample must be 0 to disable it or at least 2 to sample the start and end of files")
+	}
+	if ci.HashSample > 0 && ci.HashSampleSize <= 0 {
+		log.Fatalf("--hash-sample-size must be greater than 0 with --hash-sample")
+	}
+
 	// Process --bind into IP address
 	if bindAddr != "" {
 		addrs, err := net.LookupIP(bindAddr)
@@ -39,6 +39,7 @@ type Features struct {
 	NoMultiThreading         bool // set if can't have multiplethreads on one download open
 	Overlay                  bool // this wraps one or more backends to add functionality
 	ChunkWriterDoesntSeek    bool // set if the chunk writer doesn't need to read the data more than once
+	ChunkWriterCanCopy       bool // set if the chunk writer implements ChunkCopier
 
 	// Purge all files in the directory specified
 	//
@@ -725,6 +726,16 @@ type ChunkWriter interface {
 	Abort(ctx context.Context) error
 }
 
+// ChunkCopier is an optional interface for ChunkWriter to write a
+// chunk by copying part of an existing object server-side
+type ChunkCopier interface {
+	// CopyChunk will write chunk number by copying size bytes from
+	// offset in src, where chunk number >= 0
+	//
+	// src must be an object on the Fs the ChunkWriter was opened on
+	CopyChunk(ctx context.Context, chunkNumber int, src Object, offset, size int64) (bytesWritten int64, err error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
@@ -9,9 +9,11 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
@@ -44,6 +46,14 @@ type CheckOpt struct {
 	Match        io.Writer // matching files
 	Differ       io.Writer // differing files
 	Error        io.Writer // files with errors of some kind
+
+	// If set, Callback is called with the remote and the sigil used
+	// in the Combined report for each file reported
+	Callback func(remote string, sigil rune)
+
+	CheckMetadata   bool     // compare the metadata of files which match too
+	MetadataInclude []string // only compare these metadata keys if set
+	MetadataExclude []string // don't compare these metadata keys
 }
 
 // checkMarch is used to march over two Fses in the same way as
@@ -61,16 +71,27 @@ type checkMarch struct {
 }
 
 // report outputs the fileName to out if required and to the combined log
-func (c *checkMarch) report(o fs.DirEntry, out io.Writer, sigil rune) {
-	c.reportFilename(o.String(), out, sigil)
+//
+// Any details are written to the combined log after the fileName,
+// one per line with a ~ sigil.
+func (c *checkMarch) report(o fs.DirEntry, out io.Writer, sigil rune, details ...string) {
+	c.reportFilename(o.String(), out, sigil, details...)
+	if c.opt.Callback != nil {
+		c.opt.Callback(o.Remote(), sigil)
+	}
 }
 
-func (c *checkMarch) reportFilename(filename string, out io.Writer, sigil rune) {
+func (c *checkMarch) reportFilename(filename string, out io.Writer, sigil rune, details ...string) {
 	if out != nil {
 		SyncFprintf(out, "%s\n", filename)
 	}
 	if c.opt.Combined != nil {
-		SyncFprintf(c.opt.Combined, "%c %s\n", sigil, filename)
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "%c %s\n", sigil, filename)
+		for _, detail := range details {
+			fmt.Fprintf(&buf, "~ %s: %s\n", filename, detail)
+		}
+		SyncFprintf(c.opt.Combined, "%s", buf.String())
 	}
 }
 
@@ -136,6 +157,104 @@ func (c *checkMarch) checkIdentical(ctx context.Context, dst, src fs.Object) (di
 	return c.opt.Check(ctx, dst, src)
 }
 
+// metadataTimeKeys are the metadata keys holding RFC 3339 times which
+// are compared allowing for the modify window
+var metadataTimeKeys = map[string]struct{}{
+	"mtime": {},
+	"btime": {},
+	"atime": {},
+}
+
+// checkMetadata compares the metadata of dst with the metadata src
+// would have when copied to Fdst, which has --metadata-set and
+// --metadata-mapper applied.
+//
+// Only keys in the source metadata are compared, subject to
+// MetadataInclude and MetadataExclude, so keys the destination adds
+// aren't differences.
+//
+// It returns a description of each key which differs, having logged
+// them.
+func (c *checkMarch) checkMetadata(ctx context.Context, dst, src fs.Object) (diffs []string, err error) {
+	ctx, ci := fs.AddConfig(ctx)
+	ci.Metadata = true
+	var options []fs.OpenOption
+	if ci.MetadataSet != nil {
+		options = append(options, fs.MetadataOption(ci.MetadataSet))
+	}
+	want, err := fs.GetMetadataOptions(ctx, c.opt.Fdst, src, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source metadata: %w", err)
+	}
+	got, err := fs.GetMetadata(ctx, dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination metadata: %w", err)
+	}
+	window := fs.GetModifyWindow(ctx, c.opt.Fsrc, c.opt.Fdst)
+	keys := make([]string, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !c.compareMetadataKey(k) {
+			continue
+		}
+		wantValue := want[k]
+		gotValue, found := got[k]
+		var diff string
+		switch {
+		case !found:
+			diff = fmt.Sprintf("metadata %q missing on destination", k)
+		case gotValue == wantValue:
+		case metadataTimesEqual(k, wantValue, gotValue, window):
+		default:
+			diff = fmt.Sprintf("metadata %q differs: %q != %q", k, wantValue, gotValue)
+		}
+		if diff != "" {
+			fs.Errorf(src, "%s", diff)
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// compareMetadataKey returns true if the metadata key k should be compared
+func (c *checkMarch) compareMetadataKey(k string) bool {
+	for _, exclude := range c.opt.MetadataExclude {
+		if strings.EqualFold(k, exclude) {
+			return false
+		}
+	}
+	if len(c.opt.MetadataInclude) == 0 {
+		return true
+	}
+	for _, include := range c.opt.MetadataInclude {
+		if strings.EqualFold(k, include) {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataTimesEqual returns true if k is a time key and a and b are
+// times within window of each other
+func metadataTimesEqual(k, a, b string, window time.Duration) bool {
+	if _, ok := metadataTimeKeys[k]; !ok {
+		return false
+	}
+	ta, err := time.Parse(time.RFC3339Nano, a)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC3339Nano, b)
+	if err != nil {
+		return false
+	}
+	dt := ta.Sub(tb)
+	return dt >= -window && dt <= window
+}
+
 // Match is called when src and dst are present, so sync src to dst
 func (c *checkMarch) Match(ctx context.Context, dst, src fs.DirEntry) (recurse bool) {
 	switch srcX := src.(type) {
@@ -153,6 +272,11 @@ func (c *checkMarch) Match(ctx context.Context, dst, src fs.DirEntry) (recurse b
 					c.wg.Done()
 				}()
 				differ, noHash, err := c.checkIdentical(ctx, dstX, srcX)
+				var metadataDiffs []string
+				if err == nil && !differ && c.opt.CheckMetadata {
+					metadataDiffs, err = c.checkMetadata(ctx, dstX, srcX)
+					differ = len(metadataDiffs) > 0
+				}
 				if err != nil {
 					fs.Errorf(src, "%v", err)
 					_ = fs.CountError(err)
@@ -162,7 +286,7 @@ func (c *checkMarch) Match(ctx context.Context, dst, src fs.DirEntry) (recurse b
 					err := errors.New("files differ")
 					// the checkFn has already logged the reason
 					_ = fs.CountError(err)
-					c.report(src, c.opt.Differ, '*')
+					c.report(src, c.opt.Differ, '*', metadataDiffs...)
 				} else {
 					c.matches.Add(1)
 					c.report(src, c.opt.Match, '=')
@@ -278,7 +402,18 @@ func Check(ctx context.Context, opt *CheckOpt) error {
 			return true, false, err
 		}
 		if ht == hash.None {
-			return false, true, nil
+			if fs.GetConfig(ctx).HashSample <= 0 {
+				return false, true, nil
+			}
+			differ, err = CheckSampled(ctx, dst, src)
+			if err != nil {
+				return true, false, err
+			}
+			if differ {
+				err = errors.New("sampled content differs")
+				fs.Errorf(src, "%v", err)
+			}
+			return differ, false, nil
 		}
 		if !same {
 			err = fmt.Errorf("%v differ", ht)
@@ -456,6 +591,9 @@ func CheckSum(ctx context.Context, fsrc, fsum fs.Fs, sumFile string, hashType ha
 		}
 		c.dstFilesMissing.Add(1)
 		c.reportFilename(filename, opt.MissingOnDst, '+')
+		if opt.Callback != nil {
+			opt.Callback(filename, '+')
+		}
 	}
 
 	return c.reportResults(ctx, lastErr)
@@ -10,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/rclone/rclone/fs"
@@ -28,6 +29,7 @@ func testCheck(t *testing.T, checkFunction func(ctx context.Context, opt *operat
 	ctx := context.Background()
 	ci := fs.GetConfig(ctx)
 
+	var callbacks *bytes.Buffer
 	addBuffers := func(opt *operations.CheckOpt) {
 		opt.Combined = new(bytes.Buffer)
 		opt.MissingOnSrc = new(bytes.Buffer)
@@ -35,6 +37,15 @@ func testCheck(t *testing.T, checkFunction func(ctx context.Context, opt *operat
 		opt.Match = new(bytes.Buffer)
 		opt.Differ = new(bytes.Buffer)
 		opt.Error = new(bytes.Buffer)
+		// The callback should see the same as the combined report
+		callback := new(bytes.Buffer)
+		var mu sync.Mutex
+		opt.Callback = func(remote string, sigil rune) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(callback, "%c %s\n", sigil, remote)
+		}
+		callbacks = callback
 	}
 
 	sortLines := func(in string) []string {
@@ -60,6 +71,7 @@ func testCheck(t *testing.T, checkFunction func(ctx context.Context, opt *operat
 		checkBuffer("match", want, opt.Match)
 		checkBuffer("differ", want, opt.Differ)
 		checkBuffer("error", want, opt.Error)
+		checkBuffer("combined", want, callbacks)
 	}
 
 	check := func(i int, wantErrors int64, wantChecks int64, oneway bool, wantOutput map[string]string) {
@@ -215,6 +227,47 @@ func TestCheckSizeOnly(t *testing.T) {
 	TestCheck(t)
 }
 
+func TestCheckMetadata(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	if !r.Flocal.Features().ReadMetadata || !r.Fremote.Features().ReadMetadata {
+		t.Skip("need metadata support on both remotes")
+	}
+	file1 := r.WriteBoth(ctx, "file1", "hello", t1)
+	r.CheckLocalItems(t, file1)
+	r.CheckRemoteItems(t, file1)
+
+	check := func(include, exclude []string, wantDiffer bool) string {
+		combined := new(bytes.Buffer)
+		opt := operations.CheckOpt{
+			Fdst:            r.Fremote,
+			Fsrc:            r.Flocal,
+			Combined:        combined,
+			CheckMetadata:   true,
+			MetadataInclude: include,
+			MetadataExclude: exclude,
+		}
+		accounting.GlobalStats().ResetCounters()
+		err := operations.Check(ctx, &opt)
+		if wantDiffer {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		return combined.String()
+	}
+
+	assert.Equal(t, "= file1\n", check([]string{"mtime"}, nil, false))
+
+	// Metadata the source would be copied with which the
+	// destination doesn't have
+	ctx, ci := fs.AddConfig(ctx)
+	ci.MetadataSet = fs.Metadata{"potato": "chips"}
+	got := check([]string{"mtime", "potato"}, nil, true)
+	assert.Equal(t, "* file1\n~ file1: metadata \"potato\" missing on destination\n", got)
+	assert.Equal(t, "= file1\n", check([]string{"mtime", "POTATO"}, []string{"potato"}, false))
+}
+
 func TestCheckEqualReaders(t *testing.T) {
 	b65a := make([]byte, 65*1024)
 	b65b := make([]byte, 65*1024)
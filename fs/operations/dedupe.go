@@ -57,12 +57,28 @@ outer:
 }
 
 // dedupeDeleteAllButOne deletes all but the one in keep
-func dedupeDeleteAllButOne(ctx context.Context, keep int, remote string, objs []fs.Object) {
+//
+// If weak is set then the objects were matched on only part of their
+// content, so each is compared in full with the one kept and only
+// deleted if it is identical.
+func dedupeDeleteAllButOne(ctx context.Context, keep int, remote string, objs []fs.Object, weak bool) {
 	count := 0
 	for i, o := range objs {
 		if i == keep {
 			continue
 		}
+		if weak {
+			differ, err := checkIdenticalDownload(ctx, o, objs[keep])
+			if err != nil {
+				err = fs.CountError(err)
+				fs.Errorf(o, "Not deleting as failed to compare with %v: %v", objs[keep], err)
+				continue
+			}
+			if differ {
+				fs.Logf(o, "Not deleting as contents differ from %v", objs[keep])
+				continue
+			}
+		}
 		err := DeleteFile(ctx, o)
 		if err == nil {
 			count++
@@ -159,7 +175,9 @@ func dedupeList(ctx context.Context, f fs.Fs, ht hash.Type, remote string, objs
 }
 
 // dedupeInteractive interactively dedupes the slice of objects
-func dedupeInteractive(ctx context.Context, f fs.Fs, ht hash.Type, remote string, objs []fs.Object, byHash bool) bool {
+//
+// weak is passed to dedupeDeleteAllButOne.
+func dedupeInteractive(ctx context.Context, f fs.Fs, ht hash.Type, remote string, objs []fs.Object, byHash, weak bool) bool {
 	dedupeList(ctx, f, ht, remote, objs, byHash)
 	commands := []string{"sSkip and do nothing", "kKeep just one (choose which in next step)"}
 	if !byHash {
@@ -170,7 +188,7 @@ func dedupeInteractive(ctx context.Context, f fs.Fs, ht hash.Type, remote string
 	case 's':
 	case 'k':
 		keep := config.ChooseNumber("Enter the number of the file to keep", 1, len(objs))
-		dedupeDeleteAllButOne(ctx, keep-1, remote, objs)
+		dedupeDeleteAllButOne(ctx, keep-1, remote, objs, weak)
 	case 'r':
 		dedupeRename(ctx, f, remote, objs)
 	case 'q':
@@ -405,11 +423,17 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode, byHash bool
 	// find a hash to use
 	ht := f.Hashes().GetOne()
 	what := "names"
+	sampled := false
 	if byHash {
-		if ht == hash.None {
-			return fmt.Errorf("%v has no hashes", f)
+		switch {
+		case ht != hash.None:
+			what = ht.String() + " hashes"
+		case ci.HashSample > 0:
+			what = "sampled content"
+			sampled = true
+		default:
+			return fmt.Errorf("%v has no hashes - try --hash-sample", f)
 		}
-		what = ht.String() + " hashes"
 	}
 	fs.Infof(f, "Looking for duplicate %s using %v mode.", what, mode)
 
@@ -436,8 +460,18 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode, byHash bool
 
 	// Now find duplicate files
 	files := map[string][]fs.Object{}
+	bySize := map[int64][]fs.Object{} // files to sample grouped by size
 	err := walk.ListR(ctx, f, "", false, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
 		entries.ForObject(func(o fs.Object) {
+			if sampled {
+				if o.Size() < 0 {
+					fs.Debugf(o, "Ignoring as size is unknown")
+					return
+				}
+				bySize[o.Size()] = append(bySize[o.Size()], o)
+				return
+			}
+
 			tr := accounting.Stats(ctx).NewCheckingTransfer(o, "checking")
 			defer tr.Done(ctx, nil)
 
@@ -462,10 +496,32 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode, byHash bool
 		return err
 	}
 
+	// Only sample files which have the same size as another
+	weakIDs := map[string]bool{}
+	for size, objs := range bySize {
+		if len(objs) <= 1 {
+			continue
+		}
+		for _, o := range objs {
+			tr := accounting.Stats(ctx).NewCheckingTransfer(o, "checking")
+			sum, err := SampleHash(ctx, o, ci.HashSample, int64(ci.HashSampleSize))
+			tr.Done(ctx, nil)
+			if err != nil {
+				fs.Errorf(o, "Failed to sample: %v", err)
+				continue
+			}
+			ID := fmt.Sprintf("size %d, sampled md5 %s", size, sum)
+			files[ID] = append(files[ID], o)
+			weakIDs[ID] = len(sampleRanges(size, ci.HashSample, int64(ci.HashSampleSize))) > 1
+		}
+	}
+
 	for remote, objs := range files {
 		if len(objs) <= 1 {
 			continue
 		}
+		// Sampled matches are compared in full before deleting
+		weak := weakIDs[remote]
 		fs.Logf(remote, "Found %d files with duplicate %s", len(objs), what)
 		if !byHash && mode != DeduplicateList {
 			objs = dedupeDeleteIdentical(ctx, ht, remote, objs)
@@ -476,25 +532,25 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode, byHash bool
 		}
 		switch mode {
 		case DeduplicateInteractive:
-			if !dedupeInteractive(ctx, f, ht, remote, objs, byHash) {
+			if !dedupeInteractive(ctx, f, ht, remote, objs, byHash, weak) {
 				return nil
 			}
 		case DeduplicateFirst:
-			dedupeDeleteAllButOne(ctx, 0, remote, objs)
+			dedupeDeleteAllButOne(ctx, 0, remote, objs, weak)
 		case DeduplicateNewest:
 			sortOldestFirst(objs)
-			dedupeDeleteAllButOne(ctx, len(objs)-1, remote, objs)
+			dedupeDeleteAllButOne(ctx, len(objs)-1, remote, objs, weak)
 		case DeduplicateOldest:
 			sortOldestFirst(objs)
-			dedupeDeleteAllButOne(ctx, 0, remote, objs)
+			dedupeDeleteAllButOne(ctx, 0, remote, objs, weak)
 		case DeduplicateRename:
 			dedupeRename(ctx, f, remote, objs)
 		case DeduplicateLargest:
 			sortSmallestFirst(objs)
-			dedupeDeleteAllButOne(ctx, len(objs)-1, remote, objs)
+			dedupeDeleteAllButOne(ctx, len(objs)-1, remote, objs, weak)
 		case DeduplicateSmallest:
 			sortSmallestFirst(objs)
-			dedupeDeleteAllButOne(ctx, 0, remote, objs)
+			dedupeDeleteAllButOne(ctx, 0, remote, objs, weak)
 		case DeduplicateSkip:
 			fs.Logf(remote, "Skipping %d files with duplicate %s", len(objs), what)
 		case DeduplicateList:
@@ -0,0 +1,319 @@
+// dedupe across remotes - finds files with the same content in several remotes
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// DedupeSampleSize is the number of bytes at the start of each file
+// which are hashed when the remotes don't share a hash type
+const DedupeSampleSize = 1024 * 1024
+
+// dedupeCopy is a file found in one of the remotes
+type dedupeCopy struct {
+	o         fs.Object
+	f         fs.Fs
+	index     int  // index of f in the remotes
+	secondary bool // set if copies can be removed from f
+}
+
+// String returns the full path of the copy
+func (c *dedupeCopy) String() string {
+	return fspath.JoinRootPath(fs.ConfigString(c.f), c.o.Remote())
+}
+
+// dedupeSampleHash returns the MD5 of the first n bytes of o
+func dedupeSampleHash(ctx context.Context, o fs.Object, n int64) (sum string, err error) {
+	tr := accounting.Stats(ctx).NewTransfer(o, nil)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	var options []fs.OpenOption
+	if o.Size() < 0 || o.Size() > n {
+		options = append(options, &fs.RangeOption{Start: 0, End: n - 1})
+	}
+	var in io.ReadCloser
+	in, err = Open(ctx, o, options...)
+	if err != nil {
+		return "", err
+	}
+	in = tr.Account(ctx, in)
+	defer fs.CheckClose(in, &err)
+	sums, err := hash.StreamTypes(io.LimitReader(in, n), hash.NewHashSet(hash.MD5))
+	if err != nil {
+		return "", err
+	}
+	return sums[hash.MD5], nil
+}
+
+// dedupeContentID returns a string identifying the content of c or ""
+// if it couldn't be found.
+//
+// weak is set if the ID wasn't made from all of the content of c, so
+// copies with the same ID might still differ.
+func dedupeContentID(ctx context.Context, c *dedupeCopy, ht hash.Type) (ID string, weak bool) {
+	ci := fs.GetConfig(ctx)
+	size := c.o.Size()
+	switch {
+	case ci.SizeOnly:
+		return fmt.Sprintf("size %d", size), true
+	case ht != hash.None:
+		sum, err := c.o.Hash(ctx, ht)
+		if err != nil {
+			fs.Errorf(c.o, "Failed to hash: %v", err)
+			return "", false
+		}
+		if sum == "" {
+			return "", false
+		}
+		return fmt.Sprintf("%v %s", ht, sum), false
+	case ci.HashSample > 0:
+		sum, err := SampleHash(ctx, c.o, ci.HashSample, int64(ci.HashSampleSize))
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(c.o, "Failed to sample: %v", err)
+			return "", false
+		}
+		return fmt.Sprintf("size %d, sampled md5 %s", size, sum), int64(ci.HashSample)*int64(ci.HashSampleSize) < size
+	default:
+		sum, err := dedupeSampleHash(ctx, c.o, DedupeSampleSize)
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(c.o, "Failed to read sample: %v", err)
+			return "", false
+		}
+		return fmt.Sprintf("size %d, md5 of first %v %s", size, fs.SizeSuffix(DedupeSampleSize), sum), size > DedupeSampleSize
+	}
+}
+
+// dedupeListCopies lists the copies with the same content
+func dedupeListCopies(ctx context.Context, ID string, copies []*dedupeCopy) {
+	fmt.Printf("%s: %d copies\n", ID, len(copies))
+	for i, c := range copies {
+		kind := "primary"
+		if c.secondary {
+			kind = "secondary"
+		}
+		fmt.Printf("  %d: %12d bytes, %s, %-9s %s\n", i+1, c.o.Size(), c.o.ModTime(ctx).Local().Format("2006-01-02 15:04:05.000000000"), kind, c)
+	}
+}
+
+// dedupeDeleteRedundant deletes all the secondary copies apart from
+// the one in keep
+//
+// If weak is set then the copies were matched on only part of their
+// content, so each copy is compared in full with the one kept and
+// only deleted if it is identical.
+func dedupeDeleteRedundant(ctx context.Context, keep int, ID string, copies []*dedupeCopy, weak bool) {
+	count := 0
+	for i, c := range copies {
+		if i == keep || !c.secondary {
+			continue
+		}
+		if weak {
+			differ, err := checkIdenticalDownload(ctx, c.o, copies[keep].o)
+			if err != nil {
+				err = fs.CountError(err)
+				fs.Errorf(c, "Not deleting as failed to compare with %s: %v", copies[keep], err)
+				continue
+			}
+			if differ {
+				fs.Logf(c, "Not deleting as contents differ from %s", copies[keep])
+				continue
+			}
+		}
+		err := DeleteFile(ctx, c.o)
+		if err == nil {
+			count++
+		}
+	}
+	if count > 0 {
+		fs.Logf(nil, "%s: Deleted %d redundant copies keeping %s", ID, count, copies[keep])
+	}
+}
+
+// dedupeRenameRedundant renames all the secondary copies apart from
+// the first
+func dedupeRenameRedundant(ctx context.Context, copies []*dedupeCopy) {
+	for i, c := range copies {
+		if i == 0 || !c.secondary {
+			continue
+		}
+		dedupeRename(ctx, c.f, c.o.Remote(), []fs.Object{c.o})
+	}
+}
+
+// dedupeInteractiveCopies asks the user which copy to keep
+func dedupeInteractiveCopies(ctx context.Context, ID string, copies []*dedupeCopy, weak bool) bool {
+	dedupeListCopies(ctx, ID, copies)
+	switch config.Command([]string{"sSkip and do nothing", "kKeep just one, deleting the secondary copies (choose which in next step)", "qQuit"}) {
+	case 's':
+	case 'k':
+		keep := config.ChooseNumber("Enter the number of the file to keep", 1, len(copies))
+		dedupeDeleteRedundant(ctx, keep-1, ID, copies, weak)
+	case 'q':
+		return false
+	}
+	return true
+}
+
+// DeduplicateRemotes finds files with the same content in primary and
+// the secondaries and deals with the redundant copies according to
+// mode.
+//
+// Files are identified by a hash type which all the remotes support,
+// or if there isn't one, by the blocks set with --hash-sample, or
+// failing that by their size and the MD5 of their first
+// DedupeSampleSize bytes. If --size-only is set they are identified by
+// size alone.
+//
+// Unless files are identified by a hash, or the part read is the
+// whole file, they are compared in full before any are deleted. These
+// weak matches are deleted only if identical.
+//
+// Only copies in the secondaries are ever deleted or renamed, so
+// primary is never changed.
+func DeduplicateRemotes(ctx context.Context, primary fs.Fs, secondaries []fs.Fs, mode DeduplicateMode) error {
+	ci := fs.GetConfig(ctx)
+	fsrcs := append([]fs.Fs{primary}, secondaries...)
+
+	// find a hash which all the remotes support
+	hashes := primary.Hashes()
+	for _, f := range secondaries {
+		hashes = hashes.Overlap(f.Hashes())
+	}
+	ht := hashes.GetOne()
+	what := ht.String() + " hashes"
+	if ci.SizeOnly {
+		what = "sizes"
+	} else if ht == hash.None && ci.HashSample > 0 {
+		what = "sampled content"
+	} else if ht == hash.None {
+		what = "sizes and MD5 hashes of their start"
+	}
+	fs.Infof(primary, "Looking for files in %d remotes with duplicate %s using %v mode.", len(fsrcs), what, mode)
+
+	// Read all the files grouping them by size
+	bySize := map[int64][]*dedupeCopy{}
+	for i, f := range fsrcs {
+		err := walk.ListR(ctx, f, "", false, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+			entries.ForObject(func(o fs.Object) {
+				if o.Size() < 0 && (ci.SizeOnly || ht == hash.None) {
+					fs.Debugf(o, "Ignoring as size is unknown")
+					return
+				}
+				bySize[o.Size()] = append(bySize[o.Size()], &dedupeCopy{
+					o:         o,
+					f:         f,
+					index:     i,
+					secondary: i > 0,
+				})
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Work out the content of the files which might have
+	// duplicates in the secondaries
+	byID := map[string][]*dedupeCopy{}
+	weakIDs := map[string]bool{}
+	for size, copies := range bySize {
+		// Files of unknown size can be different sizes
+		if len(copies) <= 1 && size >= 0 {
+			continue
+		}
+		for _, c := range copies {
+			tr := accounting.Stats(ctx).NewCheckingTransfer(c.o, "hashing")
+			ID, weak := dedupeContentID(ctx, c, ht)
+			tr.Done(ctx, nil)
+			if ID != "" {
+				byID[ID] = append(byID[ID], c)
+				weakIDs[ID] = weakIDs[ID] || weak
+			}
+		}
+	}
+	var IDs []string
+	for ID, copies := range byID {
+		hasSecondary := false
+		for _, c := range copies {
+			hasSecondary = hasSecondary || c.secondary
+		}
+		if len(copies) > 1 && hasSecondary {
+			IDs = append(IDs, ID)
+		}
+	}
+	sort.Strings(IDs)
+
+	for _, ID := range IDs {
+		copies := byID[ID]
+		// Primary first then in the order of the remotes given
+		sort.Slice(copies, func(i, j int) bool {
+			if copies[i].index != copies[j].index {
+				return copies[i].index < copies[j].index
+			}
+			return copies[i].o.Remote() < copies[j].o.Remote()
+		})
+		weak := weakIDs[ID]
+		if weak {
+			fs.Logf(nil, "%s: Found %d possible copies", ID, len(copies))
+		} else {
+			fs.Logf(nil, "%s: Found %d copies", ID, len(copies))
+		}
+		objs := make([]fs.Object, len(copies))
+		for i, c := range copies {
+			objs[i] = c.o
+		}
+		// keep returns the index in copies of o
+		keep := func(o fs.Object) int {
+			for i, c := range copies {
+				if c.o == o {
+					return i
+				}
+			}
+			return 0
+		}
+		switch mode {
+		case DeduplicateInteractive:
+			if !dedupeInteractiveCopies(ctx, ID, copies, weak) {
+				return nil
+			}
+		case DeduplicateFirst:
+			dedupeDeleteRedundant(ctx, 0, ID, copies, weak)
+		case DeduplicateNewest:
+			sortOldestFirst(objs)
+			dedupeDeleteRedundant(ctx, keep(objs[len(objs)-1]), ID, copies, weak)
+		case DeduplicateOldest:
+			sortOldestFirst(objs)
+			dedupeDeleteRedundant(ctx, keep(objs[0]), ID, copies, weak)
+		case DeduplicateLargest:
+			sortSmallestFirst(objs)
+			dedupeDeleteRedundant(ctx, keep(objs[len(objs)-1]), ID, copies, weak)
+		case DeduplicateSmallest:
+			sortSmallestFirst(objs)
+			dedupeDeleteRedundant(ctx, keep(objs[0]), ID, copies, weak)
+		case DeduplicateRename:
+			dedupeRenameRedundant(ctx, copies)
+		case DeduplicateSkip:
+			fs.Logf(nil, "%s: Skipping %d copies", ID, len(copies))
+		case DeduplicateList:
+			dedupeListCopies(ctx, ID, copies)
+		default:
+			//skip
+		}
+	}
+	return nil
+}
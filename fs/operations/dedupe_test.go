@@ -2,10 +2,13 @@ package operations_test
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/walk"
@@ -164,6 +167,38 @@ func TestDeduplicateNewestByHash(t *testing.T) {
 	r.CheckRemoteItems(t, file3, file4)
 }
 
+// noHashFs hides the hashes of an Fs so --hash-sample is used
+type noHashFs struct {
+	fs.Fs
+}
+
+// Hashes returns no hashes
+func (f *noHashFs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+func TestDeduplicateSampled(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.HashSample = 2
+	ci.HashSampleSize = 4
+	ctx = accounting.WithStatsGroup(ctx, "dedupe-sampled")
+	r := fstest.NewRun(t)
+
+	// a and b only differ between the sampled blocks
+	fileA := r.WriteObject(ctx, "a", "AAAA this is the same ZZZZ", t1)
+	fileB := r.WriteObject(ctx, "b", "AAAA this is changed! ZZZZ", t1)
+	r.WriteObject(ctx, "c", "AAAA this is the same ZZZZ", t1)
+	fileD := r.WriteObject(ctx, "d", "a different size", t1)
+
+	err := operations.Deduplicate(ctx, &noHashFs{r.Fremote}, operations.DeduplicateFirst, true)
+	require.NoError(t, err)
+	r.CheckRemoteItems(t, fileA, fileB, fileD)
+
+	// The file with a unique size wasn't sampled - the checks are
+	// sampling a, b and c and deleting c
+	assert.Equal(t, int64(4), accounting.StatsGroup(ctx, "dedupe-sampled").GetChecks())
+}
+
 func TestDeduplicateOldest(t *testing.T) {
 	r := fstest.NewRun(t)
 	skipIfCantDedupe(t, r.Fremote)
@@ -246,6 +281,57 @@ func TestDeduplicateRename(t *testing.T) {
 	}))
 }
 
+func TestDeduplicateRemotes(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		mode     operations.DeduplicateMode
+		sizeOnly bool
+		want     []string
+	}{
+		{mode: operations.DeduplicateList, want: []string{"copy.txt", "dir/copy2.txt", "other.txt", "same-size.txt"}},
+		{mode: operations.DeduplicateSkip, want: []string{"copy.txt", "dir/copy2.txt", "other.txt", "same-size.txt"}},
+		{mode: operations.DeduplicateFirst, want: []string{"other.txt", "same-size.txt"}},
+		{mode: operations.DeduplicateNewest, want: []string{"dir/copy2.txt", "other.txt", "same-size.txt"}},
+		{mode: operations.DeduplicateOldest, want: []string{"other.txt", "same-size.txt"}},
+		// same-size.txt is only a weak match so isn't deleted
+		{mode: operations.DeduplicateFirst, sizeOnly: true, want: []string{"other.txt", "same-size.txt"}},
+		{mode: operations.DeduplicateList, sizeOnly: true, want: []string{"copy.txt", "dir/copy2.txt", "other.txt", "same-size.txt"}},
+	} {
+		t.Run(fmt.Sprintf("%v,sizeOnly=%v", test.mode, test.sizeOnly), func(t *testing.T) {
+			r := fstest.NewRun(t)
+			if !test.sizeOnly {
+				skipIfNoHash(t, r.Fremote)
+			}
+			ctx := ctx
+			if test.sizeOnly {
+				var ci *fs.ConfigInfo
+				ctx, ci = fs.AddConfig(ctx)
+				ci.SizeOnly = true
+			}
+			file1 := r.WriteFile("one.txt", "This is one", t1)
+			r.WriteObject(ctx, "copy.txt", "This is one", t2)
+			r.WriteObject(ctx, "dir/copy2.txt", "This is one", t3)
+			r.WriteObject(ctx, "other.txt", "This is another one", t1)
+			r.WriteObject(ctx, "same-size.txt", "This is 1!!", t1)
+
+			err := operations.DeduplicateRemotes(ctx, r.Flocal, []fs.Fs{r.Fremote}, test.mode)
+			require.NoError(t, err)
+
+			// The primary is never changed
+			r.CheckLocalItems(t, file1)
+			var got []string
+			require.NoError(t, walk.ListR(ctx, r.Fremote, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+				entries.ForObject(func(o fs.Object) {
+					got = append(got, o.Remote())
+				})
+				return nil
+			}))
+			sort.Strings(got)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
 // This should really be a unit test, but the test framework there
 // doesn't have enough tools to make it easy
 func TestMergeDirs(t *testing.T) {
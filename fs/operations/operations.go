@@ -261,6 +261,22 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 			logger(ctx, Differ, src, dst, nil)
 			return false
 		}
+		if ht == hash.None && ci.HashSample > 0 {
+			if srcObj, ok := src.(fs.Object); ok {
+				differ, err := CheckSampled(ctx, dst, srcObj)
+				if err != nil {
+					fs.Errorf(src, "Failed to compare samples: %v", err)
+				}
+				if differ {
+					fs.Debugf(src, "Sampled content differs")
+					logger(ctx, Differ, src, dst, nil)
+					return false
+				}
+				fs.Debugf(src, "Size and sampled content of src and dst objects identical")
+				logger(ctx, Match, src, dst, nil)
+				return true
+			}
+		}
 		if ht == hash.None {
 			common := src.Fs().Hashes().Overlap(dst.Fs().Hashes())
 			if common.Count() == 0 {
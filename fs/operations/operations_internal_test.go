@@ -41,3 +41,24 @@ func TestSizeDiffers(t *testing.T) {
 		assert.Equal(t, test.want, got, fmt.Sprintf("ignoreSize=%v, srcSize=%v, dstSize=%v", test.ignoreSize, test.srcSize, test.dstSize))
 	}
 }
+
+func TestSampleRanges(t *testing.T) {
+	for _, test := range []struct {
+		size      int64
+		blocks    int
+		blockSize int64
+		want      []sampleRange
+	}{
+		{0, 4, 10, nil},
+		{30, 4, 10, []sampleRange{{0, 29}}},
+		{40, 4, 10, []sampleRange{{0, 39}}},
+		{100, 1, 10, []sampleRange{{0, 9}, {90, 99}}},
+		{15, 1, 10, []sampleRange{{0, 14}}},
+		{100, 2, 10, []sampleRange{{0, 9}, {90, 99}}},
+		{100, 4, 10, []sampleRange{{0, 9}, {30, 39}, {60, 69}, {90, 99}}},
+		{101, 3, 10, []sampleRange{{0, 9}, {45, 54}, {91, 100}}},
+	} {
+		got := sampleRanges(test.size, test.blocks, test.blockSize)
+		assert.Equal(t, test.want, got, fmt.Sprintf("%+v", test))
+	}
+}
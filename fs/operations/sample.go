@@ -0,0 +1,127 @@
+// sampled hashing - fingerprints files by reading parts of them
+
+package operations
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"golang.org/x/sync/errgroup"
+)
+
+// sampleRange is a part of a file read by SampleHash
+type sampleRange struct {
+	start int64
+	end   int64 // inclusive
+}
+
+// sampleRanges returns the parts of a file of size bytes to read to
+// make blocks samples of blockSize bytes.
+//
+// The samples are spread evenly through the file and include the
+// first and last blockSize bytes, so at least 2 blocks are read. If
+// they would cover the whole file then a single range for the whole
+// file is returned.
+func sampleRanges(size int64, blocks int, blockSize int64) []sampleRange {
+	if size <= 0 {
+		return nil
+	}
+	if blocks < 2 {
+		blocks = 2
+	}
+	if int64(blocks)*blockSize >= size {
+		return []sampleRange{{start: 0, end: size - 1}}
+	}
+	ranges := make([]sampleRange, blocks)
+	for i := range ranges {
+		start := int64(i) * (size - blockSize) / int64(blocks-1)
+		ranges[i] = sampleRange{start: start, end: start + blockSize - 1}
+	}
+	return ranges
+}
+
+// SampleHash returns a fingerprint of o made by reading blocks blocks
+// of blockSize bytes spread evenly through it, including the first
+// and last, with ranged reads. At least 2 blocks are read.
+//
+// The fingerprint is the MD5 of the size of the file and the blocks
+// read so files with the same fingerprint are very likely, though
+// not certain, to be identical. Files smaller than the blocks are read
+// in full.
+func SampleHash(ctx context.Context, o fs.Object, blocks int, blockSize int64) (sum string, err error) {
+	size := o.Size()
+	if size < 0 {
+		return "", errors.New("can't sample file of unknown size")
+	}
+	if blockSize <= 0 {
+		return "", errors.New("sample block size must be greater than 0")
+	}
+	tr := accounting.Stats(ctx).NewTransfer(o, nil)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	hasher := md5.New()
+	_, _ = fmt.Fprintf(hasher, "%d\n", size)
+	for _, r := range sampleRanges(size, blocks, blockSize) {
+		var options []fs.OpenOption
+		if r.start > 0 || r.end < size-1 {
+			options = append(options, &fs.RangeOption{Start: r.start, End: r.end})
+		}
+		err = sampleRead(ctx, tr, o, hasher, r.end-r.start+1, options)
+		if err != nil {
+			return "", fmt.Errorf("failed to read sample: %w", err)
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// sampleRead reads n bytes of o opened with options into w
+func sampleRead(ctx context.Context, tr *accounting.Transfer, o fs.Object, w io.Writer, n int64, options []fs.OpenOption) (err error) {
+	var in io.ReadCloser
+	in, err = Open(ctx, o, options...)
+	if err != nil {
+		return err
+	}
+	in = tr.Account(ctx, in)
+	defer fs.CheckClose(in, &err)
+	written, err := io.Copy(w, io.LimitReader(in, n))
+	if err != nil {
+		return err
+	}
+	if written != n {
+		return fmt.Errorf("short read: got %d bytes, expecting %d", written, n)
+	}
+	return nil
+}
+
+// CheckSampled checks to see if dst and src are identical by
+// comparing the blocks set by --hash-sample and --hash-sample-size.
+//
+// it returns true if differences were found
+func CheckSampled(ctx context.Context, dst, src fs.Object) (differ bool, err error) {
+	ci := fs.GetConfig(ctx)
+	if src.Size() != dst.Size() {
+		return true, nil
+	}
+	var srcSum, dstSum string
+	g, gCtx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		srcSum, err = SampleHash(gCtx, src, ci.HashSample, int64(ci.HashSampleSize))
+		return err
+	})
+	g.Go(func() (err error) {
+		dstSum, err = SampleHash(gCtx, dst, ci.HashSample, int64(ci.HashSampleSize))
+		return err
+	})
+	err = g.Wait()
+	if err != nil {
+		return true, err
+	}
+	return srcSum != dstSum, nil
+}
@@ -0,0 +1,70 @@
+package operations_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleHash(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	same := strings.Repeat("a", 100)
+	middle := strings.Repeat("a", 50) + "b" + strings.Repeat("a", 49)
+	r.WriteFile("same", same, t1)
+	r.WriteFile("middle", middle, t1)
+	r.WriteFile("short", "aaaa", t1)
+	newObject := func(remote string) fs.Object {
+		o, err := r.Flocal.NewObject(ctx, remote)
+		require.NoError(t, err)
+		return o
+	}
+	sameObj, middleObj, shortObj := newObject("same"), newObject("middle"), newObject("short")
+
+	sampleHash := func(o fs.Object, blocks int, blockSize int64) string {
+		sum, err := operations.SampleHash(ctx, o, blocks, blockSize)
+		require.NoError(t, err)
+		return sum
+	}
+
+	// Sampling just the ends misses the difference
+	assert.Equal(t, sampleHash(sameObj, 2, 10), sampleHash(middleObj, 2, 10))
+	// but sampling the middle too finds it
+	assert.NotEqual(t, sampleHash(sameObj, 3, 10), sampleHash(middleObj, 3, 10))
+	// as does reading the whole file
+	assert.NotEqual(t, sampleHash(sameObj, 10, 10), sampleHash(middleObj, 10, 10))
+	// files of different sizes never match
+	assert.NotEqual(t, sampleHash(sameObj, 2, 4), sampleHash(shortObj, 2, 4))
+
+	// a block size of 0 is an error
+	_, err := operations.SampleHash(ctx, sameObj, 2, 0)
+	assert.Error(t, err)
+}
+
+func TestCheckSampled(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.HashSample = 3
+	ci.HashSampleSize = 10
+	r := fstest.NewRun(t)
+	r.WriteFile("file", strings.Repeat("a", 100), t1)
+	r.WriteObject(ctx, "file", strings.Repeat("a", 50)+"b"+strings.Repeat("a", 49), t1)
+	src, err := r.Flocal.NewObject(ctx, "file")
+	require.NoError(t, err)
+	dst, err := r.Fremote.NewObject(ctx, "file")
+	require.NoError(t, err)
+
+	differ, err := operations.CheckSampled(ctx, dst, src)
+	require.NoError(t, err)
+	assert.True(t, differ)
+
+	differ, err = operations.CheckSampled(ctx, src, src)
+	require.NoError(t, err)
+	assert.False(t, differ)
+}
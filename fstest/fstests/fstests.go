@@ -852,6 +852,54 @@ func Run(t *testing.T, opt *Opt) {
 			assert.NoError(t, f.Rmdir(ctx, "writer-at-subdir"))
 		})
 
+		// TestFsOpenChunkWriterCopy tests writing in chunks to fs
+		// copying some of the chunks from an existing object
+		// go test -v -run 'TestIntegration/FsMkdir/FsOpenChunkWriterCopy'
+		t.Run("FsOpenChunkWriterCopy", func(t *testing.T) {
+			skipIfNotOk(t)
+			openChunkWriter := f.Features().OpenChunkWriter
+			if openChunkWriter == nil || !f.Features().ChunkWriterCanCopy {
+				t.Skip("FS has no OpenChunkWriter interface which can copy chunks")
+			}
+			size5MBs := 5 * 1024 * 1024
+			size1MB := 1 * 1024 * 1024
+			oldContents := random.String(2 * size5MBs)
+			newContents := random.String(size5MBs)
+
+			path := "writer-copy-subdir/writer-copy-file"
+			file := fstest.NewItem(path, oldContents, file1.ModTime)
+			src := PutTestContents(ctx, t, f, &file, oldContents, true)
+
+			objSrc := object.NewStaticObjectInfo(path, file1.ModTime, int64(2*size5MBs+size1MB), true, nil, nil)
+			_, out, err := openChunkWriter(ctx, path, objSrc, &fs.ChunkOption{
+				ChunkSize: int64(size5MBs),
+			})
+			require.NoError(t, err)
+			copier, ok := out.(fs.ChunkCopier)
+			require.True(t, ok, "ChunkWriter doesn't implement ChunkCopier")
+
+			var n int64
+			n, err = copier.CopyChunk(ctx, 2, src, int64(size5MBs), int64(size1MB))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(size1MB), n)
+			n, err = out.WriteChunk(ctx, 1, strings.NewReader(newContents))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(size5MBs), n)
+			n, err = copier.CopyChunk(ctx, 0, src, 0, int64(size5MBs))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(size5MBs), n)
+
+			assert.NoError(t, out.Close(ctx))
+
+			obj := fstest.NewObject(ctx, t, f, path)
+			wantContents := oldContents[:size5MBs] + newContents + oldContents[size5MBs:size5MBs+size1MB]
+			fileContents := ReadObject(ctx, t, obj, -1)
+			assert.True(t, wantContents == fileContents, "contents of file differ")
+
+			assert.NoError(t, obj.Remove(ctx))
+			assert.NoError(t, f.Rmdir(ctx, "writer-copy-subdir"))
+		})
+
 		// TestFsChangeNotify tests that changes are properly
 		// propagated
 		//
@@ -0,0 +1,13 @@
+package file
+
+import "errors"
+
+var (
+	// ErrLockBusy is returned from TryLock when the lock is held
+	// by another process
+	ErrLockBusy = errors.New("lock: held by another process")
+
+	// ErrLockUnsupported is returned from the lock functions when
+	// the OS doesn't support locking files between processes
+	ErrLockUnsupported = errors.New("lock: not supported")
+)
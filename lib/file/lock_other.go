@@ -0,0 +1,24 @@
+//go:build !unix
+
+package file
+
+import "os"
+
+// LockImplemented is a constant indicating whether Lock, TryLock and
+// Unlock are implemented on this OS.
+const LockImplemented = false
+
+// Lock takes a shared or exclusive lock on f
+func Lock(f *os.File, exclusive bool) error {
+	return ErrLockUnsupported
+}
+
+// TryLock takes a shared or exclusive lock on f without waiting
+func TryLock(f *os.File, exclusive bool) error {
+	return ErrLockUnsupported
+}
+
+// Unlock releases the lock taken on f
+func Unlock(f *os.File) error {
+	return ErrLockUnsupported
+}
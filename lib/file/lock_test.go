@@ -0,0 +1,84 @@
+//go:build unix
+
+package file
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLockHelper holds a lock on a file in a separate process for
+// TestLock as a process never conflicts with its own locks
+func TestLockHelper(t *testing.T) {
+	path := os.Getenv("RCLONE_TEST_LOCK_FILE")
+	if path == "" {
+		t.Skip("only run as a helper for TestLock")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	require.NoError(t, err)
+	require.NoError(t, Lock(f, os.Getenv("RCLONE_TEST_LOCK_EXCLUSIVE") != ""))
+	_, _ = os.Stdout.WriteString("locked\n")
+	_, _ = io.Copy(io.Discard, os.Stdin)
+	require.NoError(t, f.Close())
+}
+
+// holdLock takes a lock on path in another process until the returned
+// function is called
+func holdLock(t *testing.T, path string, exclusive bool) (release func()) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelper$")
+	cmd.Env = append(os.Environ(), "RCLONE_TEST_LOCK_FILE="+path)
+	if exclusive {
+		cmd.Env = append(cmd.Env, "RCLONE_TEST_LOCK_EXCLUSIVE=1")
+	}
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && scanner.Text() != "locked" {
+	}
+	return func() {
+		require.NoError(t, stdin.Close())
+		require.NoError(t, cmd.Wait())
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+
+	// Locks can be taken and changed when nothing else holds them
+	require.NoError(t, TryLock(f, true))
+	require.NoError(t, TryLock(f, false))
+	require.NoError(t, Unlock(f))
+
+	// A shared lock held elsewhere allows shared locks only
+	release := holdLock(t, path, false)
+	require.NoError(t, TryLock(f, false))
+	assert.Equal(t, ErrLockBusy, TryLock(f, true))
+	release()
+
+	// Once released an exclusive lock can be taken
+	require.NoError(t, TryLock(f, true))
+	require.NoError(t, Unlock(f))
+
+	// An exclusive lock held elsewhere allows no locks
+	release = holdLock(t, path, true)
+	assert.Equal(t, ErrLockBusy, TryLock(f, false))
+	assert.Equal(t, ErrLockBusy, TryLock(f, true))
+	release()
+	require.NoError(t, Lock(f, false))
+	require.NoError(t, Unlock(f))
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package file
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockImplemented is a constant indicating whether Lock, TryLock and
+// Unlock are implemented on this OS.
+const LockImplemented = true
+
+// lock sets a POSIX record lock of lockType over the whole of f
+func lock(f *os.File, lockType int16, wait bool) error {
+	cmd := unix.F_SETLK
+	if wait {
+		cmd = unix.F_SETLKW
+	}
+	lk := unix.Flock_t{
+		Type:   lockType,
+		Whence: 0,
+		Start:  0,
+		Len:    0, // to the end of the file however large it grows
+	}
+	for {
+		err := unix.FcntlFlock(f.Fd(), cmd, &lk)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EACCES) {
+			return ErrLockBusy
+		}
+		return err
+	}
+}
+
+// lockType returns the POSIX lock type for a shared or exclusive lock
+func lockType(exclusive bool) int16 {
+	if exclusive {
+		return unix.F_WRLCK
+	}
+	return unix.F_RDLCK
+}
+
+// Lock takes a shared or exclusive lock on f, waiting until any
+// conflicting locks held by other processes are released.
+//
+// The locks are POSIX record locks so they are held by the process,
+// not by f. A process never conflicts with its own locks, and closing
+// any file descriptor of the file releases all the locks the process
+// holds on it. Calling Lock when a lock is already held changes its
+// type atomically.
+func Lock(f *os.File, exclusive bool) error {
+	return lock(f, lockType(exclusive), true)
+}
+
+// TryLock is like Lock but returns ErrLockBusy instead of waiting if
+// the lock is held by another process. If it fails then any lock
+// already held is kept unchanged.
+func TryLock(f *os.File, exclusive bool) error {
+	return lock(f, lockType(exclusive), false)
+}
+
+// Unlock releases the lock taken on f with Lock or TryLock
+func Unlock(f *os.File) error {
+	return lock(f, unix.F_UNLCK, false)
+}
@@ -2,5 +2,11 @@ package file
 
 import "errors"
 
-// ErrDiskFull is returned from PreAllocate when it detects disk full
-var ErrDiskFull = errors.New("preallocate: file too big for remaining disk space")
+var (
+	// ErrDiskFull is returned from PreAllocate when it detects disk full
+	ErrDiskFull = errors.New("preallocate: file too big for remaining disk space")
+
+	// ErrPunchHoleUnsupported is returned from PunchHole when the
+	// OS or file system can't deallocate parts of files
+	ErrPunchHoleUnsupported = errors.New("punch hole: not supported")
+)
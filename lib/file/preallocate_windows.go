@@ -103,3 +103,33 @@ func SetSparse(out *os.File) error {
 	}
 	return nil
 }
+
+type fileZeroDataInformation struct {
+	FileOffset      int64
+	BeyondFinalZero int64
+}
+
+// PunchHoleImplemented is a constant indicating whether the
+// implementation of PunchHole actually does anything.
+const PunchHoleImplemented = true
+
+// PunchHole frees the disk space used by size bytes at offset in the
+// file. The hole reads back as zeroes and the file size is unchanged.
+//
+// The file must have been made sparse with SetSparse for the space to
+// be freed.
+func PunchHole(out *os.File, offset, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	var bytesReturned uint32
+	info := fileZeroDataInformation{
+		FileOffset:      offset,
+		BeyondFinalZero: offset + size,
+	}
+	err := syscall.DeviceIoControl(syscall.Handle(out.Fd()), windows.FSCTL_SET_ZERO_DATA, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil, 0, &bytesReturned, nil)
+	if err != nil {
+		return fmt.Errorf("DeviceIoControl FSCTL_SET_ZERO_DATA: %w", err)
+	}
+	return nil
+}
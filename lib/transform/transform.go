@@ -0,0 +1,165 @@
+// Package transform implements transformations of file names for
+// bulk renaming, for example unicode normalization and case changes.
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/lib/encoder"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Transform converts a single file or directory name, which doesn't
+// contain a "/".
+//
+// modTime is the modification time of the file or directory.
+type Transform func(name string, modTime time.Time) string
+
+// Transforms is a pipeline of Transform applied in order
+type Transforms []Transform
+
+// Help describes the transforms which can be parsed
+const Help = `Each transform is one of:
+
+- nfc, nfd, nfkc, nfkd - convert to this unicode normalization form
+- lowercase, uppercase - change the case of the name
+- prefix=STRING - add STRING to the start of the name
+- suffix=STRING - add STRING to the end of the name
+- suffix_keep_extension=STRING - add STRING to the end of the name, before the extension
+- trimprefix=STRING - remove STRING from the start of the name
+- trimsuffix=STRING - remove STRING from the end of the name
+- regex=PATTERN/REPLACEMENT - replace matches of the regular expression PATTERN with REPLACEMENT, which may use $1 etc
+- encoder=ENCODING - replace characters as rclone's encoder does, where ENCODING is a comma separated list, e.g. Colon,Question,Asterisk
+- date=FORMAT - add the modification time before the extension, where {YYYY}, {MM}, {DD}, {hh}, {mm} and {ss} in FORMAT are replaced
+`
+
+// splitExt splits name into its base and extension
+func splitExt(name string) (base, ext string) {
+	ext = path.Ext(name)
+	if ext == name {
+		return name, ""
+	}
+	return name[:len(name)-len(ext)], ext
+}
+
+// formatDate replaces the placeholders in format with t
+func formatDate(format string, t time.Time) string {
+	return strings.NewReplacer(
+		"{YYYY}", fmt.Sprintf("%04d", t.Year()),
+		"{MM}", fmt.Sprintf("%02d", t.Month()),
+		"{DD}", fmt.Sprintf("%02d", t.Day()),
+		"{hh}", fmt.Sprintf("%02d", t.Hour()),
+		"{mm}", fmt.Sprintf("%02d", t.Minute()),
+		"{ss}", fmt.Sprintf("%02d", t.Second()),
+	).Replace(format)
+}
+
+// Parse a single transform as described in Help
+func Parse(spec string) (Transform, error) {
+	key, value, hasValue := strings.Cut(spec, "=")
+	needValue := func() error {
+		if !hasValue {
+			return fmt.Errorf("transform %q needs a value, e.g. %s=VALUE", key, key)
+		}
+		return nil
+	}
+	switch key {
+	case "nfc":
+		return func(name string, _ time.Time) string { return norm.NFC.String(name) }, nil
+	case "nfd":
+		return func(name string, _ time.Time) string { return norm.NFD.String(name) }, nil
+	case "nfkc":
+		return func(name string, _ time.Time) string { return norm.NFKC.String(name) }, nil
+	case "nfkd":
+		return func(name string, _ time.Time) string { return norm.NFKD.String(name) }, nil
+	case "lowercase":
+		return func(name string, _ time.Time) string { return strings.ToLower(name) }, nil
+	case "uppercase":
+		return func(name string, _ time.Time) string { return strings.ToUpper(name) }, nil
+	case "prefix":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		return func(name string, _ time.Time) string { return value + name }, nil
+	case "suffix":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		return func(name string, _ time.Time) string { return name + value }, nil
+	case "suffix_keep_extension":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		return func(name string, _ time.Time) string {
+			base, ext := splitExt(name)
+			return base + value + ext
+		}, nil
+	case "trimprefix":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		return func(name string, _ time.Time) string { return strings.TrimPrefix(name, value) }, nil
+	case "trimsuffix":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		return func(name string, _ time.Time) string { return strings.TrimSuffix(name, value) }, nil
+	case "regex":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		i := strings.LastIndex(value, "/")
+		if i < 0 {
+			return nil, errors.New("regex transform needs a value of the form PATTERN/REPLACEMENT")
+		}
+		re, err := regexp.Compile(value[:i])
+		if err != nil {
+			return nil, fmt.Errorf("bad regex transform: %w", err)
+		}
+		replacement := value[i+1:]
+		return func(name string, _ time.Time) string { return re.ReplaceAllString(name, replacement) }, nil
+	case "encoder":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		var enc encoder.MultiEncoder
+		if err := enc.Set(value); err != nil {
+			return nil, fmt.Errorf("bad encoder transform: %w", err)
+		}
+		return func(name string, _ time.Time) string { return enc.Encode(name) }, nil
+	case "date":
+		if err := needValue(); err != nil {
+			return nil, err
+		}
+		return func(name string, modTime time.Time) string {
+			base, ext := splitExt(name)
+			return base + formatDate(value, modTime) + ext
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown transform %q", spec)
+}
+
+// ParseAll parses each of specs into a pipeline of Transforms
+func ParseAll(specs []string) (ts Transforms, err error) {
+	for _, spec := range specs {
+		t, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// Apply the transforms in order to name
+func (ts Transforms) Apply(name string, modTime time.Time) string {
+	for _, t := range ts {
+		name = t(name, modTime)
+	}
+	return name
+}
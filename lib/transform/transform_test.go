@@ -0,0 +1,57 @@
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransforms(t *testing.T) {
+	modTime := time.Date(2024, 6, 3, 4, 5, 6, 0, time.UTC)
+	nfd := "cafe\u0301.txt"
+	nfc := "caf\u00e9.txt"
+	for _, test := range []struct {
+		specs []string
+		in    string
+		want  string
+	}{
+		{[]string{"nfc"}, nfd, nfc},
+		{[]string{"nfd"}, nfc, nfd},
+		{[]string{"nfkc"}, "\ufb01le", "file"},
+		{[]string{"lowercase"}, "File.TXT", "file.txt"},
+		{[]string{"uppercase"}, "File.txt", "FILE.TXT"},
+		{[]string{"prefix=old-"}, "file.txt", "old-file.txt"},
+		{[]string{"suffix=.bak"}, "file.txt", "file.txt.bak"},
+		{[]string{"suffix_keep_extension=-1"}, "file.txt", "file-1.txt"},
+		{[]string{"suffix_keep_extension=-1"}, "file", "file-1"},
+		{[]string{"suffix_keep_extension=-1"}, ".hidden", ".hidden-1"},
+		{[]string{"trimprefix=IMG_"}, "IMG_1234.jpg", "1234.jpg"},
+		{[]string{"trimsuffix=.bak"}, "file.txt.bak", "file.txt"},
+		{[]string{`regex=(\d+)-(\d+)/$2-$1`}, "12-34.txt", "34-12.txt"},
+		{[]string{"regex=a/b/c"}, "a/b", "c"},
+		{[]string{"encoder=Colon,Question"}, "what?: yes", "what？： yes"},
+		{[]string{"date=-{YYYY}{MM}{DD}"}, "report.pdf", "report-20240603.pdf"},
+		{[]string{"date=_{hh}{mm}{ss}"}, "dir", "dir_040506"},
+		{[]string{"nfc", "lowercase", "prefix=x"}, "CAFE\u0301", "xcaf\u00e9"},
+		{nil, "unchanged", "unchanged"},
+	} {
+		ts, err := ParseAll(test.specs)
+		require.NoError(t, err, test.specs)
+		assert.Equal(t, test.want, ts.Apply(test.in, modTime), test.specs)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"potato",
+		"prefix",
+		"regex=nosep",
+		"regex=([/x",
+		"encoder=Potato",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -37,6 +37,9 @@ type Dir struct {
 	virtual map[string]vState // virtual directory entries - may be nil
 	sys     atomic.Value      // user defined info to be attached here
 
+	prefetchLast  string   // protected by mu: leaf of the last file opened for read with --vfs-prefetch files
+	prefetchNames []string // protected by mu: sorted leaves of the files for --vfs-prefetch files - nil if out of date
+
 	modTimeMu sync.Mutex // protects the following
 	modTime   time.Time
 
@@ -83,7 +86,8 @@ func (d *Dir) cacheCleanup() {
 	_, stale := d._age(when)
 	d.mu.Unlock()
 
-	if stale {
+	// Keep the directory while offline as it can't be read again
+	if stale && !d.vfs.isOffline() {
 		d.ForgetAll()
 	}
 }
@@ -246,6 +250,7 @@ func (d *Dir) ForgetAll() (hasVirtual bool) {
 	// directory or any children
 	if !d.hasVirtual() {
 		d.items = make(map[string]Node)
+		d.prefetchNames = nil
 		d.cleanupTimer.Stop()
 	}
 
@@ -441,6 +446,7 @@ func (d *Dir) addObject(node Node) {
 	d.mu.Lock()
 	leaf := node.Name()
 	d.items[leaf] = node
+	d.prefetchNames = nil
 	if d.virtual == nil {
 		d.virtual = make(map[string]vState)
 	}
@@ -489,6 +495,7 @@ func (d *Dir) AddVirtual(leaf string, size int64, isDir bool) {
 func (d *Dir) delObject(leaf string) {
 	d.mu.Lock()
 	delete(d.items, leaf)
+	d.prefetchNames = nil
 	if d.virtual == nil {
 		d.virtual = make(map[string]vState)
 	}
@@ -513,6 +520,13 @@ func (d *Dir) DelVirtual(leaf string) {
 func (d *Dir) _readDir() error {
 	when := time.Now()
 	if age, stale := d._age(when); stale {
+		if d.vfs.isOffline() {
+			// Use what we have until the remote is back online
+			if !d._haveListing() {
+				return errOffline
+			}
+			return nil
+		}
 		if age != 0 {
 			fs.Debugf(d.path, "Re-reading directory (%v old)", age)
 		}
@@ -524,6 +538,10 @@ func (d *Dir) _readDir() error {
 		// We treat directory not found as empty because we
 		// create directories on the fly
 	} else if err != nil {
+		if d.vfs.checkOffline(err) && d._haveListing() {
+			fs.Debugf(d.path, "Using cached directory listing as the remote is offline: %v", err)
+			return nil
+		}
 		return err
 	}
 
@@ -708,6 +726,12 @@ func (mv manageVirtuals) end(d *Dir) {
 	}
 }
 
+// _haveListing returns true if the directory has been read and not
+// forgotten since - must be called with the lock held
+func (d *Dir) _haveListing() bool {
+	return !d.read.IsZero() || len(d.items) != 0
+}
+
 // update d.items and if dirTree is not nil update each dir in the DirTree below this one and
 // set the last read time - must be called with the lock held
 func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree, when time.Time) error {
@@ -718,6 +742,14 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 		if name == "." || name == ".." {
 			continue
 		}
+		if d.vfs.locks != nil && d.vfs.locks.remote != nil && d.path == "" && name == lockDirName {
+			continue // hide the lock records
+		}
+		_, isObject := entry.(fs.Object)
+		isLink := isObject && d.vfs.Opt.Links && strings.HasSuffix(name, LinkSuffix)
+		if isLink {
+			name = strings.TrimSuffix(name, LinkSuffix)
+		}
 		node := d.items[name]
 		if mv.add(d, name) {
 			continue
@@ -725,11 +757,13 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 		switch item := entry.(type) {
 		case fs.Object:
 			obj := item
-			// Reuse old file value if it exists
-			if file, ok := node.(*File); node != nil && ok {
+			// Reuse old file value if it exists and is the same type
+			if file, ok := node.(*File); node != nil && ok && file.isLink == isLink {
 				file.setObjectNoUpdate(obj)
 			} else {
-				node = newFile(d, d.path, obj, name)
+				file := newFile(d, d.path, obj, name)
+				file.isLink = isLink
+				node = file
 			}
 		case fs.Directory:
 			// Reuse old dir value if it exists
@@ -760,17 +794,18 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 		d.items[name] = node
 	}
 	mv.end(d)
+	d.prefetchNames = nil
 	return nil
 }
 
 // readDirTree forces a refresh of the complete directory tree
-func (d *Dir) readDirTree() error {
+func (d *Dir) readDirTree(ctx context.Context) error {
 	d.mu.RLock()
 	f, path := d.f, d.path
 	d.mu.RUnlock()
 	when := time.Now()
 	fs.Debugf(path, "Reading directory tree")
-	dt, err := walk.NewDirTree(context.TODO(), f, path, false, -1)
+	dt, err := walk.NewDirTree(ctx, f, path, false, -1)
 	if err != nil {
 		return err
 	}
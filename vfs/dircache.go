@@ -0,0 +1,442 @@
+// Persistent directory cache
+
+package vfs
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/dirtree"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/lib/encoder"
+	"github.com/rclone/rclone/lib/file"
+)
+
+const (
+	// dirCacheVersion is the version of the on disk format - bump
+	// this if it changes incompatibly
+	dirCacheVersion = 1
+
+	// dirCacheSaveInterval is how often the directory cache is
+	// saved to disk while the VFS is running
+	dirCacheSaveInterval = 5 * time.Minute
+)
+
+// dirCacheEntry is a single directory entry as saved on disk
+type dirCacheEntry struct {
+	Remote  string    `json:"remote"`
+	IsDir   bool      `json:"isDir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// dirCacheFile is the on disk format of the directory cache
+type dirCacheFile struct {
+	Version int                        `json:"version"`
+	Fs      string                     `json:"fs"`
+	Saved   time.Time                  `json:"saved"`
+	Dirs    map[string][]dirCacheEntry `json:"dirs"`
+}
+
+// dirCachePath returns the path of the file the directory cache is
+// saved in
+func (vfs *VFS) dirCachePath() string {
+	name := encoder.OS.FromStandardName(fs.ConfigString(vfs.f))
+	return filepath.Join(config.GetCacheDir(), "vfsDir", name+".json.gz")
+}
+
+// startDirCache loads the directory cache from disk and starts saving
+// it periodically and on exit
+//
+// It returns true if the directory cache was loaded, in which case
+// the caller should revalidate it in the background.
+func (vfs *VFS) startDirCache() (loaded bool) {
+	loaded, err := vfs.loadDirCache()
+	if err != nil {
+		fs.Errorf(vfs.f, "Failed to load VFS directory cache: %v", err)
+	}
+	vfs.dirCacheCtx, vfs.cancelDirCache = context.WithCancel(context.Background())
+	vfs.dirCacheAtExit = atexit.Register(vfs.saveDirCacheLog)
+	go vfs.dirCacheSaver(vfs.dirCacheCtx)
+	return loaded
+}
+
+// stopDirCache stops saving the directory cache and saves it for the
+// last time
+func (vfs *VFS) stopDirCache() {
+	if vfs.cancelDirCache == nil {
+		return
+	}
+	vfs.cancelDirCache()
+	vfs.cancelDirCache = nil
+	atexit.Unregister(vfs.dirCacheAtExit)
+	// Wait for the revalidation so it can't save over the last save
+	if vfs.refreshed != nil {
+		<-vfs.refreshed
+	}
+	vfs.saveDirCacheLog()
+}
+
+// dirCacheSaver saves the directory cache every dirCacheSaveInterval
+// until the context is cancelled
+func (vfs *VFS) dirCacheSaver(ctx context.Context) {
+	ticker := time.NewTicker(dirCacheSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			vfs.saveDirCacheLog()
+		}
+	}
+}
+
+// saveDirCacheLog saves the directory cache logging any errors
+func (vfs *VFS) saveDirCacheLog() {
+	err := vfs.saveDirCache()
+	if err != nil {
+		fs.Errorf(vfs.f, "Failed to save VFS directory cache: %v", err)
+	}
+}
+
+// dirCacheModTime returns the modification time of o to save in the
+// directory cache
+//
+// This is called with directory locks held so it mustn't talk to the
+// remote. If the backend needs an extra transaction to read the
+// modification time and it isn't already known then the zero time is
+// returned and it is read when needed after the cache is loaded.
+func dirCacheModTime(ctx context.Context, o fs.Object, slowModTime bool) time.Time {
+	if slowModTime {
+		if co, ok := o.(*cachedObject); ok {
+			return co.savedModTime()
+		}
+		return time.Time{}
+	}
+	return o.ModTime(ctx)
+}
+
+// saveDirCache saves the directories which have been read to disk
+//
+// Files which haven't been uploaded yet are not saved.
+func (vfs *VFS) saveDirCache() (err error) {
+	vfs.dirCacheMu.Lock()
+	defer vfs.dirCacheMu.Unlock()
+	ctx := context.Background()
+	slowModTime := vfs.f.Features().SlowModTime
+	data := dirCacheFile{
+		Version: dirCacheVersion,
+		Fs:      fs.ConfigString(vfs.f),
+		Saved:   time.Now(),
+		Dirs:    make(map[string][]dirCacheEntry),
+	}
+	files := 0
+	vfs.root.walk(func(d *Dir) {
+		if d.read.IsZero() {
+			return
+		}
+		entries := make([]dirCacheEntry, 0, len(d.items))
+		for _, node := range d.items {
+			switch x := node.(type) {
+			case *File:
+				o := x.getObject()
+				if o == nil {
+					continue
+				}
+				entries = append(entries, dirCacheEntry{
+					Remote:  o.Remote(),
+					Size:    o.Size(),
+					ModTime: dirCacheModTime(ctx, o, slowModTime),
+				})
+				files++
+			case *Dir:
+				entries = append(entries, dirCacheEntry{
+					Remote:  x.Path(),
+					IsDir:   true,
+					ModTime: x.ModTime(),
+				})
+			}
+		}
+		data.Dirs[d.path] = entries
+	})
+
+	cachePath := vfs.dirCachePath()
+	err = file.MkdirAll(filepath.Dir(cachePath), 0700)
+	if err != nil {
+		return fmt.Errorf("failed to make directory cache directory: %w", err)
+	}
+	tmpPath := cachePath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create directory cache: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	err = json.NewEncoder(zw).Encode(&data)
+	if err == nil {
+		err = zw.Close()
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write directory cache: %w", err)
+	}
+	err = os.Rename(tmpPath, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to rename directory cache: %w", err)
+	}
+	fs.Debugf(vfs.f, "Saved VFS directory cache with %d directories and %d files to %q", len(data.Dirs), files, cachePath)
+	return nil
+}
+
+// loadDirCache loads the directory cache from disk if it exists
+//
+// The directories loaded are marked as freshly read so they are
+// served straight away while they are read again from the remote in
+// the background. It returns true if anything was loaded.
+func (vfs *VFS) loadDirCache() (loaded bool, err error) {
+	cachePath := vfs.dirCachePath()
+	in, err := os.Open(cachePath)
+	if os.IsNotExist(err) {
+		fs.Debugf(vfs.f, "No VFS directory cache found at %q", cachePath)
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer fs.CheckClose(in, &err)
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory cache: %w", err)
+	}
+	var data dirCacheFile
+	err = json.NewDecoder(zr).Decode(&data)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode directory cache: %w", err)
+	}
+	if data.Version != dirCacheVersion || data.Fs != fs.ConfigString(vfs.f) {
+		fs.Debugf(vfs.f, "Ignoring VFS directory cache with version %d for %q", data.Version, data.Fs)
+		return false, nil
+	}
+
+	files := 0
+	dt := dirtree.New()
+	for dirPath, entries := range data.Dirs {
+		dirEntries := make(fs.DirEntries, 0, len(entries))
+		for _, entry := range entries {
+			if entry.IsDir {
+				dirEntries = append(dirEntries, fs.NewDir(entry.Remote, entry.ModTime))
+			} else {
+				dirEntries = append(dirEntries, &cachedObject{
+					f:       vfs.f,
+					remote:  entry.Remote,
+					size:    entry.Size,
+					modTime: entry.ModTime,
+				})
+				files++
+			}
+		}
+		dt[dirPath] = dirEntries
+	}
+
+	when := time.Now()
+	root := vfs.root
+	root.mu.Lock()
+	err = root._readDirFromDirTree(dt, when)
+	root.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	// Only the directories which were saved have been read
+	root.walk(func(d *Dir) {
+		if _, found := dt[d.path]; found {
+			d.read = when
+			d.cleanupTimer.Reset(time.Duration(vfs.Opt.DirCacheTime * 2))
+		} else {
+			d.read = time.Time{}
+		}
+	})
+	fs.Infof(vfs.f, "Loaded VFS directory cache with %d directories and %d files saved at %v", len(data.Dirs), files, data.Saved)
+	return true, nil
+}
+
+// cachedObject is an fs.Object loaded from the persistent directory
+// cache.
+//
+// It returns the saved size and modification time and finds the
+// object on the remote when anything else is needed, or if the
+// modification time wasn't saved.
+type cachedObject struct {
+	f       fs.Fs
+	remote  string
+	size    int64
+	modTime time.Time
+
+	mu sync.Mutex
+	o  fs.Object // the object on the remote once found
+}
+
+// resolve finds the object on the remote
+func (o *cachedObject) resolve(ctx context.Context) (fs.Object, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.o == nil {
+		obj, err := o.f.NewObject(ctx, o.remote)
+		if err != nil {
+			return nil, err
+		}
+		o.o = obj
+	}
+	return o.o, nil
+}
+
+// String returns a description of the Object
+func (o *cachedObject) String() string {
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *cachedObject) Remote() string {
+	return o.remote
+}
+
+// savedModTime returns the modification time saved in the directory
+// cache without talking to the remote
+func (o *cachedObject) savedModTime() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.modTime
+}
+
+// ModTime returns the modification date of the object
+func (o *cachedObject) ModTime(ctx context.Context) time.Time {
+	o.mu.Lock()
+	obj, modTime := o.o, o.modTime
+	o.mu.Unlock()
+	if obj != nil {
+		return obj.ModTime(ctx)
+	}
+	if !modTime.IsZero() {
+		return modTime
+	}
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		fs.Debugf(o, "Failed to read modification time: %v", err)
+		return modTime
+	}
+	modTime = obj.ModTime(ctx)
+	o.mu.Lock()
+	o.modTime = modTime
+	o.mu.Unlock()
+	return modTime
+}
+
+// Size returns the size of the object
+func (o *cachedObject) Size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.o != nil {
+		return o.o.Size()
+	}
+	return o.size
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *cachedObject) Fs() fs.Info {
+	return o.f
+}
+
+// Storable says whether this object can be stored
+func (o *cachedObject) Storable() bool {
+	return true
+}
+
+// Hash returns the selected checksum of the object
+func (o *cachedObject) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return obj.Hash(ctx, ht)
+}
+
+// SetModTime sets the metadata on the object to set the modification date
+func (o *cachedObject) SetModTime(ctx context.Context, t time.Time) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	err = obj.SetModTime(ctx, t)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.modTime = t
+	o.mu.Unlock()
+	return nil
+}
+
+// Open opens the file for read
+func (o *cachedObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Open(ctx, options...)
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *cachedObject) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	err = obj.Update(ctx, in, src, options...)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.modTime = src.ModTime(ctx)
+	o.mu.Unlock()
+	return nil
+}
+
+// Remove this object
+func (o *cachedObject) Remove(ctx context.Context) error {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return obj.Remove(ctx)
+}
+
+// Metadata returns metadata for the object
+func (o *cachedObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	obj, err := o.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fs.GetMetadata(ctx, obj)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Object     = (*cachedObject)(nil)
+	_ fs.Metadataer = (*cachedObject)(nil)
+)
@@ -0,0 +1,117 @@
+package vfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirCachePersist(t *testing.T) {
+	ctx := context.Background()
+	oldCacheDir := config.GetCacheDir()
+	require.NoError(t, config.SetCacheDir(t.TempDir()))
+	defer func() {
+		require.NoError(t, config.SetCacheDir(oldCacheDir))
+	}()
+
+	opt := vfscommon.Opt
+	opt.DirCachePersist = true
+	r, vfs := newTestVFSOpt(t, &opt)
+
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	file2 := r.WriteObject(ctx, "dir/sub/file2", "file2 contents is longer", t2)
+	r.CheckRemoteItems(t, file1, file2)
+
+	// Read the directories which get saved
+	_, err := vfs.Stat("dir/sub/file2")
+	require.NoError(t, err)
+	vfs.Shutdown()
+	_, err = os.Stat(vfs.dirCachePath())
+	require.NoError(t, err)
+
+	// Change the remote behind the VFS's back
+	r.WriteObject(ctx, "dir/file3", "file3 contents", t3)
+
+	// The new VFS serves the saved listing while it is being
+	// revalidated in the background
+	f := &blockListFs{Fs: r.Fremote, unblock: make(chan struct{})}
+	vfs2 := New(f, &opt)
+	defer cleanupVFS(t, vfs2)
+	root, err := vfs2.Root()
+	require.NoError(t, err)
+	assert.False(t, root.read.IsZero())
+	node, err := vfs2.Stat("dir")
+	require.NoError(t, err)
+	nodes, err := node.(*Dir).ReadDirAll()
+	require.NoError(t, err)
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name())
+	}
+	assert.Equal(t, []string{"file1", "sub"}, names)
+	_, err = vfs2.Stat("dir/file3")
+	assert.Equal(t, ENOENT, err)
+
+	node, err = vfs2.Stat("dir/sub/file2")
+	require.NoError(t, err)
+	assert.Equal(t, file2.Size, node.Size())
+	fstest.AssertTimeEqualWithPrecision(t, "file2", file2.ModTime, node.ModTime(), r.Fremote.Precision())
+	_, ok := node.(*File).getObject().(*cachedObject)
+	assert.True(t, ok)
+
+	// Objects are found on the remote when needed
+	fd, err := node.(*File).Open(os.O_RDONLY)
+	require.NoError(t, err)
+	contents, err := io.ReadAll(fd)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	assert.Equal(t, "file2 contents is longer", string(contents))
+
+	// Once revalidated the new file is found
+	close(f.unblock)
+	<-vfs2.refreshed
+	_, err = vfs2.Stat("dir/file3")
+	require.NoError(t, err)
+
+	require.NoError(t, vfs2.Rename("dir/file1", "dir/file4"))
+	file1.Path = "dir/file4"
+	r.CheckRemoteListing(t, []fstest.Item{file1, file2, fstest.NewItem("dir/file3", "file3 contents", t3)}, []string{"dir", "dir/sub"})
+}
+
+// blockListFs is an fs.Fs whose List blocks until unblock is closed
+type blockListFs struct {
+	fs.Fs
+	unblock chan struct{}
+}
+
+// List the objects and directories in dir once unblocked
+func (f *blockListFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	<-f.unblock
+	return f.Fs.List(ctx, dir)
+}
+
+func TestDirCacheModTime(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteObject(ctx, "file1", "file1 contents", t1)
+	o, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+
+	// Mod times aren't read from slow backends
+	assert.True(t, dirCacheModTime(ctx, o, true).IsZero())
+	fstest.AssertTimeEqualWithPrecision(t, "file1", file1.ModTime, dirCacheModTime(ctx, o, false), r.Fremote.Precision())
+
+	// and are read from the remote when needed after loading
+	co := &cachedObject{f: r.Fremote, remote: "file1", size: file1.Size}
+	assert.True(t, dirCacheModTime(ctx, co, true).IsZero())
+	fstest.AssertTimeEqualWithPrecision(t, "file1", file1.ModTime, co.ModTime(ctx), r.Fremote.Precision())
+	fstest.AssertTimeEqualWithPrecision(t, "file1", file1.ModTime, dirCacheModTime(ctx, co, true), r.Fremote.Precision())
+}
@@ -53,6 +53,10 @@ type File struct {
 	sys              atomic.Value                    // user defined info to be attached here
 	nwriters         atomic.Int32                    // len(writers)
 	appendMode       bool                            // file was opened with O_APPEND
+	isLink           bool                            // file is a symlink stored in a .rclonelink object - read only
+	metadata         fs.Metadata                     // metadata cached for the extended attributes
+	metadataObj      fs.Object                       // the object metadata was read from
+	metadataTime     time.Time                       // when metadata was read
 }
 
 // newFile creates a new File
@@ -94,6 +98,9 @@ func (f *File) IsDir() bool {
 func (f *File) Mode() (mode os.FileMode) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
+	if f.isLink {
+		return os.ModeSymlink | 0777
+	}
 	mode = os.FileMode(f.d.vfs.Opt.FilePerms)
 	if f.appendMode {
 		mode |= os.ModeAppend
@@ -183,6 +190,10 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 	oldPath := f.Path()
 	// File.mu is unlocked here to call Dir.Path()
 	newPath := path.Join(destDir.Path(), newName)
+	newRemote := newPath
+	if f.isLink {
+		newRemote += LinkSuffix
+	}
 
 	renameCall := func(ctx context.Context) (err error) {
 		// chain rename calls if any
@@ -200,13 +211,24 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 		var newObject fs.Object
 		// if o is nil then are writing the file so no need to rename the object
 		if o != nil {
-			if o.Remote() == newPath {
+			if o.Remote() == newRemote {
 				return nil // no need to rename
 			}
 
+			// find the real object if it was loaded from the
+			// persistent directory cache so it can be moved
+			// server-side
+			if co, ok := o.(*cachedObject); ok {
+				o, err = co.resolve(ctx)
+				if err != nil {
+					fs.Errorf(f.Path(), "File.Rename error: %v", err)
+					return err
+				}
+			}
+
 			// do the move of the remote object
-			dstOverwritten, _ := d.Fs().NewObject(ctx, newPath)
-			newObject, err = operations.Move(ctx, d.Fs(), dstOverwritten, newPath, o)
+			dstOverwritten, _ := d.Fs().NewObject(ctx, newRemote)
+			newObject, err = operations.Move(ctx, d.Fs(), dstOverwritten, newRemote, o)
 			if err != nil {
 				fs.Errorf(f.Path(), "File.Rename error: %v", err)
 				return err
@@ -246,6 +268,11 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 	writing := f._writingInProgress()
 	f.mu.Unlock()
 
+	// Move any advisory locks to the new name
+	if d.vfs.locks != nil {
+		d.vfs.locks.rename(ctx, oldPath, newPath)
+	}
+
 	// Delay the rename if not using RW caching. For the minimal case we
 	// need to look in the cache to see if caching is in use.
 	CacheMode := d.vfs.Opt.CacheMode
@@ -733,6 +760,11 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 		write = true
 	}
 
+	// Symlinks can only be read, which reads their target
+	if write && f.isLink {
+		return nil, EPERM
+	}
+
 	// Open the correct sort of handle
 	f.mu.RLock()
 	d := f.d
@@ -770,6 +802,10 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 		// called without File.mu held
 		d.addObject(f)
 	}
+	// prefetch the following files if reading in order
+	if err == nil && read && !write {
+		d.prefetchAfter(f)
+	}
 	return fd, err
 }
 
@@ -0,0 +1,498 @@
+// Advisory file locking
+
+package vfs
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// LockType is the type of an advisory file lock
+type LockType byte
+
+// Types of lock
+const (
+	LockUnlock LockType = iota // no lock - used to release locks
+	LockRead                   // shared lock
+	LockWrite                  // exclusive lock
+)
+
+// String turns a LockType into a string
+func (t LockType) String() string {
+	switch t {
+	case LockUnlock:
+		return "unlock"
+	case LockRead:
+		return "read"
+	case LockWrite:
+		return "write"
+	}
+	return "unknown"
+}
+
+// LockEOF is the End of a lock which extends to the end of the file
+// however large it grows
+const LockEOF = math.MaxInt64
+
+// lockPollInterval is how often a waiting lock is retried when locks
+// are stored on the remote, as other mounts can't wake it up
+const lockPollInterval = time.Second
+
+// FileLock describes an advisory lock on a byte range of a file
+type FileLock struct {
+	Start int64    `json:"start"` // first byte locked
+	End   int64    `json:"end"`   // last byte locked inclusive - LockEOF for the end of the file
+	Type  LockType `json:"type"`  // type of the lock
+	Owner uint64   `json:"owner"` // identifies the holder of the lock
+	PID   int32    `json:"pid"`   // process holding the lock - for information only
+}
+
+// overlaps returns true if the byte ranges of lk and other overlap
+func (lk FileLock) overlaps(other FileLock) bool {
+	return lk.Start <= other.End && other.Start <= lk.End
+}
+
+// blocks returns true if lk and other can't both be held by
+// different owners
+func (lk FileLock) blocks(other FileLock) bool {
+	return lk.overlaps(other) && (lk.Type == LockWrite || other.Type == LockWrite)
+}
+
+// conflicts returns true if other is held by a different owner and
+// stops lk being taken
+func (lk FileLock) conflicts(other FileLock) bool {
+	return lk.Owner != other.Owner && lk.blocks(other)
+}
+
+// locker holds the advisory locks taken through the VFS
+//
+// mu is only held while the locks in memory are read or changed, never
+// while talking to the remote, so a slow remote doesn't hold up locks
+// on other files.
+type locker struct {
+	mu         sync.Mutex
+	locks      map[string][]FileLock // locks held keyed by path
+	publishers map[string]*publisher // paths being stored on the remote
+	changed    chan struct{}         // closed when the locks change to wake up waiters
+	remote     *remoteLocks          // set if locks are stored on the remote
+	cancel     context.CancelFunc    // stops the remote lock refresher
+	wg         sync.WaitGroup        // running refresher
+}
+
+// publisher makes sure the locks on a path are stored on the remote
+// one at a time, so the last record stored is always the latest
+type publisher struct {
+	mu    sync.Mutex // held while storing the locks on the remote
+	users int        // protected by locker.mu: number of stores waiting or in progress
+}
+
+// newLocker makes a locker for the VFS, storing the locks on the
+// remote if --vfs-locks remote is set
+func newLocker(vfs *VFS) *locker {
+	l := &locker{
+		locks:      make(map[string][]FileLock),
+		publishers: make(map[string]*publisher),
+		changed:    make(chan struct{}),
+	}
+	if vfs.Opt.Locks == vfscommon.LocksRemote {
+		l.remote = newRemoteLocks(vfs.f)
+		ctx, cancel := context.WithCancel(context.Background())
+		l.cancel = cancel
+		l.wg.Add(1)
+		go l.refresher(ctx)
+	}
+	return l
+}
+
+// _conflict returns the first lock held in this VFS which stops lk
+// being taken on path
+//
+// call with mu held
+func (l *locker) _conflict(path string, lk FileLock) (FileLock, bool) {
+	for _, held := range l.locks[path] {
+		if lk.conflicts(held) {
+			return held, true
+		}
+	}
+	return FileLock{}, false
+}
+
+// conflict returns the first lock held in this VFS which stops lk
+// being taken on path
+func (l *locker) conflict(path string, lk FileLock) (FileLock, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l._conflict(path, lk)
+}
+
+// _replace sets the locks of lk.Owner on the range of lk to lk,
+// splitting any locks of the owner which partly overlap it. If lk is
+// LockUnlock then the range is released.
+//
+// call with mu held
+func (l *locker) _replace(path string, lk FileLock) {
+	var locks []FileLock
+	for _, held := range l.locks[path] {
+		if held.Owner != lk.Owner || !held.overlaps(lk) {
+			locks = append(locks, held)
+			continue
+		}
+		if held.Start < lk.Start {
+			before := held
+			before.End = lk.Start - 1
+			locks = append(locks, before)
+		}
+		if held.End > lk.End {
+			after := held
+			after.Start = lk.End + 1
+			locks = append(locks, after)
+		}
+	}
+	if lk.Type != LockUnlock {
+		locks = append(locks, lk)
+	}
+	l._setLocks(path, locks)
+}
+
+// _restore puts back the locks of lk.Owner on the range of lk to what
+// they were in old, leaving the other locks alone
+//
+// call with mu held
+func (l *locker) _restore(path string, lk FileLock, old []FileLock) {
+	l._replace(path, FileLock{Start: lk.Start, End: lk.End, Type: LockUnlock, Owner: lk.Owner})
+	for _, held := range old {
+		if held.Owner != lk.Owner || !held.overlaps(lk) {
+			continue
+		}
+		held.Start = max(held.Start, lk.Start)
+		held.End = min(held.End, lk.End)
+		l.locks[path] = append(l.locks[path], held)
+	}
+}
+
+// _setLocks sets the locks on path
+//
+// call with mu held
+func (l *locker) _setLocks(path string, locks []FileLock) {
+	if len(locks) == 0 {
+		delete(l.locks, path)
+	} else {
+		l.locks[path] = locks
+	}
+}
+
+// _changed wakes up anything waiting for a lock
+//
+// call with mu held
+func (l *locker) _changed() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// publish stores the current locks on path on the remote if required.
+//
+// call without mu held
+func (l *locker) publish(ctx context.Context, path string) error {
+	if l.remote == nil {
+		return nil
+	}
+	l.mu.Lock()
+	p := l.publishers[path]
+	if p == nil {
+		p = &publisher{}
+		l.publishers[path] = p
+	}
+	p.users++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		p.users--
+		if p.users == 0 {
+			delete(l.publishers, path)
+		}
+		l.mu.Unlock()
+	}()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Read the locks once it is our turn so they are the latest
+	l.mu.Lock()
+	locks := append([]FileLock(nil), l.locks[path]...)
+	l.mu.Unlock()
+	return l.remote.publish(ctx, path, locks)
+}
+
+// trySet takes, changes or releases lk on path without waiting
+func (l *locker) trySet(ctx context.Context, path string, lk FileLock) error {
+	if lk.Type == LockUnlock {
+		l.mu.Lock()
+		l._replace(path, lk)
+		l._changed()
+		l.mu.Unlock()
+		if err := l.publish(ctx, path); err != nil {
+			// The refresher will correct the remote later
+			fs.Errorf(path, "vfs locks: failed to release lock on remote: %v", err)
+		}
+		return nil
+	}
+	if _, found := l.conflict(path, lk); found {
+		return EAGAIN
+	}
+	if l.remote != nil {
+		_, found, err := l.remote.conflict(ctx, path, lk)
+		if err != nil {
+			return err
+		}
+		if found {
+			return EAGAIN
+		}
+	}
+	// Check the local locks again as they may have changed while
+	// the remote was being checked
+	l.mu.Lock()
+	if _, found := l._conflict(path, lk); found {
+		l.mu.Unlock()
+		return EAGAIN
+	}
+	old := append([]FileLock(nil), l.locks[path]...)
+	l._replace(path, lk)
+	if l.remote == nil {
+		l._changed()
+		l.mu.Unlock()
+		return nil
+	}
+	l.mu.Unlock()
+
+	err := l.publish(ctx, path)
+	if err == nil {
+		// Check again in case another mount took a
+		// conflicting lock at the same time - if so both
+		// back off and try again.
+		var found bool
+		_, found, err = l.remote.conflict(ctx, path, lk)
+		if err == nil && found {
+			err = EAGAIN
+		}
+	}
+	l.mu.Lock()
+	if err != nil {
+		l._restore(path, lk, old)
+	}
+	l._changed()
+	l.mu.Unlock()
+	if err != nil {
+		if pubErr := l.publish(ctx, path); pubErr != nil {
+			fs.Errorf(path, "vfs locks: failed to restore locks on remote: %v", pubErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// set takes, changes or releases lk on path.
+//
+// If the lock conflicts with one held by another owner then it
+// returns EAGAIN, unless wait is set in which case it waits until the
+// lock can be taken or ctx is cancelled.
+func (l *locker) set(ctx context.Context, path string, lk FileLock, wait bool) error {
+	for {
+		l.mu.Lock()
+		changed := l.changed
+		l.mu.Unlock()
+		err := l.trySet(ctx, path, lk)
+		if err != EAGAIN || !wait {
+			return err
+		}
+		// Locks released by other mounts can only be found
+		// by polling. Add some jitter so mounts which backed
+		// off together don't collide again.
+		var poll <-chan time.Time
+		if l.remote != nil {
+			poll = time.After(lockPollInterval/2 + time.Duration(rand.Int63n(int64(lockPollInterval))))
+		}
+		select {
+		case <-changed:
+		case <-poll:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// get returns the first lock which stops lk being taken on path, or a
+// lock with Type LockUnlock if lk could be taken.
+func (l *locker) get(ctx context.Context, path string, lk FileLock) (FileLock, error) {
+	if held, found := l.conflict(path, lk); found {
+		return held, nil
+	}
+	if l.remote != nil {
+		held, found, err := l.remote.conflict(ctx, path, lk)
+		if err != nil {
+			return FileLock{}, err
+		}
+		if found {
+			return held, nil
+		}
+	}
+	return FileLock{Start: lk.Start, End: lk.End, Type: LockUnlock}, nil
+}
+
+// release releases all the locks held by owner on path
+func (l *locker) release(ctx context.Context, path string, owner uint64) {
+	l.mu.Lock()
+	var locks []FileLock
+	for _, held := range l.locks[path] {
+		if held.Owner != owner {
+			locks = append(locks, held)
+		}
+	}
+	if len(locks) == len(l.locks[path]) {
+		l.mu.Unlock()
+		return
+	}
+	l._setLocks(path, locks)
+	l._changed()
+	l.mu.Unlock()
+	if err := l.publish(ctx, path); err != nil {
+		fs.Errorf(path, "vfs locks: failed to release locks on remote: %v", err)
+	}
+}
+
+// rename moves the locks on oldPath to newPath
+func (l *locker) rename(ctx context.Context, oldPath, newPath string) {
+	l.mu.Lock()
+	locks, found := l.locks[oldPath]
+	if !found {
+		l.mu.Unlock()
+		return
+	}
+	delete(l.locks, oldPath)
+	l.locks[newPath] = append(l.locks[newPath], locks...)
+	l._changed()
+	l.mu.Unlock()
+	if err := l.publish(ctx, oldPath); err != nil {
+		fs.Errorf(oldPath, "vfs locks: failed to remove locks from remote: %v", err)
+	}
+	if err := l.publish(ctx, newPath); err != nil {
+		fs.Errorf(newPath, "vfs locks: failed to store locks on remote: %v", err)
+	}
+}
+
+// paths returns the paths which have locks
+func (l *locker) paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	paths := make([]string, 0, len(l.locks))
+	for path := range l.locks {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// refresher renews the leases on the locks stored on the remote until
+// ctx is cancelled
+func (l *locker) refresher(ctx context.Context) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(remoteLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, path := range l.paths() {
+			if err := l.publish(ctx, path); err != nil {
+				fs.Errorf(path, "vfs locks: failed to refresh locks on remote: %v", err)
+			}
+		}
+	}
+}
+
+// shutdown releases all the locks
+func (l *locker) shutdown() {
+	if l.cancel != nil {
+		l.cancel()
+		l.wg.Wait()
+	}
+	paths := l.paths()
+	l.mu.Lock()
+	for _, path := range paths {
+		delete(l.locks, path)
+	}
+	l._changed()
+	l.mu.Unlock()
+	for _, path := range paths {
+		if err := l.publish(context.Background(), path); err != nil {
+			fs.Errorf(path, "vfs locks: failed to remove locks from remote: %v", err)
+		}
+	}
+}
+
+// fileLocker returns the locker for f or an error if locks aren't
+// supported
+func (f *File) fileLocker() (*locker, error) {
+	l := f.VFS().locks
+	if l == nil {
+		return nil, ENOSYS
+	}
+	return l, nil
+}
+
+// checkLock checks the range of lk is valid
+func checkLock(lk FileLock) error {
+	if lk.Start < 0 || lk.End < lk.Start {
+		return EINVAL
+	}
+	return nil
+}
+
+// SetLock takes, changes or releases (if lk.Type is LockUnlock) the
+// advisory lock lk on the file.
+//
+// If the lock conflicts with one held by another owner then it
+// returns EAGAIN, unless wait is set in which case it waits until the
+// lock can be taken or ctx is cancelled.
+//
+// Locks are only supported if --vfs-locks is set, otherwise it
+// returns ENOSYS.
+func (f *File) SetLock(ctx context.Context, lk FileLock, wait bool) error {
+	l, err := f.fileLocker()
+	if err != nil {
+		return err
+	}
+	if err = checkLock(lk); err != nil {
+		return err
+	}
+	return l.set(ctx, f.Path(), lk, wait)
+}
+
+// GetLock returns the first lock which would stop lk being taken on
+// the file, or a lock with Type LockUnlock if it could be taken.
+func (f *File) GetLock(ctx context.Context, lk FileLock) (FileLock, error) {
+	l, err := f.fileLocker()
+	if err != nil {
+		return FileLock{}, err
+	}
+	if err = checkLock(lk); err != nil {
+		return FileLock{}, err
+	}
+	return l.get(ctx, f.Path(), lk)
+}
+
+// ReleaseLocks releases all the advisory locks held by owner on the
+// file
+func (f *File) ReleaseLocks(ctx context.Context, owner uint64) error {
+	l, err := f.fileLocker()
+	if err != nil {
+		return err
+	}
+	l.release(ctx, f.Path(), owner)
+	return nil
+}
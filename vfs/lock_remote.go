@@ -0,0 +1,149 @@
+// Advisory file locks stored on the remote
+
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/random"
+)
+
+const (
+	// lockDirName is the directory in the root of the remote the
+	// lock records are stored in
+	lockDirName = ".rclone-locks"
+
+	// remoteLockTTL is how long a lock record is valid for
+	// without being refreshed, so the locks of mounts which
+	// went away without cleaning up expire
+	remoteLockTTL = time.Minute
+
+	// lockRecordSuffix is the suffix of the lock records
+	lockRecordSuffix = ".json"
+)
+
+// lockRecord is the lock record stored on the remote by each mount
+// holding locks on a file
+type lockRecord struct {
+	Mount   string     `json:"mount"`   // ID of the mount holding the locks
+	Host    string     `json:"host"`    // host the mount is running on - for information only
+	Expires time.Time  `json:"expires"` // when the record is no longer valid
+	Locks   []FileLock `json:"locks"`   // locks held by the mount
+}
+
+// remoteLocks stores the locks of this mount on the remote and reads
+// the locks of other mounts
+//
+// The records of a file are stored in a directory named after the
+// file within lockDirName, one per mount, eg
+// ".rclone-locks/dir/file.txt/<mount id>.json".
+type remoteLocks struct {
+	f       fs.Fs
+	mountID string
+	host    string
+}
+
+// newRemoteLocks makes a new remoteLocks with a random mount ID
+func newRemoteLocks(f fs.Fs) *remoteLocks {
+	host, _ := os.Hostname()
+	return &remoteLocks{
+		f:       f,
+		mountID: strings.ToLower(random.String(16)),
+		host:    host,
+	}
+}
+
+// dir returns the directory the lock records of filePath are stored in
+func (r *remoteLocks) dir(filePath string) string {
+	return path.Join(lockDirName, filePath)
+}
+
+// publish stores locks as the lock record of this mount for filePath,
+// removing the record if there are no locks
+func (r *remoteLocks) publish(ctx context.Context, filePath string, locks []FileLock) error {
+	remote := path.Join(r.dir(filePath), r.mountID+lockRecordSuffix)
+	if len(locks) == 0 {
+		o, err := r.f.NewObject(ctx, remote)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return o.Remove(ctx)
+	}
+	data, err := json.Marshal(lockRecord{
+		Mount:   r.mountID,
+		Host:    r.host,
+		Expires: time.Now().Add(remoteLockTTL),
+		Locks:   locks,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = operations.RcatSize(ctx, r.f, remote, io.NopCloser(bytes.NewReader(data)), int64(len(data)), time.Now(), nil)
+	return err
+}
+
+// read reads the lock record in o
+func (r *remoteLocks) read(ctx context.Context, o fs.Object) (record lockRecord, err error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return record, err
+	}
+	defer fs.CheckClose(in, &err)
+	err = json.NewDecoder(in).Decode(&record)
+	if err != nil {
+		return record, fmt.Errorf("failed to decode lock record: %w", err)
+	}
+	return record, nil
+}
+
+// conflict returns the first lock held by another mount which stops
+// lk being taken on filePath
+func (r *remoteLocks) conflict(ctx context.Context, filePath string, lk FileLock) (held FileLock, found bool, err error) {
+	entries, err := r.f.List(ctx, r.dir(filePath))
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		return held, false, nil
+	} else if err != nil {
+		return held, false, fmt.Errorf("failed to list lock records: %w", err)
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		o, ok := entry.(fs.Object)
+		if !ok {
+			continue
+		}
+		leaf := path.Base(o.Remote())
+		if !strings.HasSuffix(leaf, lockRecordSuffix) || leaf == r.mountID+lockRecordSuffix {
+			continue
+		}
+		record, err := r.read(ctx, o)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			continue // removed since listed
+		} else if err != nil {
+			return held, false, fmt.Errorf("failed to read lock record %q: %w", o.Remote(), err)
+		}
+		if now.After(record.Expires) {
+			fs.Debugf(filePath, "vfs locks: ignoring expired locks of mount %q on %q", record.Mount, record.Host)
+			continue
+		}
+		for _, other := range record.Locks {
+			if lk.blocks(other) {
+				other.PID = -1 // held by a process on another mount
+				return other, true, nil
+			}
+		}
+	}
+	return held, false, nil
+}
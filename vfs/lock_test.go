@@ -0,0 +1,233 @@
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLockFile makes a VFS with locks set to mode and returns the
+// file "file" in it
+func newTestLockFile(t *testing.T, mode vfscommon.Locks) (r *fstest.Run, vfs *VFS, file *File) {
+	opt := vfscommon.Opt
+	opt.Locks = mode
+	r, vfs = newTestVFSOpt(t, &opt)
+	r.WriteObject(context.Background(), "file", "file contents", t1)
+	node, err := vfs.Stat("file")
+	require.NoError(t, err)
+	return r, vfs, node.(*File)
+}
+
+func TestLocksOff(t *testing.T) {
+	ctx := context.Background()
+	_, _, file := newTestLockFile(t, vfscommon.LocksOff)
+
+	err := file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 1}, false)
+	assert.Equal(t, ENOSYS, err)
+	_, err = file.GetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 1})
+	assert.Equal(t, ENOSYS, err)
+	assert.Equal(t, ENOSYS, file.ReleaseLocks(ctx, 1))
+}
+
+func TestLockLocal(t *testing.T) {
+	ctx := context.Background()
+	_, _, file := newTestLockFile(t, vfscommon.LocksLocal)
+
+	// Invalid ranges
+	assert.Equal(t, EINVAL, file.SetLock(ctx, FileLock{Start: 10, End: 9, Type: LockRead, Owner: 1}, false))
+	assert.Equal(t, EINVAL, file.SetLock(ctx, FileLock{Start: -1, End: 9, Type: LockRead, Owner: 1}, false))
+
+	// Owner 1 locks the whole file
+	whole := FileLock{End: LockEOF, Type: LockWrite, Owner: 1, PID: 100}
+	require.NoError(t, file.SetLock(ctx, whole, false))
+
+	// Owner 1 can change its own lock, owner 2 can't lock
+	require.NoError(t, file.SetLock(ctx, whole, false))
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{Start: 10, End: 19, Type: LockRead, Owner: 2}, false))
+	held, err := file.GetLock(ctx, FileLock{Start: 10, End: 19, Type: LockRead, Owner: 2})
+	require.NoError(t, err)
+	assert.Equal(t, whole, held)
+
+	// Owner 1 downgrades the start of the file to a read lock so
+	// owner 2 can share it but not write lock it
+	require.NoError(t, file.SetLock(ctx, FileLock{End: 99, Type: LockRead, Owner: 1}, false))
+	require.NoError(t, file.SetLock(ctx, FileLock{Start: 10, End: 19, Type: LockRead, Owner: 2}, false))
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{Start: 10, End: 19, Type: LockWrite, Owner: 2}, false))
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{Start: 100, End: 100, Type: LockRead, Owner: 2}, false))
+	held, err = file.GetLock(ctx, FileLock{Start: 100, End: LockEOF, Type: LockRead, Owner: 2})
+	require.NoError(t, err)
+	assert.Equal(t, FileLock{Start: 100, End: LockEOF, Type: LockWrite, Owner: 1, PID: 100}, held)
+
+	// Owner 1 unlocks part of the file
+	require.NoError(t, file.SetLock(ctx, FileLock{Start: 100, End: 199, Type: LockUnlock, Owner: 1}, false))
+	require.NoError(t, file.SetLock(ctx, FileLock{Start: 100, End: 199, Type: LockWrite, Owner: 2}, false))
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{Start: 200, End: 200, Type: LockRead, Owner: 2}, false))
+
+	// Releasing all of owner 1's locks lets owner 2 lock everything
+	require.NoError(t, file.ReleaseLocks(ctx, 1))
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, false))
+	held, err = file.GetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 2})
+	require.NoError(t, err)
+	assert.Equal(t, LockUnlock, held.Type)
+}
+
+func TestLockWait(t *testing.T) {
+	ctx := context.Background()
+	_, _, file := newTestLockFile(t, vfscommon.LocksLocal)
+
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 1}, false))
+
+	// Waiting is interrupted by the context
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err := file.SetLock(timeoutCtx, FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, true)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Waiting finishes when the lock is released
+	done := make(chan error)
+	go func() {
+		done <- file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, true)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("lock taken while held: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockUnlock, Owner: 1}, false))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for lock")
+	}
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockRead, Owner: 1}, false))
+}
+
+func TestLockRename(t *testing.T) {
+	ctx := context.Background()
+	_, vfs, file := newTestLockFile(t, vfscommon.LocksLocal)
+
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 1}, false))
+	require.NoError(t, vfs.Rename("file", "renamed"))
+
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, false))
+	assert.Nil(t, vfs.locks.locks["file"])
+	assert.Len(t, vfs.locks.locks["renamed"], 1)
+}
+
+func TestLockRemote(t *testing.T) {
+	ctx := context.Background()
+	_, vfs, file := newTestLockFile(t, vfscommon.LocksRemote)
+
+	// Make a locker for a second mount of the same remote
+	other := newLocker(vfs)
+	defer other.shutdown()
+	require.NotEqual(t, vfs.locks.remote.mountID, other.remote.mountID)
+
+	// A lock on one mount stops the other taking it, even for
+	// the same owner
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 1, PID: 100}, false))
+	assert.Equal(t, EAGAIN, other.set(ctx, "file", FileLock{End: 9, Type: LockRead, Owner: 1}, false))
+	held, err := other.get(ctx, "file", FileLock{End: 9, Type: LockRead, Owner: 1})
+	require.NoError(t, err)
+	assert.Equal(t, FileLock{End: LockEOF, Type: LockWrite, Owner: 1, PID: -1}, held)
+
+	// The lock records are hidden from the listing
+	nodes, err := vfs.ReadDir("")
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "file", nodes[0].Name())
+
+	// Read locks can be shared between mounts
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockRead, Owner: 1}, false))
+	require.NoError(t, other.set(ctx, "file", FileLock{End: 9, Type: LockRead, Owner: 1}, false))
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{End: 9, Type: LockWrite, Owner: 1}, false))
+
+	// Releasing the locks lets the other mount take them
+	require.NoError(t, file.ReleaseLocks(ctx, 1))
+	other.release(ctx, "file", 1)
+	require.NoError(t, other.set(ctx, "file", FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, false))
+	assert.Equal(t, EAGAIN, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockRead, Owner: 1}, false))
+
+	// Shutting down removes the records
+	other.shutdown()
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 1}, false))
+	entries, err := vfs.f.List(ctx, other.remote.dir("file"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, vfs.locks.remote.mountID+lockRecordSuffix, path.Base(entries[0].Remote()))
+}
+
+func TestLockRemoteExpired(t *testing.T) {
+	ctx := context.Background()
+	r, vfs, file := newTestLockFile(t, vfscommon.LocksRemote)
+
+	// Write an expired record for another mount
+	data, err := json.Marshal(lockRecord{
+		Mount:   "expired",
+		Expires: time.Now().Add(-time.Second),
+		Locks:   []FileLock{{End: LockEOF, Type: LockWrite, Owner: 1}},
+	})
+	require.NoError(t, err)
+	r.WriteObject(ctx, path.Join(vfs.locks.remote.dir("file"), "expired"+lockRecordSuffix), string(data), t1)
+
+	require.NoError(t, file.SetLock(ctx, FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, false))
+}
+
+// slowListFs is an fs.Fs whose List of dir blocks until unblock is
+// closed
+type slowListFs struct {
+	fs.Fs
+	dir     string
+	unblock chan struct{}
+}
+
+// List the objects and directories in dir
+func (f *slowListFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	if dir == f.dir {
+		<-f.unblock
+	}
+	return f.Fs.List(ctx, dir)
+}
+
+func TestLockRemoteSlow(t *testing.T) {
+	ctx := context.Background()
+	_, vfs, _ := newTestLockFile(t, vfscommon.LocksRemote)
+	l := vfs.locks
+	slow := &slowListFs{Fs: vfs.f, dir: l.remote.dir("slow"), unblock: make(chan struct{})}
+	l.remote.f = slow
+
+	require.NoError(t, l.set(ctx, "file", FileLock{End: LockEOF, Type: LockWrite, Owner: 1}, false))
+
+	// Check the remote for "slow" which blocks
+	done := make(chan error)
+	go func() {
+		done <- l.set(ctx, "slow", FileLock{End: LockEOF, Type: LockWrite, Owner: 1}, false)
+	}()
+
+	// Locks on other files carry on working
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		assert.Equal(t, EAGAIN, l.set(ctx, "file", FileLock{End: LockEOF, Type: LockWrite, Owner: 2}, false))
+		l.release(ctx, "file", 1)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("locks blocked by slow remote")
+	}
+
+	close(slow.unblock)
+	require.NoError(t, <-done)
+	assert.Len(t, l.locks["slow"], 1)
+	assert.Nil(t, l.locks["file"])
+}
@@ -0,0 +1,142 @@
+// Offline mode
+
+package vfs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+const (
+	// offlineProbeInterval is how often the remote is checked to
+	// see if it is back online
+	offlineProbeInterval = 30 * time.Second
+
+	// offlineProbeName is the object looked for to check the
+	// remote is back online - it doesn't need to exist
+	offlineProbeName = ".rclone-offline-probe"
+)
+
+// errOffline is returned for directories which haven't been read
+// while the remote is offline
+var errOffline = errors.New("remote is offline")
+
+// isOfflineError returns true if err shows that the remote couldn't
+// be reached
+//
+// Only connectivity failures count - connections which couldn't be
+// made or were reset, DNS lookups which failed and timeouts. Errors
+// returned by the remote, such as rate limiting or the service being
+// unavailable, don't put the VFS offline.
+func isOfflineError(err error) bool {
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &dnsErr):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
+
+// offlineEnabled returns true if offline mode can be used
+func (vfs *VFS) offlineEnabled() bool {
+	return vfs.Opt.Offline && vfs.Opt.CacheMode >= vfscommon.CacheModeFull
+}
+
+// isOffline returns true if the remote is offline
+func (vfs *VFS) isOffline() bool {
+	return vfs.offline.Load()
+}
+
+// checkOffline takes the error from an operation on the remote and
+// puts the VFS offline if it shows the remote is unreachable.
+//
+// It returns true if the VFS is offline.
+func (vfs *VFS) checkOffline(err error) bool {
+	if err == nil || !vfs.offlineEnabled() || !isOfflineError(err) {
+		return false
+	}
+	vfs.setOffline(true)
+	return true
+}
+
+// setOffline puts the VFS offline or back online.
+//
+// While offline the remote is probed in the background until it can
+// be reached again.
+func (vfs *VFS) setOffline(offline bool) {
+	vfs.offlineMu.Lock()
+	defer vfs.offlineMu.Unlock()
+	if vfs.offline.Load() == offline {
+		return
+	}
+	vfs.offline.Store(offline)
+	if vfs.cache != nil {
+		vfs.cache.SetOffline(offline)
+	}
+	if offline {
+		fs.Logf(vfs.f, "Remote is unreachable - going offline and serving files from the cache")
+		ctx, cancel := context.WithCancel(context.Background())
+		vfs.cancelOffline = cancel
+		go vfs.offlineProber(ctx)
+	} else {
+		fs.Logf(vfs.f, "Remote is reachable - going online and uploading queued files")
+		if vfs.cancelOffline != nil {
+			vfs.cancelOffline()
+			vfs.cancelOffline = nil
+		}
+	}
+}
+
+// stopOffline stops the background probing if it is running
+func (vfs *VFS) stopOffline() {
+	vfs.offlineMu.Lock()
+	defer vfs.offlineMu.Unlock()
+	if vfs.cancelOffline != nil {
+		vfs.cancelOffline()
+		vfs.cancelOffline = nil
+	}
+}
+
+// offlineProber checks the remote every offlineProbeInterval until it
+// can be reached or the context is cancelled
+func (vfs *VFS) offlineProber(ctx context.Context) {
+	ticker := time.NewTicker(offlineProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if vfs.probeRemote(ctx) {
+			vfs.setOffline(false)
+			return
+		}
+	}
+}
+
+// probeRemote returns true if the remote can be reached
+func (vfs *VFS) probeRemote(ctx context.Context) bool {
+	ctx, ci := fs.AddConfig(ctx)
+	ci.LowLevelRetries = 1
+	_, err := vfs.f.NewObject(ctx, offlineProbeName)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil && isOfflineError(err) {
+		fs.Debugf(vfs.f, "Remote still offline: %v", err)
+		return false
+	}
+	return true
+}
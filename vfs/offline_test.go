@@ -0,0 +1,153 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsOfflineError(t *testing.T) {
+	assert.False(t, isOfflineError(errors.New("potato")))
+	assert.False(t, isOfflineError(ENOENT))
+	assert.True(t, isOfflineError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, isOfflineError(&net.DNSError{Err: "no such host", Name: "example.com"}))
+	assert.True(t, isOfflineError(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)))
+	// Errors from the remote which are retried don't count
+	assert.False(t, isOfflineError(fserrors.RetryErrorf("503 Service Unavailable")))
+	assert.False(t, isOfflineError(io.ErrUnexpectedEOF))
+}
+
+// errUnreachable is returned by unreachableObject.Open
+var errUnreachable = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+// unreachableFs is an fs.Fs whose objects can be listed but can't be
+// opened as if the network went down after listing
+type unreachableFs struct {
+	fs.Fs
+}
+
+// List the objects and directories in dir
+func (f *unreachableFs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	entries, err = f.Fs.List(ctx, dir)
+	for i, entry := range entries {
+		if o, ok := entry.(fs.Object); ok {
+			entries[i] = unreachableObject{Object: o}
+		}
+	}
+	return entries, err
+}
+
+// NewObject finds the Object at remote
+func (f *unreachableFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return unreachableObject{Object: o}, nil
+}
+
+// unreachableObject is an fs.Object which can't be opened
+type unreachableObject struct {
+	fs.Object
+}
+
+// Open returns errUnreachable
+func (o unreachableObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	return nil, errUnreachable
+}
+
+func TestOfflineOnRead(t *testing.T) {
+	ctx := context.Background()
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.Offline = true
+	r := fstest.NewRun(t)
+	r.WriteObject(ctx, "file1", "file1 contents", t1)
+	vfs := New(&unreachableFs{Fs: r.Fremote}, &opt)
+	defer cleanupVFS(t, vfs)
+
+	// Reads which fail because the remote can't be reached put
+	// the VFS offline
+	fd, err := vfs.OpenFile("file1", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	_, err = fd.Read(make([]byte, 4))
+	require.Error(t, err)
+	require.NoError(t, fd.Close())
+	assert.True(t, vfs.isOffline())
+}
+
+func TestOffline(t *testing.T) {
+	ctx := context.Background()
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.Offline = true
+	r, vfs := newTestVFSOpt(t, &opt)
+	require.True(t, vfs.offlineEnabled())
+
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.WriteObject(ctx, "other/file2", "file2 contents", t2)
+	_, err := vfs.Stat("dir/file1")
+	require.NoError(t, err)
+
+	// Errors which aren't network errors don't put the VFS offline
+	assert.False(t, vfs.checkOffline(errors.New("potato")))
+	assert.False(t, vfs.isOffline())
+
+	assert.True(t, vfs.checkOffline(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, vfs.isOffline())
+	assert.True(t, vfs.cache.IsOffline())
+	assert.Equal(t, true, vfs.Stats()["offline"])
+
+	// Make the directory stale - it should be served from the cache
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+	dir.mu.Lock()
+	dir.read = dir.read.Add(-2 * time.Duration(vfs.Opt.DirCacheTime))
+	dir.mu.Unlock()
+	r.WriteObject(ctx, "dir/file3", "file3 contents", t3)
+	nodes, err := dir.ReadDirAll()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(nodes))
+	assert.Equal(t, "file1", nodes[0].Name())
+
+	// Stale directories aren't forgotten while offline
+	dir.cacheCleanup()
+	node, err = vfs.Stat("dir/file1")
+	require.NoError(t, err)
+	assert.Equal(t, file1.Size, node.Size())
+
+	// Directories which haven't been read can't be listed
+	_, err = vfs.Stat("other/file2")
+	assert.Equal(t, errOffline, err)
+
+	// Going back online reads the remote again
+	vfs.setOffline(false)
+	assert.False(t, vfs.isOffline())
+	assert.False(t, vfs.cache.IsOffline())
+	_, err = vfs.Stat("dir/file3")
+	require.NoError(t, err)
+	_, err = vfs.Stat("other/file2")
+	require.NoError(t, err)
+}
+
+func TestOfflineNeedsCache(t *testing.T) {
+	opt := vfscommon.Opt
+	opt.Offline = true
+	_, vfs := newTestVFSOpt(t, &opt)
+	assert.False(t, vfs.offlineEnabled())
+	assert.False(t, vfs.checkOffline(&net.DNSError{Err: "no such host", Name: "example.com"}))
+	assert.False(t, vfs.isOffline())
+}
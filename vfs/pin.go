@@ -0,0 +1,97 @@
+// Pinning files in the VFS cache
+
+package vfs
+
+import (
+	"errors"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// errPinNeedsCache is returned when trying to pin without a full cache
+var errPinNeedsCache = errors.New("pinning files needs --vfs-cache-mode full")
+
+// Pin downloads the file or directory at path into the VFS cache and
+// marks it so it isn't removed when the cache is cleaned. Directories
+// are pinned recursively.
+//
+// Files added to a pinned directory later aren't pinned.
+//
+// It returns the number of files pinned.
+func (vfs *VFS) Pin(path string) (files int, err error) {
+	return vfs.setPinned(path, true)
+}
+
+// Unpin clears the pin on the file or directory at path so it can be
+// removed from the VFS cache as normal. Directories are unpinned
+// recursively.
+//
+// It returns the number of files unpinned.
+func (vfs *VFS) Unpin(path string) (files int, err error) {
+	return vfs.setPinned(path, false)
+}
+
+// setPinned pins or unpins the node at path
+func (vfs *VFS) setPinned(path string, pinned bool) (files int, err error) {
+	if vfs.cache == nil || vfs.Opt.CacheMode < vfscommon.CacheModeFull {
+		return 0, errPinNeedsCache
+	}
+	node, err := vfs.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return setNodePinned(node, pinned)
+}
+
+// setNodePinned pins or unpins node and anything below it carrying
+// on after errors and returning the first
+func setNodePinned(node Node, pinned bool) (files int, err error) {
+	switch x := node.(type) {
+	case *File:
+		err = x.setPinned(pinned)
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	case *Dir:
+		nodes, err := x.ReadDirAll()
+		if err != nil {
+			return 0, err
+		}
+		var firstErr error
+		for _, node := range nodes {
+			n, err := setNodePinned(node, pinned)
+			files += n
+			if err != nil {
+				fs.Errorf(node, "Failed to set pinned to %v: %v", pinned, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return files, firstErr
+	}
+	return 0, EINVAL
+}
+
+// setPinned pins or unpins the file in the VFS cache
+func (f *File) setPinned(pinned bool) error {
+	cache := f.VFS().cache
+	if cache == nil || f.VFS().Opt.CacheMode < vfscommon.CacheModeFull {
+		return errPinNeedsCache
+	}
+	if !pinned {
+		return cache.Unpin(f.Path())
+	}
+	return cache.Pin(f.Path(), f.getObject())
+}
+
+// isPinned returns whether the file is pinned in the VFS cache
+func (f *File) isPinned() bool {
+	cache := f.VFS().cache
+	if cache == nil {
+		return false
+	}
+	return cache.Item(f.Path()).IsPinned()
+}
@@ -0,0 +1,117 @@
+// Prefetching files in directories which are read in order
+
+package vfs
+
+import (
+	"sort"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// prefetchFilesEnabled returns true if files should be prefetched
+func (vfs *VFS) prefetchFilesEnabled() bool {
+	return vfs.Opt.Prefetch == vfscommon.PrefetchFiles && vfs.Opt.PrefetchFiles > 0 &&
+		vfs.cache != nil && vfs.Opt.CacheMode >= vfscommon.CacheModeFull
+}
+
+// _prefetchNames returns the sorted leaves of the files in d, making
+// the index if it is out of date
+//
+// call with the lock held
+func (d *Dir) _prefetchNames() []string {
+	if d.prefetchNames == nil {
+		names := make([]string, 0, len(d.items))
+		for name, node := range d.items {
+			if _, ok := node.(*File); ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		d.prefetchNames = names
+	}
+	return d.prefetchNames
+}
+
+// prefetchAfter is called when f is opened for reading.
+//
+// If f is the file following the one last opened for reading in d,
+// then the directory is being read in order, so the next
+// --vfs-prefetch-files files after f are fetched into the cache.
+func (d *Dir) prefetchAfter(f *File) {
+	if !d.vfs.prefetchFilesEnabled() {
+		return
+	}
+	leaf := f.Name()
+	d.mu.Lock()
+	names := d._prefetchNames()
+	i := sort.SearchStrings(names, leaf)
+	inOrder := i > 0 && i < len(names) && names[i] == leaf && names[i-1] == d.prefetchLast
+	d.prefetchLast = leaf
+	var files []*File
+	if inOrder {
+		for j := i + 1; j < len(names) && len(files) < d.vfs.Opt.PrefetchFiles; j++ {
+			if file, ok := d.items[names[j]].(*File); ok {
+				files = append(files, file)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	for _, file := range files {
+		d.vfs.prefetch(file)
+	}
+}
+
+// prefetch fetches f into the cache in the background unless it is
+// being fetched already or is bigger than --vfs-prefetch-max-size
+func (vfs *VFS) prefetch(f *File) {
+	o := f.getObject()
+	if o == nil {
+		return
+	}
+	path := f.Path()
+	if maxSize := vfs.Opt.PrefetchMaxSize; maxSize >= 0 && o.Size() > int64(maxSize) {
+		fs.Debugf(path, "Not prefetching as bigger than --vfs-prefetch-max-size %v", maxSize)
+		return
+	}
+	vfs.prefetchMu.Lock()
+	if _, found := vfs.prefetching[path]; found || vfs.prefetchStopped {
+		vfs.prefetchMu.Unlock()
+		return
+	}
+	vfs.prefetching[path] = struct{}{}
+	vfs.prefetchWG.Add(1)
+	vfs.prefetchMu.Unlock()
+
+	go func() {
+		defer vfs.prefetchWG.Done()
+		defer func() {
+			vfs.prefetchMu.Lock()
+			delete(vfs.prefetching, path)
+			vfs.prefetchMu.Unlock()
+		}()
+		ctx := vfs.prefetchCtx
+		select {
+		case vfs.prefetchSem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-vfs.prefetchSem }()
+		fs.Debugf(path, "Prefetching into the VFS cache")
+		err := vfs.cache.Prefetch(ctx, path, o)
+		if err != nil && ctx.Err() == nil {
+			fs.Errorf(path, "Failed to prefetch into the VFS cache: %v", err)
+		}
+	}()
+}
+
+// stopPrefetch stops any new prefetches, cancels those in progress
+// and waits for them to finish
+func (vfs *VFS) stopPrefetch() {
+	vfs.prefetchMu.Lock()
+	vfs.prefetchStopped = true
+	vfs.prefetchMu.Unlock()
+	vfs.cancelPrefetch()
+	vfs.prefetchWG.Wait()
+}
@@ -0,0 +1,109 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchFiles(t *testing.T) {
+	ctx := context.Background()
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.Prefetch = vfscommon.PrefetchFiles
+	opt.PrefetchFiles = 2
+	r, vfs := newTestVFSOpt(t, &opt)
+	require.True(t, vfs.prefetchFilesEnabled())
+
+	for i := 0; i < 6; i++ {
+		r.WriteObject(ctx, fmt.Sprintf("dir/frame%d", i), fmt.Sprintf("frame %d contents", i), t1)
+	}
+
+	open := func(i int) {
+		fd, err := vfs.OpenFile(fmt.Sprintf("dir/frame%d", i), os.O_RDONLY, 0)
+		require.NoError(t, err)
+		require.NoError(t, fd.Close())
+		vfs.prefetchWG.Wait()
+	}
+	cached := func(i int) bool {
+		name := fmt.Sprintf("dir/frame%d", i)
+		if !vfs.cache.Exists(name) {
+			return false
+		}
+		return vfs.cache.Item(name).HasRange(ranges.Range{Pos: 0, Size: int64(len("frame 0 contents"))})
+	}
+
+	// The first open doesn't prefetch anything
+	open(0)
+	for i := 1; i < 6; i++ {
+		assert.False(t, cached(i), i)
+	}
+
+	// Reading in order prefetches the following files
+	open(1)
+	assert.True(t, cached(2))
+	assert.True(t, cached(3))
+	assert.False(t, cached(4))
+	assert.False(t, cached(5))
+
+	// Reading out of order doesn't
+	open(4)
+	assert.False(t, cached(5))
+
+	// The sorted index is remade when the directory is read
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+	dir.mu.Lock()
+	assert.Equal(t, 6, len(dir.prefetchNames))
+	dir.mu.Unlock()
+	r.WriteObject(ctx, "dir/frame6", "frame 6 contents", t1)
+	require.NoError(t, dir.readDir())
+	dir.mu.Lock()
+	assert.Nil(t, dir.prefetchNames)
+	dir.mu.Unlock()
+	open(5)
+	assert.True(t, cached(6))
+}
+
+func TestPrefetchFilesMaxSize(t *testing.T) {
+	ctx := context.Background()
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.Prefetch = vfscommon.PrefetchFiles
+	opt.PrefetchFiles = 2
+	opt.PrefetchMaxSize = 16
+	r, vfs := newTestVFSOpt(t, &opt)
+
+	r.WriteObject(ctx, "dir/frame0", "frame 0", t1)
+	r.WriteObject(ctx, "dir/frame1", "frame 1", t1)
+	r.WriteObject(ctx, "dir/frame2", "frame 2 is too big to prefetch", t1)
+	r.WriteObject(ctx, "dir/frame3", "frame 3", t1)
+
+	for i := 0; i < 2; i++ {
+		fd, err := vfs.OpenFile(fmt.Sprintf("dir/frame%d", i), os.O_RDONLY, 0)
+		require.NoError(t, err)
+		require.NoError(t, fd.Close())
+	}
+	vfs.prefetchWG.Wait()
+	assert.False(t, vfs.cache.Exists("dir/frame2"))
+	assert.True(t, vfs.cache.Exists("dir/frame3"))
+}
+
+func TestPrefetchFilesDisabled(t *testing.T) {
+	opt := vfscommon.Opt
+	opt.Prefetch = vfscommon.PrefetchFiles
+	_, vfs := newTestVFSOpt(t, &opt)
+	assert.False(t, vfs.prefetchFilesEnabled())
+
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.Prefetch = vfscommon.PrefetchAdaptive
+	_, vfs = newTestVFSOpt(t, &opt)
+	assert.False(t, vfs.prefetchFilesEnabled())
+}
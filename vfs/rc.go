@@ -11,6 +11,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/cache"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs/vfscache"
 )
 
 const getVFSHelp = ` 
@@ -124,7 +125,7 @@ func rcRefresh(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	result := map[string]string{}
 	if len(in) == 0 {
 		if recursive {
-			err = root.readDirTree()
+			err = root.readDirTree(ctx)
 		} else {
 			err = root.readDir()
 		}
@@ -145,7 +146,7 @@ func rcRefresh(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 					result[path] = err.Error()
 				} else {
 					if recursive {
-						err = dir.readDirTree()
+						err = dir.readDirTree(ctx)
 					} else {
 						err = dir.readDir()
 					}
@@ -227,6 +228,155 @@ func rcForget(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	return out, nil
 }
 
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/pin",
+		Fn:    rcPin,
+		Title: "Pin files or directories in the VFS cache.",
+		Help: `
+This downloads the paths into the VFS cache and marks them so they
+are kept when the cache is cleaned, regardless of --vfs-cache-max-age
+and --vfs-cache-max-size. Directories are pinned recursively, but
+files added to them later are not pinned. This needs
+--vfs-cache-mode full.
+
+Pass the files or directories as path=path. Any parameter key
+starting with path will be pinned, e.g.
+
+    rclone rc vfs/pin path=docs path2=photos/2024/holiday.jpg
+
+This returns the number of files pinned and the result for each path.
+
+    {
+        "files": 42,
+        "result": {
+            "docs": "OK",
+            "photos/2024/holiday.jpg": "OK"
+        }
+    }
+
+Use vfs/stats to see how many pinned files are not yet completely
+downloaded or have changes waiting to be uploaded.
+` + getVFSHelp,
+	})
+	rc.Add(rc.Call{
+		Path:  "vfs/unpin",
+		Fn:    rcUnpin,
+		Title: "Unpin files or directories in the VFS cache.",
+		Help: `
+This clears the pin set with vfs/pin on the paths so they can be
+removed from the VFS cache as normal. Directories are unpinned
+recursively.
+
+Pass the files or directories as path=path. Any parameter key
+starting with path will be unpinned, e.g.
+
+    rclone rc vfs/unpin path=docs path2=photos/2024/holiday.jpg
+
+This returns the number of files unpinned and the result for each
+path in the same format as vfs/pin.
+` + getVFSHelp,
+	})
+}
+
+func rcPin(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return rcSetPinned(in, true)
+}
+
+func rcUnpin(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return rcSetPinned(in, false)
+}
+
+func rcSetPinned(in rc.Params, pinned bool) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(in) == 0 {
+		return nil, errors.New("need at least one path parameter")
+	}
+	files := 0
+	result := map[string]string{}
+	for k, v := range in {
+		path, ok := v.(string)
+		if !ok {
+			return out, fmt.Errorf("value must be string %q=%v", k, v)
+		}
+		if !strings.HasPrefix(k, "path") {
+			return out, fmt.Errorf("unknown key %q", k)
+		}
+		path = strings.Trim(path, "/")
+		n, err := vfs.setPinned(path, pinned)
+		files += n
+		if err != nil {
+			result[path] = err.Error()
+		} else {
+			result[path] = "OK"
+		}
+	}
+	out = rc.Params{
+		"files":  files,
+		"result": result,
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/conflicts",
+		Fn:    rcConflicts,
+		Title: "Show conflicts found when uploading files changed offline.",
+		Help: `
+With --vfs-offline, files changed in the VFS cache while the remote
+was unreachable are uploaded when it comes back online. If a file was
+changed on the remote in the meantime, the remote version is saved
+next to it as "name.conflict-YYYYMMDD-HHMMSS.ext" before the upload.
+If it was deleted on the remote then the cached version is uploaded
+again.
+
+This returns whether the VFS is offline and the conflicts found so
+far, e.g.
+
+    {
+        "conflicts": [
+            {
+                "name": "docs/report.txt",
+                "reason": "changed on remote",
+                "saved": "docs/report.conflict-20240102-150405.txt",
+                "time": "2024-01-02T15:04:05.123456789Z"
+            }
+        ],
+        "offline": false
+    }
+
+Pass clear=true to forget the conflicts once they have been returned.
+` + getVFSHelp,
+	})
+}
+
+func rcConflicts(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	clearConflicts, err := in.GetBool("clear")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	conflicts := []vfscache.Conflict{}
+	if vfs.cache != nil {
+		conflicts = append(conflicts, vfs.cache.Conflicts()...)
+		if clearConflicts {
+			vfs.cache.ClearConflicts()
+		}
+	}
+	out = rc.Params{
+		"conflicts": conflicts,
+		"offline":   vfs.isOffline(),
+	}
+	return out, nil
+}
+
 func getDuration(k string, v interface{}) (time.Duration, error) {
 	s, ok := v.(string)
 	if !ok {
@@ -403,15 +553,24 @@ This returns stats for the selected VFS.
             "bytesUsed": 0,
             "erroredFiles": 0,
             "files": 0,
+            "conflicts": 0,
             "hashType": 1,
+            "offline": false,
             "outOfSpace": false,
             "path": "/home/user/.cache/rclone/vfs/local/mnt/a",
             "pathMeta": "/home/user/.cache/rclone/vfsMeta/local/mnt/a",
+            // Files pinned with vfs/pin
+            "pinnedBytes": 0,
+            "pinnedDirty": 0,
+            "pinnedFiles": 0,
+            "pinnedPartial": 0,
             "uploadsInProgress": 0,
             "uploadsQueued": 0
         },
         "fs": "/mnt/a",
         "inUse": 1,
+        // Set if --vfs-offline is in use and the remote is unreachable
+        "offline": false,
         // Status of the in memory metadata cache
         "metadataCache": {
             "dirs": 1,
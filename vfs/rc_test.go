@@ -7,6 +7,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscache"
 	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -125,3 +126,71 @@ func TestRcStats(t *testing.T) {
 	assert.Equal(t, 1, out["metadataCache"].(rc.Params)["dirs"])
 	assert.Equal(t, vfs.Opt, out["opt"].(vfscommon.Options))
 }
+
+func TestRcPin(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping test on non local remote")
+	}
+	ctx := context.Background()
+	opt := vfscommon.Opt
+	opt.CacheMode = vfscommon.CacheModeFull
+	r, vfs := newTestVFSOpt(t, &opt)
+	r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.WriteObject(ctx, "dir/sub/file2", "file2 contents", t2)
+	pin := rc.Calls.Get("vfs/pin")
+	unpin := rc.Calls.Get("vfs/unpin")
+
+	out, err := pin.Fn(ctx, rc.Params{"path": "dir"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"files":  2,
+		"result": map[string]string{"dir": "OK"},
+	}, out)
+	diskCache := vfs.Stats()["diskCache"].(rc.Params)
+	assert.Equal(t, int64(2), diskCache["pinnedFiles"])
+	assert.Equal(t, int64(28), diskCache["pinnedBytes"])
+	assert.Equal(t, int64(0), diskCache["pinnedPartial"])
+
+	node, err := vfs.Stat("dir/sub/file2")
+	require.NoError(t, err)
+	value, err := node.(*File).GetXattr(context.Background(), XattrPinned)
+	require.NoError(t, err)
+	assert.Equal(t, "true", string(value))
+
+	out, err = unpin.Fn(ctx, rc.Params{"path": "dir/sub/file2", "path2": "potato"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"files": 1,
+		"result": map[string]string{
+			"dir/sub/file2": "OK",
+			"potato":        ENOENT.Error(),
+		},
+	}, out)
+	value, err = node.(*File).GetXattr(context.Background(), XattrPinned)
+	require.NoError(t, err)
+	assert.Equal(t, "false", string(value))
+	require.NoError(t, node.(*File).SetXattr(context.Background(), XattrPinned, []byte("true")))
+	assert.Equal(t, int64(2), vfs.Stats()["diskCache"].(rc.Params)["pinnedFiles"])
+
+	_, err = pin.Fn(ctx, rc.Params{"potato": "dir"})
+	assert.ErrorContains(t, err, "unknown key")
+}
+
+func TestRcConflicts(t *testing.T) {
+	_, vfs, call := rcNewRun(t, "vfs/conflicts")
+	out, err := call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"conflicts": []vfscache.Conflict{},
+		"offline":   false,
+	}, out)
+
+	vfs.Opt.CacheMode = vfscommon.CacheModeFull
+	vfs.Opt.Offline = true
+	vfs.SetCacheMode(vfscommon.CacheModeFull)
+	vfs.setOffline(true)
+	defer vfs.setOffline(false)
+	out, err = call.Fn(context.Background(), rc.Params{"clear": true})
+	require.NoError(t, err)
+	assert.Equal(t, true, out["offline"])
+}
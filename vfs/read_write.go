@@ -100,6 +100,11 @@ func (fh *RWFileHandle) openPending() (err error) {
 
 	o := fh.file.getObject()
 	err = fh.item.Open(o)
+	if err != nil && fh.file.VFS().checkOffline(err) {
+		// The remote has just gone offline so try again using
+		// the cached copy if there is one
+		err = fh.item.Open(o)
+	}
 	if err != nil {
 		return fmt.Errorf("open RW handle failed to open cache file: %w", err)
 	}
@@ -274,6 +279,9 @@ func (fh *RWFileHandle) _readAt(b []byte, off int64, release bool) (n int, err e
 	}
 
 	n, err = fh.item.ReadAt(b, off)
+	if err != nil && err != io.EOF {
+		fh.file.VFS().checkOffline(err)
+	}
 
 	if release {
 		fh.mu.Lock()
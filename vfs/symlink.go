@@ -0,0 +1,83 @@
+// Symlinks stored as .rclonelink objects
+
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// LinkSuffix is the suffix of the objects which are shown as
+// symlinks with --vfs-links. This is the same as the local backend
+// uses with --links.
+const LinkSuffix = ".rclonelink"
+
+// maxLinkLength is the longest symlink target which will be read
+const maxLinkLength = 64 * 1024
+
+// Readlink returns the target of the symlink
+//
+// It returns EINVAL if the file isn't a symlink.
+func (f *File) Readlink() (target string, err error) {
+	if !f.isLink {
+		return "", EINVAL
+	}
+	o := f.getObject()
+	if o == nil {
+		return "", ENOENT
+	}
+	in, err := o.Open(context.TODO())
+	if err != nil {
+		return "", err
+	}
+	defer fs.CheckClose(in, &err)
+	data, err := io.ReadAll(io.LimitReader(in, maxLinkLength+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxLinkLength {
+		return "", fmt.Errorf("symlink target longer than %d bytes", maxLinkLength)
+	}
+	return string(data), nil
+}
+
+// Symlink makes a symlink called name in the directory pointing to
+// target.
+//
+// This is stored on the remote as an object called name with
+// LinkSuffix added whose contents are the target.
+func (d *Dir) Symlink(target, name string) (*File, error) {
+	if !d.vfs.Opt.Links {
+		return nil, ENOSYS
+	}
+	if d.vfs.Opt.ReadOnly {
+		return nil, EROFS
+	}
+	_, err := d.stat(name)
+	switch err {
+	case ENOENT:
+		// not found, carry on
+	case nil:
+		return nil, EEXIST
+	default:
+		fs.Errorf(d, "Dir.Symlink stat failed: %v", err)
+		return nil, err
+	}
+	remote := path.Join(d.Path(), name) + LinkSuffix
+	o, err := operations.RcatSize(context.TODO(), d.Fs(), remote, io.NopCloser(strings.NewReader(target)), int64(len(target)), time.Now(), nil)
+	if err != nil {
+		fs.Errorf(d, "Dir.Symlink failed to upload: %v", err)
+		return nil, err
+	}
+	file := newFile(d, d.Path(), o, name)
+	file.isLink = true
+	d.addObject(file)
+	return file, nil
+}
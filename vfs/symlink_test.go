@@ -0,0 +1,80 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymlink(t *testing.T) {
+	opt := vfscommon.Opt
+	opt.Links = true
+	r, vfs := newTestVFSOpt(t, &opt)
+
+	link1 := r.WriteObject(context.Background(), "dir/link1"+LinkSuffix, "target1", t1)
+	r.CheckRemoteItems(t, link1)
+
+	// Existing .rclonelink objects show up as symlinks
+	node, err := vfs.Stat("dir/link1")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, node.Mode()&os.ModeType)
+	link := node.(*File)
+	target, err := link.Readlink()
+	require.NoError(t, err)
+	assert.Equal(t, "target1", target)
+
+	// Symlinks can't be written
+	_, err = link.Open(os.O_WRONLY)
+	assert.Equal(t, EPERM, err)
+
+	// Making a symlink
+	node, err = vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+	_, err = dir.Symlink("potato", "link1")
+	assert.Equal(t, EEXIST, err)
+	link2, err := dir.Symlink("../target2", "link2")
+	require.NoError(t, err)
+	assert.Equal(t, "link2", link2.Name())
+	target, err = link2.Readlink()
+	require.NoError(t, err)
+	assert.Equal(t, "../target2", target)
+	link2Item := fstest.NewItem("dir/link2"+LinkSuffix, "../target2", link2.ModTime())
+	r.CheckRemoteItems(t, link1, link2Item)
+
+	// Renaming keeps the suffix
+	require.NoError(t, vfs.Rename("dir/link2", "dir/link3"))
+	link3Item := fstest.NewItem("dir/link3"+LinkSuffix, "../target2", link2.ModTime())
+	r.CheckRemoteItems(t, link1, link3Item)
+
+	// Removing
+	require.NoError(t, vfs.Remove("dir/link3"))
+	r.CheckRemoteItems(t, link1)
+
+	// Regular files aren't symlinks
+	file, err := dir.Create("file1", os.O_WRONLY|os.O_CREATE)
+	require.NoError(t, err)
+	_, err = file.Readlink()
+	assert.Equal(t, EINVAL, err)
+}
+
+func TestSymlinkDisabled(t *testing.T) {
+	r, vfs := newTestVFS(t)
+
+	link1 := r.WriteObject(context.Background(), "link1"+LinkSuffix, "target1", t1)
+	r.CheckRemoteItems(t, link1)
+
+	node, err := vfs.Stat("link1" + LinkSuffix)
+	require.NoError(t, err)
+	assert.True(t, node.Mode().IsRegular())
+
+	root, err := vfs.Root()
+	require.NoError(t, err)
+	_, err = root.Symlink("target", "link2")
+	assert.Equal(t, ENOSYS, err)
+}
@@ -39,6 +39,7 @@ import (
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/atexit"
 	"github.com/rclone/rclone/vfs/vfscache"
 	"github.com/rclone/rclone/vfs/vfscommon"
 )
@@ -183,6 +184,26 @@ type VFS struct {
 	usage       *fs.Usage
 	pollChan    chan time.Duration
 	inUse       atomic.Int32 // count of number of opens
+
+	dirCacheMu     sync.Mutex         // held while saving the directory cache
+	dirCacheCtx    context.Context    // cancelled when the directory cache is stopped
+	cancelDirCache context.CancelFunc // stops the directory cache saver and refresh
+	dirCacheAtExit atexit.FnHandle    // saves the directory cache on exit
+	refreshed      chan struct{}      // closed when the loaded directory cache is revalidated - nil if not loaded
+
+	offline       atomic.Bool        // set while the remote is unreachable
+	offlineMu     sync.Mutex         // protects going offline and online
+	cancelOffline context.CancelFunc // stops probing the remote while offline
+
+	prefetchMu      sync.Mutex          // protects the following
+	prefetching     map[string]struct{} // paths of files being prefetched
+	prefetchStopped bool                // set when the VFS is shut down
+	prefetchWG      sync.WaitGroup      // prefetches in progress
+	prefetchSem     chan struct{}       // limits the number of prefetches at once
+	prefetchCtx     context.Context     // cancelled when the VFS is shut down
+	cancelPrefetch  context.CancelFunc  // cancels the prefetches in progress
+
+	locks *locker // advisory file locks - nil if --vfs-locks is off
 }
 
 // Keep track of active VFS keyed on fs.ConfigString(f)
@@ -196,7 +217,8 @@ var (
 func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	fsDir := fs.NewDir("", time.Now())
 	vfs := &VFS{
-		f: f,
+		f:           f,
+		prefetching: make(map[string]struct{}),
 	}
 	vfs.inUse.Store(1)
 
@@ -227,6 +249,12 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	// Create root directory
 	vfs.root = newDir(vfs, f, nil, fsDir)
 
+	// Load the persistent directory cache if required
+	dirCacheLoaded := false
+	if vfs.Opt.DirCachePersist {
+		dirCacheLoaded = vfs.startDirCache()
+	}
+
 	// Start polling function
 	features := vfs.f.Features()
 	if do := features.ChangeNotify; do != nil {
@@ -247,23 +275,49 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	// removed when the vfs is finalized
 	cache.PinUntilFinalized(f, vfs)
 
-	// Refresh the dircache if required
-	if vfs.Opt.Refresh {
-		go vfs.refresh()
+	// Refresh the dircache if required. A directory cache loaded
+	// from disk is always revalidated as the remote may have
+	// changed while rclone wasn't running.
+	if dirCacheLoaded {
+		vfs.refreshed = make(chan struct{})
+		go func() {
+			defer close(vfs.refreshed)
+			vfs.refresh(vfs.dirCacheCtx)
+		}()
+	} else if vfs.Opt.Refresh {
+		go vfs.refresh(context.Background())
 	}
 
 	// This can take some time so do it after the Pin
 	vfs.SetCacheMode(vfs.Opt.CacheMode)
 
+	if vfs.Opt.Offline && !vfs.offlineEnabled() {
+		fs.Logf(f, "--vfs-offline needs --vfs-cache-mode full - ignoring")
+	}
+
+	vfs.prefetchSem = make(chan struct{}, max(vfs.Opt.PrefetchFiles, 1))
+	vfs.prefetchCtx, vfs.cancelPrefetch = context.WithCancel(context.Background())
+	if vfs.Opt.Prefetch != vfscommon.PrefetchOff && vfs.Opt.CacheMode < vfscommon.CacheModeFull {
+		fs.Logf(f, "--vfs-prefetch needs --vfs-cache-mode full - ignoring")
+	}
+
+	if vfs.Opt.Locks != vfscommon.LocksOff {
+		vfs.locks = newLocker(vfs)
+	}
+
 	return vfs
 }
 
 // refresh the directory cache for all directories
-func (vfs *VFS) refresh() {
+func (vfs *VFS) refresh(ctx context.Context) {
 	fs.Debugf(vfs.f, "Refreshing VFS directory cache")
-	err := vfs.root.readDirTree()
+	err := vfs.root.readDirTree(ctx)
 	if err != nil {
-		fs.Errorf(vfs.f, "Error refreshing VFS directory cache: %v", err)
+		if ctx.Err() == nil {
+			fs.Errorf(vfs.f, "Error refreshing VFS directory cache: %v", err)
+		}
+	} else if vfs.Opt.DirCachePersist && ctx.Err() == nil {
+		vfs.saveDirCacheLog()
 	}
 }
 
@@ -273,6 +327,7 @@ func (vfs *VFS) Stats() (out rc.Params) {
 	out["fs"] = fs.ConfigString(vfs.f)
 	out["opt"] = vfs.Opt
 	out["inUse"] = vfs.inUse.Load()
+	out["offline"] = vfs.isOffline()
 
 	var (
 		dirs  int
@@ -328,6 +383,9 @@ func (vfs *VFS) SetCacheMode(cacheMode vfscommon.CacheMode) {
 		vfs.Opt.CacheMode = cacheMode
 		vfs.cancelCache = cancel
 		vfs.cache = cache
+		if vfs.isOffline() {
+			cache.SetOffline(true)
+		}
 	}
 }
 
@@ -359,6 +417,12 @@ func (vfs *VFS) Shutdown() {
 	}
 	activeMu.Unlock()
 
+	vfs.stopDirCache()
+	vfs.stopOffline()
+	vfs.stopPrefetch()
+	if vfs.locks != nil {
+		vfs.locks.shutdown()
+	}
 	vfs.shutdownCache()
 }
 
@@ -0,0 +1,338 @@
+// Evicting blocks of large files from the cache
+
+package vfscache
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/lib/diskusage"
+	"github.com/rclone/rclone/lib/file"
+	"github.com/rclone/rclone/lib/ranges"
+)
+
+// defaultBlockSize is the size of the blocks evicted when
+// --vfs-cache-max-file-size is set without --vfs-cache-block-size
+const defaultBlockSize = 16 * 1024 * 1024
+
+// cacheBlock is a candidate for eviction from the cache
+type cacheBlock struct {
+	item  *Item
+	index int64     // block number or -1 for the whole item
+	size  int64     // bytes of the block present in the cache
+	atime time.Time // when the block was last accessed
+}
+
+// blockSize returns the size of the blocks to evict from large files
+// or 0 if only whole files should be evicted
+func (c *Cache) blockSize() int64 {
+	if !file.PunchHoleImplemented || c.noPunchHole.Load() {
+		return 0
+	}
+	if c.opt.CacheBlockSize > 0 {
+		return int64(c.opt.CacheBlockSize)
+	}
+	if c.opt.CacheMaxFileSize > 0 {
+		return defaultBlockSize
+	}
+	return 0
+}
+
+// quotaExcess returns the number of bytes which need to be freed to
+// bring the cache within its quotas
+//
+// must be called with mu held.
+func (c *Cache) quotaExcess() (excess int64) {
+	if c.opt.CacheMaxSize > 0 && c.used > int64(c.opt.CacheMaxSize) {
+		excess = c.used - int64(c.opt.CacheMaxSize)
+	}
+	if c.opt.CacheMinFreeSpace > 0 {
+		du, err := diskusage.New(config.GetCacheDir())
+		if err == nil && du.Available < uint64(c.opt.CacheMinFreeSpace) {
+			need := int64(uint64(c.opt.CacheMinFreeSpace) - du.Available)
+			if need > excess {
+				excess = need
+			}
+		}
+	}
+	return excess
+}
+
+// purgeBlocksOverQuota evicts the least recently used blocks of large
+// files and whole small files not in use until the quota is satisfied
+//
+// Blocks of large files are evicted even if the file is open, so a
+// single large file doesn't push everything else out of the cache.
+func (c *Cache) purgeBlocksOverQuota(bs int64) {
+	c.updateUsed()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	excess := c.quotaExcess()
+	if excess <= 0 {
+		return
+	}
+
+	var blocks []cacheBlock
+	for _, item := range c.item {
+		blocks = append(blocks, item.evictableBlocks(bs)...)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].atime.Before(blocks[j].atime)
+	})
+
+	// Choose the oldest blocks until enough space would be freed
+	evict := make(map[*Item][]int64)
+	var items []*Item
+	for _, block := range blocks {
+		if excess <= 0 {
+			break
+		}
+		if block.index < 0 {
+			c.removeNotInUse(block.item, 0, false)
+		} else {
+			if _, found := evict[block.item]; !found {
+				items = append(items, block.item)
+			}
+			evict[block.item] = append(evict[block.item], block.index)
+		}
+		excess -= block.size
+	}
+
+	for _, item := range items {
+		spaceFreed, err := item.evictBlocks(bs, evict[item])
+		c.used -= spaceFreed
+		fs.Infof(nil, "vfs cache purgeBlocks %s: evicted %d blocks, freed %d bytes", item.name, len(evict[item]), spaceFreed)
+		if err != nil {
+			c.blockEvictError(item, err)
+			continue
+		}
+		// Remove any items which are now empty
+		if item.getDiskSize() == 0 {
+			c.removeNotInUse(item, 0, true)
+		}
+	}
+
+	if c.quotasOK() {
+		c.outOfSpace = false
+		c.cond.Broadcast()
+	}
+}
+
+// purgeOverFileSize evicts the least recently used blocks of any
+// files using more than --vfs-cache-max-file-size
+func (c *Cache) purgeOverFileSize(bs int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range c.item {
+		spaceFreed, err := item.evictOverFileSize(bs, ranges.Range{})
+		c.used -= spaceFreed
+		if err != nil {
+			c.blockEvictError(item, err)
+		}
+	}
+}
+
+// blockEvictError logs an error evicting blocks from item and turns
+// off block eviction if the cache can't punch holes in files
+func (c *Cache) blockEvictError(item *Item, err error) {
+	if err == file.ErrPunchHoleUnsupported {
+		if !c.noPunchHole.Swap(true) {
+			fs.Errorf(nil, "vfs cache: the cache directory doesn't support punching holes in files - evicting whole files instead of blocks")
+		}
+		return
+	}
+	fs.Errorf(item.name, "vfs cache: failed to evict blocks: %v", err)
+}
+
+// _touchBlocks records that the blocks from offset, size have been
+// accessed
+//
+// call with the lock held
+func (item *Item) _touchBlocks(offset, size int64) {
+	bs := item.c.blockSize()
+	if bs <= 0 || size <= 0 {
+		return
+	}
+	if item.info.BlockSize != bs || item.info.BlockATimes == nil {
+		item.info.BlockSize = bs
+		item.info.BlockATimes = make(map[int64]time.Time)
+	}
+	now := time.Now()
+	for i := offset / bs; i <= (offset+size-1)/bs; i++ {
+		item.info.BlockATimes[i] = now
+	}
+}
+
+// _canEvictBlocks returns true if parts of the cache file may be
+// evicted
+//
+// call with the lock held
+func (item *Item) _canEvictBlocks() bool {
+	return !item.info.Dirty && !item.info.Pinned && !item.beingReset && item.writers == 0
+}
+
+// _blocks returns the blocks of the item which are in the cache file
+//
+// call with the lock held
+func (item *Item) _blocks(bs int64) (blocks []cacheBlock) {
+	for _, r := range item.info.Rs {
+		if r.IsEmpty() {
+			continue
+		}
+		for i := r.Pos / bs; i <= (r.End()-1)/bs; i++ {
+			if n := len(blocks); n > 0 && blocks[n-1].index == i {
+				continue
+			}
+			atime, found := item.info.BlockATimes[i]
+			if !found || item.info.BlockSize != bs {
+				atime = item.info.ATime
+			}
+			block := ranges.Range{Pos: i * bs, Size: bs}
+			blocks = append(blocks, cacheBlock{
+				item:  item,
+				index: i,
+				size:  item.info.Rs.Intersection(block).Size(),
+				atime: atime,
+			})
+		}
+	}
+	return blocks
+}
+
+// evictableBlocks returns the blocks of the item which may be
+// evicted.
+//
+// Files larger than a block return their blocks. Smaller files
+// which aren't in use return a single block for the whole file.
+func (item *Item) evictableBlocks(bs int64) []cacheBlock {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	if !item._canEvictBlocks() || len(item.info.Rs) == 0 {
+		return nil
+	}
+	if item.info.Size <= bs {
+		if item.opens != 0 {
+			return nil
+		}
+		return []cacheBlock{{
+			item:  item,
+			index: -1,
+			size:  item.info.Rs.Size(),
+			atime: item.info.ATime,
+		}}
+	}
+	return item._blocks(bs)
+}
+
+// evictOverFileSize evicts the least recently used blocks of the
+// item until it is using no more than --vfs-cache-max-file-size
+// without evicting any blocks in keep.
+func (item *Item) evictOverFileSize(bs int64, keep ranges.Range) (spaceFreed int64, err error) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item._evictOverFileSize(bs, keep)
+}
+
+// _evictOverFileSize evicts the least recently used blocks of the
+// item until it is using no more than --vfs-cache-max-file-size
+// without evicting any blocks in keep.
+//
+// call with the lock held
+func (item *Item) _evictOverFileSize(bs int64, keep ranges.Range) (spaceFreed int64, err error) {
+	maxSize := int64(item.c.opt.CacheMaxFileSize)
+	if bs <= 0 || maxSize <= 0 || !item._canEvictBlocks() {
+		return 0, nil
+	}
+	excess := item.info.Rs.Size() - maxSize
+	if excess <= 0 {
+		return 0, nil
+	}
+	blocks := item._blocks(bs)
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].atime.Before(blocks[j].atime)
+	})
+	var indexes []int64
+	for _, block := range blocks {
+		if excess <= 0 {
+			break
+		}
+		r := ranges.Range{Pos: block.index * bs, Size: bs}
+		if !r.Intersection(keep).IsEmpty() {
+			continue
+		}
+		indexes = append(indexes, block.index)
+		excess -= block.size
+	}
+	return item._evictBlocks(bs, indexes)
+}
+
+// evictBlocks removes the blocks with the indexes given from the
+// cache file
+func (item *Item) evictBlocks(bs int64, indexes []int64) (spaceFreed int64, err error) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item._evictBlocks(bs, indexes)
+}
+
+// _evictBlocks removes the blocks with the indexes given from the
+// cache file
+//
+// The metadata is saved before the holes are punched so a crash can
+// never leave blocks marked as present which have been freed.
+//
+// call with the lock held
+func (item *Item) _evictBlocks(bs int64, indexes []int64) (spaceFreed int64, err error) {
+	if len(indexes) == 0 || !item._canEvictBlocks() {
+		return 0, nil
+	}
+	// Other processes sharing the cache may be reading the blocks
+	if item._lock(lockExclusive) != nil {
+		return 0, nil
+	}
+	defer item._relock()
+	oldSize := item.info.Rs.Size()
+	for _, i := range indexes {
+		item.info.Rs.Remove(ranges.Range{Pos: i * bs, Size: bs})
+		delete(item.info.BlockATimes, i)
+	}
+	spaceFreed = oldSize - item.info.Rs.Size()
+	err = item._save()
+	if err != nil {
+		return spaceFreed, err
+	}
+
+	fd := item.fd
+	if fd == nil {
+		fd, err = file.OpenFile(item.c.toOSPath(item.name), os.O_WRONLY, 0600)
+		if err != nil {
+			return spaceFreed, fmt.Errorf("vfs cache: failed to open cache file to evict blocks: %w", err)
+		}
+		defer fs.CheckClose(fd, &err)
+	}
+	for _, i := range indexes {
+		err = file.PunchHole(fd, i*bs, bs)
+		if err != nil {
+			return spaceFreed, err
+		}
+	}
+	fs.Debugf(item.name, "vfs cache: evicted %d blocks freeing %d bytes", len(indexes), spaceFreed)
+	return spaceFreed, nil
+}
+
+// _isPresent returns true if the range from offset, size clipped to
+// the size of the file is in the cache file
+//
+// call with the lock held
+func (item *Item) _isPresent(offset, size int64) bool {
+	if offset+size > item.info.Size {
+		size = item.info.Size - offset
+	}
+	return item.info.Rs.Present(ranges.Range{Pos: offset, Size: size})
+}
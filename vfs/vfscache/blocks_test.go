@@ -0,0 +1,140 @@
+package vfscache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/file"
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBlocksTestCache(t *testing.T, blockSize, maxFileSize fs.SizeSuffix) (r *fstest.Run, c *Cache) {
+	if !file.PunchHoleImplemented {
+		t.Skip("evicting blocks not supported on this OS")
+	}
+	opt := vfscommon.Opt
+	opt.CachePollInterval = 0
+	opt.WriteBack = 0
+	opt.CacheBlockSize = blockSize
+	opt.CacheMaxFileSize = maxFileSize
+	return newTestCacheOpt(t, opt)
+}
+
+// read the whole of item checking it is the same as contents
+func checkItemRead(t *testing.T, item *Item, contents string) {
+	buf := make([]byte, len(contents))
+	n, err := item.ReadAt(buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, len(contents), n)
+	assert.Equal(t, contents, string(buf))
+}
+
+func TestBlocksEvict(t *testing.T) {
+	r, c := newBlocksTestCache(t, 16, -1)
+	assert.Equal(t, int64(16), c.blockSize())
+
+	contents, obj, item := newFile(t, r, c, "existing")
+	require.NoError(t, item.Open(obj))
+	checkItemRead(t, item, contents)
+	assert.Equal(t, int64(100), item.getDiskSize())
+	assert.Equal(t, 7, len(item.info.BlockATimes))
+
+	// Evict some blocks while the file is open
+	spaceFreed, err := item.evictBlocks(16, []int64{1, 2, 6})
+	require.NoError(t, err)
+	assert.Equal(t, int64(16+16+4), spaceFreed)
+	assert.Equal(t, ranges.Ranges{{Pos: 0, Size: 16}, {Pos: 48, Size: 48}}, item.info.Rs)
+	assert.Equal(t, 4, len(item.info.BlockATimes))
+
+	// The evicted blocks are downloaded again when read
+	checkItemRead(t, item, contents)
+	assert.Equal(t, int64(100), item.getDiskSize())
+
+	// Dirty files can't have blocks evicted
+	_, err = item.WriteAt([]byte("HELLO"), 0)
+	require.NoError(t, err)
+	spaceFreed, err = item.evictBlocks(16, []int64{3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), spaceFreed)
+	assert.Equal(t, int64(100), item.getDiskSize())
+
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "existing", "HELLO"+contents[5:])
+}
+
+func TestBlocksPurgeOverQuota(t *testing.T) {
+	r, c := newBlocksTestCache(t, 16, -1)
+
+	bigContents, bigObj, big := newFile(t, r, c, "big")
+	require.NoError(t, big.Open(bigObj))
+	checkItemRead(t, big, bigContents)
+
+	smallContents, smallObj, small := newFileLength(t, r, c, "small", 5)
+	require.NoError(t, small.Open(smallObj))
+	checkItemRead(t, small, smallContents)
+	require.NoError(t, small.Close(nil))
+
+	// Make block 3 of big the oldest followed by small then
+	// the other blocks in order
+	base := time.Now().Add(-time.Hour)
+	small.info.ATime = base
+	for i := int64(0); i < 7; i++ {
+		big.info.BlockATimes[i] = base.Add(time.Duration(i+1) * time.Second)
+	}
+	big.info.BlockATimes[3] = base.Add(-time.Second)
+
+	// Nothing to do if within quota
+	c.opt.CacheMaxSize = 105
+	c.purgeBlocksOverQuota(16)
+	assert.Equal(t, int64(105), c.used)
+
+	// Evict block 3, small and block 0 while big is open
+	c.opt.CacheMaxSize = 70
+	c.purgeBlocksOverQuota(16)
+	assert.Equal(t, int64(105-16-5-16), c.used)
+	assert.Equal(t, []string{
+		`name="big" opens=1 size=100`,
+	}, itemAsString(c))
+	assert.Equal(t, ranges.Ranges{{Pos: 16, Size: 32}, {Pos: 64, Size: 36}}, big.info.Rs)
+
+	checkItemRead(t, big, bigContents)
+	require.NoError(t, big.Close(nil))
+}
+
+func TestBlocksMaxFileSize(t *testing.T) {
+	r, c := newBlocksTestCache(t, 0, 48)
+	assert.Equal(t, int64(defaultBlockSize), c.blockSize())
+	c.opt.CacheBlockSize = 16
+
+	contents, obj, item := newFile(t, r, c, "existing")
+	require.NoError(t, item.Open(obj))
+
+	// Reading the file keeps it under the max file size
+	for off := 0; off < len(contents); off += 16 {
+		end := off + 16
+		if end > len(contents) {
+			end = len(contents)
+		}
+		buf := make([]byte, end-off)
+		n, err := item.ReadAt(buf, int64(off))
+		require.NoError(t, err)
+		assert.Equal(t, end-off, n)
+		assert.Equal(t, contents[off:end], string(buf))
+		assert.LessOrEqual(t, item.getDiskSize(), int64(48))
+		assert.True(t, item.HasRange(ranges.Range{Pos: int64(off), Size: int64(end - off)}))
+	}
+
+	// The whole file can still be read
+	checkItemRead(t, item, contents)
+	assert.Equal(t, int64(100), item.getDiskSize())
+	require.NoError(t, item.Close(nil))
+
+	// The cleaner brings it back under the limit
+	c.purgeOverFileSize(16)
+	assert.LessOrEqual(t, item.getDiskSize(), int64(48))
+}
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rclone/rclone/fs"
@@ -47,20 +48,28 @@ type Cache struct {
 	opt        *vfscommon.Options   // vfs Options
 	root       string               // root of the cache directory
 	metaRoot   string               // root of the cache metadata directory
+	lockRoot   string               // root of the cache lock files if shared
 	hashType   hash.Type            // hash to use locally and remotely
 	hashOption *fs.HashesOption     // corresponding OpenOption
 	writeback  *writeback.WriteBack // holds Items for writeback
 	avFn       AddVirtualFn         // if set, can be called to add dir entries
 
-	mu            sync.Mutex       // protects the following variables
-	cond          sync.Cond        // cond lock for synchronous cache cleaning
-	item          map[string]*Item // files/directories in the cache
-	errItems      map[string]error // items in error state
-	used          int64            // total size of files in the cache
-	outOfSpace    bool             // out of space
-	cleanerKicked bool             // some thread kicked the cleaner upon out of space
-	kickerMu      sync.Mutex       // mutex for cleanerKicked
-	kick          chan struct{}    // channel for kicking clear to start
+	offline     atomic.Bool // set while the remote is unreachable
+	noPunchHole atomic.Bool // set if the cache can't punch holes in files
+
+	conflictsMu sync.Mutex // protects conflicts
+	conflicts   []Conflict // files changed in the cache and on the remote
+
+	mu            sync.Mutex          // protects the following variables
+	cond          sync.Cond           // cond lock for synchronous cache cleaning
+	item          map[string]*Item    // files/directories in the cache
+	errItems      map[string]error    // items in error state
+	used          int64               // total size of files in the cache
+	outOfSpace    bool                // out of space
+	cleanerKicked bool                // some thread kicked the cleaner upon out of space
+	kickerMu      sync.Mutex          // mutex for cleanerKicked
+	kick          chan struct{}       // channel for kicking clear to start
+	sharedSeen    map[string]metaStat // metadata files found by the last rescan of a shared cache
 
 }
 
@@ -129,6 +138,20 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 		avFn:       avFn,
 	}
 
+	if (opt.CacheBlockSize > 0 || opt.CacheMaxFileSize > 0) && !file.PunchHoleImplemented {
+		fs.Logf(nil, "vfs cache: evicting blocks isn't supported on this OS - ignoring --vfs-cache-block-size and --vfs-cache-max-file-size")
+	}
+
+	if opt.CacheShared {
+		if !file.LockImplemented {
+			fs.Logf(nil, "vfs cache: sharing the cache isn't supported on this OS - ignoring --vfs-cache-shared")
+		} else if c.lockRoot, err = createRootDir(parentOSPath, "vfsLock", relativeDirOSPath); err != nil {
+			return nil, fmt.Errorf("failed to create lock cache directory: %w", err)
+		} else {
+			fs.Debugf(nil, "vfs cache: lock root is %q", c.lockRoot)
+		}
+	}
+
 	// load in the cache and metadata off disk
 	err = c.reload(ctx)
 	if err != nil {
@@ -166,6 +189,28 @@ func (c *Cache) Stats() (out rc.Params) {
 	out["erroredFiles"] = len(c.errItems)
 	out["bytesUsed"] = c.used
 	out["outOfSpace"] = c.outOfSpace
+	out["offline"] = c.offline.Load()
+	out["conflicts"] = len(c.Conflicts())
+
+	var pinnedFiles, pinnedBytes, pinnedPartial, pinnedDirty int64
+	for _, item := range c.item {
+		item.mu.Lock()
+		if item.info.Pinned {
+			pinnedFiles++
+			pinnedBytes += item.info.Rs.Size()
+			if !item._present() {
+				pinnedPartial++
+			}
+			if item.info.Dirty {
+				pinnedDirty++
+			}
+		}
+		item.mu.Unlock()
+	}
+	out["pinnedFiles"] = pinnedFiles
+	out["pinnedBytes"] = pinnedBytes
+	out["pinnedPartial"] = pinnedPartial
+	out["pinnedDirty"] = pinnedDirty
 
 	return out
 }
@@ -348,6 +393,30 @@ func (c *Cache) Item(name string) (item *Item) {
 	return item
 }
 
+// Pin marks name as pinned in the cache and downloads it completely
+// from o so it is kept in the cache.
+//
+// name should be a remote path not an osPath
+func (c *Cache) Pin(name string, o fs.Object) error {
+	return c.Item(name).Pin(o)
+}
+
+// Prefetch downloads the object o into the cache as name so it can
+// be read from the cache later. It stops early if ctx is cancelled.
+//
+// name should be a remote path not an osPath
+func (c *Cache) Prefetch(ctx context.Context, name string, o fs.Object) error {
+	return c.Item(name).Prefetch(ctx, o)
+}
+
+// Unpin clears the pinned mark on name so it can be removed from the
+// cache as normal.
+//
+// name should be a remote path not an osPath
+func (c *Cache) Unpin(name string) error {
+	return c.Item(name).Unpin()
+}
+
 // Exists checks to see if the file exists in the cache or not.
 //
 // This is done by bringing the item into the cache which will
@@ -487,6 +556,11 @@ func (c *Cache) SetModTime(name string, modTime time.Time) {
 func (c *Cache) CleanUp() error {
 	err1 := os.RemoveAll(c.root)
 	err2 := os.RemoveAll(c.metaRoot)
+	if c.lockRoot != "" {
+		if err := os.RemoveAll(c.lockRoot); err != nil && err2 == nil {
+			err2 = err
+		}
+	}
 	if err1 != nil {
 		return err1
 	}
@@ -619,9 +693,9 @@ func (c *Cache) purgeClean() {
 
 	var items Items
 
-	// Make a slice of clean cache files
+	// Make a slice of clean cache files which aren't pinned
 	for _, item := range c.item {
-		if !item.IsDirty() {
+		if !item.IsDirty() && !item.IsPinned() {
 			items = append(items, item)
 		}
 	}
@@ -773,6 +847,10 @@ func (c *Cache) clean(kicked bool) {
 	if os.IsNotExist(err) {
 		return
 	}
+	// Find items added or removed by other processes
+	if c.shared() {
+		c.rescan()
+	}
 	c.updateUsed()
 	c.mu.Lock()
 	oldItems, oldUsed := len(c.item), fs.SizeSuffix(c.used)
@@ -781,10 +859,21 @@ func (c *Cache) clean(kicked bool) {
 	// Remove any files that are over age
 	c.purgeOld(time.Duration(c.opt.CacheMaxAge))
 
+	// Remove the oldest blocks of any files which are too big
+	if bs := c.blockSize(); bs > 0 && c.opt.CacheMaxFileSize > 0 {
+		c.purgeOverFileSize(bs)
+	}
+
 	// If have a maximum cache size...
 	if c.haveQuotas() {
-		// Remove files not in use until cache size is below quota starting from the oldest first
-		c.purgeOverQuota()
+		if bs := c.blockSize(); bs > 0 {
+			// Remove the oldest blocks of large files and
+			// small files not in use until cache size is below quota
+			c.purgeBlocksOverQuota(bs)
+		} else {
+			// Remove files not in use until cache size is below quota starting from the oldest first
+			c.purgeOverQuota()
+		}
 
 		// Remove cache files that are not dirty if we are still above the max cache size
 		c.purgeClean()
@@ -857,6 +946,20 @@ func (c *Cache) TotalInUse() (n int) {
 	return n
 }
 
+// SetOffline marks the remote as unreachable or reachable again.
+//
+// While offline, cached files are used without checking them against
+// the remote and uploads are queued until it is back online.
+func (c *Cache) SetOffline(offline bool) {
+	c.offline.Store(offline)
+	c.writeback.SetOffline(offline)
+}
+
+// IsOffline returns true if the remote has been marked as unreachable
+func (c *Cache) IsOffline() bool {
+	return c.offline.Load()
+}
+
 // Dump the cache into a string for debugging purposes
 func (c *Cache) Dump() string {
 	if c == nil {
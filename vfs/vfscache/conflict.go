@@ -0,0 +1,103 @@
+package vfscache
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Conflict records a file which was changed in the cache and on the
+// remote before the cached changes were uploaded.
+type Conflict struct {
+	Name   string    `json:"name"`            // name of the file
+	Saved  string    `json:"saved,omitempty"` // name the remote version was saved as, if it was changed
+	Reason string    `json:"reason"`          // what happened to the remote version
+	Time   time.Time `json:"time"`            // when the conflict was found
+}
+
+// conflictName makes the name the remote version of name is saved as
+// when it conflicts with the cached version.
+//
+// The time is inserted before the extension, eg "dir/file.txt"
+// becomes "dir/file.conflict-20240102-150405.txt".
+func conflictName(name string, t time.Time) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + ".conflict-" + t.Format("20060102-150405") + ext
+}
+
+// addConflict records a conflict
+func (c *Cache) addConflict(conflict Conflict) {
+	if conflict.Saved != "" {
+		fs.Errorf(conflict.Name, "vfs cache: conflict: file %s - saved remote version as %q", conflict.Reason, conflict.Saved)
+	} else {
+		fs.Errorf(conflict.Name, "vfs cache: conflict: file %s", conflict.Reason)
+	}
+	c.conflictsMu.Lock()
+	c.conflicts = append(c.conflicts, conflict)
+	c.conflictsMu.Unlock()
+}
+
+// Conflicts returns the conflicts found when uploading files
+func (c *Cache) Conflicts() []Conflict {
+	c.conflictsMu.Lock()
+	defer c.conflictsMu.Unlock()
+	return append([]Conflict(nil), c.conflicts...)
+}
+
+// ClearConflicts forgets the conflicts found so far
+func (c *Cache) ClearConflicts() {
+	c.conflictsMu.Lock()
+	c.conflicts = nil
+	c.conflictsMu.Unlock()
+}
+
+// _checkConflict checks to see if the remote file has been changed
+// or deleted since the cache file was based on it.
+//
+// If it has been changed then the remote version is saved under a
+// conflict name so the upload doesn't overwrite it. Conflicts are
+// recorded so they can be shown to the user.
+//
+// It returns the object to upload over, which may be nil.
+//
+// call with lock held
+func (item *Item) _checkConflict(ctx context.Context) (o fs.Object, err error) {
+	name, fingerprint := item.name, item.info.Fingerprint
+	var remote fs.Object
+	unlockMutexForCall(&item.mu, func() {
+		remote, err = item.c.fremote.NewObject(ctx, name)
+	})
+	if err == fs.ErrorObjectNotFound {
+		if fingerprint != "" {
+			item.c.addConflict(Conflict{Name: name, Reason: "deleted on remote", Time: time.Now()})
+		}
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("vfs cache: failed to check remote for conflicts: %w", err)
+	}
+	if fs.Fingerprint(ctx, remote, item.c.opt.FastFingerprint) == fingerprint {
+		return remote, nil
+	}
+	now := time.Now()
+	saved := conflictName(name, now)
+	unlockMutexForCall(&item.mu, func() {
+		_, err = operations.Move(ctx, item.c.fremote, nil, saved, remote)
+		if err == nil {
+			err = item.c.AddVirtual(saved, remote.Size(), false)
+			if err != nil {
+				fs.Debugf(saved, "vfs cache: failed to add conflict to directory listing: %v", err)
+				err = nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("vfs cache: failed to save conflicting remote file: %w", err)
+	}
+	item.c.addConflict(Conflict{Name: name, Saved: saved, Reason: "changed on remote", Time: now})
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package vfscache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictName(t *testing.T) {
+	when := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"file.txt", "file.conflict-20240102-150405.txt"},
+		{"dir/file.txt", "dir/file.conflict-20240102-150405.txt"},
+		{"dir/file", "dir/file.conflict-20240102-150405"},
+		{"dir.d/file.tar.gz", "dir.d/file.tar.conflict-20240102-150405.gz"},
+	} {
+		assert.Equal(t, test.want, conflictName(test.in, when), test.in)
+	}
+}
+
+func newConflictTestCache(t *testing.T) (r *fstest.Run, c *Cache) {
+	opt := vfscommon.Opt
+	opt.CachePollInterval = 0
+	opt.WriteBack = 0
+	opt.Offline = true
+	return newTestCacheOpt(t, opt)
+}
+
+// Open the item, read it all into the cache then change it
+func openAndWrite(t *testing.T, item *Item, obj fs.Object) {
+	require.NoError(t, item.Open(obj))
+	buf := make([]byte, obj.Size())
+	_, err := item.ReadAt(buf, 0)
+	require.NoError(t, err)
+	_, err = item.WriteAt([]byte("HELLO"), 0)
+	require.NoError(t, err)
+}
+
+func TestConflictChanged(t *testing.T) {
+	ctx := context.Background()
+	r, c := newConflictTestCache(t)
+
+	contents, obj, item := newFile(t, r, c, "dir/existing.txt")
+	openAndWrite(t, item, obj)
+
+	// Change the file on the remote before the upload
+	r.WriteObject(ctx, "dir/existing.txt", "changed on the remote", time.Now())
+
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "dir/existing.txt", "HELLO"+contents[5:])
+
+	conflicts := c.Conflicts()
+	require.Equal(t, 1, len(conflicts))
+	assert.Equal(t, "dir/existing.txt", conflicts[0].Name)
+	assert.Equal(t, "changed on remote", conflicts[0].Reason)
+	checkObject(t, r, conflicts[0].Saved, "changed on the remote")
+	assert.Equal(t, 1, c.Stats()["conflicts"])
+
+	c.ClearConflicts()
+	assert.Equal(t, 0, len(c.Conflicts()))
+}
+
+func TestConflictDeleted(t *testing.T) {
+	r, c := newConflictTestCache(t)
+
+	contents, obj, item := newFile(t, r, c, "existing")
+	openAndWrite(t, item, obj)
+
+	// Delete the file on the remote before the upload
+	require.NoError(t, obj.Remove(context.Background()))
+
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "existing", "HELLO"+contents[5:])
+
+	conflicts := c.Conflicts()
+	require.Equal(t, 1, len(conflicts))
+	assert.Equal(t, "existing", conflicts[0].Name)
+	assert.Equal(t, "deleted on remote", conflicts[0].Reason)
+	assert.Equal(t, "", conflicts[0].Saved)
+}
+
+func TestConflictUnchanged(t *testing.T) {
+	r, c := newConflictTestCache(t)
+
+	contents, obj, item := newFile(t, r, c, "existing")
+	openAndWrite(t, item, obj)
+	require.NoError(t, item.Close(nil))
+
+	checkObject(t, r, "existing", "HELLO"+contents[5:])
+	assert.Equal(t, 0, len(c.Conflicts()))
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{
+		fstest.NewItem("existing", "HELLO"+contents[5:], time.Now()),
+	}, nil, fs.ModTimeNotSupported)
+}
@@ -0,0 +1,326 @@
+package vfscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/lib/multipart"
+	"github.com/rclone/rclone/lib/ranges"
+	"golang.org/x/sync/errgroup"
+)
+
+// errRemoteChanged is returned by deltaUpload if the remote object
+// isn't the one the cache file was made from
+var errRemoteChanged = errors.New("remote file changed since it was cached")
+
+// _canDeltaUpload returns true if the item can be uploaded by only
+// uploading the parts which have changed with --vfs-delta-upload-cutoff
+//
+// call with lock held
+func (item *Item) _canDeltaUpload() bool {
+	opt := item.c.opt
+	if opt.DeltaUploadCutoff < 0 || item.info.Size <= int64(opt.DeltaUploadCutoff) {
+		return false
+	}
+	// Changes made before the changed parts were recorded need a
+	// full upload, as do changes while offline as the remote may
+	// have been replaced
+	if item.o == nil || len(item.info.Changed) == 0 || opt.Offline {
+		return false
+	}
+	features := item.c.fremote.Features()
+	return features.OpenChunkWriter != nil && features.ChunkWriterCanCopy
+}
+
+// _changed marks the (offset, size) as changed since the file was
+// last uploaded
+//
+// call with lock held
+func (item *Item) _changed(offset, size int64) {
+	if size <= 0 {
+		return
+	}
+	item.info.Changed.Insert(ranges.Range{Pos: offset, Size: size})
+}
+
+// deltaUploadState is the state of an upload by deltaUpload
+type deltaUploadState struct {
+	src     fs.Object           // the cache file
+	old     fs.Object           // the remote object the cache file was made from
+	changed ranges.Ranges       // parts of src changed since it was uploaded
+	present ranges.Ranges       // parts of src present in the cache
+	writer  fs.ChunkWriter      // writer for the new object
+	copier  fs.ChunkCopier      // writer for the new object to copy chunks
+	acc     *accounting.Account // accounting for the upload
+}
+
+// deltaUpload uploads src, the cache file for remote on f, copying
+// the chunks which haven't changed from the remote object
+// server-side so only the changed chunks are uploaded.
+//
+// fingerprint is the fingerprint of the remote object the cache file
+// was made from, and if the remote object doesn't match it then
+// errRemoteChanged is returned. The parts of changed chunks which
+// aren't present in the cache file are read from the remote object.
+//
+// hashes are the hashes of the cache file to set on the new object,
+// or nil if they aren't known. The metadata of the remote object is
+// carried over to the new object.
+func deltaUpload(ctx context.Context, f fs.Fs, remote string, src fs.Object, fingerprint string, fastFingerprint bool, changed, present ranges.Ranges, hashes map[hash.Type]string) (newObj fs.Object, err error) {
+	old, err := f.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		return nil, errRemoteChanged
+	} else if err != nil {
+		return nil, fmt.Errorf("delta upload: failed to find remote object: %w", err)
+	}
+	if fs.Fingerprint(ctx, old, fastFingerprint) != fingerprint {
+		return nil, errRemoteChanged
+	}
+
+	// Keep the metadata of the remote object as it is being
+	// modified rather than replaced
+	meta, err := fs.GetMetadata(ctx, old)
+	if err != nil {
+		return nil, fmt.Errorf("delta upload: failed to read metadata: %w", err)
+	}
+	modTime := src.ModTime(ctx)
+	if meta != nil {
+		meta["mtime"] = modTime.Format(time.RFC3339Nano)
+		var ci *fs.ConfigInfo
+		ctx, ci = fs.AddConfig(ctx)
+		ci.Metadata = true
+	}
+
+	// Don't use src for the upload as it would read the whole of
+	// the cache file to find its hashes
+	size := src.Size()
+	info := object.NewStaticObjectInfo(remote, modTime, size, true, hashes, nil).WithMetadata(meta)
+	chunkInfo, writer, err := f.Features().OpenChunkWriter(ctx, remote, info)
+	if err != nil {
+		return nil, fmt.Errorf("delta upload: failed to open chunk writer: %w", err)
+	}
+
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	uploadedOK := false
+	defer atexit.OnError(&err, func() {
+		cancel()
+		if chunkInfo.LeavePartsOnError || uploadedOK {
+			return
+		}
+		fs.Debugf(remote, "delta upload: cancelling transfer on exit")
+		abortErr := writer.Abort(ctx)
+		if abortErr != nil {
+			fs.Debugf(remote, "delta upload: abort failed: %v", abortErr)
+		}
+	})()
+
+	copier, ok := writer.(fs.ChunkCopier)
+	if !ok {
+		return nil, errors.New("delta upload: chunk writer can't copy chunks")
+	}
+
+	tr := accounting.Stats(ctx).NewTransfer(src, f)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+
+	g, gCtx := errgroup.WithContext(uploadCtx)
+	concurrency := chunkInfo.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	g.SetLimit(concurrency)
+
+	ds := &deltaUploadState{
+		src:     src,
+		old:     old,
+		changed: changed,
+		present: present,
+		writer:  writer,
+		copier:  copier,
+		acc:     tr.Account(gCtx, nil),
+	}
+
+	fs.Debugf(remote, "delta upload: starting with chunks of size %v with %v parallel streams", fs.SizeSuffix(chunkInfo.ChunkSize), concurrency)
+	for chunk := 0; int64(chunk)*chunkInfo.ChunkSize < size; chunk++ {
+		// Fail fast, in case an errgroup managed function returns an error
+		if gCtx.Err() != nil {
+			break
+		}
+		chunk := chunk
+		r := ranges.Range{Pos: int64(chunk) * chunkInfo.ChunkSize, Size: chunkInfo.ChunkSize}
+		r.Clip(size)
+		g.Go(func() error {
+			return ds.uploadChunk(gCtx, chunk, r)
+		})
+	}
+
+	err = g.Wait()
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("delta upload: failed to close object after upload: %w", err)
+	}
+	uploadedOK = true // file is definitely uploaded OK so no need to abort
+
+	newObj, err = f.NewObject(ctx, remote)
+	if err != nil {
+		return nil, fmt.Errorf("delta upload: failed to find object after upload: %w", err)
+	}
+	return newObj, nil
+}
+
+// uploadChunk copies the chunk at r from the old object if it hasn't
+// changed, otherwise uploads it
+func (ds *deltaUploadState) uploadChunk(ctx context.Context, chunk int, r ranges.Range) (err error) {
+	if r.End() <= ds.old.Size() && len(ds.changed.Intersection(r)) == 0 {
+		n, err := ds.copier.CopyChunk(ctx, chunk, ds.old, r.Pos, r.Size)
+		if err != nil {
+			return fmt.Errorf("delta upload: failed to copy chunk %d: %w", chunk+1, err)
+		}
+		ds.acc.ServerSideCopyEnd(n)
+		return nil
+	}
+
+	// Read the chunk into a buffer from the cache file, or from
+	// the old object for the parts not in the cache
+	rw := multipart.NewRW()
+	defer fs.CheckClose(rw, &err)
+	for _, fr := range ds.present.FindAll(r) {
+		in := ds.old
+		if fr.Present {
+			in = ds.src
+		}
+		err = readRange(ctx, rw, in, fr.R)
+		if err != nil {
+			return fmt.Errorf("delta upload: failed to read chunk %d: %w", chunk+1, err)
+		}
+	}
+	rw.SetAccounting(ds.acc.AccountRead)
+
+	_, err = ds.writer.WriteChunk(ctx, chunk, rw)
+	if err != nil {
+		return fmt.Errorf("delta upload: failed to write chunk %d: %w", chunk+1, err)
+	}
+	return nil
+}
+
+// readRange reads the range r of o into out
+func readRange(ctx context.Context, out io.Writer, o fs.Object, r ranges.Range) (err error) {
+	in, err := operations.Open(ctx, o, &fs.RangeOption{Start: r.Pos, End: r.End() - 1})
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	_, err = io.CopyN(out, in, r.Size)
+	return err
+}
+
+// _deltaStore uploads the changed parts of the cache file with
+// deltaUpload, returning the new remote object
+//
+// call with lock held
+func (item *Item) _deltaStore(ctx context.Context, cacheObj fs.Object) (o fs.Object, err error) {
+	var (
+		name        = item.name
+		fingerprint = item.info.Fingerprint
+		changed     = append(ranges.Ranges(nil), item.info.Changed...)
+		present     = append(ranges.Ranges(nil), item.info.Rs...)
+		whole       = item._present()
+	)
+	fs.Infof(name, "vfs cache: uploading %v changed of %v", fs.SizeSuffix(changed.Size()), fs.SizeSuffix(item.info.Size))
+	unlockMutexForCall(&item.mu, func() {
+		var hashes map[hash.Type]string
+		if whole {
+			hashes, err = cacheHashes(ctx, item.c.fremote, cacheObj)
+			if err != nil {
+				return
+			}
+		}
+		o, err = deltaUpload(ctx, item.c.fremote, name, cacheObj, fingerprint, item.c.opt.FastFingerprint, changed, present, hashes)
+	})
+	return o, err
+}
+
+// cacheHashes returns the hashes f supports of the cache file
+// cacheObj which must be wholly present in the cache.
+//
+// This reads the cache file but not the remote, so the hashes of
+// files only partly in the cache aren't set on delta uploads.
+func cacheHashes(ctx context.Context, f fs.Fs, cacheObj fs.Object) (map[hash.Type]string, error) {
+	hashes := make(map[hash.Type]string)
+	for _, ht := range f.Hashes().Array() {
+		sum, err := cacheObj.Hash(ctx, ht)
+		if err == hash.ErrUnsupported {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("delta upload: failed to hash cache file: %w", err)
+		}
+		hashes[ht] = sum
+	}
+	return hashes, nil
+}
+
+// _downloadMissing downloads the parts of the cache file which aren't
+// present from the remote object so the whole file can be uploaded
+// if a delta upload fails.
+//
+// The cache file is opened while downloading if it isn't open
+// already. item.opens is held raised until the downloaders are
+// closed so an Open while the mutex is released uses this file
+// handle rather than trying to create another.
+//
+// call with lock held
+func (item *Item) _downloadMissing(ctx context.Context) (err error) {
+	if item._present() {
+		return nil
+	}
+	item.opens++
+	defer func() {
+		if item.opens == 1 {
+			if dls := item.downloaders; dls != nil {
+				item.downloaders = nil
+				unlockMutexForCall(&item.mu, func() {
+					if closeErr := dls.Close(nil); closeErr != nil && err == nil {
+						err = closeErr
+					}
+				})
+			}
+		}
+		item.opens--
+		if item.opens == 0 && item.fd != nil {
+			if closeErr := item.fd.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+			item.fd = nil
+			// Put back the modtime changed by downloading
+			item._setModTime(item.info.ModTime)
+		}
+		if saveErr := item._save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}()
+	if item.fd == nil {
+		osPath, err := item.c.createItemDir(item.name)
+		if err != nil {
+			return err
+		}
+		err = item._createFile(osPath)
+		if err != nil {
+			return err
+		}
+	}
+	return item._ensure(ctx, 0, item.info.Size)
+}
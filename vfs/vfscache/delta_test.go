@@ -0,0 +1,290 @@
+package vfscache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deltaTestFs wraps an Fs with a chunk writer which can copy chunks
+// and records which chunks were copied and which were written
+type deltaTestFs struct {
+	fs.Fs
+	features *fs.Features
+	copyErr  error // error to return from CopyChunk if set
+	mu       sync.Mutex
+	src      fs.ObjectInfo // src of the last OpenChunkWriter
+	copied   []int
+	written  []int
+}
+
+// deltaTestChunkSize is the chunk size of the deltaTestFs chunk writer
+const deltaTestChunkSize = 16
+
+func (f *deltaTestFs) Features() *fs.Features {
+	return f.features
+}
+
+func (f *deltaTestFs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   deltaTestChunkSize,
+		Concurrency: 2,
+	}
+	f.mu.Lock()
+	f.src = src
+	f.mu.Unlock()
+	return info, &deltaTestChunkWriter{f: f, src: src, chunks: map[int][]byte{}}, nil
+}
+
+// chunks returns the copied and written chunks and resets them
+func (f *deltaTestFs) chunks() (copied, written []int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied, written = f.copied, f.written
+	f.copied, f.written = nil, nil
+	sort.Ints(copied)
+	sort.Ints(written)
+	return copied, written
+}
+
+type deltaTestChunkWriter struct {
+	f      *deltaTestFs
+	src    fs.ObjectInfo
+	mu     sync.Mutex
+	chunks map[int][]byte
+}
+
+func (w *deltaTestChunkWriter) addChunk(chunkNumber int, data []byte, copied bool) {
+	w.mu.Lock()
+	w.chunks[chunkNumber] = data
+	w.mu.Unlock()
+	w.f.mu.Lock()
+	if copied {
+		w.f.copied = append(w.f.copied, chunkNumber)
+	} else {
+		w.f.written = append(w.f.written, chunkNumber)
+	}
+	w.f.mu.Unlock()
+}
+
+func (w *deltaTestChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return -1, err
+	}
+	w.addChunk(chunkNumber, data, false)
+	return int64(len(data)), nil
+}
+
+func (w *deltaTestChunkWriter) CopyChunk(ctx context.Context, chunkNumber int, src fs.Object, offset, size int64) (int64, error) {
+	if w.f.copyErr != nil {
+		return -1, w.f.copyErr
+	}
+	in, err := src.Open(ctx, &fs.RangeOption{Start: offset, End: offset + size - 1})
+	if err != nil {
+		return -1, err
+	}
+	data, err := io.ReadAll(in)
+	_ = in.Close()
+	if err != nil {
+		return -1, err
+	}
+	w.addChunk(chunkNumber, data, true)
+	return int64(len(data)), nil
+}
+
+func (w *deltaTestChunkWriter) Close(ctx context.Context) error {
+	var buf bytes.Buffer
+	for i := 0; i < len(w.chunks); i++ {
+		buf.Write(w.chunks[i])
+	}
+	_, err := w.f.Fs.Put(ctx, &buf, w.src)
+	return err
+}
+
+func (w *deltaTestChunkWriter) Abort(ctx context.Context) error {
+	return nil
+}
+
+var (
+	_ fs.OpenChunkWriter = &deltaTestFs{}
+	_ fs.ChunkCopier     = &deltaTestChunkWriter{}
+)
+
+func newDeltaTestCache(t *testing.T) (r *fstest.Run, f *deltaTestFs, c *Cache) {
+	r = fstest.NewRun(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f = &deltaTestFs{Fs: r.Fremote}
+	f.features = (&fs.Features{ChunkWriterCanCopy: true}).Fill(ctx, f)
+
+	opt := vfscommon.Opt
+	opt.CachePollInterval = 0
+	opt.WriteBack = 0
+	opt.DeltaUploadCutoff = 0
+	c, err := New(ctx, f, &opt, nil)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, c.CleanUp())
+		cancel()
+	})
+	return r, f, c
+}
+
+func TestDeltaUpload(t *testing.T) {
+	r, f, c := newDeltaTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+
+	// Change the middle of the file without reading it
+	require.NoError(t, item.Open(obj))
+	_, err := item.WriteAt([]byte("HELLO"), 40)
+	require.NoError(t, err)
+	assert.Equal(t, ranges.Ranges{{Pos: 40, Size: 5}}, item.info.Changed)
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "existing", contents[:40]+"HELLO"+contents[45:])
+
+	// Only the changed chunk was uploaded and the rest wasn't
+	// downloaded
+	copied, written := f.chunks()
+	assert.Equal(t, []int{0, 1, 3, 4, 5, 6}, copied)
+	assert.Equal(t, []int{2}, written)
+	assert.Equal(t, ranges.Ranges{{Pos: 40, Size: 5}}, item.info.Rs)
+	assert.Nil(t, item.info.Changed)
+	assert.False(t, item.IsDirty())
+
+	// Extend the file with a write past the end
+	obj, err = r.Fremote.NewObject(context.Background(), "existing")
+	require.NoError(t, err)
+	require.NoError(t, item.Open(obj))
+	_, err = item.WriteAt([]byte("END"), 110)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "existing", contents[:40]+"HELLO"+contents[45:]+string(make([]byte, 10))+"END")
+
+	copied, written = f.chunks()
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, copied)
+	assert.Equal(t, []int{6, 7}, written)
+}
+
+func TestDeltaUploadTruncate(t *testing.T) {
+	r, f, c := newDeltaTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+
+	require.NoError(t, item.Open(obj))
+	_, err := item.WriteAt([]byte("HELLO"), 60)
+	require.NoError(t, err)
+	require.NoError(t, item.Truncate(50))
+	assert.Equal(t, ranges.Ranges(nil), item.info.Changed)
+	_, err = item.WriteAt([]byte("HELLO"), 10)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	checkObject(t, r, "existing", contents[:10]+"HELLO"+contents[15:50])
+
+	// The changes past the new end aren't uploaded
+	copied, written := f.chunks()
+	assert.Equal(t, []int{1, 2, 3}, copied)
+	assert.Equal(t, []int{0}, written)
+}
+
+func TestDeltaUploadRemoteChanged(t *testing.T) {
+	ctx := context.Background()
+	r, f, c := newDeltaTestCache(t)
+	_, obj, item := newFile(t, r, c, "existing")
+
+	// If the remote changes the changes can't be uploaded without
+	// the whole file so are kept in the cache
+	require.NoError(t, item.Open(obj))
+	_, err := item.WriteAt([]byte("HELLO"), 0)
+	require.NoError(t, err)
+	r.WriteObject(ctx, "existing", "changed on the remote", time.Now())
+	assert.ErrorIs(t, item.Close(nil), errRemoteChanged)
+	assert.True(t, item.IsDirty())
+	checkObject(t, r, "existing", "changed on the remote")
+
+	// If the whole file is in the cache then it is all uploaded
+	contents, obj, item := newFile(t, r, c, "whole")
+	require.NoError(t, item.Open(obj))
+	checkItemRead(t, item, contents)
+	_, err = item.WriteAt([]byte("HELLO"), 0)
+	require.NoError(t, err)
+	r.WriteObject(ctx, "whole", "changed on the remote", time.Now())
+	require.NoError(t, item.Close(nil))
+	assert.False(t, item.IsDirty())
+	checkObject(t, r, "whole", "HELLO"+contents[5:])
+
+	copied, written := f.chunks()
+	assert.Equal(t, []int(nil), copied)
+	assert.Equal(t, []int(nil), written)
+}
+
+func TestDeltaUploadCopyFails(t *testing.T) {
+	r, f, c := newDeltaTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+	f.copyErr = errors.New("copy not supported")
+
+	// If the chunks can't be copied the rest of the file is
+	// downloaded and the whole file uploaded
+	require.NoError(t, item.Open(obj))
+	_, err := item.WriteAt([]byte("HELLO"), 40)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	assert.False(t, item.IsDirty())
+	assert.True(t, item.present())
+	checkObject(t, r, "existing", contents[:40]+"HELLO"+contents[45:])
+}
+
+func TestDeltaUploadHashMetadata(t *testing.T) {
+	ctx := context.Background()
+	r, f, c := newDeltaTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+	oldMeta, err := fs.GetMetadata(ctx, obj)
+	require.NoError(t, err)
+
+	// Hashes are set if the whole file is in the cache and the
+	// metadata of the remote object is kept
+	require.NoError(t, item.Open(obj))
+	checkItemRead(t, item, contents)
+	_, err = item.WriteAt([]byte("HELLO"), 40)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	newContents := contents[:40] + "HELLO" + contents[45:]
+	checkObject(t, r, "existing", newContents)
+
+	f.mu.Lock()
+	src := f.src
+	f.mu.Unlock()
+	for _, ht := range r.Fremote.Hashes().Array() {
+		want, err := hash.StreamTypes(strings.NewReader(newContents), hash.NewHashSet(ht))
+		require.NoError(t, err)
+		got, err := src.Hash(ctx, ht)
+		require.NoError(t, err)
+		assert.Equal(t, want[ht], got, ht.String())
+	}
+	meta, err := fs.GetMetadata(ctx, src)
+	require.NoError(t, err)
+	if oldMeta == nil {
+		assert.Nil(t, meta)
+	} else {
+		assert.Equal(t, item.info.ModTime.Format(time.RFC3339Nano), meta["mtime"])
+		for k, v := range oldMeta {
+			if k != "mtime" && k != "atime" {
+				assert.Equal(t, v, meta[k], k)
+			}
+		}
+	}
+}
@@ -70,8 +70,9 @@ type Downloaders struct {
 	mu         sync.Mutex
 	dls        []*downloader
 	waiters    []waiter
-	errorCount int   // number of consecutive errors
-	lastErr    error // last error received
+	errorCount int      // number of consecutive errors
+	lastErr    error    // last error received
+	streams    []stream // readers of the file for --vfs-prefetch
 }
 
 // waiter is a range we are waiting for and a channel to signal when
@@ -248,18 +249,22 @@ func (dls *Downloaders) Close(inErr error) (err error) {
 
 // Download the range passed in returning when it has been downloaded
 // with an error from the downloading go routine.
-func (dls *Downloaders) Download(r ranges.Range) (err error) {
+//
+// If ctx is cancelled it stops waiting and returns the context error,
+// though the downloaders carry on until they are closed.
+func (dls *Downloaders) Download(ctx context.Context, r ranges.Range) (err error) {
 	// defer log.Trace(dls.src, "r=%+v", r)("err=%v", &err)
 
 	dls.mu.Lock()
 
-	errChan := make(chan error)
+	// buffered so the waiter can be dispatched after ctx is cancelled
+	errChan := make(chan error, 1)
 	waiter := waiter{
 		r:       r,
 		errChan: errChan,
 	}
 
-	err = dls._ensureDownloader(r)
+	err = dls._ensureDownloader(r, dls._readAhead(r))
 	if err != nil {
 		dls.mu.Unlock()
 		return err
@@ -267,7 +272,22 @@ func (dls *Downloaders) Download(r ranges.Range) (err error) {
 
 	dls.waiters = append(dls.waiters, waiter)
 	dls.mu.Unlock()
-	return <-errChan
+	select {
+	case err = <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop waiting
+	dls.mu.Lock()
+	defer dls.mu.Unlock()
+	for i := range dls.waiters {
+		if dls.waiters[i].errChan == errChan {
+			dls.waiters = append(dls.waiters[:i], dls.waiters[i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
 }
 
 // close any waiters with the error passed in
@@ -281,18 +301,18 @@ func (dls *Downloaders) _closeWaiters(err error) {
 }
 
 // ensure a downloader is running for the range if required.  If one isn't found
-// then it starts it.
+// then it starts it. The range is extended by readAhead.
 //
 // call with lock held
-func (dls *Downloaders) _ensureDownloader(r ranges.Range) (err error) {
+func (dls *Downloaders) _ensureDownloader(r ranges.Range, readAhead int64) (err error) {
 	// defer log.Trace(dls.src, "r=%v", r)("err=%v", &err)
 
 	// The window includes potentially unread data in the buffer
 	window := int64(fs.GetConfig(context.TODO()).BufferSize)
 
 	// Increase the read range by the read ahead if set
-	if dls.opt.ReadAhead > 0 {
-		r.Size += int64(dls.opt.ReadAhead)
+	if readAhead > 0 {
+		r.Size += readAhead
 	}
 
 	// We may be reopening a downloader after a failure here or
@@ -379,7 +399,7 @@ func (dls *Downloaders) _ensureDownloader(r ranges.Range) (err error) {
 func (dls *Downloaders) EnsureDownloader(r ranges.Range) (err error) {
 	dls.mu.Lock()
 	defer dls.mu.Unlock()
-	return dls._ensureDownloader(r)
+	return dls._ensureDownloader(r, dls._readAhead(r))
 }
 
 // _dispatchWaiters() sends any waiters which have completed back to
@@ -422,7 +442,7 @@ func (dls *Downloaders) kickWaiters() (err error) {
 	// However the number of waiters and the number of downloaders
 	// are both expected to be small.
 	for _, waiter := range dls.waiters {
-		err = dls._ensureDownloader(waiter.r)
+		err = dls._ensureDownloader(waiter.r, int64(dls.opt.ReadAhead))
 		if err != nil {
 			// Failures here will be retried by background kicker
 			fs.Errorf(dls.src, "vfs cache: restart download failed: %v", err)
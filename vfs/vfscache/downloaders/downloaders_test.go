@@ -110,12 +110,25 @@ func TestDownloaders(t *testing.T) {
 			{Pos: 500, Size: 250},
 			{Pos: 25000000, Size: 250},
 		} {
-			err := dls.Download(r)
+			err := dls.Download(context.Background(), r)
 			require.NoError(t, err)
 			assert.True(t, item.HasRange(r))
 		}
 	})
 
+	t.Run("DownloadCancel", func(t *testing.T) {
+		_, dls := newTest()
+		defer cancel(dls)
+
+		ctx, cancelCtx := context.WithCancel(context.Background())
+		cancelCtx()
+		err := dls.Download(ctx, ranges.Range{Pos: 0, Size: size})
+		assert.ErrorIs(t, err, context.Canceled)
+		dls.mu.Lock()
+		assert.Equal(t, 0, len(dls.waiters))
+		dls.mu.Unlock()
+	})
+
 	t.Run("EnsureDownloader", func(t *testing.T) {
 		item, dls := newTest()
 		defer cancel(dls)
@@ -128,3 +141,45 @@ func TestDownloaders(t *testing.T) {
 		assert.True(t, item.HasRange(r))
 	})
 }
+
+func TestDownloadersReadAhead(t *testing.T) {
+	opt := vfscommon.Opt
+	opt.ReadAhead = 2 * minAdaptiveReadAhead
+	dls := &Downloaders{opt: &opt}
+	const mib = 1024 * 1024
+	read := func(pos int64) int64 {
+		return dls._readAhead(ranges.Range{Pos: pos, Size: 128 * 1024})
+	}
+
+	// Without --vfs-prefetch always read ahead --vfs-read-ahead
+	opt.Prefetch = vfscommon.PrefetchOff
+	assert.Equal(t, int64(opt.ReadAhead), read(0))
+	assert.Equal(t, int64(opt.ReadAhead), read(100*mib))
+	assert.Equal(t, 0, len(dls.streams))
+
+	// Sequential readers read ahead more each time but never
+	// less than --vfs-read-ahead
+	opt.Prefetch = vfscommon.PrefetchAdaptive
+	assert.Equal(t, int64(0), read(0))
+	assert.Equal(t, int64(2*mib), read(128*1024))
+	assert.Equal(t, int64(2*mib), read(256*1024))
+	assert.Equal(t, int64(4*mib), read(384*1024))
+	for i := 4; i < 20; i++ {
+		read(int64(i) * 128 * 1024)
+	}
+	assert.Equal(t, int64(maxAdaptiveReadAhead), read(20*128*1024))
+
+	// A random read starts a new stream with no read ahead
+	assert.Equal(t, int64(0), read(1000*mib))
+	assert.Equal(t, 2, len(dls.streams))
+
+	// and the first reader carries on where it was
+	assert.Equal(t, int64(maxAdaptiveReadAhead), read(21*128*1024))
+
+	// The oldest streams are forgotten
+	for i := 0; i < maxStreams; i++ {
+		assert.Equal(t, int64(0), read(int64(2000+100*i)*mib))
+	}
+	assert.Equal(t, maxStreams, len(dls.streams))
+	assert.Equal(t, int64(0), read(22*128*1024))
+}
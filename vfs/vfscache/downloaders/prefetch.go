@@ -0,0 +1,82 @@
+package downloaders
+
+import (
+	"time"
+
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+const (
+	// maximum number of readers of a file tracked with --vfs-prefetch
+	maxStreams = 8
+	// read ahead for a reader which has just been seen reading sequentially
+	minAdaptiveReadAhead = 1024 * 1024
+	// maximum read ahead for a sequential reader
+	maxAdaptiveReadAhead = 128 * 1024 * 1024
+	// maximum doublings of the read ahead - must reach maxAdaptiveReadAhead
+	maxAdaptiveShift = 7
+)
+
+// stream is a reader reading the file
+type stream struct {
+	next  int64     // offset the next sequential read will start at
+	reads int       // number of sequential reads seen
+	used  time.Time // time of the last read
+}
+
+// _readAhead works out how much to read ahead of a read of r
+//
+// Without --vfs-prefetch this is --vfs-read-ahead. Otherwise each
+// read is matched to one of the readers of the file. Readers reading
+// sequentially have their read ahead doubled on each read up to
+// maxAdaptiveReadAhead and readers reading randomly get none.
+//
+// Reads within minWindow of where a reader is expected count as
+// sequential as the kernel may issue reads slightly out of order.
+//
+// call with lock held
+func (dls *Downloaders) _readAhead(r ranges.Range) int64 {
+	readAhead := int64(dls.opt.ReadAhead)
+	if dls.opt.Prefetch == vfscommon.PrefetchOff {
+		return readAhead
+	}
+	now := time.Now()
+	oldest := -1
+	for i := range dls.streams {
+		s := &dls.streams[i]
+		if r.Pos >= s.next-minWindow && r.Pos <= s.next+minWindow {
+			if r.End() > s.next {
+				s.next = r.End()
+			}
+			s.reads++
+			s.used = now
+			shift := s.reads - 1
+			if shift > maxAdaptiveShift {
+				shift = maxAdaptiveShift
+			}
+			adaptive := int64(minAdaptiveReadAhead) << shift
+			if adaptive > maxAdaptiveReadAhead {
+				adaptive = maxAdaptiveReadAhead
+			}
+			if adaptive > readAhead {
+				readAhead = adaptive
+			}
+			return readAhead
+		}
+		if oldest < 0 || s.used.Before(dls.streams[oldest].used) {
+			oldest = i
+		}
+	}
+	// Not part of a sequential read so start a new stream
+	s := stream{
+		next: r.End(),
+		used: now,
+	}
+	if len(dls.streams) < maxStreams {
+		dls.streams = append(dls.streams, s)
+	} else {
+		dls.streams[oldest] = s
+	}
+	return 0
+}
@@ -68,16 +68,23 @@ type Item struct {
 	pendingAccesses int                      // number of threads - cache reset not allowed if not zero
 	modified        bool                     // set if the file has been modified since the last Open
 	beingReset      bool                     // cache cleaner is resetting the cache file, access not allowed
+	writers         int                      // number of WriteAt calls writing with the lock released
+	lockFile        *os.File                 // lock file if the cache is shared - may be nil
+	lockType        lockType                 // type of lock held on lockFile
 }
 
 // Info is persisted to backing store
 type Info struct {
-	ModTime     time.Time     // last time file was modified
-	ATime       time.Time     // last time file was accessed
-	Size        int64         // size of the file
-	Rs          ranges.Ranges // which parts of the file are present
-	Fingerprint string        // fingerprint of remote object
-	Dirty       bool          // set if the backing file has been modified
+	ModTime     time.Time           // last time file was modified
+	ATime       time.Time           // last time file was accessed
+	Size        int64               // size of the file
+	Rs          ranges.Ranges       // which parts of the file are present
+	Fingerprint string              // fingerprint of remote object
+	Dirty       bool                // set if the backing file has been modified
+	Pinned      bool                // set if the file should be kept in the cache
+	BlockSize   int64               // size of the blocks in BlockATimes
+	BlockATimes map[int64]time.Time // last time each block was accessed
+	Changed     ranges.Ranges       // parts of the file changed since it was last uploaded
 }
 
 // Items are a slice of *Item ordered by ATime
@@ -94,11 +101,13 @@ const (
 	RemovedNotInUse                         // Item not used. Remove instead of reset
 	ResetFailed                             // Reset failed with an error
 	ResetComplete                           // Reset completed successfully
+	SkippedShared                           // Item in use by another process sharing the cache
 )
 
 func (rr ResetResult) String() string {
 	return [...]string{"Dirty item skipped", "In-access item skipped", "Empty item skipped",
-		"Not-in-use item removed", "Item reset failed", "Item reset completed"}[rr]
+		"Not-in-use item removed", "Item reset failed", "Item reset completed",
+		"Shared item skipped"}[rr]
 }
 
 func (v Items) Len() int      { return len(v) }
@@ -142,7 +151,21 @@ func newItem(c *Cache, name string) (item *Item) {
 	// check the cache file exists
 	osPath := c.toOSPath(name)
 	fi, statErr := os.Stat(osPath)
-	if statErr != nil {
+	if os.IsNotExist(statErr) {
+		if _, err := os.Stat(c.toOSPathMeta(name)); os.IsNotExist(err) {
+			// Nothing cached
+			return item
+		}
+	}
+
+	// Orphans can only be removed if no other process sharing the
+	// cache is using the item
+	canRemove := func() bool {
+		return item._lock(lockExclusive) == nil
+	}
+	defer item._removeUnused()
+
+	if statErr != nil && canRemove() {
 		if os.IsNotExist(statErr) {
 			item._removeMeta("cache file doesn't exist")
 		} else {
@@ -153,8 +176,10 @@ func newItem(c *Cache, name string) (item *Item) {
 	// Try to load the metadata
 	exists, err := item.load()
 	if !exists {
-		item._removeFile("metadata doesn't exist")
-	} else if err != nil {
+		if canRemove() {
+			item._removeFile("metadata doesn't exist")
+		}
+	} else if err != nil && canRemove() {
 		item.remove(fmt.Sprintf("failed to load metadata: %v", err))
 	}
 
@@ -186,6 +211,13 @@ func (item *Item) getDiskSize() int64 {
 func (item *Item) load() (exists bool, err error) {
 	item.mu.Lock()
 	defer item.mu.Unlock()
+	return item._readInfo(&item.info)
+}
+
+// _readInfo reads the item info from the disk into info
+//
+// call with the lock held
+func (item *Item) _readInfo(info *Info) (exists bool, err error) {
 	osPathMeta := item.c.toOSPathMeta(item.name) // No locking in Cache
 	in, err := os.Open(osPathMeta)
 	if err != nil {
@@ -196,7 +228,7 @@ func (item *Item) load() (exists bool, err error) {
 	}
 	defer fs.CheckClose(in, &err)
 	decoder := json.NewDecoder(in)
-	err = decoder.Decode(&item.info)
+	err = decoder.Decode(info)
 	if err != nil {
 		return true, fmt.Errorf("vfs cache item: corrupt metadata: %w", err)
 	}
@@ -207,6 +239,9 @@ func (item *Item) load() (exists bool, err error) {
 //
 // call with the lock held
 func (item *Item) _save() (err error) {
+	if item.c.shared() {
+		return item._saveShared()
+	}
 	osPathMeta := item.c.toOSPathMeta(item.name) // No locking in Cache
 	out, err := os.Create(osPathMeta)
 	if err != nil {
@@ -330,6 +365,11 @@ func (item *Item) Truncate(size int64) (err error) {
 		return errors.New("vfs cache item truncate: internal error: didn't Open file")
 	}
 
+	err = item._lock(lockExclusive)
+	if err != nil {
+		return err
+	}
+
 	// Read old size
 	oldSize, err := item._getSize()
 	if err != nil {
@@ -350,9 +390,11 @@ func (item *Item) Truncate(size int64) (err error) {
 		// read as zeros. In this case we must show we have written to
 		// the new parts of the file.
 		item._written(oldSize, size)
+		item._changed(oldSize, size-oldSize)
 	} else if size < oldSize {
 		// Truncate shrinks the file so clip the downloaded ranges
 		item.info.Rs = item.info.Rs.Intersection(ranges.Range{Pos: 0, Size: size})
+		item.info.Changed = item.info.Changed.Intersection(ranges.Range{Pos: 0, Size: size})
 	} else {
 		changed = item.o == nil
 	}
@@ -427,6 +469,11 @@ func (item *Item) Exists() bool {
 //
 // call with lock held
 func (item *Item) _dirty() {
+	// Dirty items are locked so other processes sharing the
+	// cache can't use them until they are uploaded
+	if err := item._lock(lockExclusive); err != nil {
+		fs.Errorf(item.name, "vfs cache: failed to lock changed item: %v", err)
+	}
 	item.info.ModTime = time.Now()
 	item.info.ATime = item.info.ModTime
 	if !item.modified {
@@ -517,6 +564,14 @@ func (item *Item) open(o fs.Object) (err error) {
 	// defer log.Trace(o, "item=%p", item)("err=%v", &err)
 	item.mu.Lock()
 	defer item.mu.Unlock()
+	defer item._relock()
+
+	if item.opens == 0 {
+		err = item._openShared()
+		if err != nil {
+			return fmt.Errorf("vfs cache item: %w", err)
+		}
+	}
 
 	item.info.ATime = time.Now()
 
@@ -598,9 +653,46 @@ func (item *Item) _store(ctx context.Context, storeFn StoreFn) (err error) {
 	// Object has disappeared if cacheObj == nil
 	if cacheObj != nil {
 		o, name := item.o, item.name
-		unlockMutexForCall(&item.mu, func() {
-			o, err = operations.Copy(ctx, item.c.fremote, o, name, cacheObj)
-		})
+		// Check the remote hasn't been changed while we were offline
+		if item.c.opt.Offline {
+			o, err = item._checkConflict(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		delta := item._canDeltaUpload()
+		if delta {
+			var newObj fs.Object
+			newObj, err = item._deltaStore(ctx, cacheObj)
+			switch {
+			case err == nil:
+				o = newObj
+			case err == errRemoteChanged && item._present():
+				// The whole file is cached so it can still be uploaded
+				fs.Infof(name, "vfs cache: %v - uploading whole file", err)
+				delta = false
+			case err == errRemoteChanged:
+				return fmt.Errorf("vfs cache: failed to upload changed parts of file from cache to remote: %w", err)
+			default:
+				// The remote may not support copying parts so
+				// fetch the rest of the file and upload it all
+				fs.Errorf(name, "vfs cache: failed to upload changed parts of file - uploading whole file: %v", err)
+				err = item._downloadMissing(ctx)
+				if err != nil {
+					return fmt.Errorf("vfs cache: failed to download missing parts of cache file: %w", err)
+				}
+				cacheObj, err = item.c.fcache.NewObject(ctx, name)
+				if err != nil {
+					return fmt.Errorf("vfs cache: failed to find cache file: %w", err)
+				}
+				delta = false
+			}
+		}
+		if !delta {
+			unlockMutexForCall(&item.mu, func() {
+				o, err = operations.Copy(ctx, item.c.fremote, o, name, cacheObj)
+			})
+		}
 		if err != nil {
 			if errors.Is(err, fs.ErrorCantUploadEmptyFiles) {
 				fs.Errorf(name, "Writeback failed: %v", err)
@@ -626,10 +718,12 @@ func (item *Item) _store(ctx context.Context, storeFn StoreFn) (err error) {
 
 	// Show item is clean and is eligible for cache removal
 	item.info.Dirty = false
+	item.info.Changed = nil
 	err = item._save()
 	if err != nil {
 		fs.Errorf(item.name, "vfs cache: failed to write metadata file: %v", err)
 	}
+	item._relock()
 
 	return nil
 }
@@ -662,18 +756,20 @@ func (item *Item) Close(storeFn StoreFn) (err error) {
 	} else if item.opens > 0 {
 		return nil
 	}
+	defer item._relock()
 
 	// Update the size on close
 	_, _ = item._getSize()
 
 	// If the file is dirty ensure any segments not transferred
-	// are brought in first.
+	// are brought in first, unless only the changed parts are
+	// going to be uploaded.
 	//
 	// FIXME It would be nice to do this asynchronously however it
 	// would require keeping the downloaders alive after the item
 	// has been closed
-	if item.info.Dirty && item.o != nil {
-		err = item._ensure(0, item.info.Size)
+	if item.info.Dirty && item.o != nil && !item._canDeltaUpload() {
+		err = item._ensure(context.TODO(), 0, item.info.Size)
 		if err != nil {
 			return fmt.Errorf("vfs cache: failed to download missing parts of cache file: %w", err)
 		}
@@ -763,10 +859,19 @@ func (item *Item) reload(ctx context.Context) error {
 	if !dirty {
 		return nil
 	}
+	// leave items being written by other processes sharing the cache
+	item.mu.Lock()
+	err := item._lock(lockShared)
+	item._unlock()
+	item.mu.Unlock()
+	if err == errItemBusy {
+		fs.Debugf(item.name, "vfs cache: not reloading item as in use by another process")
+		return nil
+	}
 	// see if the object still exists
 	obj, _ := item.c.fremote.NewObject(ctx, item.name)
 	// open the file with the object (or nil)
-	err := item.Open(obj)
+	err = item.Open(obj)
 	if err != nil {
 		return err
 	}
@@ -802,6 +907,9 @@ func (item *Item) _checkObject(o fs.Object) error {
 			// no remote object && local object
 			// remove local object unless dirty
 			if !item.info.Dirty {
+				if err := item._lock(lockExclusive); err != nil {
+					return err
+				}
 				item._remove("stale (remote deleted)")
 			} else {
 				fs.Debugf(item.name, "vfs cache: remote object has gone but local object modified - keeping it")
@@ -810,6 +918,10 @@ func (item *Item) _checkObject(o fs.Object) error {
 			// no remote object && no local object
 			// OK
 		}
+	} else if item.c.IsOffline() && item.info.Fingerprint != "" {
+		// remote object && local object but can't check the
+		// remote so use the local object
+		fs.Debugf(item.name, "vfs cache: remote is offline - using cached entry without checking fingerprint")
 	} else {
 		remoteFingerprint := fs.Fingerprint(context.TODO(), o, item.c.opt.FastFingerprint)
 		fs.Debugf(item.name, "vfs cache: checking remote fingerprint %q against cached fingerprint %q", remoteFingerprint, item.info.Fingerprint)
@@ -818,8 +930,13 @@ func (item *Item) _checkObject(o fs.Object) error {
 			if remoteFingerprint != item.info.Fingerprint {
 				if !item.info.Dirty {
 					fs.Debugf(item.name, "vfs cache: removing cached entry as stale (remote fingerprint %q != cached fingerprint %q)", remoteFingerprint, item.info.Fingerprint)
+					if err := item._lock(lockExclusive); err != nil {
+						return err
+					}
+					pinned := item.info.Pinned
 					item._remove("stale (remote is different)")
 					item.info.Fingerprint = remoteFingerprint
+					item.info.Pinned = pinned
 				} else {
 					fs.Debugf(item.name, "vfs cache: remote object has changed but local object modified - keeping it (remote fingerprint %q != cached fingerprint %q)", remoteFingerprint, item.info.Fingerprint)
 				}
@@ -841,6 +958,75 @@ func (item *Item) _checkObject(o fs.Object) error {
 	return nil
 }
 
+// Pin marks the item as pinned so it is kept in the cache and
+// downloads any parts of the object o which aren't present.
+//
+// o may be nil if the file hasn't been uploaded yet in which case
+// the item is just marked as pinned.
+func (item *Item) Pin(o fs.Object) (err error) {
+	return item.fetch(context.TODO(), o, true)
+}
+
+// Prefetch downloads any parts of the object o which aren't present
+// so it can be read from the cache later.
+//
+// It stops early if ctx is cancelled.
+func (item *Item) Prefetch(ctx context.Context, o fs.Object) (err error) {
+	return item.fetch(ctx, o, false)
+}
+
+// fetch downloads any parts of the object o which aren't present,
+// marking the item as pinned if pin is set.
+func (item *Item) fetch(ctx context.Context, o fs.Object, pin bool) (err error) {
+	err = item.Open(o)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := item.Close(nil)
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	item.preAccess()
+	defer item.postAccess()
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	if pin {
+		item.info.Pinned = true
+	}
+	if o == nil {
+		return nil
+	}
+	err = item._ensure(ctx, 0, item.info.Size)
+	if err != nil {
+		return fmt.Errorf("vfs cache: failed to download file: %w", err)
+	}
+	return nil
+}
+
+// Unpin clears the pinned mark on the item so it can be removed from
+// the cache as normal.
+func (item *Item) Unpin() (err error) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	if !item.info.Pinned {
+		return nil
+	}
+	item.info.Pinned = false
+	if !item._exists() {
+		return nil
+	}
+	return item._save()
+}
+
+// IsPinned returns true if the item is pinned
+func (item *Item) IsPinned() bool {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item.info.Pinned
+}
+
 // WrittenBack checks to see if the item has been written back or not
 func (item *Item) WrittenBack() bool {
 	item.mu.Lock()
@@ -902,7 +1088,25 @@ func (item *Item) _remove(reason string) (wasWriting bool) {
 func (item *Item) remove(reason string) (wasWriting bool) {
 	item.mu.Lock()
 	defer item.mu.Unlock()
-	return item._remove(reason)
+	if err := item._lock(lockExclusive); err != nil {
+		fs.Debugf(item.name, "vfs cache: not removing cache file as %s: %v", reason, err)
+		return false
+	}
+	wasWriting = item._remove(reason)
+	item._removeUnused()
+	return wasWriting
+}
+
+// _removeUnused removes the lock file of a removed item if it isn't
+// open, otherwise changes the lock to the one needed.
+//
+// call with lock held
+func (item *Item) _removeUnused() {
+	if item.opens == 0 {
+		item._removeLockFile()
+	} else {
+		item._relock()
+	}
 }
 
 // RemoveNotInUse is called to remove cache file that has not been accessed recently
@@ -914,7 +1118,7 @@ func (item *Item) RemoveNotInUse(maxAge time.Duration, emptyOnly bool) (removed
 	spaceFreed = 0
 	removed = false
 
-	if item.opens != 0 || item.info.Dirty {
+	if item.opens != 0 || item.info.Dirty || item.info.Pinned {
 		return
 	}
 
@@ -932,12 +1136,13 @@ func (item *Item) RemoveNotInUse(maxAge time.Duration, emptyOnly bool) (removed
 	}
 	if removeIt {
 		spaceUsed := item.info.Rs.Size()
-		if !emptyOnly || spaceUsed == 0 {
+		if (!emptyOnly || spaceUsed == 0) && item._lock(lockExclusive) == nil {
 			spaceFreed = spaceUsed
 			removed = true
 			if item._remove("Removing old cache file not in use") {
 				fs.Errorf(item.name, "item removed when it was writing/uploaded")
 			}
+			item._removeLockFile()
 		}
 	}
 	return
@@ -949,12 +1154,18 @@ func (item *Item) Reset() (rr ResetResult, spaceFreed int64, err error) {
 	item.mu.Lock()
 	defer item.mu.Unlock()
 
+	// do not reset an item another process sharing the cache is using
+	if !item.info.Dirty && item._lock(lockExclusive) != nil {
+		return SkippedShared, 0, nil
+	}
+
 	// The item is not being used now.  Just remove it instead of resetting it.
 	if item.opens == 0 && !item.info.Dirty {
 		spaceFreed = item.info.Rs.Size()
 		if item._remove("Removing old cache file not in use") {
 			fs.Errorf(item.name, "item removed when it was writing/uploaded")
 		}
+		item._removeLockFile()
 		return RemovedNotInUse, spaceFreed, nil
 	}
 
@@ -962,6 +1173,7 @@ func (item *Item) Reset() (rr ResetResult, spaceFreed int64, err error) {
 	if item.info.Dirty {
 		return SkippedDirty, 0, nil
 	}
+	defer item._relock()
 
 	/* A wait on pendingAccessCnt to become 0 can lead to deadlock when an item.Open bumps
 	   up the pendingAccesses count, calls item.open, which calls cache.put. The cache.put
@@ -1133,7 +1345,7 @@ func (item *Item) FindMissing(r ranges.Range) (outr ranges.Range) {
 // ensure the range from offset, size is present in the backing file
 //
 // call with the item lock held
-func (item *Item) _ensure(offset, size int64) (err error) {
+func (item *Item) _ensure(ctx context.Context, offset, size int64) (err error) {
 	// defer log.Trace(item.name, "offset=%d, size=%d", offset, size)("err=%v", &err)
 	if offset+size > item.info.Size {
 		size = item.info.Size - offset
@@ -1164,7 +1376,7 @@ func (item *Item) _ensure(offset, size int64) (err error) {
 		// See: https://github.com/rclone/rclone/issues/6190
 		// See: https://github.com/rclone/rclone/issues/6235
 		if item.o == nil {
-			o, err := item.c.fremote.NewObject(context.Background(), item.name)
+			o, err := item.c.fremote.NewObject(ctx, item.name)
 			if err != nil {
 				return err
 			}
@@ -1172,7 +1384,7 @@ func (item *Item) _ensure(offset, size int64) (err error) {
 		}
 		item.downloaders = downloaders.New(item, item.c.opt, item.name, item.o)
 	}
-	return item.downloaders.Download(r)
+	return item.downloaders.Download(ctx, r)
 }
 
 // _written marks the (offset, size) as present in the backing file
@@ -1188,6 +1400,7 @@ func (item *Item) _ensure(offset, size int64) (err error) {
 func (item *Item) _written(offset, size int64) {
 	// defer log.Trace(item.name, "offset=%d, size=%d", offset, size)("")
 	item.info.Rs.Insert(ranges.Range{Pos: offset, Size: size})
+	item._touchBlocks(offset, size)
 }
 
 // update the fingerprint of the object if any
@@ -1283,9 +1496,23 @@ func (item *Item) readAt(b []byte, off int64) (n int, err error) {
 	}
 	defer item.mu.Unlock()
 
-	err = item._ensure(off, int64(len(b)))
-	if err != nil {
-		return 0, err
+	for {
+		err = item._ensure(context.TODO(), off, int64(len(b)))
+		if err != nil {
+			return 0, err
+		}
+		// Check the blocks weren't evicted while _ensure had
+		// the lock released
+		if item._isPresent(off, int64(len(b))) {
+			break
+		}
+	}
+	item._touchBlocks(off, int64(len(b)))
+	if item.c.opt.CacheMaxFileSize > 0 {
+		_, err = item._evictOverFileSize(item.c.blockSize(), ranges.Range{Pos: off, Size: int64(len(b))})
+		if err != nil {
+			fs.Errorf(item.name, "vfs cache: failed to evict blocks over --vfs-cache-max-file-size: %v", err)
+		}
 	}
 
 	// Check to see if object has shrunk - if so don't read too much.
@@ -1312,6 +1539,12 @@ func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 		item.mu.Unlock()
 		return 0, errors.New("vfs cache item WriteAt: internal error: didn't Open file")
 	}
+	err = item._lock(lockExclusive)
+	if err != nil {
+		item.mu.Unlock()
+		return 0, err
+	}
+	item.writers++
 	item.mu.Unlock()
 	// Do the writing with Item.mu unlocked
 	n, err = item.fd.WriteAt(b, off)
@@ -1319,7 +1552,9 @@ func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 		err = fmt.Errorf("short write: tried to write %d but only %d written", len(b), n)
 	}
 	item.mu.Lock()
+	item.writers--
 	item._written(off, int64(n))
+	item._changed(off, int64(n))
 	if n > 0 {
 		item._dirty()
 	}
@@ -1329,6 +1564,7 @@ func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 	// new parts of the file.
 	if off > item.info.Size {
 		item._written(item.info.Size, off-item.info.Size)
+		item._changed(item.info.Size, off-item.info.Size)
 		item._dirty()
 	}
 	// Update size
@@ -1422,6 +1658,14 @@ func (item *Item) rename(name string, newName string, newObj fs.Object) (err err
 	defer item.postAccess()
 	item.mu.Lock()
 
+	// Lock the item so other processes sharing the cache stop
+	// using it while it is moved
+	busyErr := item._lock(lockExclusive)
+	if busyErr != nil && item.fd != nil {
+		item.mu.Unlock()
+		return fmt.Errorf("vfs cache: can't rename item: %w", busyErr)
+	}
+
 	// stop downloader
 	downloaders := item.downloaders
 	item.downloaders = nil
@@ -1433,13 +1677,26 @@ func (item *Item) rename(name string, newName string, newObj fs.Object) (err err
 	item.name = newName
 	item.o = newObj
 
-	// Rename cache file if it exists
-	err = rename(item.c.toOSPath(name), item.c.toOSPath(newName)) // No locking in Cache
+	if busyErr != nil {
+		// Another process is using the cache files and this
+		// one isn't, so leave them where they are and forget
+		// them. The item will be downloaded again under the
+		// new name if needed.
+		fs.Debugf(name, "vfs cache: not moving cache files as %v - dropping item from this cache", busyErr)
+		item.info.clean()
+	} else {
+		// Rename cache file if it exists
+		err = rename(item.c.toOSPath(name), item.c.toOSPath(newName)) // No locking in Cache
+
+		// Rename meta file if it exists
+		err2 := rename(item.c.toOSPathMeta(name), item.c.toOSPathMeta(newName)) // No locking in Cache
+		if err2 != nil {
+			err = err2
+		}
 
-	// Rename meta file if it exists
-	err2 := rename(item.c.toOSPathMeta(name), item.c.toOSPathMeta(newName)) // No locking in Cache
-	if err2 != nil {
-		err = err2
+		// Move the lock with the item
+		item._renameLock(name)
+		item._relock()
 	}
 
 	item.mu.Unlock()
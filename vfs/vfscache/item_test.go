@@ -294,6 +294,40 @@ func TestItemLoadMeta(t *testing.T) {
 	assert.Equal(t, info, info2)
 }
 
+func TestItemPin(t *testing.T) {
+	r, c := newItemTestCache(t)
+
+	contents, obj, item := newFile(t, r, c, "existing")
+	assert.False(t, item.IsPinned())
+
+	// Pinning downloads the whole file
+	require.NoError(t, c.Pin("existing", obj))
+	assert.True(t, item.IsPinned())
+	assert.True(t, item.present())
+	assert.Equal(t, int64(1), c.Stats()["pinnedFiles"])
+	assert.Equal(t, int64(len(contents)), c.Stats()["pinnedBytes"])
+	assert.Equal(t, int64(0), c.Stats()["pinnedPartial"])
+
+	// The pinned mark is persisted
+	c.mu.Lock()
+	delete(c.item, item.name)
+	c.mu.Unlock()
+	item, _ = c.get("existing")
+	assert.True(t, item.IsPinned())
+
+	// Pinned items aren't removed
+	removed, _ := item.RemoveNotInUse(0, false)
+	assert.False(t, removed)
+	assert.True(t, item.Exists())
+
+	// Unpinned items are
+	require.NoError(t, c.Unpin("existing"))
+	assert.False(t, item.IsPinned())
+	removed, _ = item.RemoveNotInUse(0, false)
+	assert.True(t, removed)
+	assert.False(t, item.Exists())
+}
+
 func TestItemReload(t *testing.T) {
 	r, c := newItemTestCache(t)
 
@@ -0,0 +1,366 @@
+// Sharing the cache between processes
+
+package vfscache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/file"
+)
+
+// lockType is the type of lock an item holds on its lock file when
+// the cache is shared with other processes.
+//
+// A process holds a shared lock while it has the item open and an
+// exclusive lock while the item is dirty or while it is removing,
+// resetting or evicting blocks from the cache file, so no other
+// process can be reading the data being changed.
+type lockType byte
+
+// Types of lock
+const (
+	lockNone      lockType = iota // no lock held
+	lockShared                    // the item is being read
+	lockExclusive                 // the item is being changed
+)
+
+// errItemBusy is returned when an item is in use by another process
+// sharing the cache in a way which stops it being used
+var errItemBusy = errors.New("vfs cache: file is in use by another process sharing the cache")
+
+// shared returns true if the cache is shared with other processes
+func (c *Cache) shared() bool {
+	return c.opt.CacheShared && file.LockImplemented
+}
+
+// toOSPathLock turns a remote relative name into an OS path in the
+// cache for the lock file
+func (c *Cache) toOSPathLock(name string) string {
+	return filepath.Join(c.lockRoot, toOSPath(name))
+}
+
+// metaStat is what rescan remembers about a metadata file to tell
+// whether it has changed
+type metaStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// rescan finds the items which other processes sharing the cache
+// have added, changed or removed so the quotas apply to the whole
+// cache.
+//
+// Only the items whose metadata files have changed since the last
+// rescan are loaded.
+func (c *Cache) rescan() {
+	c.mu.Lock()
+	lastSeen := c.sharedSeen
+	c.mu.Unlock()
+	seen := make(map[string]metaStat, len(lastSeen))
+	err := c.walk(c.metaRoot, func(osPath string, fi os.FileInfo, name string) error {
+		if fi.IsDir() {
+			return nil
+		}
+		stat := metaStat{modTime: fi.ModTime(), size: fi.Size()}
+		seen[name] = stat
+		c.mu.Lock()
+		_, known := c.item[name]
+		c.mu.Unlock()
+		if known && lastSeen[name] == stat {
+			return nil
+		}
+		item, found := c.get(name)
+		if found {
+			item.refreshShared()
+		}
+		return nil
+	})
+	if err != nil {
+		fs.Errorf(nil, "vfs cache: failed to rescan shared cache: %v", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sharedSeen = seen
+	for name, item := range c.item {
+		if _, found := seen[name]; !found && !item.inUse() {
+			delete(c.item, name)
+		}
+	}
+}
+
+// _lock changes the lock the item holds on its lock file to lt
+// without waiting. Changing the type of a lock already held is atomic
+// and leaves the lock unchanged if it fails.
+//
+// It returns errItemBusy if another process holds a conflicting lock
+// and does nothing if the cache isn't shared.
+//
+// call with lock held
+func (item *Item) _lock(lt lockType) error {
+	if !item.c.shared() || item.lockType == lt {
+		return nil
+	}
+	if lt == lockNone {
+		item._unlock()
+		return nil
+	}
+	for {
+		if item.lockFile == nil {
+			osPath := item.c.toOSPathLock(item.name)
+			err := createDir(filepath.Dir(osPath))
+			if err != nil {
+				return fmt.Errorf("vfs cache: failed to create lock directory: %w", err)
+			}
+			item.lockFile, err = file.OpenFile(osPath, os.O_RDWR|os.O_CREATE, 0600)
+			if err != nil {
+				return fmt.Errorf("vfs cache: failed to open lock file: %w", err)
+			}
+		}
+		err := file.TryLock(item.lockFile, lt == lockExclusive)
+		if err != nil {
+			if item.lockType == lockNone {
+				item._unlock()
+			}
+			if err == file.ErrLockBusy {
+				return errItemBusy
+			}
+			return fmt.Errorf("vfs cache: failed to lock cache item: %w", err)
+		}
+		// Check the lock file wasn't removed by another process
+		// before it was locked, otherwise lock the new one
+		if item._lockFileValid() {
+			item.lockType = lt
+			return nil
+		}
+		item._unlock()
+	}
+}
+
+// _lockFileValid returns true if the open lock file is the one at
+// the item's lock path
+//
+// call with lock held
+func (item *Item) _lockFileValid() bool {
+	fi, err := item.lockFile.Stat()
+	if err != nil {
+		return false
+	}
+	pathFi, err := os.Stat(item.c.toOSPathLock(item.name))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, pathFi)
+}
+
+// _unlock releases any lock held on the lock file and closes it
+//
+// call with lock held
+func (item *Item) _unlock() {
+	if item.lockFile == nil {
+		return
+	}
+	// Closing the file releases the lock
+	err := item.lockFile.Close()
+	if err != nil {
+		fs.Errorf(item.name, "vfs cache: failed to close lock file: %v", err)
+	}
+	item.lockFile = nil
+	item.lockType = lockNone
+}
+
+// _removeLockFile removes the lock file after the item has been
+// removed from the cache and releases the lock
+//
+// call with lock held
+func (item *Item) _removeLockFile() {
+	if item.lockType != lockExclusive {
+		item._unlock()
+		return
+	}
+	err := os.Remove(item.c.toOSPathLock(item.name))
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(item.name, "vfs cache: failed to remove lock file: %v", err)
+	}
+	item._unlock()
+}
+
+// _relock changes the lock held on the lock file to the one needed
+// for the current state of the item, after it has been changed under
+// an exclusive lock.
+//
+// call with lock held
+func (item *Item) _relock() {
+	lt := lockNone
+	if item.info.Dirty {
+		lt = lockExclusive
+	} else if item.opens > 0 {
+		lt = lockShared
+	}
+	if lt == item.lockType {
+		return
+	}
+	// Releasing or downgrading a lock can't conflict
+	if err := item._lock(lt); err != nil {
+		fs.Errorf(item.name, "vfs cache: failed to change lock: %v", err)
+	}
+}
+
+// _renameLock moves the lock of the item to its new name after it
+// has been renamed from oldName
+//
+// call with lock held
+func (item *Item) _renameLock(oldName string) {
+	if !item.c.shared() || item.lockFile == nil {
+		return
+	}
+	lt := item.lockType
+	if lt == lockExclusive {
+		// Nothing else can be using the lock file so move it
+		// with the item
+		err := rename(item.c.toOSPathLock(oldName), item.c.toOSPathLock(item.name))
+		if err == nil {
+			return
+		}
+		fs.Errorf(item.name, "vfs cache: failed to rename lock file: %v", err)
+	}
+	item._unlock()
+	if err := item._lock(lt); err != nil {
+		fs.Errorf(item.name, "vfs cache: failed to lock renamed item: %v", err)
+	}
+}
+
+// _openShared locks the item when it is first opened and loads the
+// metadata other processes sharing the cache may have changed.
+//
+// An item left dirty by a process which has gone away is taken over
+// so it gets uploaded.
+//
+// call with lock held
+func (item *Item) _openShared() error {
+	if !item.c.shared() || item.lockType != lockNone {
+		return nil
+	}
+	err := item._lock(lockShared)
+	if err != nil {
+		return err
+	}
+	exists, err := item._readInfo(&item.info)
+	if err != nil && exists {
+		item._unlock()
+		return err
+	}
+	if !exists {
+		item.info.clean()
+	}
+	if item.info.Dirty {
+		err = item._lock(lockExclusive)
+		if err != nil {
+			item._unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshShared reloads the info of an item which isn't in use in
+// this process as other processes sharing the cache may have changed
+// it.
+func (item *Item) refreshShared() {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	if item.opens != 0 || item.lockType != lockNone {
+		return
+	}
+	var info Info
+	exists, err := item._readInfo(&info)
+	if !exists {
+		item.info.clean()
+	} else if err == nil {
+		item.info = info
+	}
+}
+
+// _mergeShared prepares the info for saving when the cache is shared
+// with other processes, returning false if it shouldn't be saved.
+//
+// Other processes reading the same version of the file may have
+// saved ranges which they downloaded so these are added to the info.
+// If the item isn't open in this process then the info may be out of
+// date, so if another process has changed the file the info is
+// replaced with theirs and not saved.
+//
+// call with lock held
+func (item *Item) _mergeShared() (save bool) {
+	if item.lockType == lockExclusive {
+		return true
+	}
+	var disk Info
+	exists, err := item._readInfo(&disk)
+	if !exists {
+		// Removed by another process unless being created
+		return item.fd != nil
+	} else if err != nil {
+		return true
+	}
+	sameVersion := disk.Fingerprint == item.info.Fingerprint && disk.Size == item.info.Size && !disk.Dirty
+	if item.fd == nil && !sameVersion {
+		item.info = disk
+		return false
+	}
+	if sameVersion {
+		for _, r := range disk.Rs {
+			item.info.Rs.Insert(r)
+		}
+	}
+	return true
+}
+
+// _saveShared writes the item info to disk when the cache is shared
+// with other processes.
+//
+// The info is written to a temporary file then renamed so other
+// processes never read a partially written file.
+//
+// call with lock held
+func (item *Item) _saveShared() (err error) {
+	if item.lockType == lockNone {
+		err = item._lock(lockShared)
+		if err == errItemBusy {
+			fs.Debugf(item.name, "vfs cache: not saving metadata as being changed by another process")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer item._unlock()
+	}
+	if !item._mergeShared() {
+		return nil
+	}
+	out, err := os.CreateTemp(item.c.lockRoot, ".meta-*")
+	if err != nil {
+		return fmt.Errorf("vfs cache item: failed to write metadata: %w", err)
+	}
+	tmpPath := out.Name()
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(item.info)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, item.c.toOSPathMeta(item.name))
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("vfs cache item: failed to write metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+//go:build unix
+
+package vfscache
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/file"
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSharedTestCache(t *testing.T) (r *fstest.Run, c *Cache) {
+	opt := vfscommon.Opt
+	opt.CachePollInterval = 0
+	opt.WriteBack = 0
+	opt.CacheShared = true
+	return newTestCacheOpt(t, opt)
+}
+
+// TestSharedLockHelper holds a lock on a cache item in a separate
+// process as a process never conflicts with its own locks
+func TestSharedLockHelper(t *testing.T) {
+	path := os.Getenv("RCLONE_TEST_LOCK_FILE")
+	if path == "" {
+		t.Skip("only run as a helper for the shared cache tests")
+	}
+	require.NoError(t, createDir(filepath.Dir(path)))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	require.NoError(t, file.Lock(f, os.Getenv("RCLONE_TEST_LOCK_EXCLUSIVE") != ""))
+	_, _ = os.Stdout.WriteString("locked\n")
+	_, _ = io.Copy(io.Discard, os.Stdin)
+	require.NoError(t, f.Close())
+}
+
+// holdItemLock locks the item name as another process sharing the
+// cache would until the returned function is called
+func holdItemLock(t *testing.T, c *Cache, name string, exclusive bool) (release func()) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSharedLockHelper$")
+	cmd.Env = append(os.Environ(), "RCLONE_TEST_LOCK_FILE="+c.toOSPathLock(name))
+	if exclusive {
+		cmd.Env = append(cmd.Env, "RCLONE_TEST_LOCK_EXCLUSIVE=1")
+	}
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && scanner.Text() != "locked" {
+	}
+	return func() {
+		require.NoError(t, stdin.Close())
+		require.NoError(t, cmd.Wait())
+	}
+}
+
+func TestSharedOpen(t *testing.T) {
+	r, c := newSharedTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+
+	// Can't open an item another process is changing
+	release := holdItemLock(t, c, "existing", true)
+	assert.ErrorIs(t, item.Open(obj), errItemBusy)
+	release()
+
+	// But can open it when another process is reading it
+	release = holdItemLock(t, c, "existing", false)
+	require.NoError(t, item.Open(obj))
+	assert.Equal(t, lockShared, item.lockType)
+	checkItemRead(t, item, contents)
+
+	// Can't write to it while the other process is reading it
+	_, err := item.WriteAt([]byte("HELLO"), 0)
+	assert.ErrorIs(t, err, errItemBusy)
+	assert.ErrorIs(t, item.Truncate(10), errItemBusy)
+	release()
+
+	// Writing locks the item until it is uploaded
+	_, err = item.WriteAt([]byte("HELLO"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, lockExclusive, item.lockType)
+	require.NoError(t, item.Close(nil))
+	assert.Equal(t, lockNone, item.lockType)
+	checkObject(t, r, "existing", "HELLO"+contents[5:])
+}
+
+func TestSharedRemove(t *testing.T) {
+	r, c := newSharedTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+	require.NoError(t, item.Open(obj))
+	checkItemRead(t, item, contents)
+	require.NoError(t, item.Close(nil))
+	osPathLock := c.toOSPathLock("existing")
+
+	// Can't remove or reset an item another process is reading
+	release := holdItemLock(t, c, "existing", false)
+	removed, _ := item.RemoveNotInUse(0, false)
+	assert.False(t, removed)
+	rr, _, err := item.Reset()
+	require.NoError(t, err)
+	assert.Equal(t, SkippedShared, rr)
+	assert.False(t, c.Remove("existing"))
+	assert.True(t, item.Exists())
+	release()
+
+	// Once it has finished the item can be removed
+	removed, spaceFreed := item.RemoveNotInUse(0, false)
+	assert.True(t, removed)
+	assert.Equal(t, int64(100), spaceFreed)
+	assert.False(t, item.Exists())
+	assertPathNotExist(t, osPathLock)
+}
+
+func TestSharedCaches(t *testing.T) {
+	r, c := newSharedTestCache(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Make another cache on the same cache directory
+	opt := *c.opt
+	other, err := New(ctx, r.Fremote, &opt, nil)
+	require.NoError(t, err)
+
+	// Download part of a file with the first cache
+	contents, obj, item := newFile(t, r, c, "existing")
+	require.NoError(t, item.Open(obj))
+	buf := make([]byte, 10)
+	_, err = item.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+
+	// The other cache finds it when it rescans
+	other.rescan()
+	assert.Equal(t, []string{`name="existing" opens=0 size=100`}, itemAsString(other))
+
+	// The other cache downloads the rest of the file
+	otherItem, found := other.get("existing")
+	require.True(t, found)
+	require.NoError(t, otherItem.Open(obj))
+	assert.True(t, otherItem.HasRange(ranges.Range{Pos: 0, Size: 10}))
+	checkItemRead(t, otherItem, contents)
+
+	// Meanwhile the first cache reads the start again merging
+	// the ranges when it saves its metadata
+	require.NoError(t, item.Open(obj))
+	require.NoError(t, otherItem.Close(nil))
+	require.NoError(t, item.Close(nil))
+	assert.Equal(t, ranges.Ranges{{Pos: 0, Size: 100}}, item.info.Rs)
+
+	// Removing the file from one cache removes it from the other
+	assert.False(t, c.Remove("existing"))
+	other.rescan()
+	assert.Equal(t, []string(nil), itemAsString(other))
+}
+
+func TestSharedRename(t *testing.T) {
+	r, c := newSharedTestCache(t)
+	contents, obj, item := newFile(t, r, c, "existing")
+	require.NoError(t, item.Open(obj))
+	checkItemRead(t, item, contents)
+
+	// Can't rename an item open in this process while another
+	// process is reading it
+	release := holdItemLock(t, c, "existing", false)
+	assert.ErrorIs(t, c.Rename("existing", "renamed", obj), errItemBusy)
+	assert.True(t, c.Exists("existing"))
+	require.NoError(t, item.Close(nil))
+
+	// If it isn't open in this process the cache files are left
+	// for the other process and the item is dropped from this one
+	require.NoError(t, c.Rename("existing", "renamed", obj))
+	release()
+	assertPathExist(t, c.toOSPath("existing"))
+	assertPathNotExist(t, c.toOSPath("renamed"))
+	assert.Equal(t, "renamed", item.name)
+	assert.Equal(t, int64(0), item.info.Size)
+}
+
+func TestSharedRescanUnchanged(t *testing.T) {
+	r, c := newSharedTestCache(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opt := *c.opt
+	other, err := New(ctx, r.Fremote, &opt, nil)
+	require.NoError(t, err)
+
+	contents, obj, item := newFile(t, r, c, "existing")
+	require.NoError(t, item.Open(obj))
+	_, err = item.ReadAt(make([]byte, 10), 0)
+	require.NoError(t, err)
+	require.NoError(t, item.Close(nil))
+	other.rescan()
+	otherItem, found := other.get("existing")
+	require.True(t, found)
+	require.NotEmpty(t, otherItem.info.Rs)
+
+	// Items whose metadata hasn't changed aren't reloaded
+	otherItem.mu.Lock()
+	otherItem.info.Rs = nil
+	otherItem.mu.Unlock()
+	other.rescan()
+	assert.Equal(t, ranges.Ranges(nil), otherItem.info.Rs)
+
+	// But are when it changes
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, item.Open(obj))
+	checkItemRead(t, item, contents)
+	require.NoError(t, item.Close(nil))
+	other.rescan()
+	assert.Equal(t, ranges.Ranges{{Pos: 0, Size: 100}}, otherItem.info.Rs)
+}
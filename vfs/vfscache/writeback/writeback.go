@@ -37,6 +37,7 @@ type WriteBack struct {
 	timer   *time.Timer               // next scheduled time for the uploader
 	expiry  time.Time                 // time the next item expires or IsZero
 	uploads int                       // number of uploads in progress
+	offline bool                      // set if uploads are paused because the remote is offline
 }
 
 // New make a new WriteBack
@@ -431,6 +432,11 @@ func (wb *WriteBack) processItems(ctx context.Context) {
 		return
 	}
 
+	// Leave the items queued until the remote comes back online
+	if wb.offline {
+		return
+	}
+
 	resetTimer := true
 	for wbItem := wb._peekItem(); wbItem != nil && time.Until(wbItem.expiry) <= 0; wbItem = wb._peekItem() {
 		// If reached transfer limit don't restart the timer
@@ -457,6 +463,29 @@ func (wb *WriteBack) processItems(ctx context.Context) {
 	}
 }
 
+// SetOffline pauses uploads while offline is set. When it is cleared
+// all the queued uploads are started straight away.
+func (wb *WriteBack) SetOffline(offline bool) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if wb.offline == offline {
+		return
+	}
+	wb.offline = offline
+	if offline {
+		wb._stopTimer()
+		return
+	}
+	// Copy the items as updating them reorders the heap
+	items := append(writeBackItems(nil), wb.items...)
+	now := time.Now()
+	for _, wbItem := range items {
+		wbItem.delay = time.Duration(wb.opt.WriteBack)
+		wb.items._update(wbItem, now)
+	}
+	wb._resetTimer()
+}
+
 // Stats return the number of uploads in progress and queued
 func (wb *WriteBack) Stats() (uploadsInProgress, uploadsQueued int) {
 	wb.mu.Lock()
@@ -347,6 +347,33 @@ func TestWriteBackAddFailRetry(t *testing.T) {
 	checkNotInLookup(t, wb, wbItem)
 }
 
+// Test uploads are paused while offline
+func TestWriteBackOffline(t *testing.T) {
+	wb, cancel := newTestWriteBack(t)
+	defer cancel()
+
+	pi := newPutItem(t)
+
+	wb.SetOffline(true)
+	id := wb.Add(0, "one", true, pi.put)
+	wbItem := wb.lookup[id]
+
+	// wait for longer than the writeback delay
+	time.Sleep(2 * time.Duration(wb.opt.WriteBack))
+	checkOnHeap(t, wb, wbItem)
+	checkInLookup(t, wb, wbItem)
+
+	wb.SetOffline(false)
+	<-pi.started
+	checkNotOnHeap(t, wb, wbItem)
+	checkInLookup(t, wb, wbItem)
+
+	pi.finish(nil) // transfer successful
+	waitUntilNoTransfers(t, wb)
+	checkNotOnHeap(t, wb, wbItem)
+	checkNotInLookup(t, wb, wbItem)
+}
+
 // Now test the upload being cancelled by another upload being added
 func TestWriteBackAddUpdate(t *testing.T) {
 	wb, cancel := newTestWriteBack(t)
@@ -0,0 +1,30 @@
+package vfscommon
+
+import (
+	"github.com/rclone/rclone/fs"
+)
+
+type locksChoices struct{}
+
+func (locksChoices) Choices() []string {
+	return []string{
+		LocksOff:    "off",
+		LocksLocal:  "local",
+		LocksRemote: "remote",
+	}
+}
+
+// Locks controls how advisory file locks are supported
+type Locks = fs.Enum[locksChoices]
+
+// Locks options
+const (
+	LocksOff    Locks = iota // file locks are not supported
+	LocksLocal               // file locks are kept within this VFS only
+	LocksRemote              // file locks are also stored on the remote to coordinate with other mounts
+)
+
+// Type of the value
+func (locksChoices) Type() string {
+	return "Locks"
+}
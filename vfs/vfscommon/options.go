@@ -34,6 +34,11 @@ var OptionsInfo = fs.Options{{
 	Default: false,
 	Help:    "Refreshes the directory cache recursively in the background on start",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_dir_cache_persist",
+	Default: false,
+	Help:    "Save the directory cache to disk and load it on start",
+	Groups:  "VFS",
 }, {
 	Name:    "poll_interval",
 	Default: fs.Duration(time.Minute),
@@ -64,6 +69,26 @@ var OptionsInfo = fs.Options{{
 	Default: fs.SizeSuffix(-1),
 	Help:    "Max total size of objects in the cache",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_cache_max_file_size",
+	Default: fs.SizeSuffix(-1),
+	Help:    "Max size of a single file in the cache - cold blocks are evicted beyond this",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_cache_block_size",
+	Default: fs.SizeSuffix(0),
+	Help:    "Evict cold blocks of this size from large files instead of whole files (0 to disable)",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_cache_shared",
+	Default: false,
+	Help:    "Share the cache directory with other rclone processes using the same remote",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_delta_upload_cutoff",
+	Default: fs.SizeSuffix(-1),
+	Help:    "Upload only the changed parts of files bigger than this if the backend supports it",
+	Groups:  "VFS",
 }, {
 	Name:    "vfs_cache_min_free_space",
 	Default: fs.SizeSuffix(-1),
@@ -119,6 +144,21 @@ var OptionsInfo = fs.Options{{
 	Default: 0 * fs.Mebi,
 	Help:    "Extra read ahead over --buffer-size when using cache-mode full",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_prefetch",
+	Default: PrefetchOff,
+	Help:    "Prefetch policy off|adaptive|files when using cache-mode full",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_prefetch_files",
+	Default: 2,
+	Help:    "Number of following files in a directory to prefetch with --vfs-prefetch files",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_prefetch_max_size",
+	Default: 1 * fs.Gibi,
+	Help:    "Don't prefetch files bigger than this with --vfs-prefetch files",
+	Groups:  "VFS",
 }, {
 	Name:    "vfs_used_is_size",
 	Default: false,
@@ -129,6 +169,21 @@ var OptionsInfo = fs.Options{{
 	Default: false,
 	Help:    "Use fast (less accurate) fingerprints for change detection",
 	Groups:  "VFS",
+}, {
+	Name:    "vfs_links",
+	Default: false,
+	Help:    "Translate symlinks to/from regular files with a '.rclonelink' extension for the VFS",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_offline",
+	Default: false,
+	Help:    "Serve files from the cache and queue uploads while the remote is unreachable",
+	Groups:  "VFS",
+}, {
+	Name:    "vfs_locks",
+	Default: LocksOff,
+	Help:    "Support for advisory file locks off|local|remote",
+	Groups:  "VFS",
 }, {
 	Name:    "vfs_disk_space_total_size",
 	Default: fs.SizeSuffix(-1),
@@ -185,6 +240,17 @@ type Options struct {
 	UsedIsSize         bool          `config:"vfs_used_is_size"`     // if true, use the `rclone size` algorithm for Used size
 	FastFingerprint    bool          `config:"vfs_fast_fingerprint"` // if set use fast fingerprints
 	DiskSpaceTotalSize fs.SizeSuffix `config:"vfs_disk_space_total_size"`
+	Links              bool          `config:"vfs_links"`               // translate .rclonelink files to symlinks
+	DirCachePersist    bool          `config:"vfs_dir_cache_persist"`   // save the directory cache to disk and load it on start
+	Offline            bool          `config:"vfs_offline"`             // serve from the cache while the remote is unreachable
+	CacheMaxFileSize   fs.SizeSuffix `config:"vfs_cache_max_file_size"` // max cache space used by a single file
+	CacheBlockSize     fs.SizeSuffix `config:"vfs_cache_block_size"`    // size of blocks evicted from large files
+	Prefetch           Prefetch      `config:"vfs_prefetch"`            // how to read ahead of readers
+	PrefetchFiles      int           `config:"vfs_prefetch_files"`      // number of files to prefetch with --vfs-prefetch files
+	PrefetchMaxSize    fs.SizeSuffix `config:"vfs_prefetch_max_size"`   // don't prefetch files bigger than this
+	Locks              Locks         `config:"vfs_locks"`               // how advisory file locks are supported
+	CacheShared        bool          `config:"vfs_cache_shared"`        // share the cache with other processes
+	DeltaUploadCutoff  fs.SizeSuffix `config:"vfs_delta_upload_cutoff"` // upload only the changed parts of files bigger than this
 }
 
 // Opt is the default options modified by the environment variables and command line flags
@@ -0,0 +1,30 @@
+package vfscommon
+
+import (
+	"github.com/rclone/rclone/fs"
+)
+
+type prefetchChoices struct{}
+
+func (prefetchChoices) Choices() []string {
+	return []string{
+		PrefetchOff:      "off",
+		PrefetchAdaptive: "adaptive",
+		PrefetchFiles:    "files",
+	}
+}
+
+// Prefetch controls how much data is read ahead of the reader
+type Prefetch = fs.Enum[prefetchChoices]
+
+// Prefetch options
+const (
+	PrefetchOff      Prefetch = iota // read ahead by --vfs-read-ahead only
+	PrefetchAdaptive                 // read ahead more for sequential readers and none for random readers
+	PrefetchFiles                    // as adaptive and fetch the next files in directories read in order
+)
+
+// Type of the value
+func (prefetchChoices) Type() string {
+	return "Prefetch"
+}
@@ -0,0 +1,215 @@
+// Extended attributes for files
+
+package vfs
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// Extended attribute names
+//
+// The hashes are XattrPrefix + the hash name, eg "user.rclone.md5".
+const (
+	XattrPrefix         = "user.rclone."
+	XattrTier           = XattrPrefix + "tier"
+	XattrPinned         = XattrPrefix + "pinned"
+	XattrMetadataPrefix = XattrPrefix + "meta."
+)
+
+// xattrHash returns the hash type for the extended attribute name
+// or hash.None if it isn't a hash
+func (f *File) xattrHash(name string) hash.Type {
+	hashName, found := strings.CutPrefix(name, XattrPrefix)
+	if !found {
+		return hash.None
+	}
+	var ht hash.Type
+	if ht.Set(hashName) != nil || !f.Fs().Hashes().Contains(ht) {
+		return hash.None
+	}
+	return ht
+}
+
+// isDirty returns true if the file has changes which haven't been
+// uploaded yet, so the object's hashes don't match its contents
+func (f *File) isDirty() bool {
+	if f.writingInProgress() {
+		return true
+	}
+	cache := f.VFS().cache
+	return cache != nil && cache.DirtyItem(f.Path()) != nil
+}
+
+// getMetadata returns the metadata of o, which is cached for
+// --dir-cache-time so listing the extended attributes doesn't read it
+// from the backend every time.
+func (f *File) getMetadata(ctx context.Context, o fs.Object) (fs.Metadata, error) {
+	cacheTime := time.Duration(f.VFS().Opt.DirCacheTime)
+	f.mu.RLock()
+	if f.metadataObj == o && time.Since(f.metadataTime) < cacheTime {
+		metadata := f.metadata
+		f.mu.RUnlock()
+		return metadata, nil
+	}
+	f.mu.RUnlock()
+	metadata, err := fs.GetMetadata(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.metadata, f.metadataObj, f.metadataTime = metadata, o, time.Now()
+	f.mu.Unlock()
+	return metadata, nil
+}
+
+// clearMetadata removes the cached metadata
+func (f *File) clearMetadata() {
+	f.mu.Lock()
+	f.metadata, f.metadataObj = nil, nil
+	f.mu.Unlock()
+}
+
+// ListXattr returns the names of the extended attributes of the
+// file.
+//
+// These are the hashes the backend supports, the storage tier, the
+// metadata of the object and whether the file is pinned in the
+// cache. Values are only read from the backend when asked for with
+// GetXattr except for the metadata which is read here and cached for
+// --dir-cache-time.
+//
+// A file being written has no extended attributes until it has been
+// uploaded and the hashes aren't listed while it has changes which
+// haven't been uploaded.
+func (f *File) ListXattr(ctx context.Context) (names []string, err error) {
+	o := f.getObject()
+	if o == nil {
+		return nil, nil
+	}
+	if !f.isDirty() {
+		for _, ht := range f.Fs().Hashes().Array() {
+			names = append(names, XattrPrefix+ht.String())
+		}
+	}
+	if do, ok := o.(fs.GetTierer); ok && do.GetTier() != "" {
+		names = append(names, XattrTier)
+	}
+	if f.VFS().Opt.CacheMode >= vfscommon.CacheModeFull {
+		names = append(names, XattrPinned)
+	}
+	metadata, err := f.getMetadata(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		names = append(names, XattrMetadataPrefix+k)
+	}
+	return names, nil
+}
+
+// GetXattr returns the value of the extended attribute name of the
+// file or ENOATTR if it doesn't exist.
+//
+// Hashes are returned as lower case hex strings. They don't exist
+// while the file has changes which haven't been uploaded.
+func (f *File) GetXattr(ctx context.Context, name string) (value []byte, err error) {
+	o := f.getObject()
+	if o == nil {
+		return nil, ENOATTR
+	}
+	if ht := f.xattrHash(name); ht != hash.None {
+		if f.isDirty() {
+			return nil, ENOATTR
+		}
+		sum, err := o.Hash(ctx, ht)
+		if err != nil {
+			return nil, err
+		}
+		if sum == "" {
+			return nil, ENOATTR
+		}
+		return []byte(sum), nil
+	}
+	if name == XattrTier {
+		if do, ok := o.(fs.GetTierer); ok && do.GetTier() != "" {
+			return []byte(do.GetTier()), nil
+		}
+		return nil, ENOATTR
+	}
+	if name == XattrPinned && f.VFS().Opt.CacheMode >= vfscommon.CacheModeFull {
+		return []byte(strconv.FormatBool(f.isPinned())), nil
+	}
+	if key, found := strings.CutPrefix(name, XattrMetadataPrefix); found {
+		metadata, err := f.getMetadata(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		if value, found := metadata[key]; found {
+			return []byte(value), nil
+		}
+	}
+	return nil, ENOATTR
+}
+
+// SetXattr sets the extended attribute name of the file to value.
+//
+// Only the storage tier and the metadata can be set and only if the
+// backend supports it, otherwise EPERM is returned. Setting metadata
+// updates just the key given on the object.
+//
+// With --vfs-cache-mode full XattrPinned can be set to "true" or
+// "false" to pin or unpin the file in the cache. Pinning downloads
+// the file before returning.
+func (f *File) SetXattr(ctx context.Context, name string, value []byte) (err error) {
+	if f.VFS().Opt.ReadOnly {
+		return EROFS
+	}
+	o := f.getObject()
+	if o == nil {
+		return EPERM
+	}
+	if name == XattrTier {
+		do, ok := o.(fs.SetTierer)
+		if !ok {
+			return EPERM
+		}
+		return do.SetTier(string(value))
+	}
+	if name == XattrPinned {
+		pinned, err := strconv.ParseBool(string(value))
+		if err != nil {
+			return EINVAL
+		}
+		err = f.setPinned(pinned)
+		if err == errPinNeedsCache {
+			return EPERM
+		}
+		return err
+	}
+	if key, found := strings.CutPrefix(name, XattrMetadataPrefix); found && key != "" {
+		do, ok := o.(fs.SetMetadataer)
+		if !ok {
+			return EPERM
+		}
+		f.clearMetadata()
+		err = do.SetMetadata(ctx, fs.Metadata{key: string(value)})
+		if err == fs.ErrorNotImplemented {
+			return EPERM
+		}
+		return err
+	}
+	return EPERM
+}
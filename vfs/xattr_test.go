@@ -0,0 +1,82 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileXattr(t *testing.T) {
+	ctx := context.Background()
+	r, _, file, _ := fileCreate(t, vfscommon.CacheModeOff)
+
+	names, err := file.ListXattr(ctx)
+	require.NoError(t, err)
+	for _, ht := range r.Fremote.Hashes().Array() {
+		assert.Contains(t, names, XattrPrefix+ht.String())
+	}
+
+	// Hashes
+	if r.Fremote.Hashes().Contains(hash.MD5) {
+		value, err := file.GetXattr(ctx, XattrPrefix+"md5")
+		require.NoError(t, err)
+		assert.Equal(t, "0ef726ce9b1a7692357ff70dd321d595", string(value))
+	}
+	_, err = file.GetXattr(ctx, XattrPrefix+"potato")
+	assert.Equal(t, ENOATTR, err)
+	_, err = file.GetXattr(ctx, "user.other")
+	assert.Equal(t, ENOATTR, err)
+	assert.Equal(t, EPERM, file.SetXattr(ctx, XattrPrefix+"md5", []byte("x")))
+
+	// Metadata
+	if !r.Fremote.Features().ReadMetadata {
+		return
+	}
+	metadata, err := fs.GetMetadata(ctx, file.getObject())
+	require.NoError(t, err)
+	for k, v := range metadata {
+		assert.Contains(t, names, XattrMetadataPrefix+k)
+		value, err := file.GetXattr(ctx, XattrMetadataPrefix+k)
+		require.NoError(t, err)
+		assert.Equal(t, v, string(value))
+	}
+	_, err = file.GetXattr(ctx, XattrMetadataPrefix+"potato")
+	assert.Equal(t, ENOATTR, err)
+
+	if _, ok := file.getObject().(fs.SetMetadataer); !ok || !r.Fremote.Features().WriteMetadata {
+		return
+	}
+	const mtime = "2011-12-25T12:59:59.123456789Z"
+	require.NoError(t, file.SetXattr(ctx, XattrMetadataPrefix+"mtime", []byte(mtime)))
+	value, err := file.GetXattr(ctx, XattrMetadataPrefix+"mtime")
+	require.NoError(t, err)
+	assert.Equal(t, mtime, string(value))
+}
+
+func TestFileXattrDirty(t *testing.T) {
+	ctx := context.Background()
+	r, vfs, file, _ := fileCreate(t, vfscommon.CacheModeWrites)
+	if !r.Fremote.Hashes().Contains(hash.MD5) {
+		t.Skip("no MD5 hash")
+	}
+	_, err := file.GetXattr(ctx, XattrPrefix+"md5")
+	require.NoError(t, err)
+
+	// While the file is being written the hashes don't exist
+	fd, err := vfs.OpenFile("dir/file1", os.O_WRONLY|os.O_APPEND, 0777)
+	require.NoError(t, err)
+	_, err = fd.Write([]byte("more"))
+	require.NoError(t, err)
+	_, err = file.GetXattr(ctx, XattrPrefix+"md5")
+	assert.Equal(t, ENOATTR, err)
+	names, err := file.ListXattr(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, names, XattrPrefix+"md5")
+	require.NoError(t, fd.Close())
+}